@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/couchbase/gocbcorex/contrib/cbconfig"
+	"github.com/couchbase/gocbcorex/memdx"
 	"go.uber.org/zap"
 )
 
@@ -20,6 +21,11 @@ type agentState struct {
 	tlsConfig          *tls.Config
 	authenticator      Authenticator
 	numPoolConnections uint
+	poolScaling        *KvClientPoolScalingConfig
+
+	poolReconnectBackoff BackoffCalculator
+	poolCircuitBreaker   *KvClientPoolCircuitBreakerConfig
+	kvHeartbeat          *memdx.HeartbeatOptions
 
 	lastClients  map[string]*KvClientConfig
 	latestConfig *ParsedConfig
@@ -36,14 +42,25 @@ type Agent struct {
 	connMgr     KvClientManager
 	collections CollectionResolver
 	retries     RetryManager
+	timeouts    TimeoutConfig
 	vbRouter    VbucketRouter
+	orphans     *OrphanReporter
+	thresholds  *ThresholdLogger
+	meter       Meter
 
 	httpCfgWatcher *ConfigWatcherHttp
 	memdCfgWatcher *ConfigWatcherMemd
 
 	crud  *CrudComponent
+	stats *StatsComponent
 	query *QueryComponent
 	mgmt  *MgmtComponent
+
+	dcpConnMgrs []*agentDcpConnMgr
+
+	httpTransports         map[ServiceType]*http.Transport
+	httpTransportTlsConfig *tls.Config
+	httpAuthenticator      AtomicPointer[Authenticator]
 }
 
 func CreateAgent(ctx context.Context, opts AgentOptions) (*Agent, error) {
@@ -102,30 +119,12 @@ func CreateAgent(ctx context.Context, opts AgentOptions) (*Agent, error) {
 	logger := loggerOrNop(opts.Logger)
 	httpUserAgent := "gocbcorex/0.0.1-dev"
 
-	httpDialer := &net.Dialer{
-		// Timeout:   connectTimeout,
-		KeepAlive: 30 * time.Second,
-	}
-
-	httpTransport := &http.Transport{
-		ForceAttemptHTTP2: true,
-
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return httpDialer.DialContext(ctx, network, addr)
-		},
-		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			tcpConn, err := httpDialer.DialContext(ctx, network, addr)
-			if err != nil {
-				return nil, err
-			}
-
-			tlsConn := tls.Client(tcpConn, opts.TLSConfig)
-			return tlsConn, nil
-		},
-		// MaxIdleConns:        maxIdleConns,
-		// MaxIdleConnsPerHost: maxIdleConnsPerHost,
-		// IdleConnTimeout:     idleTimeout,
-	}
+	httpTransport := newHttpTransport(opts.TLSConfig, func(hostPort string) (*tls.Certificate, error) {
+		if opts.Authenticator == nil {
+			return nil, nil
+		}
+		return opts.Authenticator.GetClientCertificate(ServiceTypeMgmt, hostPort)
+	})
 
 	bootstrapper, err := NewConfigBootstrapHttp(ConfigBoostrapHttpOptions{
 		Logger:           logger.Named("http-bootstrap"),
@@ -148,6 +147,11 @@ func CreateAgent(ctx context.Context, opts AgentOptions) (*Agent, error) {
 		zap.Any("bootstrapConfig", bootstrapConfig),
 		zap.String("networkType", networkType))
 
+	numPoolConnections := opts.KvPoolConfig.NumConnections
+	if numPoolConnections == 0 {
+		numPoolConnections = 1
+	}
+
 	agent := &Agent{
 		logger:      logger,
 		networkType: networkType,
@@ -156,38 +160,68 @@ func CreateAgent(ctx context.Context, opts AgentOptions) (*Agent, error) {
 			bucket:             opts.BucketName,
 			tlsConfig:          opts.TLSConfig,
 			authenticator:      opts.Authenticator,
-			numPoolConnections: 1,
+			numPoolConnections: numPoolConnections,
+			poolScaling:        opts.KvPoolConfig.Scaling,
 			latestConfig:       bootstrapConfig,
+
+			poolReconnectBackoff: opts.KvPoolConfig.ReconnectBackoff,
+			poolCircuitBreaker:   opts.KvPoolConfig.CircuitBreaker,
+			kvHeartbeat:          opts.KvPoolConfig.Heartbeat,
 		},
 
-		retries: NewRetryManagerFastFail(),
+		retries:  opts.RetryManager,
+		timeouts: opts.TimeoutConfig.withDefaults(),
+		meter:    opts.Meter,
+	}
+
+	if agent.retries == nil {
+		agent.retries = NewRetryManagerErrorMap(NewRetryManagerBestEffort())
+	}
+
+	if !opts.OrphanReporterConfig.Disabled {
+		agent.orphans = NewOrphanReporter(&OrphanReporterOptions{
+			Logger:         logger.Named("orphan-reporter"),
+			ReportInterval: opts.OrphanReporterConfig.ReportInterval,
+			SampleSize:     opts.OrphanReporterConfig.SampleSize,
+		})
+		agent.orphans.Start()
+	}
+
+	if !opts.ThresholdLoggerConfig.Disabled {
+		agent.thresholds = NewThresholdLogger(&ThresholdLoggerOptions{
+			Logger:              logger.Named("threshold-logger"),
+			ReportInterval:      opts.ThresholdLoggerConfig.ReportInterval,
+			SampleSize:          opts.ThresholdLoggerConfig.SampleSize,
+			KvThreshold:         opts.ThresholdLoggerConfig.KvThreshold,
+			QueryThreshold:      opts.ThresholdLoggerConfig.QueryThreshold,
+			ManagementThreshold: opts.ThresholdLoggerConfig.ManagementThreshold,
+		})
+		agent.thresholds.Start()
 	}
 
 	agentComponentConfigs := agent.genAgentComponentConfigsLocked()
 
 	connMgr, err := NewKvClientManager(&KvClientManagerConfig{
-		NumPoolConnections: agent.state.numPoolConnections,
-		Clients:            agentComponentConfigs.KvClientManagerClients,
+		NumPoolConnections:   agent.state.numPoolConnections,
+		PoolScaling:          agent.state.poolScaling,
+		Clients:              agentComponentConfigs.KvClientManagerClients,
+		PoolReconnectBackoff: agent.state.poolReconnectBackoff,
+		PoolCircuitBreaker:   agent.state.poolCircuitBreaker,
 	}, &KvClientManagerOptions{
-		Logger: agent.logger,
+		Logger:                  agent.logger,
+		ClusterMapChangeHandler: agentClusterMapHandler{agent},
+		OrphanedResponseHandler: agent.orphanedResponseHandler(),
+		Meter:                   agent.meter,
 	})
 	if err != nil {
 		return nil, err
 	}
 	agent.connMgr = connMgr
-
-	coreCollections, err := NewCollectionResolverMemd(&CollectionResolverMemdOptions{
-		Logger:  agent.logger,
-		ConnMgr: agent.connMgr,
-	})
-	if err != nil {
-		return nil, err
+	if opts.WrapKvClientManager != nil {
+		agent.connMgr = opts.WrapKvClientManager(connMgr)
 	}
-	collections, err := NewCollectionResolverCached(&CollectionResolverCachedOptions{
-		Logger:         agent.logger,
-		Resolver:       coreCollections,
-		ResolveTimeout: 10 * time.Second,
-	})
+
+	collections, err := newAgentCollectionResolver(agent.logger, agent.connMgr, opts.CollectionsConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -236,6 +270,9 @@ func CreateAgent(ctx context.Context, opts AgentOptions) (*Agent, error) {
 		connManager: agent.connMgr,
 		nmvHandler:  &agentNmvHandler{agent},
 		vbs:         agent.vbRouter,
+		tracer:      opts.Tracer,
+		meter:       opts.Meter,
+		thresholds:  agent.thresholds,
 		compression: &CompressionManagerDefault{
 			disableCompression:   !useCompression,
 			compressionMinSize:   compressionMinSize,
@@ -243,20 +280,31 @@ func CreateAgent(ctx context.Context, opts AgentOptions) (*Agent, error) {
 			disableDecompression: disableDecompression,
 		},
 	}
+	agent.stats = &StatsComponent{
+		logger:      agent.logger,
+		retries:     agent.retries,
+		connManager: agent.connMgr,
+	}
 	agent.query = NewQueryComponent(
 		agent.retries,
 		&agentComponentConfigs.QueryComponentConfig,
 		&QueryComponentOptions{
-			Logger:    logger,
-			UserAgent: httpUserAgent,
+			Logger:          logger,
+			Tracer:          opts.Tracer,
+			Meter:           opts.Meter,
+			ThresholdLogger: agent.thresholds,
+			UserAgent:       httpUserAgent,
 		},
 	)
 	agent.mgmt = NewMgmtComponent(
 		agent.retries,
 		&agentComponentConfigs.MgmtComponentConfig,
 		&MgmtComponentOptions{
-			Logger:    logger,
-			UserAgent: httpUserAgent,
+			Logger:          logger,
+			Tracer:          opts.Tracer,
+			Meter:           opts.Meter,
+			ThresholdLogger: agent.thresholds,
+			UserAgent:       httpUserAgent,
 		},
 	)
 
@@ -317,37 +365,16 @@ func (agent *Agent) genAgentComponentConfigsLocked() *agentComponentConfigs {
 			TlsConfig:      agent.state.tlsConfig,
 			SelectedBucket: agent.state.bucket,
 			Authenticator:  agent.state.authenticator,
+			Heartbeat:      agent.state.kvHeartbeat,
 		}
 	}
 
-	httpDialer := &net.Dialer{
-		// Timeout:   connectTimeout,
-		KeepAlive: 30 * time.Second,
-	}
-
-	httpTransport := &http.Transport{
-		ForceAttemptHTTP2: true,
-
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return httpDialer.DialContext(ctx, network, addr)
-		},
-		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			tcpConn, err := httpDialer.DialContext(ctx, network, addr)
-			if err != nil {
-				return nil, err
-			}
-
-			tlsConn := tls.Client(tcpConn, agent.state.tlsConfig)
-			return tlsConn, nil
-		},
-		// MaxIdleConns:        maxIdleConns,
-		// MaxIdleConnsPerHost: maxIdleConnsPerHost,
-		// IdleConnTimeout:     idleTimeout,
-	}
+	mgmtTransport := agent.httpTransportLocked(ServiceTypeMgmt)
+	queryTransport := agent.httpTransportLocked(ServiceTypeQuery)
 
 	return &agentComponentConfigs{
 		ConfigWatcherHttpConfig: ConfigWatcherHttpConfig{
-			HttpRoundTripper: httpTransport,
+			HttpRoundTripper: mgmtTransport,
 			Endpoints:        mgmtEndpoints,
 			UserAgent:        httpUserAgent,
 			Authenticator:    agent.state.authenticator,
@@ -362,26 +389,126 @@ func (agent *Agent) genAgentComponentConfigsLocked() *agentComponentConfigs {
 			ServerList: kvDataNodeIds,
 		},
 		QueryComponentConfig: QueryComponentConfig{
-			HttpRoundTripper: httpTransport,
+			HttpRoundTripper: queryTransport,
 			Endpoints:        queryEndpoints,
 			Authenticator:    agent.state.authenticator,
 		},
 		MgmtComponentConfig: MgmtComponentConfig{
-			HttpRoundTripper: httpTransport,
+			HttpRoundTripper: mgmtTransport,
 			Endpoints:        mgmtEndpoints,
 			Authenticator:    agent.state.authenticator,
 		},
 	}
 }
 
+// httpTransportLocked returns the transport used by the HTTP components of a
+// service.  The transports are only replaced when the TLS config changes, so
+// that changing credentials does not discard the pooled HTTP connections.
+func (agent *Agent) httpTransportLocked(service ServiceType) *http.Transport {
+	authenticator := agent.state.authenticator
+	agent.httpAuthenticator.Store(&authenticator)
+
+	tlsConfig := agent.state.tlsConfig
+	if agent.httpTransports != nil && agent.httpTransportTlsConfig != tlsConfig {
+		for _, httpTransport := range agent.httpTransports {
+			// requests already using the old transport are allowed to complete.
+			httpTransport.CloseIdleConnections()
+		}
+		agent.httpTransports = nil
+	}
+	agent.httpTransportTlsConfig = tlsConfig
+
+	if httpTransport := agent.httpTransports[service]; httpTransport != nil {
+		return httpTransport
+	}
+
+	httpTransport := newHttpTransport(tlsConfig, func(hostPort string) (*tls.Certificate, error) {
+		// the certificate is fetched for each new connection, so that new
+		// connections pick up a rotated certificate.
+		authenticator := *agent.httpAuthenticator.Load()
+		if authenticator == nil {
+			return nil, nil
+		}
+		return authenticator.GetClientCertificate(service, hostPort)
+	})
+
+	if agent.httpTransports == nil {
+		agent.httpTransports = make(map[ServiceType]*http.Transport)
+	}
+	agent.httpTransports[service] = httpTransport
+	return httpTransport
+}
+
+func newHttpTransport(
+	tlsConfig *tls.Config,
+	getClientCertificate func(hostPort string) (*tls.Certificate, error),
+) *http.Transport {
+	httpDialer := &net.Dialer{
+		// Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return &http.Transport{
+		ForceAttemptHTTP2: true,
+
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return httpDialer.DialContext(ctx, network, addr)
+		},
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			clientCert, err := getClientCertificate(addr)
+			if err != nil {
+				return nil, err
+			}
+
+			tcpConn, err := httpDialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+
+			tlsConn := tls.Client(tcpConn, clientCertificateTlsConfig(tlsConfig, clientCert))
+			return tlsConn, nil
+		},
+		// MaxIdleConns:        maxIdleConns,
+		// MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		// IdleConnTimeout:     idleTimeout,
+	}
+}
+
+func newAgentCollectionResolver(
+	logger *zap.Logger,
+	connMgr KvClientManager,
+	config CollectionsConfig,
+) (CollectionResolver, error) {
+	if !config.DisableManifestResolver {
+		return NewCollectionResolverManifest(&CollectionResolverManifestOptions{
+			Logger:       logger,
+			ConnMgr:      connMgr,
+			FetchTimeout: 10 * time.Second,
+		})
+	}
+
+	coreCollections, err := NewCollectionResolverMemd(&CollectionResolverMemdOptions{
+		Logger:  logger,
+		ConnMgr: connMgr,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCollectionResolverCached(&CollectionResolverCachedOptions{
+		Logger:         logger,
+		Resolver:       coreCollections,
+		ResolveTimeout: 10 * time.Second,
+	})
+}
+
 func (agent *Agent) Reconfigure(opts *AgentReconfigureOptions) error {
 	agent.lock.Lock()
 	defer agent.lock.Unlock()
 
-	if agent.state.bucket != "" {
-		if opts.BucketName != agent.state.bucket {
-			return errors.New("cannot change an already-specified bucket name")
-		}
+	err := agent.checkReconfigureLocked(opts)
+	if err != nil {
+		return err
 	}
 
 	agent.state.tlsConfig = opts.TLSConfig
@@ -392,7 +519,40 @@ func (agent *Agent) Reconfigure(opts *AgentReconfigureOptions) error {
 	return nil
 }
 
+// checkReconfigure returns the error Reconfigure would fail with, without
+// applying the options.
+func (agent *Agent) checkReconfigure(opts *AgentReconfigureOptions) error {
+	agent.lock.Lock()
+	defer agent.lock.Unlock()
+
+	return agent.checkReconfigureLocked(opts)
+}
+
+func (agent *Agent) checkReconfigureLocked(opts *AgentReconfigureOptions) error {
+	if agent.state.bucket != "" {
+		if opts.BucketName != agent.state.bucket {
+			return errors.New("cannot change an already-specified bucket name")
+		}
+	}
+
+	// a client certificate can only be presented during a TLS handshake, so
+	// without TLS every new connection would fail to authenticate.
+	if _, ok := opts.Authenticator.(*CertificateAuthenticator); ok && opts.TLSConfig == nil {
+		return ErrClientCertificateRequiresTls
+	}
+
+	return nil
+}
+
 func (agent *Agent) Close() error {
+	if agent.thresholds != nil {
+		agent.thresholds.Close()
+	}
+
+	if agent.orphans != nil {
+		agent.orphans.Close()
+	}
+
 	return nil
 }
 
@@ -456,9 +616,13 @@ func (agent *Agent) updateStateLocked() {
 	}
 
 	agent.connMgr.Reconfigure(&KvClientManagerConfig{
-		NumPoolConnections: agent.state.numPoolConnections,
-		Clients:            oldClients,
+		NumPoolConnections:   agent.state.numPoolConnections,
+		PoolScaling:          agent.state.poolScaling,
+		Clients:              oldClients,
+		PoolReconnectBackoff: agent.state.poolReconnectBackoff,
+		PoolCircuitBreaker:   agent.state.poolCircuitBreaker,
 	}, func(error) {})
+	agent.reconfigureDcpConnMgrsLocked(oldClients)
 
 	agent.vbRouter.UpdateRoutingInfo(agentComponentConfigs.VbucketRoutingInfo)
 
@@ -467,9 +631,13 @@ func (agent *Agent) updateStateLocked() {
 	}
 
 	agent.connMgr.Reconfigure(&KvClientManagerConfig{
-		NumPoolConnections: agent.state.numPoolConnections,
-		Clients:            agentComponentConfigs.KvClientManagerClients,
+		NumPoolConnections:   agent.state.numPoolConnections,
+		PoolScaling:          agent.state.poolScaling,
+		Clients:              agentComponentConfigs.KvClientManagerClients,
+		PoolReconnectBackoff: agent.state.poolReconnectBackoff,
+		PoolCircuitBreaker:   agent.state.poolCircuitBreaker,
 	}, func(error) {})
+	agent.reconfigureDcpConnMgrsLocked(agentComponentConfigs.KvClientManagerClients)
 
 	agent.query.Reconfigure(&agentComponentConfigs.QueryComponentConfig)
 	agent.mgmt.Reconfigure(&agentComponentConfigs.MgmtComponentConfig)
@@ -509,3 +677,23 @@ type agentNmvHandler struct {
 func (h *agentNmvHandler) HandleNotMyVbucketConfig(config *cbconfig.TerseConfigJson, sourceHostname string) {
 	h.agent.handleNotMyVbucketConfig(config, sourceHostname)
 }
+
+// orphanedResponseHandler returns the orphan reporter of the agent, or a nil
+// handler when orphan reporting is disabled.
+func (agent *Agent) orphanedResponseHandler() OrphanedResponseHandler {
+	if agent.orphans == nil {
+		return nil
+	}
+	return agent.orphans
+}
+
+// agentClusterMapHandler exists for the purpose of satisfying the ClusterMapChangeHandler
+// interface for Agent.  It is intentionally a value type so that handlers for the same
+// agent compare as equal when reconfiguring clients.
+type agentClusterMapHandler struct {
+	agent *Agent
+}
+
+func (h agentClusterMapHandler) HandleClusterMapChange(config *cbconfig.TerseConfigJson, sourceHostname string) {
+	h.agent.applyTerseConfigJson(config, sourceHostname)
+}
@@ -0,0 +1,121 @@
+package gocbcorex
+
+import (
+	"errors"
+	"time"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+type AgentDcpStreamerOptions struct {
+	ConnectionName string
+	Flags          memdx.DcpConnectionFlags
+	BufferSize     uint32
+	NoopInterval   time.Duration
+	Handlers       DcpEventHandlers
+}
+
+type agentDcpConnMgr struct {
+	dcpConfig *KvClientDcpConfig
+	connMgr   KvClientManager
+}
+
+func dcpKvClientConfigs(clients map[string]*KvClientConfig, dcpConfig *KvClientDcpConfig) map[string]*KvClientConfig {
+	dcpClients := make(map[string]*KvClientConfig, len(clients))
+	for clientName, client := range clients {
+		dcpClient := *client
+		dcpClient.Dcp = dcpConfig
+		dcpClients[clientName] = &dcpClient
+	}
+	return dcpClients
+}
+
+// NewDcpStreamer creates a DcpStreamer which streams from the bucket of this agent
+// using its own set of DCP connections, which follow the topology of the cluster.
+func (agent *Agent) NewDcpStreamer(opts *AgentDcpStreamerOptions) (*DcpStreamer, error) {
+	if opts == nil {
+		return nil, errors.New("must pass options")
+	}
+	if opts.ConnectionName == "" {
+		return nil, errors.New("must specify a connection name")
+	}
+
+	agent.lock.Lock()
+	defer agent.lock.Unlock()
+
+	if agent.state.bucket == "" || agent.state.latestConfig.VbucketMap == nil {
+		return nil, errors.New("dcp requires a bucket with a vbucket map")
+	}
+
+	dcpConfig := &KvClientDcpConfig{
+		ConnectionName: opts.ConnectionName,
+		Flags:          opts.Flags,
+		BufferSize:     opts.BufferSize,
+		NoopInterval:   opts.NoopInterval,
+	}
+
+	agentComponentConfigs := agent.genAgentComponentConfigsLocked()
+
+	connMgr, err := NewKvClientManager(&KvClientManagerConfig{
+		NumPoolConnections: 1,
+		Clients:            dcpKvClientConfigs(agentComponentConfigs.KvClientManagerClients, dcpConfig),
+	}, &KvClientManagerOptions{
+		Logger:                  agent.logger.Named("dcp"),
+		OrphanedResponseHandler: agent.orphanedResponseHandler(),
+		Meter:                   agent.meter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dcpMgr := &agentDcpConnMgr{
+		dcpConfig: dcpConfig,
+		connMgr:   connMgr,
+	}
+
+	streamer, err := NewDcpStreamer(&DcpStreamerOptions{
+		Logger:      agent.logger.Named("dcp-streamer"),
+		ConnMgr:     connMgr,
+		VbRouter:    agent.vbRouter,
+		NmvHandler:  &agentNmvHandler{agent},
+		NumVbuckets: agent.state.latestConfig.VbucketMap.NumVbuckets(),
+		Handlers:    opts.Handlers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	streamer.closeHook = func() {
+		agent.removeDcpConnMgr(dcpMgr)
+	}
+
+	agent.dcpConnMgrs = append(agent.dcpConnMgrs, dcpMgr)
+
+	return streamer, nil
+}
+
+func (agent *Agent) removeDcpConnMgr(dcpMgr *agentDcpConnMgr) {
+	agent.lock.Lock()
+	defer agent.lock.Unlock()
+
+	for mgrIdx, mgr := range agent.dcpConnMgrs {
+		if mgr == dcpMgr {
+			agent.dcpConnMgrs = append(agent.dcpConnMgrs[:mgrIdx], agent.dcpConnMgrs[mgrIdx+1:]...)
+			break
+		}
+	}
+
+	dcpMgr.connMgr.Reconfigure(&KvClientManagerConfig{
+		NumPoolConnections: 0,
+		Clients:            nil,
+	}, func(error) {})
+}
+
+func (agent *Agent) reconfigureDcpConnMgrsLocked(clients map[string]*KvClientConfig) {
+	for _, dcpMgr := range agent.dcpConnMgrs {
+		dcpMgr.connMgr.Reconfigure(&KvClientManagerConfig{
+			NumPoolConnections: 1,
+			Clients:            dcpKvClientConfigs(clients, dcpMgr.dcpConfig),
+		}, func(error) {})
+	}
+}
@@ -2,138 +2,289 @@ package gocbcorex
 
 import (
 	"context"
+	"time"
 
 	"github.com/couchbase/gocbcorex/cbmgmtx"
 )
 
 func (agent *Agent) Upsert(ctx context.Context, opts *UpsertOptions) (*UpsertResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.kvTimeout(opts.DurabilityLevel > 0 || opts.PersistTo > 0 || opts.ReplicateTo > 0))
+	defer cancel()
+
 	return agent.crud.Upsert(ctx, opts)
 }
 
 func (agent *Agent) Get(ctx context.Context, opts *GetOptions) (*GetResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
 	return agent.crud.Get(ctx, opts)
 }
 
 func (agent *Agent) GetReplica(ctx context.Context, opts *GetReplicaOptions) (*GetReplicaResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
 	return agent.crud.GetReplica(ctx, opts)
 }
 
 func (agent *Agent) Delete(ctx context.Context, opts *DeleteOptions) (*DeleteResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.kvTimeout(opts.DurabilityLevel > 0 || opts.PersistTo > 0 || opts.ReplicateTo > 0))
+	defer cancel()
+
 	return agent.crud.Delete(ctx, opts)
 }
 
+func (agent *Agent) WaitForLegacyDurability(ctx context.Context, opts *LegacyDurabilityOptions) error {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvDurableTimeout)
+	defer cancel()
+
+	return agent.crud.WaitForLegacyDurability(ctx, opts)
+}
+
+func (agent *Agent) Stats(ctx context.Context, opts *StatsOptions) (*StatsResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
+	return agent.stats.Stats(ctx, opts)
+}
+
+func (agent *Agent) GetAllVbSeqnos(ctx context.Context, opts *GetAllVbSeqnosOptions) (*GetAllVbSeqnosResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
+	return agent.crud.GetAllVbSeqnos(ctx, opts)
+}
+
 func (agent *Agent) GetAndLock(ctx context.Context, opts *GetAndLockOptions) (*GetAndLockResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
 	return agent.crud.GetAndLock(ctx, opts)
 }
 
 func (agent *Agent) GetAndTouch(ctx context.Context, opts *GetAndTouchOptions) (*GetAndTouchResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
 	return agent.crud.GetAndTouch(ctx, opts)
 }
 
 func (agent *Agent) GetRandom(ctx context.Context, opts *GetRandomOptions) (*GetRandomResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
 	return agent.crud.GetRandom(ctx, opts)
 }
 
 func (agent *Agent) Unlock(ctx context.Context, opts *UnlockOptions) (*UnlockResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
 	return agent.crud.Unlock(ctx, opts)
 }
 
 func (agent *Agent) Touch(ctx context.Context, opts *TouchOptions) (*TouchResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
 	return agent.crud.Touch(ctx, opts)
 }
 
 func (agent *Agent) Add(ctx context.Context, opts *AddOptions) (*AddResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.kvTimeout(opts.DurabilityLevel > 0))
+	defer cancel()
+
 	return agent.crud.Add(ctx, opts)
 }
 
 func (agent *Agent) Replace(ctx context.Context, opts *ReplaceOptions) (*ReplaceResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.kvTimeout(opts.DurabilityLevel > 0))
+	defer cancel()
+
 	return agent.crud.Replace(ctx, opts)
 }
 
 func (agent *Agent) Append(ctx context.Context, opts *AppendOptions) (*AppendResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.kvTimeout(opts.DurabilityLevel > 0))
+	defer cancel()
+
 	return agent.crud.Append(ctx, opts)
 }
 
 func (agent *Agent) Prepend(ctx context.Context, opts *PrependOptions) (*PrependResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.kvTimeout(opts.DurabilityLevel > 0))
+	defer cancel()
+
 	return agent.crud.Prepend(ctx, opts)
 }
 
 func (agent *Agent) Increment(ctx context.Context, opts *IncrementOptions) (*IncrementResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.kvTimeout(opts.DurabilityLevel > 0))
+	defer cancel()
+
 	return agent.crud.Increment(ctx, opts)
 }
 
 func (agent *Agent) Decrement(ctx context.Context, opts *DecrementOptions) (*DecrementResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.kvTimeout(opts.DurabilityLevel > 0))
+	defer cancel()
+
 	return agent.crud.Decrement(ctx, opts)
 }
 
 func (agent *Agent) GetMeta(ctx context.Context, opts *GetMetaOptions) (*GetMetaResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
 	return agent.crud.GetMeta(ctx, opts)
 }
 
 func (agent *Agent) SetMeta(ctx context.Context, opts *SetMetaOptions) (*SetMetaResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
 	return agent.crud.SetMeta(ctx, opts)
 }
 
 func (agent *Agent) DeleteMeta(ctx context.Context, opts *DeleteMetaOptions) (*DeleteMetaResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
 	return agent.crud.DeleteMeta(ctx, opts)
 }
 
 func (agent *Agent) LookupIn(ctx context.Context, opts *LookupInOptions) (*LookupInResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.KvTimeout)
+	defer cancel()
+
 	return agent.crud.LookupIn(ctx, opts)
 }
 
 func (agent *Agent) MutateIn(ctx context.Context, opts *MutateInOptions) (*MutateInResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.kvTimeout(opts.DurabilityLevel > 0))
+	defer cancel()
+
 	return agent.crud.MutateIn(ctx, opts)
 }
 
 func (agent *Agent) Query(ctx context.Context, opts *QueryOptions) (QueryResultStream, error) {
-	return agent.query.Query(ctx, opts)
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.QueryTimeout)
+
+	res, err := agent.query.Query(ctx, opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the rows are streamed using ctx after we return, so it is only
+	// released once the stream is finished with.
+	return newQueryResultStream(res, cancel), nil
 }
 
 func (agent *Agent) PreparedQuery(ctx context.Context, opts *QueryOptions) (QueryResultStream, error) {
-	return agent.query.PreparedQuery(ctx, opts)
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.QueryTimeout)
+
+	res, err := agent.query.PreparedQuery(ctx, opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the rows are streamed using ctx after we return, so it is only
+	// released once the stream is finished with.
+	return newQueryResultStream(res, cancel), nil
 }
 
 func (agent *Agent) GetCollectionManifest(ctx context.Context, opts *cbmgmtx.GetCollectionManifestOptions) (*cbmgmtx.CollectionManifestJson, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.ManagementTimeout)
+	defer cancel()
+
 	return agent.mgmt.GetCollectionManifest(ctx, opts)
 }
 
 func (agent *Agent) CreateScope(ctx context.Context, opts *cbmgmtx.CreateScopeOptions) error {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.ManagementTimeout)
+	defer cancel()
+
 	return agent.mgmt.CreateScope(ctx, opts)
 }
 
 func (agent *Agent) DeleteScope(ctx context.Context, opts *cbmgmtx.DeleteScopeOptions) error {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.ManagementTimeout)
+	defer cancel()
+
 	return agent.mgmt.DeleteScope(ctx, opts)
 }
 
 func (agent *Agent) CreateCollection(ctx context.Context, opts *cbmgmtx.CreateCollectionOptions) error {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.ManagementTimeout)
+	defer cancel()
+
 	return agent.mgmt.CreateCollection(ctx, opts)
 }
 
 func (agent *Agent) DeleteCollection(ctx context.Context, opts *cbmgmtx.DeleteCollectionOptions) error {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.ManagementTimeout)
+	defer cancel()
+
 	return agent.mgmt.DeleteCollection(ctx, opts)
 }
 
 func (agent *Agent) GetAllBuckets(ctx context.Context, opts *cbmgmtx.GetAllBucketsOptions) ([]*cbmgmtx.BucketDef, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.ManagementTimeout)
+	defer cancel()
+
 	return agent.mgmt.GetAllBuckets(ctx, opts)
 }
 
 func (agent *Agent) GetBucket(ctx context.Context, opts *cbmgmtx.GetBucketOptions) (*cbmgmtx.BucketDef, error) {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.ManagementTimeout)
+	defer cancel()
+
 	return agent.mgmt.GetBucket(ctx, opts)
 }
 
 func (agent *Agent) CreateBucket(ctx context.Context, opts *cbmgmtx.CreateBucketOptions) error {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.ManagementTimeout)
+	defer cancel()
+
 	return agent.mgmt.CreateBucket(ctx, opts)
 }
 
 func (agent *Agent) UpdateBucket(ctx context.Context, opts *cbmgmtx.UpdateBucketOptions) error {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.ManagementTimeout)
+	defer cancel()
+
 	return agent.mgmt.UpdateBucket(ctx, opts)
 }
 
 func (agent *Agent) FlushBucket(ctx context.Context, opts *cbmgmtx.FlushBucketOptions) error {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.ManagementTimeout)
+	defer cancel()
+
 	return agent.mgmt.FlushBucket(ctx, opts)
 }
 
 func (agent *Agent) DeleteBucket(ctx context.Context, opts *cbmgmtx.DeleteBucketOptions) error {
+	ctx, cancel := withDefaultTimeout(ctx, agent.timeouts.ManagementTimeout)
+	defer cancel()
+
 	return agent.mgmt.DeleteBucket(ctx, opts)
 }
+
+// withDefaultTimeout applies timeout to ctx, unless it already has a deadline.
+func withDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (agent *Agent) kvTimeout(durable bool) time.Duration {
+	if durable {
+		return agent.timeouts.KvDurableTimeout
+	}
+	return agent.timeouts.KvTimeout
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/memdx"
 )
 
 type AgentReconfigureOptions struct {
@@ -17,6 +19,20 @@ type AgentReconfigureOptions struct {
 type AgentOptions struct {
 	Logger *zap.Logger
 
+	// Tracer, if set, is used to create spans for each operation and to
+	// propagate the trace context to the server.
+	Tracer RequestTracer
+
+	// Meter, if set, records the latency, retries and errors of operations,
+	// along with the network traffic and connections of the agent.  An
+	// AggregatingMeter can be used to expose them to Prometheus.
+	Meter Meter
+
+	// RetryManager decides which failed operations are retried, defaults to
+	// retrying according to the server's error map and otherwise on a best
+	// effort basis.
+	RetryManager RetryManager
+
 	TLSConfig     *tls.Config
 	Authenticator Authenticator
 	BucketName    string
@@ -28,6 +44,21 @@ type AgentOptions struct {
 	ConfigPollerConfig ConfigPollerConfig
 
 	HTTPConfig HTTPConfig
+
+	KvPoolConfig KvPoolConfig
+
+	CollectionsConfig CollectionsConfig
+
+	// WrapKvClientManager, if set, wraps the manager of the KV connections
+	// used by operations, such as to inject faults using the faultinject
+	// package.
+	WrapKvClientManager func(KvClientManager) KvClientManager
+
+	OrphanReporterConfig OrphanReporterConfig
+
+	ThresholdLoggerConfig ThresholdLoggerConfig
+
+	TimeoutConfig TimeoutConfig
 }
 
 // SeedConfig specifies initial seed configuration options such as addresses.
@@ -53,6 +84,92 @@ type ConfigPollerConfig struct {
 	// CccpPollPeriod   time.Duration
 }
 
+// KvPoolConfig specifies options for controlling the pool of KV connections
+// to each node.
+type KvPoolConfig struct {
+	// NumConnections is the number of connections made to each node, defaults
+	// to 1.  It is ignored when Scaling is specified.
+	NumConnections uint
+
+	// Scaling, when specified, makes the pool for each node grow and shrink
+	// its number of connections with the load placed on it.
+	Scaling *KvClientPoolScalingConfig
+
+	// ReconnectBackoff calculates how long to wait between failed attempts
+	// to connect to a node, defaults to a jittered exponential backoff.
+	ReconnectBackoff BackoffCalculator
+
+	// CircuitBreaker, when specified, makes operations for a node which
+	// cannot be connected to fail immediately with a CircuitBreakerOpenError
+	// rather than waiting for their timeout.
+	CircuitBreaker *KvClientPoolCircuitBreakerConfig
+
+	// Heartbeat, when specified, makes each connection send NOOPs while it is
+	// idle, so that connections which have silently died are replaced.
+	Heartbeat *memdx.HeartbeatOptions
+}
+
+// CollectionsConfig specifies how collection names are resolved to their IDs.
+type CollectionsConfig struct {
+	// DisableManifestResolver resolves each collection individually with
+	// GetCollectionID, caching the results, rather than fetching the whole
+	// collections manifest of the bucket.
+	DisableManifestResolver bool
+}
+
+// TimeoutConfig specifies the timeouts applied to operations whose context does
+// not already have a deadline.
+type TimeoutConfig struct {
+	// KvTimeout defaults to 2.5 seconds.
+	KvTimeout time.Duration
+
+	// KvDurableTimeout is used for mutations with durability requirements,
+	// and defaults to 10 seconds.
+	KvDurableTimeout time.Duration
+
+	// QueryTimeout defaults to 75 seconds.
+	QueryTimeout time.Duration
+
+	// ManagementTimeout defaults to 75 seconds.
+	ManagementTimeout time.Duration
+}
+
+func (c TimeoutConfig) withDefaults() TimeoutConfig {
+	if c.KvTimeout <= 0 {
+		c.KvTimeout = 2500 * time.Millisecond
+	}
+	if c.KvDurableTimeout <= 0 {
+		c.KvDurableTimeout = 10 * time.Second
+	}
+	if c.QueryTimeout <= 0 {
+		c.QueryTimeout = 75 * time.Second
+	}
+	if c.ManagementTimeout <= 0 {
+		c.ManagementTimeout = 75 * time.Second
+	}
+	return c
+}
+
+// OrphanReporterConfig specifies options for the periodic report of responses
+// which arrived after their operation had already timed out.
+type OrphanReporterConfig struct {
+	Disabled       bool
+	ReportInterval time.Duration
+	SampleSize     int
+}
+
+// ThresholdLoggerConfig specifies options for the periodic report of the
+// slowest operations which exceeded the threshold for their service.
+type ThresholdLoggerConfig struct {
+	Disabled       bool
+	ReportInterval time.Duration
+	SampleSize     int
+
+	KvThreshold         time.Duration
+	QueryThreshold      time.Duration
+	ManagementThreshold time.Duration
+}
+
 // HTTPConfig specifies http related configuration options.
 type HTTPConfig struct {
 	// MaxIdleConns controls the maximum number of idle (keep-alive) connections across all hosts.
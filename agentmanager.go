@@ -59,8 +59,49 @@ func (m *AgentManager) makeAgentLocked(ctx context.Context, bucketName string) (
 	})
 }
 
+// Reconfigure applies new credentials to every agent.  Existing KV connections
+// are replaced one by one, each being closed once its operations complete.
+// The new credentials are checked against every agent before any is changed,
+// so that invalid credentials leave all of them using the previous ones.
 func (m *AgentManager) Reconfigure(opts AgentManagerReconfigureOptions) error {
-	return errors.New("not yet supported")
+	if opts.Authenticator == nil {
+		return errors.New("must specify an authenticator")
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	agentOpts := make(map[*Agent]*AgentReconfigureOptions, 1+len(m.bucketAgents))
+	agentOpts[m.clusterAgent] = &AgentReconfigureOptions{
+		TLSConfig:     opts.TLSConfig,
+		Authenticator: opts.Authenticator,
+	}
+	for bucketName, bucketAgent := range m.bucketAgents {
+		agentOpts[bucketAgent] = &AgentReconfigureOptions{
+			TLSConfig:     opts.TLSConfig,
+			Authenticator: opts.Authenticator,
+			BucketName:    bucketName,
+		}
+	}
+
+	for agent, reconfigureOpts := range agentOpts {
+		err := agent.checkReconfigure(reconfigureOpts)
+		if err != nil {
+			return err
+		}
+	}
+
+	for agent, reconfigureOpts := range agentOpts {
+		err := agent.Reconfigure(reconfigureOpts)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.opts.TLSConfig = opts.TLSConfig
+	m.opts.Authenticator = opts.Authenticator
+
+	return nil
 }
 
 func (m *AgentManager) GetClusterAgent() *Agent {
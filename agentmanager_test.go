@@ -0,0 +1,40 @@
+package gocbcorex
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentManagerReconfigureChecksEveryAgentFirst(t *testing.T) {
+	oldAuth := &PasswordAuthenticator{Username: "user", Password: "pass"}
+	clusterAgent := &Agent{}
+	clusterAgent.state.authenticator = oldAuth
+	bucketAgent := &Agent{}
+	bucketAgent.state.bucket = "default"
+	bucketAgent.state.authenticator = oldAuth
+
+	m := &AgentManager{
+		opts: AgentManagerOptions{
+			Authenticator: oldAuth,
+		},
+		clusterAgent: clusterAgent,
+		bucketAgents: map[string]*Agent{
+			"default": bucketAgent,
+		},
+	}
+
+	// a client certificate cannot be presented without TLS
+	err := m.Reconfigure(AgentManagerReconfigureOptions{
+		Authenticator: &CertificateAuthenticator{
+			ClientCertificate: &tls.Certificate{},
+		},
+	})
+	require.ErrorIs(t, err, ErrClientCertificateRequiresTls)
+
+	assert.Equal(t, Authenticator(oldAuth), m.opts.Authenticator)
+	assert.Equal(t, Authenticator(oldAuth), clusterAgent.state.authenticator)
+	assert.Equal(t, Authenticator(oldAuth), bucketAgent.state.authenticator)
+}
@@ -25,3 +25,36 @@ func (a *PasswordAuthenticator) GetCredentials(
 ) (string, string, error) {
 	return a.Username, a.Password, nil
 }
+
+// CertificateAuthenticator authenticates with a client certificate presented
+// during the TLS handshake, in place of a username and password.  It requires
+// TLS to be enabled.
+type CertificateAuthenticator struct {
+	ClientCertificate *tls.Certificate
+}
+
+func (a *CertificateAuthenticator) GetClientCertificate(
+	service ServiceType, hostPort string,
+) (*tls.Certificate, error) {
+	return a.ClientCertificate, nil
+}
+
+func (a *CertificateAuthenticator) GetCredentials(
+	service ServiceType, hostPort string,
+) (string, string, error) {
+	return "", "", nil
+}
+
+// clientCertificateTlsConfig returns a copy of tlsConfig which presents cert to
+// the server, or tlsConfig itself if there is no certificate to present.
+func clientCertificateTlsConfig(tlsConfig *tls.Config, cert *tls.Certificate) *tls.Config {
+	if tlsConfig == nil || cert == nil {
+		return tlsConfig
+	}
+
+	certTlsConfig := tlsConfig.Clone()
+	certTlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return cert, nil
+	}
+	return certTlsConfig
+}
@@ -2,6 +2,7 @@ package gocbcorex
 
 import (
 	"math"
+	"math/rand"
 	"time"
 )
 
@@ -36,3 +37,18 @@ func ExponentialBackoff(min, max time.Duration, backoffFactor float64) BackoffCa
 		return time.Duration(backoff)
 	}
 }
+
+// JitteredBackoff randomizes the durations of another BackoffCalculator to
+// between half and all of their original value, so that many clients backing
+// off at the same time do not all retry in lockstep.
+func JitteredBackoff(calc BackoffCalculator) BackoffCalculator {
+	return func(retryAttempts uint32) time.Duration {
+		backoff := calc(retryAttempts)
+		if backoff <= 1 {
+			return backoff
+		}
+
+		halfBackoff := backoff / 2
+		return halfBackoff + time.Duration(rand.Int63n(int64(backoff-halfBackoff)))
+	}
+}
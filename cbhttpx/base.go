@@ -11,6 +11,7 @@ type RequestBuilder struct {
 	Endpoint      string
 	BasicAuthUser string
 	BasicAuthPass string
+	TraceParent   string
 }
 
 func (h RequestBuilder) NewRequest(
@@ -36,6 +37,10 @@ func (h RequestBuilder) NewRequest(
 		req.Header.Set("cb-on-behalf-of", onBehalfOf)
 	}
 
+	if h.TraceParent != "" {
+		req.Header.Set("traceparent", h.TraceParent)
+	}
+
 	if h.BasicAuthUser != "" || h.BasicAuthPass != "" {
 		req.SetBasicAuth(h.BasicAuthUser, h.BasicAuthPass)
 	}
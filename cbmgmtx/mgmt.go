@@ -21,6 +21,9 @@ type Management struct {
 	Endpoint  string
 	Username  string
 	Password  string
+
+	// TraceParent, if set, is sent as the W3C traceparent header of each request.
+	TraceParent string
 }
 
 func (h Management) NewRequest(
@@ -33,6 +36,7 @@ func (h Management) NewRequest(
 		Endpoint:      h.Endpoint,
 		BasicAuthUser: h.Username,
 		BasicAuthPass: h.Password,
+		TraceParent:   h.TraceParent,
 	}.NewRequest(ctx, method, path, contentType, onBehalfOf, body)
 }
 
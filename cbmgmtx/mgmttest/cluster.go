@@ -0,0 +1,424 @@
+// Package mgmttest implements an in-process fake of the cluster manager REST
+// API, backed by a mutable in-memory topology.  Every node of the fake cluster
+// also runs a memdtest server, which allows an agent to be bootstrapped and
+// driven end-to-end without a real cluster.
+package mgmttest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/cbmgmtx"
+	"github.com/couchbase/gocbcorex/memdx/memdtest"
+)
+
+var (
+	ErrBucketExists   = errors.New("bucket already exists")
+	ErrBucketNotFound = errors.New("bucket not found")
+	ErrNodeNotFound   = errors.New("node not found")
+	ErrLastNode       = errors.New("cannot remove the last node of the cluster")
+	ErrInvalidVbucket = errors.New("invalid vbucket")
+
+	ErrMemcachedBucketsUnsupported = errors.New("memcached buckets are not supported")
+)
+
+type ClusterOptions struct {
+	Logger *zap.Logger
+
+	// Users maps the usernames which may authenticate to their passwords.  If
+	// nil, authentication is not required.
+	Users map[string]string
+
+	// NumNodes is the number of nodes the cluster starts with, defaults to 1.
+	NumNodes int
+}
+
+// BucketOptions specifies the settings of a bucket created directly through
+// Cluster.CreateBucket.
+type BucketOptions struct {
+	Name string
+
+	// NumVbuckets is the number of vbuckets in the bucket, defaults to 1024.
+	NumVbuckets int
+
+	Settings cbmgmtx.BucketSettings
+}
+
+type clusterBucket struct {
+	Bucket   *memdtest.Bucket
+	UUID     string
+	Settings cbmgmtx.BucketSettings
+
+	// VbMap holds the index of the active node followed by the indexes of the
+	// replica nodes for each vbucket, or -1 where there is no such node.
+	VbMap [][]int
+}
+
+// Node is a single node of the fake cluster, serving both the management
+// REST API and the KV protocol.
+type Node struct {
+	cluster *Cluster
+	mgmt    *httptest.Server
+	kv      *memdtest.Server
+
+	// closeCh is closed when the node is removed, which ends any streaming
+	// requests it is serving.
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+// MgmtAddr returns the host:port address of the node's management service.
+func (n *Node) MgmtAddr() string {
+	return n.mgmt.Listener.Addr().String()
+}
+
+// MgmtEndpoint returns the base URL of the node's management service.
+func (n *Node) MgmtEndpoint() string {
+	return n.mgmt.URL
+}
+
+// KvAddr returns the host:port address of the node's KV service.
+func (n *Node) KvAddr() string {
+	return n.kv.Addr()
+}
+
+// KvServer returns the fake memcached server backing the node's KV service.
+func (n *Node) KvServer() *memdtest.Server {
+	return n.kv
+}
+
+func (n *Node) close() {
+	n.closeOnce.Do(func() {
+		close(n.closeCh)
+		n.mgmt.Close()
+		_ = n.kv.Close()
+	})
+}
+
+// Cluster is a fake cluster made up of one or more nodes, all of which share
+// the same topology and buckets.
+type Cluster struct {
+	logger *zap.Logger
+	users  map[string]string
+
+	lock     sync.Mutex
+	rev      int
+	revEpoch int
+	nodes    []*Node
+	buckets  []*clusterBucket
+	closed   bool
+
+	// changeCh is closed and replaced whenever the topology changes, which
+	// allows streaming requests to wait for a new config.
+	changeCh chan struct{}
+}
+
+func NewCluster(opts *ClusterOptions) (*Cluster, error) {
+	if opts == nil {
+		opts = &ClusterOptions{}
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	numNodes := opts.NumNodes
+	if numNodes == 0 {
+		numNodes = 1
+	}
+
+	c := &Cluster{
+		logger:   logger,
+		users:    opts.Users,
+		rev:      1,
+		changeCh: make(chan struct{}),
+	}
+
+	for i := 0; i < numNodes; i++ {
+		_, err := c.AddNode()
+		if err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Nodes returns the nodes which are currently part of the cluster.
+func (c *Cluster) Nodes() []*Node {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]*Node{}, c.nodes...)
+}
+
+// Rev returns the revision epoch and revision of the current cluster config.
+func (c *Cluster) Rev() (revEpoch int, rev int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.revEpoch, c.rev
+}
+
+// AddNode starts a new node and adds it to the cluster.  The node serves the
+// existing buckets, but does not own any vbuckets until Rebalance is called.
+func (c *Cluster) AddNode() (*Node, error) {
+	node := &Node{
+		cluster: c,
+		closeCh: make(chan struct{}),
+	}
+
+	kv, err := memdtest.NewServer(&memdtest.ServerOptions{
+		Logger: c.logger.Named("kv"),
+		Users:  c.users,
+		ClusterConfig: func(s *memdtest.Server, bucketName string) []byte {
+			return c.terseConfig(node, bucketName)
+		},
+		IsVbucketActive: func(s *memdtest.Server, bucketName string, vbID uint16) bool {
+			return c.isVbucketActive(node, bucketName, vbID)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	node.kv = kv
+	node.mgmt = httptest.NewServer(&mgmtHandler{
+		cluster: c,
+		node:    node,
+	})
+
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		node.close()
+		return nil, errors.New("cluster is closed")
+	}
+	for _, bucket := range c.buckets {
+		kv.AddBucket(bucket.Bucket)
+	}
+	c.nodes = append(c.nodes, node)
+	c.configChangedLocked()
+	c.lock.Unlock()
+
+	return node, nil
+}
+
+// RemoveNode fails a node over and stops it.  The vbuckets it owned are
+// redistributed across the remaining nodes.
+func (c *Cluster) RemoveNode(node *Node) error {
+	c.lock.Lock()
+
+	if c.nodeIndexLocked(node) < 0 {
+		c.lock.Unlock()
+		return ErrNodeNotFound
+	}
+	if len(c.nodes) == 1 {
+		c.lock.Unlock()
+		return ErrLastNode
+	}
+
+	c.removeNodesLocked([]*Node{node})
+	c.rebalanceLocked()
+	c.lock.Unlock()
+
+	node.close()
+	return nil
+}
+
+// Rebalance distributes the vbuckets of every bucket evenly across the nodes
+// of the cluster.
+func (c *Cluster) Rebalance() {
+	c.lock.Lock()
+	c.rebalanceLocked()
+	c.lock.Unlock()
+}
+
+// CreateBucket creates a bucket and makes it available on every node.
+func (c *Cluster) CreateBucket(opts *BucketOptions) (*memdtest.Bucket, error) {
+	settings := opts.Settings
+	if settings.BucketType == cbmgmtx.BucketTypeUnset {
+		settings.BucketType = cbmgmtx.BucketTypeCouchbase
+	}
+	if settings.BucketType == cbmgmtx.BucketTypeMemcached {
+		return nil, ErrMemcachedBucketsUnsupported
+	}
+	if settings.RAMQuotaMB == 0 {
+		settings.RAMQuotaMB = 100
+	}
+
+	bucket := &clusterBucket{
+		Bucket: memdtest.NewBucket(&memdtest.BucketOptions{
+			Name:        opts.Name,
+			NumVbuckets: opts.NumVbuckets,
+		}),
+		UUID:     newUUID(),
+		Settings: settings,
+	}
+
+	c.lock.Lock()
+	if c.findBucketLocked(opts.Name) != nil {
+		c.lock.Unlock()
+		return nil, ErrBucketExists
+	}
+	// the bucket is made available on the nodes before it is published in
+	// the config, so clients never see a bucket they cannot select.
+	for _, node := range c.nodes {
+		node.kv.AddBucket(bucket.Bucket)
+	}
+	c.buckets = append(c.buckets, bucket)
+	c.rebalanceBucketLocked(bucket)
+	c.configChangedLocked()
+	c.lock.Unlock()
+
+	return bucket.Bucket, nil
+}
+
+// DeleteBucket deletes a bucket, closing any KV connections which have it
+// selected.
+func (c *Cluster) DeleteBucket(bucketName string) error {
+	c.lock.Lock()
+	bucketIdx := -1
+	for i, bucket := range c.buckets {
+		if bucket.Bucket.Name() == bucketName {
+			bucketIdx = i
+		}
+	}
+	if bucketIdx < 0 {
+		c.lock.Unlock()
+		return ErrBucketNotFound
+	}
+	c.buckets = append(c.buckets[:bucketIdx], c.buckets[bucketIdx+1:]...)
+	c.configChangedLocked()
+	nodes := append([]*Node{}, c.nodes...)
+	c.lock.Unlock()
+
+	for _, node := range nodes {
+		node.kv.RemoveBucket(bucketName)
+	}
+
+	return nil
+}
+
+// Bucket returns the bucket with the given name, or nil if there is none.
+func (c *Cluster) Bucket(bucketName string) *memdtest.Bucket {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	bucket := c.findBucketLocked(bucketName)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Bucket
+}
+
+// ConfigChanged bumps the revision of the cluster config, notifying any
+// streaming config requests.  It must be called after modifying the
+// collections of a bucket directly rather than through the REST API.
+func (c *Cluster) ConfigChanged() {
+	c.lock.Lock()
+	c.configChangedLocked()
+	c.lock.Unlock()
+}
+
+// Close stops every node of the cluster.
+func (c *Cluster) Close() error {
+	c.lock.Lock()
+	c.closed = true
+	nodes := c.nodes
+	c.nodes = nil
+	c.lock.Unlock()
+
+	for _, node := range nodes {
+		node.close()
+	}
+
+	return nil
+}
+
+func (c *Cluster) configChangedLocked() {
+	c.rev++
+	close(c.changeCh)
+	c.changeCh = make(chan struct{})
+}
+
+func (c *Cluster) nodeIndexLocked(node *Node) int {
+	for nodeIdx, foundNode := range c.nodes {
+		if foundNode == node {
+			return nodeIdx
+		}
+	}
+	return -1
+}
+
+func (c *Cluster) findBucketLocked(bucketName string) *clusterBucket {
+	for _, bucket := range c.buckets {
+		if bucket.Bucket.Name() == bucketName {
+			return bucket
+		}
+	}
+	return nil
+}
+
+func (c *Cluster) rebalanceLocked() {
+	for _, bucket := range c.buckets {
+		c.rebalanceBucketLocked(bucket)
+	}
+	c.configChangedLocked()
+}
+
+func (c *Cluster) rebalanceBucketLocked(bucket *clusterBucket) {
+	nodeIdxs := make([]int, len(c.nodes))
+	for nodeIdx := range nodeIdxs {
+		nodeIdxs[nodeIdx] = nodeIdx
+	}
+
+	bucket.VbMap = targetVbMap(bucket, nodeIdxs)
+}
+
+// targetVbMap generates a vbucket map which spreads the active and replica
+// copies of every vbucket evenly across the nodes with the given indexes.
+func targetVbMap(bucket *clusterBucket, nodeIdxs []int) [][]int {
+	numNodes := len(nodeIdxs)
+	numReplicas := int(bucket.Settings.ReplicaNumber)
+
+	vbMap := make([][]int, bucket.Bucket.NumVbuckets())
+	for vbID := range vbMap {
+		entry := make([]int, 1+numReplicas)
+		for copyIdx := range entry {
+			if copyIdx >= numNodes {
+				entry[copyIdx] = -1
+			} else {
+				entry[copyIdx] = nodeIdxs[(vbID+copyIdx)%numNodes]
+			}
+		}
+		vbMap[vbID] = entry
+	}
+
+	return vbMap
+}
+
+func (c *Cluster) isVbucketActive(node *Node, bucketName string, vbID uint16) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	bucket := c.findBucketLocked(bucketName)
+	if bucket == nil || int(vbID) >= len(bucket.VbMap) {
+		return false
+	}
+
+	activeIdx := bucket.VbMap[vbID][0]
+	return activeIdx >= 0 && activeIdx < len(c.nodes) && c.nodes[activeIdx] == node
+}
+
+func newUUID() string {
+	uuid := make([]byte, 16)
+	_, _ = rand.Read(uuid)
+	return hex.EncodeToString(uuid)
+}
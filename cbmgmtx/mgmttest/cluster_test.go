@@ -0,0 +1,359 @@
+package mgmttest
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/cbmgmtx"
+)
+
+const (
+	testUsername = "Administrator"
+	testPassword = "password"
+	testBucket   = "default"
+)
+
+func startTestCluster(t *testing.T, numNodes int) *Cluster {
+	cluster, err := NewCluster(&ClusterOptions{
+		Users:    map[string]string{testUsername: testPassword},
+		NumNodes: numNodes,
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = cluster.Close()
+	})
+
+	_, err = cluster.CreateBucket(&BucketOptions{
+		Name: testBucket,
+		Settings: cbmgmtx.BucketSettings{
+			MutableBucketSettings: cbmgmtx.MutableBucketSettings{
+				FlushEnabled: true,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	return cluster
+}
+
+func newTestMgmt(node *Node) *cbmgmtx.Management {
+	return &cbmgmtx.Management{
+		UserAgent: "gocbcorex test",
+		Endpoint:  node.MgmtEndpoint(),
+		Username:  testUsername,
+		Password:  testPassword,
+	}
+}
+
+func TestConfigs(t *testing.T) {
+	ctx := context.Background()
+	cluster := startTestCluster(t, 2)
+	nodes := cluster.Nodes()
+	mgmt := newTestMgmt(nodes[1])
+
+	clusterConfig, err := mgmt.GetTerseClusterConfig(ctx, &cbmgmtx.GetTerseClusterConfigOptions{})
+	require.NoError(t, err)
+	require.Len(t, clusterConfig.NodesExt, 2)
+	assert.False(t, clusterConfig.NodesExt[0].ThisNode)
+	assert.True(t, clusterConfig.NodesExt[1].ThisNode)
+	assert.Equal(t, "127.0.0.1", clusterConfig.NodesExt[1].Hostname)
+	assert.Equal(t, uint16(nodes[1].KvServer().Port()), clusterConfig.NodesExt[1].Services.Kv)
+
+	bucketConfig, err := mgmt.GetTerseBucketConfig(ctx, &cbmgmtx.GetTerseBucketConfigOptions{
+		BucketName: testBucket,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testBucket, bucketConfig.Name)
+	assert.Equal(t, clusterConfig.Rev, bucketConfig.Rev)
+	assert.Len(t, bucketConfig.Nodes, 2)
+	require.NotNil(t, bucketConfig.VBucketServerMap)
+	assert.Equal(t, []string{nodes[0].KvAddr(), nodes[1].KvAddr()}, bucketConfig.VBucketServerMap.ServerList)
+	assert.Len(t, bucketConfig.VBucketServerMap.VBucketMap, 1024)
+
+	fullConfig, err := mgmt.GetClusterConfig(ctx, &cbmgmtx.GetClusterConfigOptions{})
+	require.NoError(t, err)
+	assert.Len(t, fullConfig.Nodes, 2)
+
+	_, err = mgmt.GetTerseBucketConfig(ctx, &cbmgmtx.GetTerseBucketConfigOptions{
+		BucketName: "missing",
+	})
+	require.Error(t, err)
+
+	badMgmt := newTestMgmt(nodes[0])
+	badMgmt.Password = "wrong"
+	_, err = badMgmt.GetTerseClusterConfig(ctx, &cbmgmtx.GetTerseClusterConfigOptions{})
+	require.ErrorIs(t, err, cbmgmtx.ErrAccessDenied)
+}
+
+func TestStreamTerseBucketConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cluster := startTestCluster(t, 1)
+	mgmt := newTestMgmt(cluster.Nodes()[0])
+
+	stream, err := mgmt.StreamTerseBucketConfig(ctx, &cbmgmtx.StreamTerseBucketConfigOptions{
+		BucketName: testBucket,
+	})
+	require.NoError(t, err)
+
+	config, err := stream.Recv()
+	require.NoError(t, err)
+	require.Len(t, config.NodesExt, 1)
+	firstRev := config.Rev
+
+	_, err = cluster.AddNode()
+	require.NoError(t, err)
+
+	config, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Greater(t, config.Rev, firstRev)
+	assert.Len(t, config.NodesExt, 2)
+
+	cluster.Rebalance()
+
+	config, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, config.VBucketServerMap.VBucketMap[1])
+
+	// deleting the bucket ends the stream
+	err = cluster.DeleteBucket(testBucket)
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	require.Error(t, err)
+}
+
+func TestBucketManagement(t *testing.T) {
+	ctx := context.Background()
+	cluster := startTestCluster(t, 1)
+	mgmt := newTestMgmt(cluster.Nodes()[0])
+
+	settings := cbmgmtx.BucketSettings{
+		MutableBucketSettings: cbmgmtx.MutableBucketSettings{
+			RAMQuotaMB:    256,
+			ReplicaNumber: 1,
+			BucketType:    cbmgmtx.BucketTypeCouchbase,
+			MaxTTL:        time.Hour,
+		},
+	}
+
+	err := mgmt.CreateBucket(ctx, &cbmgmtx.CreateBucketOptions{
+		BucketName:     "new-bucket",
+		BucketSettings: settings,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, cluster.Bucket("new-bucket"))
+
+	err = mgmt.CreateBucket(ctx, &cbmgmtx.CreateBucketOptions{
+		BucketName:     "new-bucket",
+		BucketSettings: settings,
+	})
+	require.ErrorIs(t, err, cbmgmtx.ErrBucketExists)
+
+	bucket, err := mgmt.GetBucket(ctx, &cbmgmtx.GetBucketOptions{
+		BucketName: "new-bucket",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "new-bucket", bucket.Name)
+	assert.Equal(t, uint64(256), bucket.RAMQuotaMB)
+	assert.Equal(t, uint32(1), bucket.ReplicaNumber)
+	assert.Equal(t, time.Hour, bucket.MaxTTL)
+	assert.False(t, bucket.FlushEnabled)
+
+	buckets, err := mgmt.GetAllBuckets(ctx, &cbmgmtx.GetAllBucketsOptions{})
+	require.NoError(t, err)
+	require.Len(t, buckets, 2)
+	assert.Equal(t, testBucket, buckets[0].Name)
+	assert.Equal(t, "new-bucket", buckets[1].Name)
+
+	err = mgmt.FlushBucket(ctx, &cbmgmtx.FlushBucketOptions{
+		BucketName: "new-bucket",
+	})
+	require.Error(t, err)
+
+	settings.FlushEnabled = true
+	settings.RAMQuotaMB = 512
+	err = mgmt.UpdateBucket(ctx, &cbmgmtx.UpdateBucketOptions{
+		BucketName:            "new-bucket",
+		MutableBucketSettings: settings.MutableBucketSettings,
+	})
+	require.NoError(t, err)
+
+	bucket, err = mgmt.GetBucket(ctx, &cbmgmtx.GetBucketOptions{
+		BucketName: "new-bucket",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(512), bucket.RAMQuotaMB)
+	assert.True(t, bucket.FlushEnabled)
+
+	err = mgmt.FlushBucket(ctx, &cbmgmtx.FlushBucketOptions{
+		BucketName: "new-bucket",
+	})
+	require.NoError(t, err)
+
+	err = mgmt.DeleteBucket(ctx, &cbmgmtx.DeleteBucketOptions{
+		BucketName: "new-bucket",
+	})
+	require.NoError(t, err)
+	require.Nil(t, cluster.Bucket("new-bucket"))
+
+	err = mgmt.DeleteBucket(ctx, &cbmgmtx.DeleteBucketOptions{
+		BucketName: "new-bucket",
+	})
+	require.ErrorIs(t, err, cbmgmtx.ErrBucketNotFound)
+}
+
+func TestCollectionManagement(t *testing.T) {
+	ctx := context.Background()
+	cluster := startTestCluster(t, 1)
+	mgmt := newTestMgmt(cluster.Nodes()[0])
+
+	_, startRev := cluster.Rev()
+
+	err := mgmt.CreateScope(ctx, &cbmgmtx.CreateScopeOptions{
+		BucketName: testBucket,
+		ScopeName:  "scope",
+	})
+	require.NoError(t, err)
+
+	err = mgmt.CreateScope(ctx, &cbmgmtx.CreateScopeOptions{
+		BucketName: testBucket,
+		ScopeName:  "scope",
+	})
+	require.ErrorIs(t, err, cbmgmtx.ErrScopeExists)
+
+	err = mgmt.CreateCollection(ctx, &cbmgmtx.CreateCollectionOptions{
+		BucketName:     testBucket,
+		ScopeName:      "scope",
+		CollectionName: "collection",
+		MaxTTL:         60,
+	})
+	require.NoError(t, err)
+
+	err = mgmt.CreateCollection(ctx, &cbmgmtx.CreateCollectionOptions{
+		BucketName:     testBucket,
+		ScopeName:      "scope",
+		CollectionName: "collection",
+	})
+	require.ErrorIs(t, err, cbmgmtx.ErrCollectionExists)
+
+	err = mgmt.CreateCollection(ctx, &cbmgmtx.CreateCollectionOptions{
+		BucketName:     testBucket,
+		ScopeName:      "missing",
+		CollectionName: "collection",
+	})
+	require.ErrorIs(t, err, cbmgmtx.ErrScopeNotFound)
+
+	manifest, err := mgmt.GetCollectionManifest(ctx, &cbmgmtx.GetCollectionManifestOptions{
+		BucketName: testBucket,
+	})
+	require.NoError(t, err)
+	require.Len(t, manifest.Scopes, 2)
+	assert.Equal(t, "scope", manifest.Scopes[1].Name)
+	require.Len(t, manifest.Scopes[1].Collections, 1)
+	assert.Equal(t, uint32(60), manifest.Scopes[1].Collections[0].MaxTTL)
+
+	// collection changes publish the new manifest uid in the bucket config
+	_, rev := cluster.Rev()
+	assert.Greater(t, rev, startRev)
+
+	bucketConfig, err := mgmt.GetTerseBucketConfig(ctx, &cbmgmtx.GetTerseBucketConfigOptions{
+		BucketName: testBucket,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, manifest.UID, bucketConfig.CollectionsManifestUid)
+
+	err = mgmt.DeleteCollection(ctx, &cbmgmtx.DeleteCollectionOptions{
+		BucketName:     testBucket,
+		ScopeName:      "scope",
+		CollectionName: "collection",
+	})
+	require.NoError(t, err)
+
+	err = mgmt.DeleteCollection(ctx, &cbmgmtx.DeleteCollectionOptions{
+		BucketName:     testBucket,
+		ScopeName:      "scope",
+		CollectionName: "collection",
+	})
+	require.ErrorIs(t, err, cbmgmtx.ErrCollectionNotFound)
+
+	err = mgmt.DeleteScope(ctx, &cbmgmtx.DeleteScopeOptions{
+		BucketName: testBucket,
+		ScopeName:  "scope",
+	})
+	require.NoError(t, err)
+
+	err = mgmt.DeleteScope(ctx, &cbmgmtx.DeleteScopeOptions{
+		BucketName: testBucket,
+		ScopeName:  "scope",
+	})
+	require.ErrorIs(t, err, cbmgmtx.ErrScopeNotFound)
+}
+
+func TestAgentReconfiguration(t *testing.T) {
+	ctx := context.Background()
+	cluster := startTestCluster(t, 1)
+
+	agent, err := gocbcorex.CreateAgent(ctx, gocbcorex.AgentOptions{
+		BucketName: testBucket,
+		Authenticator: &gocbcorex.PasswordAuthenticator{
+			Username: testUsername,
+			Password: testPassword,
+		},
+		SeedConfig: gocbcorex.SeedConfig{
+			HTTPAddrs: []string{cluster.Nodes()[0].MgmtAddr()},
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = agent.Close()
+	})
+
+	upsertAll := func() {
+		for i := 0; i < 32; i++ {
+			_, err := agent.Upsert(ctx, &gocbcorex.UpsertOptions{
+				Key:            []byte("key-" + strconv.Itoa(i)),
+				ScopeName:      "_default",
+				CollectionName: "_default",
+				Value:          []byte(`{"i":` + strconv.Itoa(i) + `}`),
+			})
+			require.NoError(t, err)
+		}
+	}
+	getAll := func() {
+		for i := 0; i < 32; i++ {
+			res, err := agent.Get(ctx, &gocbcorex.GetOptions{
+				Key:            []byte("key-" + strconv.Itoa(i)),
+				ScopeName:      "_default",
+				CollectionName: "_default",
+			})
+			require.NoError(t, err)
+			assert.Equal(t, []byte(`{"i":`+strconv.Itoa(i)+`}`), res.Value)
+		}
+	}
+
+	upsertAll()
+
+	// after a second node joins and takes over half the vbuckets, requests
+	// still routed by the old config receive a not-my-vbucket response
+	// carrying the new config, which the agent applies before retrying.
+	newNode, err := cluster.AddNode()
+	require.NoError(t, err)
+	cluster.Rebalance()
+
+	getAll()
+	upsertAll()
+
+	// once the old node is removed, everything must be served by the new one
+	err = cluster.RemoveNode(cluster.Nodes()[0])
+	require.NoError(t, err)
+	require.Equal(t, []*Node{newNode}, cluster.Nodes())
+}
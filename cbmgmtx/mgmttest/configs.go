@@ -0,0 +1,189 @@
+package mgmttest
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+
+	"github.com/couchbase/gocbcorex/contrib/cbconfig"
+)
+
+// every node of the cluster listens on the loopback interface.  Like a real
+// multi-node cluster, the configs name each node explicitly rather than
+// relying on $HOST substitution.
+const configHostname = "127.0.0.1"
+
+var defaultBucketCapabilities = []string{
+	"collections", "durableWrite", "tombstonedUserXAttrs", "couchapi",
+	"subdoc.ReplaceBodyWithXattr", "subdoc.DocumentMacroSupport",
+	"dcp", "cbhello", "touch", "cccp", "xdcrCheckpointing", "nodesExt", "xattr",
+}
+
+type bucketJson struct {
+	cbconfig.FullConfigJson
+
+	Controllers struct {
+		Flush string `json:"flush,omitempty"`
+	} `json:"controllers"`
+	ReplicaIndex bool `json:"replicaIndex"`
+	Quota        struct {
+		RAM    uint64 `json:"ram"`
+		RawRAM uint64 `json:"rawRAM"`
+	} `json:"quota"`
+	ReplicaNumber          uint32 `json:"replicaNumber"`
+	BucketType             string `json:"bucketType"`
+	ConflictResolutionType string `json:"conflictResolutionType"`
+	EvictionPolicy         string `json:"evictionPolicy"`
+	MaxTTL                 uint32 `json:"maxTTL"`
+	CompressionMode        string `json:"compressionMode"`
+	MinimumDurabilityLevel string `json:"durabilityMinLevel"`
+	StorageBackend         string `json:"storageBackend"`
+}
+
+func (n *Node) mgmtPort() uint16 {
+	return uint16(n.mgmt.Listener.Addr().(*net.TCPAddr).Port)
+}
+
+func (n *Node) kvPort() uint16 {
+	return uint16(n.kv.Port())
+}
+
+func (c *Cluster) terseNodesExtLocked(thisNode *Node) []cbconfig.TerseExtNodeJson {
+	nodesExt := make([]cbconfig.TerseExtNodeJson, len(c.nodes))
+	for nodeIdx, node := range c.nodes {
+		nodesExt[nodeIdx] = cbconfig.TerseExtNodeJson{
+			Services: &cbconfig.TerseExtNodePortsJson{
+				Kv:   node.kvPort(),
+				Mgmt: node.mgmtPort(),
+			},
+			ThisNode: node == thisNode,
+			Hostname: configHostname,
+		}
+	}
+	return nodesExt
+}
+
+func (c *Cluster) terseClusterConfigLocked(thisNode *Node) *cbconfig.TerseConfigJson {
+	return &cbconfig.TerseConfigJson{
+		Rev:                    c.rev,
+		RevEpoch:               c.revEpoch,
+		NodesExt:               c.terseNodesExtLocked(thisNode),
+		ClusterCapabilitiesVer: []int{1, 0},
+		ClusterCapabilities:    map[string][]string{},
+	}
+}
+
+func (c *Cluster) terseBucketConfigLocked(bucket *clusterBucket, thisNode *Node) *cbconfig.TerseConfigJson {
+	bucketName := bucket.Bucket.Name()
+
+	config := c.terseClusterConfigLocked(thisNode)
+	config.Name = bucketName
+	config.NodeLocator = "vbucket"
+	config.UUID = bucket.UUID
+	config.URI = "/pools/default/buckets/" + bucketName + "?bucket_uuid=" + bucket.UUID
+	config.StreamingURI = "/pools/default/bucketsStreaming/" + bucketName + "?bucket_uuid=" + bucket.UUID
+	config.BucketCapabilitiesVer = ""
+	config.BucketCapabilities = defaultBucketCapabilities
+	config.CollectionsManifestUid = strconv.FormatUint(bucket.Bucket.ManifestUid(), 16)
+	config.DDocs = &cbconfig.ConfigDDocsJson{
+		URI: "/pools/default/buckets/" + bucketName + "/ddocs",
+	}
+
+	// every node serves data for every bucket, so the nodes list holds all of
+	// them in the same order as the server list of the vbucket map.
+	serverList := make([]string, len(c.nodes))
+	for nodeIdx, node := range c.nodes {
+		serverList[nodeIdx] = configHostname + ":" + strconv.Itoa(int(node.kvPort()))
+		config.Nodes = append(config.Nodes, cbconfig.TerseNodeJson{
+			Hostname: configHostname + ":" + strconv.Itoa(int(node.mgmtPort())),
+			Ports: &cbconfig.TerseNodePortsJson{
+				Direct: node.kvPort(),
+			},
+		})
+	}
+
+	config.VBucketServerMap = &cbconfig.VBucketServerMapJson{
+		HashAlgorithm: "CRC",
+		NumReplicas:   int(bucket.Settings.ReplicaNumber),
+		ServerList:    serverList,
+		VBucketMap:    bucket.VbMap,
+	}
+
+	return config
+}
+
+func (c *Cluster) fullNodesLocked() []cbconfig.FullNodeJson {
+	nodes := make([]cbconfig.FullNodeJson, len(c.nodes))
+	for nodeIdx, node := range c.nodes {
+		nodes[nodeIdx] = cbconfig.FullNodeJson{
+			Hostname: configHostname + ":" + strconv.Itoa(int(node.mgmtPort())),
+			NodeUUID: strconv.Itoa(nodeIdx),
+			Ports: map[string]int{
+				"direct": int(node.kvPort()),
+			},
+			Services: []string{"kv"},
+		}
+	}
+	return nodes
+}
+
+func (c *Cluster) fullClusterConfigLocked() *cbconfig.FullConfigJson {
+	return &cbconfig.FullConfigJson{
+		Name:  "default",
+		Nodes: c.fullNodesLocked(),
+	}
+}
+
+func (c *Cluster) bucketJsonLocked(bucket *clusterBucket) *bucketJson {
+	terseConfig := c.terseBucketConfigLocked(bucket, nil)
+	settings := bucket.Settings
+
+	out := &bucketJson{
+		FullConfigJson: cbconfig.FullConfigJson{
+			Name:                   terseConfig.Name,
+			NodeLocator:            terseConfig.NodeLocator,
+			UUID:                   terseConfig.UUID,
+			URI:                    terseConfig.URI,
+			StreamingURI:           terseConfig.StreamingURI,
+			BucketCapabilitiesVer:  terseConfig.BucketCapabilitiesVer,
+			BucketCapabilities:     terseConfig.BucketCapabilities,
+			CollectionsManifestUid: terseConfig.CollectionsManifestUid,
+			DDocs:                  terseConfig.DDocs,
+			VBucketServerMap:       terseConfig.VBucketServerMap,
+			Nodes:                  c.fullNodesLocked(),
+		},
+		ReplicaIndex:           !settings.ReplicaIndexDisabled,
+		ReplicaNumber:          settings.ReplicaNumber,
+		BucketType:             string(settings.BucketType),
+		ConflictResolutionType: string(settings.ConflictResolutionType),
+		EvictionPolicy:         string(settings.EvictionPolicy),
+		MaxTTL:                 uint32(settings.MaxTTL.Seconds()),
+		CompressionMode:        string(settings.CompressionMode),
+		MinimumDurabilityLevel: string(settings.DurabilityMinLevel),
+		StorageBackend:         string(settings.StorageBackend),
+	}
+	if settings.FlushEnabled {
+		out.Controllers.Flush = out.URI + "/controller/doFlush"
+	}
+	out.Quota.RawRAM = settings.RAMQuotaMB * 1024 * 1024
+	out.Quota.RAM = out.Quota.RawRAM * uint64(len(c.nodes))
+
+	return out
+}
+
+// terseConfig returns the config served by a node's KV service, which is the
+// bucket config when bucketName names a bucket and the cluster config
+// otherwise.
+func (c *Cluster) terseConfig(thisNode *Node, bucketName string) []byte {
+	c.lock.Lock()
+	var config *cbconfig.TerseConfigJson
+	if bucket := c.findBucketLocked(bucketName); bucket != nil {
+		config = c.terseBucketConfigLocked(bucket, thisNode)
+	} else {
+		config = c.terseClusterConfigLocked(thisNode)
+	}
+	c.lock.Unlock()
+
+	configBytes, _ := json.Marshal(config)
+	return configBytes
+}
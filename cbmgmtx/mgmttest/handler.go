@@ -0,0 +1,155 @@
+package mgmttest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// mgmtHandler serves the management REST API of a single node.
+type mgmtHandler struct {
+	cluster *Cluster
+	node    *Node
+}
+
+func (h *mgmtHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.cluster.logger.Debug("handling management request",
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path))
+
+	if !h.checkAuth(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "pools" || parts[1] != "default" {
+		writeNotFound(w)
+		return
+	}
+
+	parts = parts[2:]
+	switch {
+	case len(parts) == 0:
+		h.route(w, r, map[string]http.HandlerFunc{"GET": h.handleGetClusterConfig})
+	case len(parts) == 1 && parts[0] == "nodeServices":
+		h.route(w, r, map[string]http.HandlerFunc{"GET": h.handleGetTerseClusterConfig})
+	case len(parts) == 1 && parts[0] == "nodeServicesStreaming":
+		h.route(w, r, map[string]http.HandlerFunc{"GET": h.handleStreamTerseClusterConfig})
+	case len(parts) == 2 && parts[0] == "b":
+		h.route(w, r, map[string]http.HandlerFunc{"GET": func(w http.ResponseWriter, r *http.Request) {
+			h.handleGetTerseBucketConfig(w, r, parts[1])
+		}})
+	case len(parts) == 2 && parts[0] == "bs":
+		h.route(w, r, map[string]http.HandlerFunc{"GET": func(w http.ResponseWriter, r *http.Request) {
+			h.handleStreamTerseBucketConfig(w, r, parts[1])
+		}})
+	case len(parts) >= 1 && parts[0] == "buckets":
+		h.routeBuckets(w, r, parts[1:])
+	default:
+		writeNotFound(w)
+	}
+}
+
+func (h *mgmtHandler) routeBuckets(w http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 0:
+		h.route(w, r, map[string]http.HandlerFunc{
+			"GET":  h.handleGetAllBuckets,
+			"POST": h.handleCreateBucket,
+		})
+	case len(parts) == 1:
+		bucketName := parts[0]
+		h.route(w, r, map[string]http.HandlerFunc{
+			"GET": func(w http.ResponseWriter, r *http.Request) {
+				h.handleGetBucket(w, r, bucketName)
+			},
+			"POST": func(w http.ResponseWriter, r *http.Request) {
+				h.handleUpdateBucket(w, r, bucketName)
+			},
+			"DELETE": func(w http.ResponseWriter, r *http.Request) {
+				h.handleDeleteBucket(w, r, bucketName)
+			},
+		})
+	case len(parts) == 3 && parts[1] == "controller" && parts[2] == "doFlush":
+		h.route(w, r, map[string]http.HandlerFunc{"POST": func(w http.ResponseWriter, r *http.Request) {
+			h.handleFlushBucket(w, r, parts[0])
+		}})
+	case len(parts) == 2 && parts[1] == "scopes":
+		bucketName := parts[0]
+		h.route(w, r, map[string]http.HandlerFunc{
+			"GET": func(w http.ResponseWriter, r *http.Request) {
+				h.handleGetCollectionManifest(w, r, bucketName)
+			},
+			"POST": func(w http.ResponseWriter, r *http.Request) {
+				h.handleCreateScope(w, r, bucketName)
+			},
+		})
+	case len(parts) == 3 && parts[1] == "scopes":
+		h.route(w, r, map[string]http.HandlerFunc{"DELETE": func(w http.ResponseWriter, r *http.Request) {
+			h.handleDeleteScope(w, r, parts[0], parts[2])
+		}})
+	case len(parts) == 4 && parts[1] == "scopes" && parts[3] == "collections":
+		h.route(w, r, map[string]http.HandlerFunc{"POST": func(w http.ResponseWriter, r *http.Request) {
+			h.handleCreateCollection(w, r, parts[0], parts[2])
+		}})
+	case len(parts) == 5 && parts[1] == "scopes" && parts[3] == "collections":
+		h.route(w, r, map[string]http.HandlerFunc{"DELETE": func(w http.ResponseWriter, r *http.Request) {
+			h.handleDeleteCollection(w, r, parts[0], parts[2], parts[4])
+		}})
+	default:
+		writeNotFound(w)
+	}
+}
+
+func (h *mgmtHandler) route(w http.ResponseWriter, r *http.Request, handlers map[string]http.HandlerFunc) {
+	handler, ok := handlers[r.Method]
+	if !ok {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	handler(w, r)
+}
+
+func (h *mgmtHandler) checkAuth(r *http.Request) bool {
+	users := h.cluster.users
+	if users == nil {
+		return true
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	expectedPassword, ok := users[username]
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(expectedPassword), []byte(password)) == 1
+}
+
+func writeJson(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// writeErrors writes an error response in the same shape the real server
+// uses for validation failures.
+func writeErrors(w http.ResponseWriter, statusCode int, errs map[string]string) {
+	writeJson(w, statusCode, map[string]interface{}{
+		"errors": errs,
+	})
+}
+
+func writeNotFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte("Requested resource not found.\r\n"))
+}
@@ -0,0 +1,240 @@
+package mgmttest
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/couchbase/gocbcorex/cbmgmtx"
+)
+
+func parseFormBool(form url.Values, field string, errs map[string]string, value *bool) {
+	switch form.Get(field) {
+	case "":
+	case "0":
+		*value = false
+	case "1":
+		*value = true
+	default:
+		errs[field] = field + " can only be 1 or 0"
+	}
+}
+
+func parseFormUint(form url.Values, field string, errs map[string]string, value *uint64) {
+	if !form.Has(field) {
+		return
+	}
+
+	parsedValue, err := strconv.ParseUint(form.Get(field), 10, 32)
+	if err != nil {
+		errs[field] = field + " must be a non-negative integer"
+		return
+	}
+	*value = parsedValue
+}
+
+// parseMutableBucketSettings applies the settings present in a create or
+// update request to settings, returning any validation errors keyed by field
+// name.
+func parseMutableBucketSettings(form url.Values, settings *cbmgmtx.MutableBucketSettings) map[string]string {
+	errs := make(map[string]string)
+
+	parseFormBool(form, "flushEnabled", errs, &settings.FlushEnabled)
+
+	replicaIndex := !settings.ReplicaIndexDisabled
+	parseFormBool(form, "replicaIndex", errs, &replicaIndex)
+	settings.ReplicaIndexDisabled = !replicaIndex
+
+	ramQuotaMB := settings.RAMQuotaMB
+	parseFormUint(form, "ramQuotaMB", errs, &ramQuotaMB)
+	settings.RAMQuotaMB = ramQuotaMB
+
+	replicaNumber := uint64(settings.ReplicaNumber)
+	parseFormUint(form, "replicaNumber", errs, &replicaNumber)
+	if replicaNumber > 3 {
+		errs["replicaNumber"] = "Replica number larger than 3 is not supported."
+	}
+	settings.ReplicaNumber = uint32(replicaNumber)
+
+	maxTTL := uint64(settings.MaxTTL / time.Second)
+	parseFormUint(form, "maxTTL", errs, &maxTTL)
+	settings.MaxTTL = time.Duration(maxTTL) * time.Second
+
+	if form.Has("bucketType") {
+		switch bucketType := form.Get("bucketType"); bucketType {
+		case "couchbase", string(cbmgmtx.BucketTypeCouchbase):
+			settings.BucketType = cbmgmtx.BucketTypeCouchbase
+		case string(cbmgmtx.BucketTypeEphemeral), string(cbmgmtx.BucketTypeMemcached):
+			settings.BucketType = cbmgmtx.BucketType(bucketType)
+		default:
+			errs["bucketType"] = "invalid bucket type"
+		}
+	}
+	if form.Has("evictionPolicy") {
+		settings.EvictionPolicy = cbmgmtx.EvictionPolicyType(form.Get("evictionPolicy"))
+	}
+	if form.Has("compressionMode") {
+		settings.CompressionMode = cbmgmtx.CompressionMode(form.Get("compressionMode"))
+	}
+	if form.Has("durabilityMinLevel") {
+		settings.DurabilityMinLevel = cbmgmtx.DurabilityLevel(form.Get("durabilityMinLevel"))
+	}
+	if form.Has("storageBackend") {
+		settings.StorageBackend = cbmgmtx.StorageBackend(form.Get("storageBackend"))
+	}
+
+	return errs
+}
+
+func (h *mgmtHandler) handleGetAllBuckets(w http.ResponseWriter, r *http.Request) {
+	c := h.cluster
+
+	c.lock.Lock()
+	buckets := make([]*bucketJson, len(c.buckets))
+	for bucketIdx, bucket := range c.buckets {
+		buckets[bucketIdx] = c.bucketJsonLocked(bucket)
+	}
+	c.lock.Unlock()
+
+	writeJson(w, http.StatusOK, buckets)
+}
+
+func (h *mgmtHandler) handleGetBucket(w http.ResponseWriter, r *http.Request, bucketName string) {
+	c := h.cluster
+
+	c.lock.Lock()
+	bucket := c.findBucketLocked(bucketName)
+	if bucket == nil {
+		c.lock.Unlock()
+		writeNotFound(w)
+		return
+	}
+	config := c.bucketJsonLocked(bucket)
+	c.lock.Unlock()
+
+	writeJson(w, http.StatusOK, config)
+}
+
+func (h *mgmtHandler) handleCreateBucket(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	bucketName := r.PostForm.Get("name")
+
+	var settings cbmgmtx.BucketSettings
+	errs := parseMutableBucketSettings(r.PostForm, &settings.MutableBucketSettings)
+	if bucketName == "" {
+		errs["name"] = "Bucket name cannot be empty"
+	}
+	if r.PostForm.Has("conflictResolutionType") {
+		settings.ConflictResolutionType = cbmgmtx.ConflictResolutionType(r.PostForm.Get("conflictResolutionType"))
+	}
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	_, err := h.cluster.CreateBucket(&BucketOptions{
+		Name:     bucketName,
+		Settings: settings,
+	})
+	if errors.Is(err, ErrBucketExists) {
+		writeErrors(w, http.StatusBadRequest, map[string]string{
+			"name": "Bucket with given name already exists",
+		})
+		return
+	} else if errors.Is(err, ErrMemcachedBucketsUnsupported) {
+		writeErrors(w, http.StatusBadRequest, map[string]string{
+			"bucketType": err.Error(),
+		})
+		return
+	} else if err != nil {
+		writeErrors(w, http.StatusInternalServerError, map[string]string{
+			"_": err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *mgmtHandler) handleUpdateBucket(w http.ResponseWriter, r *http.Request, bucketName string) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c := h.cluster
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	bucket := c.findBucketLocked(bucketName)
+	if bucket == nil {
+		writeNotFound(w)
+		return
+	}
+
+	settings := bucket.Settings
+	errs := parseMutableBucketSettings(r.PostForm, &settings.MutableBucketSettings)
+	if settings.BucketType != bucket.Settings.BucketType {
+		errs["bucketType"] = "Cannot change bucket type."
+	}
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	replicasChanged := settings.ReplicaNumber != bucket.Settings.ReplicaNumber
+	bucket.Settings = settings
+	if replicasChanged {
+		c.rebalanceBucketLocked(bucket)
+	}
+	c.configChangedLocked()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *mgmtHandler) handleDeleteBucket(w http.ResponseWriter, r *http.Request, bucketName string) {
+	err := h.cluster.DeleteBucket(bucketName)
+	if errors.Is(err, ErrBucketNotFound) {
+		writeNotFound(w)
+		return
+	} else if err != nil {
+		writeErrors(w, http.StatusInternalServerError, map[string]string{
+			"_": err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *mgmtHandler) handleFlushBucket(w http.ResponseWriter, r *http.Request, bucketName string) {
+	c := h.cluster
+
+	c.lock.Lock()
+	bucket := c.findBucketLocked(bucketName)
+	if bucket == nil {
+		c.lock.Unlock()
+		writeNotFound(w)
+		return
+	}
+	flushEnabled := bucket.Settings.FlushEnabled
+	c.lock.Unlock()
+
+	if !flushEnabled {
+		writeJson(w, http.StatusBadRequest, map[string]string{
+			"_": "Flush is disabled for the bucket",
+		})
+		return
+	}
+
+	bucket.Bucket.Flush()
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,163 @@
+package mgmttest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/couchbase/gocbcorex/memdx/memdtest"
+)
+
+func (h *mgmtHandler) findBucket(bucketName string) *memdtest.Bucket {
+	return h.cluster.Bucket(bucketName)
+}
+
+// writeCollectionsError writes the response the real server sends when a
+// scope or collection operation fails.
+func writeCollectionsError(w http.ResponseWriter, err error, scopeName, collectionName string) {
+	switch {
+	case errors.Is(err, memdtest.ErrScopeExists):
+		writeErrors(w, http.StatusBadRequest, map[string]string{
+			"_": fmt.Sprintf("Scope with name \"%s\" already exists", scopeName),
+		})
+	case errors.Is(err, memdtest.ErrScopeNotFound):
+		writeErrors(w, http.StatusNotFound, map[string]string{
+			"_": fmt.Sprintf("Scope with name \"%s\" is not found", scopeName),
+		})
+	case errors.Is(err, memdtest.ErrCollectionExists):
+		writeErrors(w, http.StatusBadRequest, map[string]string{
+			"_": fmt.Sprintf("Collection with name \"%s\" in scope \"%s\" already exists", collectionName, scopeName),
+		})
+	case errors.Is(err, memdtest.ErrCollectionNotFound):
+		writeErrors(w, http.StatusNotFound, map[string]string{
+			"_": fmt.Sprintf("Collection with name \"%s\" in scope \"%s\" is not found", collectionName, scopeName),
+		})
+	default:
+		writeErrors(w, http.StatusInternalServerError, map[string]string{
+			"_": err.Error(),
+		})
+	}
+}
+
+// writeManifestUid completes a successful collections change, bumping the
+// config revision so that the new manifest uid is published.
+func (h *mgmtHandler) writeManifestUid(w http.ResponseWriter, bucket *memdtest.Bucket) {
+	h.cluster.ConfigChanged()
+
+	writeJson(w, http.StatusOK, map[string]string{
+		"uid": strconv.FormatUint(bucket.ManifestUid(), 16),
+	})
+}
+
+func (h *mgmtHandler) handleGetCollectionManifest(w http.ResponseWriter, r *http.Request, bucketName string) {
+	bucket := h.findBucket(bucketName)
+	if bucket == nil {
+		writeNotFound(w)
+		return
+	}
+
+	writeJson(w, http.StatusOK, bucket.Manifest())
+}
+
+func (h *mgmtHandler) handleCreateScope(w http.ResponseWriter, r *http.Request, bucketName string) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	bucket := h.findBucket(bucketName)
+	if bucket == nil {
+		writeNotFound(w)
+		return
+	}
+
+	scopeName := r.PostForm.Get("name")
+	if scopeName == "" {
+		writeErrors(w, http.StatusBadRequest, map[string]string{
+			"name": "The value must be supplied",
+		})
+		return
+	}
+
+	_, err := bucket.CreateScope(scopeName)
+	if err != nil {
+		writeCollectionsError(w, err, scopeName, "")
+		return
+	}
+
+	h.writeManifestUid(w, bucket)
+}
+
+func (h *mgmtHandler) handleDeleteScope(w http.ResponseWriter, r *http.Request, bucketName, scopeName string) {
+	bucket := h.findBucket(bucketName)
+	if bucket == nil {
+		writeNotFound(w)
+		return
+	}
+
+	err := bucket.DropScope(scopeName)
+	if err != nil {
+		writeCollectionsError(w, err, scopeName, "")
+		return
+	}
+
+	h.writeManifestUid(w, bucket)
+}
+
+func (h *mgmtHandler) handleCreateCollection(w http.ResponseWriter, r *http.Request, bucketName, scopeName string) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	bucket := h.findBucket(bucketName)
+	if bucket == nil {
+		writeNotFound(w)
+		return
+	}
+
+	collectionName := r.PostForm.Get("name")
+	if collectionName == "" {
+		writeErrors(w, http.StatusBadRequest, map[string]string{
+			"name": "The value must be supplied",
+		})
+		return
+	}
+
+	var maxTTL uint64
+	errs := make(map[string]string)
+	parseFormUint(r.PostForm, "maxTTL", errs, &maxTTL)
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	_, err := bucket.CreateCollection(scopeName, collectionName, uint32(maxTTL))
+	if err != nil {
+		writeCollectionsError(w, err, scopeName, collectionName)
+		return
+	}
+
+	h.writeManifestUid(w, bucket)
+}
+
+func (h *mgmtHandler) handleDeleteCollection(
+	w http.ResponseWriter,
+	r *http.Request,
+	bucketName, scopeName, collectionName string,
+) {
+	bucket := h.findBucket(bucketName)
+	if bucket == nil {
+		writeNotFound(w)
+		return
+	}
+
+	err := bucket.DropCollection(scopeName, collectionName)
+	if err != nil {
+		writeCollectionsError(w, err, scopeName, collectionName)
+		return
+	}
+
+	h.writeManifestUid(w, bucket)
+}
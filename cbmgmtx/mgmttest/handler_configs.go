@@ -0,0 +1,117 @@
+package mgmttest
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (h *mgmtHandler) handleGetClusterConfig(w http.ResponseWriter, r *http.Request) {
+	c := h.cluster
+
+	c.lock.Lock()
+	config := c.fullClusterConfigLocked()
+	c.lock.Unlock()
+
+	writeJson(w, http.StatusOK, config)
+}
+
+func (h *mgmtHandler) handleGetTerseClusterConfig(w http.ResponseWriter, r *http.Request) {
+	c := h.cluster
+
+	c.lock.Lock()
+	config := c.terseClusterConfigLocked(h.node)
+	c.lock.Unlock()
+
+	writeJson(w, http.StatusOK, config)
+}
+
+func (h *mgmtHandler) handleStreamTerseClusterConfig(w http.ResponseWriter, r *http.Request) {
+	c := h.cluster
+
+	h.streamConfigs(w, r, func() (interface{}, <-chan struct{}, bool) {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		return c.terseClusterConfigLocked(h.node), c.changeCh, true
+	})
+}
+
+func (h *mgmtHandler) handleGetTerseBucketConfig(w http.ResponseWriter, r *http.Request, bucketName string) {
+	c := h.cluster
+
+	c.lock.Lock()
+	bucket := c.findBucketLocked(bucketName)
+	if bucket == nil {
+		c.lock.Unlock()
+		writeNotFound(w)
+		return
+	}
+	config := c.terseBucketConfigLocked(bucket, h.node)
+	c.lock.Unlock()
+
+	writeJson(w, http.StatusOK, config)
+}
+
+func (h *mgmtHandler) handleStreamTerseBucketConfig(w http.ResponseWriter, r *http.Request, bucketName string) {
+	c := h.cluster
+
+	h.streamConfigs(w, r, func() (interface{}, <-chan struct{}, bool) {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		bucket := c.findBucketLocked(bucketName)
+		if bucket == nil {
+			return nil, nil, false
+		}
+
+		return c.terseBucketConfigLocked(bucket, h.node), c.changeCh, true
+	})
+}
+
+// streamConfigs writes a new config block each time the topology changes,
+// until the client goes away, the node is removed or getConfig reports that
+// the resource no longer exists.  Like the real server, each block is
+// followed by four newlines.
+func (h *mgmtHandler) streamConfigs(
+	w http.ResponseWriter,
+	r *http.Request,
+	getConfig func() (interface{}, <-chan struct{}, bool),
+) {
+	config, changeCh, ok := getConfig()
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		configBytes, err := json.Marshal(config)
+		if err != nil {
+			return
+		}
+
+		_, err = w.Write(append(configBytes, "\n\n\n\n"...))
+		if err != nil {
+			return
+		}
+
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+
+		select {
+		case <-changeCh:
+		case <-r.Context().Done():
+			return
+		case <-h.node.closeCh:
+			return
+		}
+
+		config, changeCh, ok = getConfig()
+		if !ok {
+			return
+		}
+	}
+}
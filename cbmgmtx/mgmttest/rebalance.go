@@ -0,0 +1,280 @@
+package mgmttest
+
+import (
+	"context"
+	"time"
+)
+
+type RebalanceOptions struct {
+	// EjectNodes lists the nodes which should have all of their vbuckets
+	// moved elsewhere and then be removed from the cluster.
+	EjectNodes []*Node
+
+	// MovesPerRev is the number of vbuckets moved in each new config
+	// revision, defaults to 1.
+	MovesPerRev int
+
+	// MoveDelay is how long to wait after publishing each config revision
+	// before moving the next batch of vbuckets.
+	MoveDelay time.Duration
+
+	// EjectDelay is how long ejected nodes keep answering requests with
+	// NOT_MY_VBUCKET after being removed from the config, before they are
+	// stopped.
+	EjectDelay time.Duration
+}
+
+type vbucketMove struct {
+	Bucket *clusterBucket
+	VbID   int
+	Target []*Node
+}
+
+// RebalanceGradually moves vbuckets one batch at a time until every bucket is
+// spread evenly across the nodes of the cluster, excluding any being ejected.
+// Each batch is published as a new config revision, and between batches the
+// old owners reject requests for moved vbuckets with NOT_MY_VBUCKET and the
+// new config, as a real server does mid-rebalance.  As the nodes share their
+// buckets, no documents are moved or lost.
+//
+// Nodes must not be added or removed while a rebalance is running.
+func (c *Cluster) RebalanceGradually(ctx context.Context, opts *RebalanceOptions) error {
+	if opts == nil {
+		opts = &RebalanceOptions{}
+	}
+
+	movesPerRev := opts.MovesPerRev
+	if movesPerRev <= 0 {
+		movesPerRev = 1
+	}
+
+	moves, err := c.planRebalance(opts.EjectNodes)
+	if err != nil {
+		return err
+	}
+
+	for len(moves) > 0 {
+		batchSize := movesPerRev
+		if batchSize > len(moves) {
+			batchSize = len(moves)
+		}
+
+		c.lock.Lock()
+		for _, move := range moves[:batchSize] {
+			c.applyMoveLocked(move)
+		}
+		c.configChangedLocked()
+		c.lock.Unlock()
+
+		moves = moves[batchSize:]
+
+		if err := sleepContext(ctx, opts.MoveDelay); err != nil {
+			return err
+		}
+	}
+
+	if len(opts.EjectNodes) == 0 {
+		return nil
+	}
+
+	c.lock.Lock()
+	c.removeNodesLocked(opts.EjectNodes)
+	c.configChangedLocked()
+	c.lock.Unlock()
+
+	err = sleepContext(ctx, opts.EjectDelay)
+
+	for _, node := range opts.EjectNodes {
+		node.close()
+	}
+
+	return err
+}
+
+// MoveVbucket makes a node the active owner of a vbucket, promoting it from
+// a replica if it holds one, and publishes a new config revision.
+func (c *Cluster) MoveVbucket(bucketName string, vbID uint16, node *Node) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	bucket := c.findBucketLocked(bucketName)
+	if bucket == nil {
+		return ErrBucketNotFound
+	}
+	if int(vbID) >= len(bucket.VbMap) {
+		return ErrInvalidVbucket
+	}
+
+	nodeIdx := c.nodeIndexLocked(node)
+	if nodeIdx < 0 {
+		return ErrNodeNotFound
+	}
+
+	entry := append([]int{}, bucket.VbMap[vbID]...)
+	for copyIdx := 1; copyIdx < len(entry); copyIdx++ {
+		if entry[copyIdx] == nodeIdx {
+			entry[copyIdx] = entry[0]
+		}
+	}
+	entry[0] = nodeIdx
+
+	c.setVbMapEntryLocked(bucket, int(vbID), entry)
+	c.configChangedLocked()
+
+	return nil
+}
+
+// BumpRevEpoch starts a new config epoch, as happens when the cluster is
+// recovered from a failure which loses the config history.  The revision is
+// restarted, so only clients which compare epochs will accept the new
+// configs.
+func (c *Cluster) BumpRevEpoch() {
+	c.lock.Lock()
+	c.revEpoch++
+	c.rev = 0
+	c.configChangedLocked()
+	c.lock.Unlock()
+}
+
+// planRebalance determines the vbuckets which need to move for every bucket
+// to be balanced across the nodes which are being kept.  Targets are recorded
+// as nodes rather than indexes, since ejecting nodes changes the indexes.
+func (c *Cluster) planRebalance(ejectNodes []*Node) ([]vbucketMove, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, node := range ejectNodes {
+		if c.nodeIndexLocked(node) < 0 {
+			return nil, ErrNodeNotFound
+		}
+	}
+
+	var keepIdxs []int
+	for nodeIdx, node := range c.nodes {
+		if !containsNode(ejectNodes, node) {
+			keepIdxs = append(keepIdxs, nodeIdx)
+		}
+	}
+	if len(keepIdxs) == 0 {
+		return nil, ErrLastNode
+	}
+
+	var moves []vbucketMove
+	for _, bucket := range c.buckets {
+		targetMap := targetVbMap(bucket, keepIdxs)
+		for vbID, targetEntry := range targetMap {
+			if intsEqual(bucket.VbMap[vbID], targetEntry) {
+				continue
+			}
+
+			target := make([]*Node, len(targetEntry))
+			for copyIdx, nodeIdx := range targetEntry {
+				if nodeIdx >= 0 {
+					target[copyIdx] = c.nodes[nodeIdx]
+				}
+			}
+
+			moves = append(moves, vbucketMove{
+				Bucket: bucket,
+				VbID:   vbID,
+				Target: target,
+			})
+		}
+	}
+
+	return moves, nil
+}
+
+func (c *Cluster) applyMoveLocked(move vbucketMove) {
+	// the bucket may have been deleted since the rebalance was planned
+	if c.findBucketLocked(move.Bucket.Bucket.Name()) != move.Bucket {
+		return
+	}
+
+	entry := make([]int, len(move.Target))
+	for copyIdx, node := range move.Target {
+		entry[copyIdx] = -1
+		if node != nil {
+			entry[copyIdx] = c.nodeIndexLocked(node)
+		}
+	}
+
+	c.setVbMapEntryLocked(move.Bucket, move.VbID, entry)
+}
+
+// setVbMapEntryLocked replaces a single entry of a vbucket map.  Configs are
+// serialized outside of the cluster lock, so the map is copied rather than
+// modified in place.
+func (c *Cluster) setVbMapEntryLocked(bucket *clusterBucket, vbID int, entry []int) {
+	vbMap := append([][]int{}, bucket.VbMap...)
+	vbMap[vbID] = entry
+	bucket.VbMap = vbMap
+}
+
+// removeNodesLocked removes nodes from the cluster, updating the indexes in
+// every vbucket map to match.  Any vbucket copies held by the removed nodes
+// are dropped from the maps.
+func (c *Cluster) removeNodesLocked(nodes []*Node) {
+	newIdxs := make([]int, len(c.nodes))
+	var newNodes []*Node
+	for oldIdx, node := range c.nodes {
+		if containsNode(nodes, node) {
+			newIdxs[oldIdx] = -1
+			continue
+		}
+
+		newIdxs[oldIdx] = len(newNodes)
+		newNodes = append(newNodes, node)
+	}
+
+	for _, bucket := range c.buckets {
+		vbMap := make([][]int, len(bucket.VbMap))
+		for vbID, entry := range bucket.VbMap {
+			newEntry := make([]int, len(entry))
+			for copyIdx, nodeIdx := range entry {
+				newEntry[copyIdx] = -1
+				if nodeIdx >= 0 && nodeIdx < len(newIdxs) {
+					newEntry[copyIdx] = newIdxs[nodeIdx]
+				}
+			}
+			vbMap[vbID] = newEntry
+		}
+		bucket.VbMap = vbMap
+	}
+
+	c.nodes = newNodes
+}
+
+func containsNode(nodes []*Node, node *Node) bool {
+	for _, foundNode := range nodes {
+		if foundNode == node {
+			return true
+		}
+	}
+	return false
+}
+
+func intsEqual(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
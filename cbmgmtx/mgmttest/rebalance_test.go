@@ -0,0 +1,180 @@
+package mgmttest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/cbmgmtx"
+)
+
+func createTestAgent(t *testing.T, node *Node) *gocbcorex.Agent {
+	agent, err := gocbcorex.CreateAgent(context.Background(), gocbcorex.AgentOptions{
+		BucketName: testBucket,
+		Authenticator: &gocbcorex.PasswordAuthenticator{
+			Username: testUsername,
+			Password: testPassword,
+		},
+		SeedConfig: gocbcorex.SeedConfig{
+			HTTPAddrs: []string{node.MgmtAddr()},
+		},
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = agent.Close()
+	})
+
+	return agent
+}
+
+func TestMoveVbucket(t *testing.T) {
+	ctx := context.Background()
+	cluster := startTestCluster(t, 2)
+	nodes := cluster.Nodes()
+	mgmt := newTestMgmt(nodes[0])
+
+	// vbucket 0 starts on the first node
+	err := cluster.MoveVbucket(testBucket, 0, nodes[1])
+	require.NoError(t, err)
+
+	config, err := mgmt.GetTerseBucketConfig(ctx, &cbmgmtx.GetTerseBucketConfigOptions{
+		BucketName: testBucket,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, config.VBucketServerMap.VBucketMap[0])
+
+	err = cluster.MoveVbucket(testBucket, 1024, nodes[1])
+	require.ErrorIs(t, err, ErrInvalidVbucket)
+
+	cluster.BumpRevEpoch()
+
+	newConfig, err := mgmt.GetTerseBucketConfig(ctx, &cbmgmtx.GetTerseBucketConfigOptions{
+		BucketName: testBucket,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, config.RevEpoch+1, newConfig.RevEpoch)
+	assert.Less(t, newConfig.Rev, config.Rev)
+}
+
+func TestRebalanceUnderLoad(t *testing.T) {
+	ctx := context.Background()
+	cluster := startTestCluster(t, 1)
+	firstNode := cluster.Nodes()[0]
+	agent := createTestAgent(t, firstNode)
+
+	const numWorkers = 8
+	const keysPerWorker = 16
+
+	stopCh := make(chan struct{})
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var opErrs []error
+	acked := make(map[string][]byte)
+
+	for workerIdx := 0; workerIdx < numWorkers; workerIdx++ {
+		wg.Add(1)
+		go func(workerIdx int) {
+			defer wg.Done()
+
+			for iter := 0; ; iter++ {
+				select {
+				case <-stopCh:
+					return
+				default:
+				}
+
+				key := fmt.Sprintf("worker-%d-key-%d", workerIdx, iter%keysPerWorker)
+				value := []byte(fmt.Sprintf(`{"worker":%d,"iter":%d}`, workerIdx, iter))
+
+				_, err := agent.Upsert(ctx, &gocbcorex.UpsertOptions{
+					Key:            []byte(key),
+					ScopeName:      "_default",
+					CollectionName: "_default",
+					Value:          value,
+				})
+				if err == nil {
+					lock.Lock()
+					acked[key] = value
+					lock.Unlock()
+
+					var res *gocbcorex.GetResult
+					res, err = agent.Get(ctx, &gocbcorex.GetOptions{
+						Key:            []byte(key),
+						ScopeName:      "_default",
+						CollectionName: "_default",
+					})
+					if err == nil && string(res.Value) != string(value) {
+						err = fmt.Errorf("read %s after writing %s to %s", res.Value, value, key)
+					}
+				}
+				if err != nil {
+					lock.Lock()
+					opErrs = append(opErrs, err)
+					lock.Unlock()
+					return
+				}
+			}
+		}(workerIdx)
+	}
+
+	// grow the cluster, move to a new config epoch and then drain and eject
+	// the node the agent originally bootstrapped from, all while the workers
+	// are running.
+	for i := 0; i < 2; i++ {
+		_, err := cluster.AddNode()
+		require.NoError(t, err)
+	}
+
+	err := cluster.RebalanceGradually(ctx, &RebalanceOptions{
+		MovesPerRev: 32,
+		MoveDelay:   2 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	cluster.BumpRevEpoch()
+
+	err = cluster.RebalanceGradually(ctx, &RebalanceOptions{
+		EjectNodes:  []*Node{firstNode},
+		MovesPerRev: 32,
+		MoveDelay:   2 * time.Millisecond,
+		EjectDelay:  50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	close(stopCh)
+	wg.Wait()
+
+	require.Empty(t, opErrs)
+	require.Len(t, acked, numWorkers*keysPerWorker)
+
+	// the fake nodes reject requests for vbuckets they do not own, so every
+	// acknowledged write was applied by its owner.  Check none were lost.
+	for key, value := range acked {
+		res, err := agent.Get(ctx, &gocbcorex.GetOptions{
+			Key:            []byte(key),
+			ScopeName:      "_default",
+			CollectionName: "_default",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, value, res.Value, "value of %s", key)
+	}
+
+	nodes := cluster.Nodes()
+	require.Len(t, nodes, 2)
+
+	config, err := newTestMgmt(nodes[0]).GetTerseBucketConfig(ctx, &cbmgmtx.GetTerseBucketConfigOptions{
+		BucketName: testBucket,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, config.RevEpoch)
+	for vbID, entry := range config.VBucketServerMap.VBucketMap {
+		assert.Equal(t, []int{vbID % 2}, entry)
+	}
+}
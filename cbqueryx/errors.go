@@ -64,6 +64,9 @@ type QueryServerError struct {
 	InnerError error
 	Code       uint32
 	Msg        string
+
+	// Retry indicates that the server considers the query safe to retry.
+	Retry bool
 }
 
 func (e QueryServerError) Error() string {
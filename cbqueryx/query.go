@@ -18,6 +18,9 @@ type Query struct {
 	Endpoint  string
 	Username  string
 	Password  string
+
+	// TraceParent, if set, is sent as the W3C traceparent header of each request.
+	TraceParent string
 }
 
 func (h Query) NewRequest(
@@ -29,6 +32,7 @@ func (h Query) NewRequest(
 		Endpoint:      h.Endpoint,
 		BasicAuthUser: h.Username,
 		BasicAuthPass: h.Password,
+		TraceParent:   h.TraceParent,
 	}.NewRequest(ctx, method, path, contentType, onBehalfOf, body)
 }
 
@@ -48,6 +52,10 @@ type QueryResultStream interface {
 	HasMoreRows() bool
 	ReadRow() (json.RawMessage, error)
 	MetaData() (*QueryMetaData, error)
+
+	// Close releases the response, which is needed when the rows of a query
+	// are not all read.
+	Close() error
 }
 
 func (h Query) Query(ctx context.Context, opts *QueryOptions) (QueryResultStream, error) {
@@ -25,6 +25,7 @@ type queryRespReader struct {
 	statement       string
 	clientContextId string
 	statusCode      int
+	body            io.ReadCloser
 
 	streamer      cbhttpx.RawJsonRowStreamer
 	earlyMetaData *QueryEarlyMetaData
@@ -39,6 +40,7 @@ func newQueryRespReader(resp *http.Response, opts *queryRespReaderOptions) (*que
 		statement:       opts.Statement,
 		clientContextId: opts.ClientContextId,
 		statusCode:      resp.StatusCode,
+		body:            resp.Body,
 	}
 
 	err := r.init(resp)
@@ -168,6 +170,7 @@ func (r *queryRespReader) parseError(errJson *queryErrorJson) *QueryServerError
 		InnerError: err,
 		Code:       errJson.Code,
 		Msg:        errJson.Msg,
+		Retry:      errJson.Retry,
 	}
 }
 
@@ -316,3 +319,7 @@ func (r *queryRespReader) MetaData() (*QueryMetaData, error) {
 
 	return r.metaData, nil
 }
+
+func (r *queryRespReader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,237 @@
+package gocbcorex
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocbcorex/cbmgmtx"
+	"github.com/couchbase/gocbcorex/memdx"
+	"go.uber.org/zap"
+)
+
+type collectionsManifest struct {
+	Rev         uint64
+	Scopes      map[string]struct{}
+	Collections map[string]uint32
+}
+
+func parseCollectionsManifest(manifestBytes []byte) (*collectionsManifest, error) {
+	var manifestJson cbmgmtx.CollectionManifestJson
+	err := json.Unmarshal(manifestBytes, &manifestJson)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestRev, err := strconv.ParseUint(manifestJson.UID, 16, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &collectionsManifest{
+		Rev:         manifestRev,
+		Scopes:      make(map[string]struct{}),
+		Collections: make(map[string]uint32),
+	}
+
+	for _, scope := range manifestJson.Scopes {
+		manifest.Scopes[scope.Name] = struct{}{}
+
+		for _, collection := range scope.Collections {
+			collectionID, err := strconv.ParseUint(collection.UID, 16, 32)
+			if err != nil {
+				return nil, err
+			}
+
+			manifest.Collections[scope.Name+"."+collection.Name] = uint32(collectionID)
+		}
+	}
+
+	return manifest, nil
+}
+
+type collectionsManifestFetch struct {
+	Generation uint64
+	Manifest   *collectionsManifest
+	Err        error
+	DoneCh     chan struct{}
+
+	// Discarded is set when the manifest was invalidated while the fetch was
+	// in flight, in which case its result may predate the invalidation.
+	Discarded bool
+}
+
+type CollectionResolverManifestOptions struct {
+	Logger       *zap.Logger
+	ConnMgr      KvClientManager
+	FetchTimeout time.Duration
+}
+
+// CollectionResolverManifest resolves collection ids by fetching the entire
+// collections manifest from the server and resolving all collections locally.
+// The manifest is only refetched when a collection cannot be found, or when an
+// invalidation is received for a manifest newer than the one we have.
+type CollectionResolverManifest struct {
+	logger       *zap.Logger
+	connMgr      KvClientManager
+	fetchTimeout time.Duration
+
+	manifest AtomicPointer[collectionsManifest]
+
+	lock         sync.Mutex
+	generation   uint64
+	pendingFetch *collectionsManifestFetch
+}
+
+var _ CollectionResolver = (*CollectionResolverManifest)(nil)
+
+func NewCollectionResolverManifest(opts *CollectionResolverManifestOptions) (*CollectionResolverManifest, error) {
+	if opts == nil {
+		opts = &CollectionResolverManifestOptions{}
+	}
+
+	fetchTimeout := opts.FetchTimeout
+	if fetchTimeout <= 0 {
+		fetchTimeout = 10 * time.Second
+	}
+
+	return &CollectionResolverManifest{
+		logger:       loggerOrNop(opts.Logger),
+		connMgr:      opts.ConnMgr,
+		fetchTimeout: fetchTimeout,
+	}, nil
+}
+
+func (cr *CollectionResolverManifest) fetchManifestThread(fetch *collectionsManifestFetch) {
+	ctx, cancel := context.WithTimeout(context.Background(), cr.fetchTimeout)
+	defer cancel()
+
+	manifest, err := OrchestrateRandomMemdClient(
+		ctx, cr.connMgr,
+		func(client KvClient) (*collectionsManifest, error) {
+			manifestBytes, err := client.GetCollectionsManifest(ctx, &memdx.GetCollectionsManifestRequest{})
+			if err != nil {
+				return nil, err
+			}
+
+			return parseCollectionsManifest(manifestBytes)
+		})
+	if err != nil {
+		cr.logger.Debug("failed to fetch collections manifest", zap.Error(err))
+	}
+
+	cr.lock.Lock()
+
+	if fetch.Generation != cr.generation {
+		fetch.Discarded = true
+	} else if err == nil {
+		// we only ever move forward, in case a node which has not yet seen
+		// the latest manifest was the one we happened to ask.
+		oldManifest := cr.manifest.Load()
+		if oldManifest == nil || manifest.Rev >= oldManifest.Rev {
+			cr.manifest.Store(manifest)
+		} else {
+			manifest = oldManifest
+		}
+	}
+
+	fetch.Manifest = manifest
+	fetch.Err = err
+	if cr.pendingFetch == fetch {
+		cr.pendingFetch = nil
+	}
+
+	cr.lock.Unlock()
+
+	close(fetch.DoneCh)
+}
+
+func (cr *CollectionResolverManifest) fetchManifest(ctx context.Context) (*collectionsManifest, error) {
+	for {
+		cr.lock.Lock()
+
+		// a fetch started before the latest invalidation cannot be joined, as
+		// it may already have been answered with the invalidated manifest.
+		fetch := cr.pendingFetch
+		if fetch == nil || fetch.Generation != cr.generation {
+			fetch = &collectionsManifestFetch{
+				Generation: cr.generation,
+				DoneCh:     make(chan struct{}),
+			}
+			cr.pendingFetch = fetch
+
+			go cr.fetchManifestThread(fetch)
+		}
+
+		cr.lock.Unlock()
+
+		select {
+		case <-fetch.DoneCh:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if !fetch.Discarded {
+			return fetch.Manifest, fetch.Err
+		}
+	}
+}
+
+func (cr *CollectionResolverManifest) ResolveCollectionID(
+	ctx context.Context, scopeName, collectionName string,
+) (collectionId uint32, manifestRev uint64, err error) {
+	fullKeyPath := scopeName + "." + collectionName
+
+	manifest := cr.manifest.Load()
+	if manifest != nil {
+		collectionID, wasFound := manifest.Collections[fullKeyPath]
+		if wasFound {
+			return collectionID, manifest.Rev, nil
+		}
+	}
+
+	// either we have no manifest, or the collection was not in it, in which
+	// case it may have been created since we last fetched the manifest.
+	manifest, err = cr.fetchManifest(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	collectionID, wasFound := manifest.Collections[fullKeyPath]
+	if !wasFound {
+		notFoundErr := memdx.ErrUnknownCollectionName
+		if _, hasScope := manifest.Scopes[scopeName]; !hasScope {
+			notFoundErr = memdx.ErrUnknownScopeName
+		}
+
+		return 0, 0, CollectionNotFoundError{
+			CoreError: CoreError{
+				InnerError: notFoundErr,
+				Context:    fullKeyPath,
+			},
+			ManifestUid: manifest.Rev,
+		}
+	}
+
+	return collectionID, manifest.Rev, nil
+}
+
+func (cr *CollectionResolverManifest) InvalidateCollectionID(
+	ctx context.Context, scopeName, collectionName, endpoint string, manifestRev uint64,
+) {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+
+	manifest := cr.manifest.Load()
+	if manifest != nil && manifestRev > 0 && manifestRev <= manifest.Rev {
+		// our manifest is at least as new as the one being invalidated
+		return
+	}
+
+	// dropping the manifest forces the next resolution to refetch it, and
+	// moving to a new generation discards any fetch already in flight.
+	cr.manifest.Store(nil)
+	cr.generation++
+}
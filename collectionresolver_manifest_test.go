@@ -0,0 +1,155 @@
+package gocbcorex
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+func newManifestResolverTestClient(numFetches *uint32, manifests ...string) *KvClientManagerMock {
+	client := &KvClientMock{
+		GetCollectionsManifestFunc: func(ctx context.Context, req *memdx.GetCollectionsManifestRequest) ([]byte, error) {
+			fetchIdx := atomic.AddUint32(numFetches, 1) - 1
+			if int(fetchIdx) >= len(manifests) {
+				fetchIdx = uint32(len(manifests) - 1)
+			}
+			return []byte(manifests[fetchIdx]), nil
+		},
+	}
+
+	return &KvClientManagerMock{
+		GetRandomClientFunc: func(ctx context.Context) (KvClient, error) {
+			return client, nil
+		},
+	}
+}
+
+const testManifestRev1 = `{"uid":"1","scopes":[
+	{"name":"_default","uid":"0","collections":[{"name":"_default","uid":"0"}]},
+	{"name":"inventory","uid":"8","collections":[{"name":"hotels","uid":"a"},{"name":"airlines","uid":"b"}]}
+]}`
+
+const testManifestRev2 = `{"uid":"2","scopes":[
+	{"name":"_default","uid":"0","collections":[{"name":"_default","uid":"0"}]},
+	{"name":"inventory","uid":"8","collections":[{"name":"hotels","uid":"c"},{"name":"airlines","uid":"b"}]}
+]}`
+
+func TestCollectionResolverManifestResolvesLocally(t *testing.T) {
+	var numFetches uint32
+	resolver, err := NewCollectionResolverManifest(&CollectionResolverManifestOptions{
+		ConnMgr: newManifestResolverTestClient(&numFetches, testManifestRev1),
+	})
+	require.NoError(t, err)
+
+	collectionID, manifestRev, err := resolver.ResolveCollectionID(context.Background(), "inventory", "hotels")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0xa), collectionID)
+	assert.Equal(t, uint64(1), manifestRev)
+
+	collectionID, manifestRev, err = resolver.ResolveCollectionID(context.Background(), "inventory", "airlines")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0xb), collectionID)
+	assert.Equal(t, uint64(1), manifestRev)
+
+	collectionID, _, err = resolver.ResolveCollectionID(context.Background(), "_default", "_default")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), collectionID)
+
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&numFetches))
+}
+
+func TestCollectionResolverManifestInvalidate(t *testing.T) {
+	var numFetches uint32
+	resolver, err := NewCollectionResolverManifest(&CollectionResolverManifestOptions{
+		ConnMgr: newManifestResolverTestClient(&numFetches, testManifestRev1, testManifestRev2),
+	})
+	require.NoError(t, err)
+
+	collectionID, _, err := resolver.ResolveCollectionID(context.Background(), "inventory", "hotels")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0xa), collectionID)
+
+	// an invalidation for a manifest we already have should be ignored
+	resolver.InvalidateCollectionID(context.Background(), "inventory", "hotels", "", 1)
+
+	collectionID, _, err = resolver.ResolveCollectionID(context.Background(), "inventory", "hotels")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0xa), collectionID)
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&numFetches))
+
+	resolver.InvalidateCollectionID(context.Background(), "inventory", "hotels", "", 2)
+
+	collectionID, manifestRev, err := resolver.ResolveCollectionID(context.Background(), "inventory", "hotels")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0xc), collectionID)
+	assert.Equal(t, uint64(2), manifestRev)
+	assert.Equal(t, uint32(2), atomic.LoadUint32(&numFetches))
+}
+
+func TestCollectionResolverManifestNotFound(t *testing.T) {
+	var numFetches uint32
+	resolver, err := NewCollectionResolverManifest(&CollectionResolverManifestOptions{
+		ConnMgr: newManifestResolverTestClient(&numFetches, testManifestRev1),
+	})
+	require.NoError(t, err)
+
+	_, _, err = resolver.ResolveCollectionID(context.Background(), "inventory", "missing")
+	assert.ErrorIs(t, err, memdx.ErrUnknownCollectionName)
+
+	_, _, err = resolver.ResolveCollectionID(context.Background(), "missing", "hotels")
+	assert.ErrorIs(t, err, memdx.ErrUnknownScopeName)
+}
+
+func TestCollectionResolverManifestInvalidateDuringFetch(t *testing.T) {
+	var numFetches uint32
+	fetchStartedCh := make(chan struct{}, 2)
+	releaseFetchCh := make(chan struct{})
+	manifests := []string{testManifestRev1, testManifestRev2}
+	client := &KvClientMock{
+		GetCollectionsManifestFunc: func(ctx context.Context, req *memdx.GetCollectionsManifestRequest) ([]byte, error) {
+			fetchIdx := atomic.AddUint32(&numFetches, 1) - 1
+			fetchStartedCh <- struct{}{}
+			if fetchIdx == 0 {
+				<-releaseFetchCh
+			}
+			return []byte(manifests[fetchIdx]), nil
+		},
+	}
+
+	resolver, err := NewCollectionResolverManifest(&CollectionResolverManifestOptions{
+		ConnMgr: &KvClientManagerMock{
+			GetRandomClientFunc: func(ctx context.Context) (KvClient, error) {
+				return client, nil
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	type resolveResult struct {
+		CollectionID uint32
+		ManifestRev  uint64
+		Err          error
+	}
+	resultCh := make(chan resolveResult, 1)
+	go func() {
+		collectionID, manifestRev, err := resolver.ResolveCollectionID(context.Background(), "inventory", "hotels")
+		resultCh <- resolveResult{collectionID, manifestRev, err}
+	}()
+
+	// the fetch in flight was answered before the invalidation, so its
+	// manifest must not be used.
+	<-fetchStartedCh
+	resolver.InvalidateCollectionID(context.Background(), "inventory", "hotels", "", 2)
+	close(releaseFetchCh)
+
+	res := <-resultCh
+	require.NoError(t, res.Err)
+	assert.Equal(t, uint32(0xc), res.CollectionID)
+	assert.Equal(t, uint64(2), res.ManifestRev)
+	assert.Equal(t, uint32(2), atomic.LoadUint32(&numFetches))
+}
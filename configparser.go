@@ -13,7 +13,7 @@ func parseConfigHostname(hostname string, sourceHostname string) string {
 		return sourceHostname
 	}
 
-	if strings.Contains(hostname, ":") {
+	if strings.Contains(hostname, ":") && !strings.HasPrefix(hostname, "[") {
 		// this appears to be an IPv6 address, wrap it for everyone else
 		return "[" + hostname + "]"
 	}
@@ -15,6 +15,9 @@ type CrudComponent struct {
 	connManager KvClientManager
 	compression CompressionManager
 	vbs         VbucketRouter
+	tracer      RequestTracer
+	meter       Meter
+	thresholds  *ThresholdLogger
 }
 
 func OrchestrateSimpleCrud[RespT any](
@@ -24,23 +27,46 @@ func OrchestrateSimpleCrud[RespT any](
 	vb VbucketRouter,
 	ch NotMyVbucketConfigHandler,
 	nkcp KvClientManager,
+	meter Meter,
+	thresholds *ThresholdLogger,
+	opCode memdx.OpCode,
 	scopeName, collectionName string,
 	key []byte,
 	fn func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (RespT, error),
 ) (RespT, error) {
-	return OrchestrateMemdRetries(
+	// we keep track of where the last attempt was sent, to describe it in the
+	// error if the operation fails.
+	kvErr := &KeyValueError{
+		OpCode:         opCode,
+		DocumentKey:    key,
+		ScopeName:      scopeName,
+		CollectionName: collectionName,
+	}
+
+	ctx = withOperationMeter(ctx, meter, meterServiceKv, opCode.String())
+	ctx = withThresholdLogging(ctx, thresholds, meterServiceKv, opCode.String())
+
+	res, err := OrchestrateMemdRetries(
 		ctx, rs,
 		func() (RespT, error) {
 			return OrchestrateMemdCollectionID(
 				ctx, cr, scopeName, collectionName,
 				func(collectionID uint32, manifestID uint64) (RespT, error) {
+					kvErr.CollectionID = collectionID
 					return OrchestrateMemdRouting(ctx, vb, ch, key, 0, func(endpoint string, vbID uint16) (RespT, error) {
+						kvErr.VbucketID = vbID
+						kvErr.Endpoint = endpoint
 						return OrchestrateMemdClient(ctx, nkcp, endpoint, func(client KvClient) (RespT, error) {
 							return fn(collectionID, manifestID, endpoint, vbID, client)
 						})
 					})
 				})
 		})
+	if err != nil {
+		return res, kvErr.withCause(err)
+	}
+
+	return res, nil
 }
 
 type GetOptions struct {
@@ -48,6 +74,7 @@ type GetOptions struct {
 	ScopeName      string
 	CollectionName string
 	OnBehalfOf     string
+	RetryManager   RetryManager
 }
 
 type GetResult struct {
@@ -55,11 +82,16 @@ type GetResult struct {
 	Flags    uint32
 	Datatype memdx.DatatypeFlag
 	Cas      uint64
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) Get(ctx context.Context, opts *GetOptions) (*GetResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "get")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeGet,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*GetResult, error) {
 			resp, err := client.Get(ctx, &memdx.GetRequest{
@@ -82,6 +114,7 @@ func (cc *CrudComponent) Get(ctx context.Context, opts *GetOptions) (*GetResult,
 				Flags:    resp.Flags,
 				Datatype: datatype,
 				Cas:      resp.Cas,
+				Meta:     resp.Meta,
 			}, nil
 		})
 }
@@ -91,6 +124,7 @@ type GetReplicaOptions struct {
 	ScopeName      string
 	CollectionName string
 	ReplicaIdx     uint32
+	RetryManager   RetryManager
 }
 
 type GetReplicaResult struct {
@@ -98,9 +132,14 @@ type GetReplicaResult struct {
 	Flags    uint32
 	Datatype memdx.DatatypeFlag
 	Cas      uint64
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) GetReplica(ctx context.Context, opts *GetReplicaOptions) (*GetReplicaResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "get_replica")
+	defer span.End()
+
 	fn := func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*GetReplicaResult, error) {
 		resp, err := client.GetReplica(ctx, &memdx.GetReplicaRequest{
 			CollectionID: collectionID,
@@ -121,22 +160,41 @@ func (cc *CrudComponent) GetReplica(ctx context.Context, opts *GetReplicaOptions
 			Flags:    resp.Flags,
 			Datatype: datatype,
 			Cas:      resp.Cas,
+			Meta:     resp.Meta,
 		}, nil
 	}
 
-	return OrchestrateMemdRetries(
-		ctx, cc.retries,
+	kvErr := &KeyValueError{
+		OpCode:         memdx.OpCodeGetReplica,
+		DocumentKey:    opts.Key,
+		ScopeName:      opts.ScopeName,
+		CollectionName: opts.CollectionName,
+	}
+
+	ctx = withOperationMeter(ctx, cc.meter, meterServiceKv, memdx.OpCodeGetReplica.String())
+	ctx = withThresholdLogging(ctx, cc.thresholds, meterServiceKv, memdx.OpCodeGetReplica.String())
+
+	res, err := OrchestrateMemdRetries(
+		ctx, cc.retriesFor(opts.RetryManager),
 		func() (*GetReplicaResult, error) {
 			return OrchestrateMemdCollectionID(
 				ctx, cc.collections, opts.ScopeName, opts.CollectionName,
 				func(collectionID uint32, manifestID uint64) (*GetReplicaResult, error) {
+					kvErr.CollectionID = collectionID
 					return OrchestrateMemdRouting(ctx, cc.vbs, cc.nmvHandler, opts.Key, opts.ReplicaIdx, func(endpoint string, vbID uint16) (*GetReplicaResult, error) {
+						kvErr.VbucketID = vbID
+						kvErr.Endpoint = endpoint
 						return OrchestrateMemdClient(ctx, cc.connManager, endpoint, func(client KvClient) (*GetReplicaResult, error) {
 							return fn(collectionID, manifestID, endpoint, vbID, client)
 						})
 					})
 				})
 		})
+	if err != nil {
+		return nil, kvErr.withCause(err)
+	}
+
+	return res, nil
 }
 
 type UpsertOptions struct {
@@ -150,17 +208,35 @@ type UpsertOptions struct {
 	PreserveExpiry  bool
 	Cas             uint64
 	DurabilityLevel memdx.DurabilityLevel
+	PersistTo       uint32
+	ReplicateTo     uint32
 	OnBehalfOf      string
+	RetryManager    RetryManager
 }
 
 type UpsertResult struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) Upsert(ctx context.Context, opts *UpsertOptions) (*UpsertResult, error) {
-	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "upsert")
+	defer span.End()
+
+	err := validateLegacyDurability(opts.DurabilityLevel, opts.PersistTo, opts.ReplicateTo)
+	if err != nil {
+		return nil, (&KeyValueError{
+			OpCode:         memdx.OpCodeSet,
+			DocumentKey:    opts.Key,
+			ScopeName:      opts.ScopeName,
+			CollectionName: opts.CollectionName,
+		}).withCause(err)
+	}
+
+	res, err := OrchestrateSimpleCrud(
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeSet,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*UpsertResult, error) {
 			value, datatype, err := cc.compression.Compress(client.HasFeature(memdx.HelloFeatureSnappy), opts.Datatype, opts.Value)
@@ -192,8 +268,38 @@ func (cc *CrudComponent) Upsert(ctx context.Context, opts *UpsertOptions) (*Upse
 					VbUuid: resp.MutationToken.VbUuid,
 					SeqNo:  resp.MutationToken.SeqNo,
 				},
+				Meta: resp.Meta,
 			}, nil
 		})
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.PersistTo > 0 || opts.ReplicateTo > 0 {
+		err := cc.waitForLegacyDurability(ctx, &LegacyDurabilityOptions{
+			Key:           opts.Key,
+			MutationToken: res.MutationToken,
+			PersistTo:     opts.PersistTo,
+			ReplicateTo:   opts.ReplicateTo,
+			OnBehalfOf:    opts.OnBehalfOf,
+		})
+		if err != nil {
+			// the mutation itself was applied, so its result is still returned
+			return res, (&KeyValueError{
+				OpCode:         memdx.OpCodeSet,
+				DocumentKey:    opts.Key,
+				ScopeName:      opts.ScopeName,
+				CollectionName: opts.CollectionName,
+				VbucketID:      res.MutationToken.VbID,
+			}).withCause(LegacyDurabilityError{
+				Cause:         err,
+				Cas:           res.Cas,
+				MutationToken: res.MutationToken,
+			})
+		}
+	}
+
+	return res, nil
 }
 
 type DeleteOptions struct {
@@ -202,17 +308,35 @@ type DeleteOptions struct {
 	CollectionName  string
 	Cas             uint64
 	DurabilityLevel memdx.DurabilityLevel
+	PersistTo       uint32
+	ReplicateTo     uint32
 	OnBehalfOf      string
+	RetryManager    RetryManager
 }
 
 type DeleteResult struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) Delete(ctx context.Context, opts *DeleteOptions) (*DeleteResult, error) {
-	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "delete")
+	defer span.End()
+
+	err := validateLegacyDurability(opts.DurabilityLevel, opts.PersistTo, opts.ReplicateTo)
+	if err != nil {
+		return nil, (&KeyValueError{
+			OpCode:         memdx.OpCodeDelete,
+			DocumentKey:    opts.Key,
+			ScopeName:      opts.ScopeName,
+			CollectionName: opts.CollectionName,
+		}).withCause(err)
+	}
+
+	res, err := OrchestrateSimpleCrud(
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeDelete,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*DeleteResult, error) {
 			resp, err := client.Delete(ctx, &memdx.DeleteRequest{
@@ -234,8 +358,38 @@ func (cc *CrudComponent) Delete(ctx context.Context, opts *DeleteOptions) (*Dele
 					VbUuid: resp.MutationToken.VbUuid,
 					SeqNo:  resp.MutationToken.SeqNo,
 				},
+				Meta: resp.Meta,
 			}, nil
 		})
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.PersistTo > 0 || opts.ReplicateTo > 0 {
+		err := cc.waitForLegacyDurability(ctx, &LegacyDurabilityOptions{
+			Key:           opts.Key,
+			MutationToken: res.MutationToken,
+			PersistTo:     opts.PersistTo,
+			ReplicateTo:   opts.ReplicateTo,
+			OnBehalfOf:    opts.OnBehalfOf,
+		})
+		if err != nil {
+			// the mutation itself was applied, so its result is still returned
+			return res, (&KeyValueError{
+				OpCode:         memdx.OpCodeDelete,
+				DocumentKey:    opts.Key,
+				ScopeName:      opts.ScopeName,
+				CollectionName: opts.CollectionName,
+				VbucketID:      res.MutationToken.VbID,
+			}).withCause(LegacyDurabilityError{
+				Cause:         err,
+				Cas:           res.Cas,
+				MutationToken: res.MutationToken,
+			})
+		}
+	}
+
+	return res, nil
 }
 
 type GetAndTouchOptions struct {
@@ -244,6 +398,7 @@ type GetAndTouchOptions struct {
 	CollectionName string
 	Expiry         uint32
 	OnBehalfOf     string
+	RetryManager   RetryManager
 }
 
 type GetAndTouchResult struct {
@@ -251,11 +406,16 @@ type GetAndTouchResult struct {
 	Flags    uint32
 	Datatype memdx.DatatypeFlag
 	Cas      uint64
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) GetAndTouch(ctx context.Context, opts *GetAndTouchOptions) (*GetAndTouchResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "get_and_touch")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeGAT,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*GetAndTouchResult, error) {
 			resp, err := client.GetAndTouch(ctx, &memdx.GetAndTouchRequest{
@@ -279,6 +439,7 @@ func (cc *CrudComponent) GetAndTouch(ctx context.Context, opts *GetAndTouchOptio
 				Flags:    resp.Flags,
 				Datatype: datatype,
 				Cas:      resp.Cas,
+				Meta:     resp.Meta,
 			}, nil
 		})
 }
@@ -287,6 +448,7 @@ type GetRandomOptions struct {
 	ScopeName      string
 	CollectionName string
 	OnBehalfOf     string
+	RetryManager   RetryManager
 }
 
 type GetRandomResult struct {
@@ -295,11 +457,16 @@ type GetRandomResult struct {
 	Flags    uint32
 	Datatype memdx.DatatypeFlag
 	Cas      uint64
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) GetRandom(ctx context.Context, opts *GetRandomOptions) (*GetRandomResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "get_random")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeGetRandom,
 		opts.ScopeName, opts.CollectionName, nil,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*GetRandomResult, error) {
 			resp, err := client.GetRandom(ctx, &memdx.GetRandomRequest{
@@ -321,6 +488,7 @@ func (cc *CrudComponent) GetRandom(ctx context.Context, opts *GetRandomOptions)
 				Datatype: datatype,
 				Cas:      resp.Cas,
 				Key:      resp.Key,
+				Meta:     resp.Meta,
 			}, nil
 		})
 }
@@ -331,15 +499,21 @@ type UnlockOptions struct {
 	CollectionName string
 	Cas            uint64
 	OnBehalfOf     string
+	RetryManager   RetryManager
 }
 
 type UnlockResult struct {
 	MutationToken MutationToken
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) Unlock(ctx context.Context, opts *UnlockOptions) (*UnlockResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "unlock")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeUnlockKey,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*UnlockResult, error) {
 			resp, err := client.Unlock(ctx, &memdx.UnlockRequest{
@@ -358,6 +532,7 @@ func (cc *CrudComponent) Unlock(ctx context.Context, opts *UnlockOptions) (*Unlo
 					VbUuid: resp.MutationToken.VbUuid,
 					SeqNo:  resp.MutationToken.SeqNo,
 				},
+				Meta: resp.Meta,
 			}, nil
 		})
 }
@@ -368,15 +543,21 @@ type TouchOptions struct {
 	CollectionName string
 	Expiry         uint32
 	OnBehalfOf     string
+	RetryManager   RetryManager
 }
 
 type TouchResult struct {
 	Cas uint64
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) Touch(ctx context.Context, opts *TouchOptions) (*TouchResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "touch")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeTouch,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*TouchResult, error) {
 			resp, err := client.Touch(ctx, &memdx.TouchRequest{
@@ -391,7 +572,8 @@ func (cc *CrudComponent) Touch(ctx context.Context, opts *TouchOptions) (*TouchR
 			}
 
 			return &TouchResult{
-				Cas: resp.Cas,
+				Cas:  resp.Cas,
+				Meta: resp.Meta,
 			}, nil
 		})
 }
@@ -403,6 +585,7 @@ type GetAndLockOptions struct {
 	ScopeName      string
 	CollectionID   uint32
 	OnBehalfOf     string
+	RetryManager   RetryManager
 }
 
 type GetAndLockResult struct {
@@ -410,10 +593,15 @@ type GetAndLockResult struct {
 	Flags    uint32
 	Datatype memdx.DatatypeFlag
 	Cas      uint64
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) GetAndLock(ctx context.Context, opts *GetAndLockOptions) (*GetAndLockResult, error) {
-	return OrchestrateSimpleCrud(ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, opts.ScopeName, opts.CollectionName, opts.Key,
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "get_and_lock")
+	defer span.End()
+
+	return OrchestrateSimpleCrud(ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeGetLocked, opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*GetAndLockResult, error) {
 			resp, err := client.GetAndLock(ctx, &memdx.GetAndLockRequest{
 				CollectionID: collectionID,
@@ -436,6 +624,7 @@ func (cc *CrudComponent) GetAndLock(ctx context.Context, opts *GetAndLockOptions
 				Value:    value,
 				Datatype: datatype,
 				Flags:    resp.Flags,
+				Meta:     resp.Meta,
 			}, nil
 		})
 }
@@ -450,16 +639,22 @@ type AddOptions struct {
 	Expiry          uint32
 	DurabilityLevel memdx.DurabilityLevel
 	OnBehalfOf      string
+	RetryManager    RetryManager
 }
 
 type AddResult struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) Add(ctx context.Context, opts *AddOptions) (*AddResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "add")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeAdd,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*AddResult, error) {
 			value, datatype, err := cc.compression.Compress(client.HasFeature(memdx.HelloFeatureSnappy), opts.Datatype, opts.Value)
@@ -489,6 +684,7 @@ func (cc *CrudComponent) Add(ctx context.Context, opts *AddOptions) (*AddResult,
 					VbUuid: resp.MutationToken.VbUuid,
 					SeqNo:  resp.MutationToken.SeqNo,
 				},
+				Meta: resp.Meta,
 			}, nil
 		})
 }
@@ -505,16 +701,22 @@ type ReplaceOptions struct {
 	Cas             uint64
 	DurabilityLevel memdx.DurabilityLevel
 	OnBehalfOf      string
+	RetryManager    RetryManager
 }
 
 type ReplaceResult struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) Replace(ctx context.Context, opts *ReplaceOptions) (*ReplaceResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "replace")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeReplace,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*ReplaceResult, error) {
 			value, datatype, err := cc.compression.Compress(client.HasFeature(memdx.HelloFeatureSnappy), opts.Datatype, opts.Value)
@@ -546,6 +748,7 @@ func (cc *CrudComponent) Replace(ctx context.Context, opts *ReplaceOptions) (*Re
 					VbUuid: resp.MutationToken.VbUuid,
 					SeqNo:  resp.MutationToken.SeqNo,
 				},
+				Meta: resp.Meta,
 			}, nil
 		})
 }
@@ -558,16 +761,22 @@ type AppendOptions struct {
 	Cas             uint64
 	DurabilityLevel memdx.DurabilityLevel
 	OnBehalfOf      string
+	RetryManager    RetryManager
 }
 
 type AppendResult struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) Append(ctx context.Context, opts *AppendOptions) (*AppendResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "append")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeAppend,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*AppendResult, error) {
 			value, datatype, err := cc.compression.Compress(client.HasFeature(memdx.HelloFeatureSnappy), 0, opts.Value)
@@ -596,6 +805,7 @@ func (cc *CrudComponent) Append(ctx context.Context, opts *AppendOptions) (*Appe
 					VbUuid: resp.MutationToken.VbUuid,
 					SeqNo:  resp.MutationToken.SeqNo,
 				},
+				Meta: resp.Meta,
 			}, nil
 		})
 }
@@ -608,16 +818,22 @@ type PrependOptions struct {
 	Cas             uint64
 	DurabilityLevel memdx.DurabilityLevel
 	OnBehalfOf      string
+	RetryManager    RetryManager
 }
 
 type PrependResult struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) Prepend(ctx context.Context, opts *PrependOptions) (*PrependResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "prepend")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodePrepend,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*PrependResult, error) {
 			value, datatype, err := cc.compression.Compress(client.HasFeature(memdx.HelloFeatureSnappy), 0, opts.Value)
@@ -646,6 +862,7 @@ func (cc *CrudComponent) Prepend(ctx context.Context, opts *PrependOptions) (*Pr
 					VbUuid: resp.MutationToken.VbUuid,
 					SeqNo:  resp.MutationToken.SeqNo,
 				},
+				Meta: resp.Meta,
 			}, nil
 		})
 }
@@ -660,17 +877,23 @@ type IncrementOptions struct {
 	Expiry          uint32
 	DurabilityLevel memdx.DurabilityLevel
 	OnBehalfOf      string
+	RetryManager    RetryManager
 }
 
 type IncrementResult struct {
 	Cas           uint64
 	Value         uint64
 	MutationToken MutationToken
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) Increment(ctx context.Context, opts *IncrementOptions) (*IncrementResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "increment")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeIncrement,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*IncrementResult, error) {
 			resp, err := client.Increment(ctx, &memdx.IncrementRequest{
@@ -695,6 +918,7 @@ func (cc *CrudComponent) Increment(ctx context.Context, opts *IncrementOptions)
 					VbUuid: resp.MutationToken.VbUuid,
 					SeqNo:  resp.MutationToken.SeqNo,
 				},
+				Meta: resp.Meta,
 			}, nil
 		})
 }
@@ -709,17 +933,23 @@ type DecrementOptions struct {
 	Expiry          uint32
 	DurabilityLevel memdx.DurabilityLevel
 	OnBehalfOf      string
+	RetryManager    RetryManager
 }
 
 type DecrementResult struct {
 	Cas           uint64
 	Value         uint64
 	MutationToken MutationToken
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) Decrement(ctx context.Context, opts *DecrementOptions) (*DecrementResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "decrement")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeDecrement,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*DecrementResult, error) {
 			resp, err := client.Decrement(ctx, &memdx.DecrementRequest{
@@ -744,6 +974,7 @@ func (cc *CrudComponent) Decrement(ctx context.Context, opts *DecrementOptions)
 					VbUuid: resp.MutationToken.VbUuid,
 					SeqNo:  resp.MutationToken.SeqNo,
 				},
+				Meta: resp.Meta,
 			}, nil
 		})
 }
@@ -753,6 +984,7 @@ type GetMetaOptions struct {
 	ScopeName      string
 	CollectionName string
 	OnBehalfOf     string
+	RetryManager   RetryManager
 }
 
 type GetMetaResult struct {
@@ -763,11 +995,16 @@ type GetMetaResult struct {
 	SeqNo    uint64
 	Datatype memdx.DatatypeFlag
 	Deleted  bool
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) GetMeta(ctx context.Context, opts *GetMetaOptions) (*GetMetaResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "get_meta")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeGetMeta,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*GetMetaResult, error) {
 			resp, err := client.GetMeta(ctx, &memdx.GetMetaRequest{
@@ -788,6 +1025,7 @@ func (cc *CrudComponent) GetMeta(ctx context.Context, opts *GetMetaOptions) (*Ge
 				SeqNo:    resp.SeqNo,
 				Datatype: memdx.DatatypeFlag(resp.Datatype),
 				Deleted:  resp.Deleted,
+				Meta:     resp.Meta,
 			}, nil
 		})
 }
@@ -805,16 +1043,22 @@ type SetMetaOptions struct {
 	Cas            uint64
 	Options        uint32
 	OnBehalfOf     string
+	RetryManager   RetryManager
 }
 
 type SetMetaResult struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) SetMeta(ctx context.Context, opts *SetMetaOptions) (*SetMetaResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "set_meta")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeSetMeta,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*SetMetaResult, error) {
 			resp, err := client.SetMeta(ctx, &memdx.SetMetaRequest{
@@ -842,6 +1086,7 @@ func (cc *CrudComponent) SetMeta(ctx context.Context, opts *SetMetaOptions) (*Se
 					VbUuid: resp.MutationToken.VbUuid,
 					SeqNo:  resp.MutationToken.SeqNo,
 				},
+				Meta: resp.Meta,
 			}, nil
 		})
 }
@@ -857,16 +1102,22 @@ type DeleteMetaOptions struct {
 	Cas            uint64
 	Options        uint32
 	OnBehalfOf     string
+	RetryManager   RetryManager
 }
 
 type DeleteMetaResult struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) DeleteMeta(ctx context.Context, opts *DeleteMetaOptions) (*DeleteMetaResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "delete_meta")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeDelMeta,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*DeleteMetaResult, error) {
 			resp, err := client.DeleteMeta(ctx, &memdx.DeleteMetaRequest{
@@ -892,6 +1143,7 @@ func (cc *CrudComponent) DeleteMeta(ctx context.Context, opts *DeleteMetaOptions
 					VbUuid: resp.MutationToken.VbUuid,
 					SeqNo:  resp.MutationToken.SeqNo,
 				},
+				Meta: resp.Meta,
 			}, nil
 		})
 }
@@ -903,17 +1155,23 @@ type LookupInOptions struct {
 	Ops            []memdx.LookupInOp
 	Flags          memdx.SubdocDocFlag
 	OnBehalfOf     string
+	RetryManager   RetryManager
 }
 
 type LookupInResult struct {
 	Ops          []memdx.SubDocResult
 	DocIsDeleted bool
 	Cas          uint64
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) LookupIn(ctx context.Context, opts *LookupInOptions) (*LookupInResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "lookup_in")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeSubDocMultiLookup,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*LookupInResult, error) {
 			resp, err := client.LookupIn(ctx, &memdx.LookupInRequest{
@@ -932,6 +1190,7 @@ func (cc *CrudComponent) LookupIn(ctx context.Context, opts *LookupInOptions) (*
 				Ops:          resp.Ops,
 				DocIsDeleted: resp.DocIsDeleted,
 				Cas:          resp.Cas,
+				Meta:         resp.Meta,
 			}, nil
 		})
 }
@@ -947,17 +1206,23 @@ type MutateInOptions struct {
 	Cas             uint64
 	DurabilityLevel memdx.DurabilityLevel
 	OnBehalfOf      string
+	RetryManager    RetryManager
 }
 
 type MutateInResult struct {
 	Cas           uint64
 	Ops           []memdx.SubDocResult
 	MutationToken MutationToken
+
+	Meta ResultMeta
 }
 
 func (cc *CrudComponent) MutateIn(ctx context.Context, opts *MutateInOptions) (*MutateInResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "mutate_in")
+	defer span.End()
+
 	return OrchestrateSimpleCrud(
-		ctx, cc.retries, cc.collections, cc.vbs, cc.nmvHandler, cc.connManager,
+		ctx, cc.retriesFor(opts.RetryManager), cc.collections, cc.vbs, cc.nmvHandler, cc.connManager, cc.meter, cc.thresholds, memdx.OpCodeSubDocMultiMutation,
 		opts.ScopeName, opts.CollectionName, opts.Key,
 		func(collectionID uint32, manifestID uint64, endpoint string, vbID uint16, client KvClient) (*MutateInResult, error) {
 			resp, err := client.MutateIn(ctx, &memdx.MutateInRequest{
@@ -984,6 +1249,16 @@ func (cc *CrudComponent) MutateIn(ctx context.Context, opts *MutateInOptions) (*
 					VbUuid: resp.MutationToken.VbUuid,
 					SeqNo:  resp.MutationToken.SeqNo,
 				},
+				Meta: resp.Meta,
 			}, nil
 		})
 }
+
+// retriesFor returns the RetryManager for an operation, which is the one the
+// operation specified, or otherwise the component's.
+func (cc *CrudComponent) retriesFor(retries RetryManager) RetryManager {
+	if retries != nil {
+		return retries
+	}
+	return cc.retries
+}
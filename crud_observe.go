@@ -0,0 +1,163 @@
+package gocbcorex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+var legacyDurabilityBackoff = ExponentialBackoff(5*time.Millisecond, 500*time.Millisecond, 2)
+
+func validateLegacyDurability(level memdx.DurabilityLevel, persistTo, replicateTo uint32) error {
+	if level > 0 && (persistTo > 0 || replicateTo > 0) {
+		return invalidArgumentError{"cannot use both a durability level and persistTo/replicateTo"}
+	}
+
+	return nil
+}
+
+type LegacyDurabilityOptions struct {
+	Key           []byte
+	MutationToken MutationToken
+	PersistTo     uint32
+	ReplicateTo   uint32
+	OnBehalfOf    string
+}
+
+// WaitForLegacyDurability polls the active and replica nodes for the vbucket of a
+// mutation until it has been persisted to PersistTo nodes and replicated to
+// ReplicateTo replicas.  This is intended for use on clusters or buckets which
+// do not support synchronous replication.
+func (cc *CrudComponent) WaitForLegacyDurability(ctx context.Context, opts *LegacyDurabilityOptions) error {
+	err := cc.waitForLegacyDurability(ctx, opts)
+	if err != nil {
+		return (&KeyValueError{
+			OpCode:      memdx.OpCodeObserveSeqNo,
+			DocumentKey: opts.Key,
+			VbucketID:   opts.MutationToken.VbID,
+		}).withCause(err)
+	}
+
+	return nil
+}
+
+func (cc *CrudComponent) waitForLegacyDurability(ctx context.Context, opts *LegacyDurabilityOptions) error {
+	numServers, numAssigned, numAssignedReplicas, err := cc.serversForKey(opts.Key)
+	if err != nil {
+		return err
+	}
+
+	// servers which are not assigned, such as after a failover, can never
+	// satisfy the requirements, so waiting for them would only time out.
+	if opts.PersistTo > numAssigned || opts.ReplicateTo > numAssignedReplicas {
+		return ErrDurabilityImpossible
+	}
+
+	for attempt := uint32(0); ; attempt++ {
+		numPersisted, numReplicated, err := cc.observeSeqNoAll(ctx, numServers, opts)
+		if err != nil {
+			return err
+		}
+
+		if numPersisted >= opts.PersistTo && numReplicated >= opts.ReplicateTo {
+			return nil
+		}
+
+		select {
+		case <-time.After(legacyDurabilityBackoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// serversForKey returns the number of servers (active plus replicas) the
+// vbucket for a key is configured to have, along with how many of them, and
+// how many of the replicas alone, currently have a server assigned.
+func (cc *CrudComponent) serversForKey(key []byte) (uint32, uint32, uint32, error) {
+	var numAssigned, numAssignedReplicas uint32
+	for replicaIdx := uint32(0); ; replicaIdx++ {
+		_, _, err := cc.vbs.DispatchByKey(key, replicaIdx)
+		if err != nil {
+			if errors.Is(err, ErrInvalidReplica) {
+				return replicaIdx, numAssigned, numAssignedReplicas, nil
+			} else if errors.Is(err, ErrNoServerAssigned) {
+				continue
+			}
+
+			return 0, 0, 0, err
+		}
+
+		numAssigned++
+		if replicaIdx > 0 {
+			numAssignedReplicas++
+		}
+	}
+}
+
+func (cc *CrudComponent) observeSeqNoAll(ctx context.Context, numServers uint32, opts *LegacyDurabilityOptions) (uint32, uint32, error) {
+	type observeResult struct {
+		Resp *memdx.ObserveSeqNoResponse
+		Err  error
+	}
+
+	results := make([]observeResult, numServers)
+
+	var wg sync.WaitGroup
+	for replicaIdx := uint32(0); replicaIdx < numServers; replicaIdx++ {
+		wg.Add(1)
+		go func(replicaIdx uint32) {
+			defer wg.Done()
+
+			resp, err := OrchestrateMemdRouting(ctx, cc.vbs, cc.nmvHandler, opts.Key, replicaIdx,
+				func(endpoint string, vbID uint16) (*memdx.ObserveSeqNoResponse, error) {
+					return OrchestrateMemdClient(ctx, cc.connManager, endpoint, func(client KvClient) (*memdx.ObserveSeqNoResponse, error) {
+						return client.ObserveSeqNo(ctx, &memdx.ObserveSeqNoRequest{
+							VbucketID:  vbID,
+							VbUuid:     opts.MutationToken.VbUuid,
+							OnBehalfOf: opts.OnBehalfOf,
+						})
+					})
+				})
+			results[replicaIdx] = observeResult{
+				Resp: resp,
+				Err:  err,
+			}
+		}(replicaIdx)
+	}
+	wg.Wait()
+
+	var numPersisted, numReplicated uint32
+	for replicaIdx, result := range results {
+		if result.Err != nil {
+			// a node failing to respond simply means it does not count towards
+			// the durability requirements on this attempt.
+			cc.logger.Debug("failed to observe seqno",
+				zap.Int("replicaIdx", replicaIdx),
+				zap.Error(result.Err))
+			continue
+		}
+
+		resp := result.Resp
+		if resp.DidFailover && resp.OldVbUuid == opts.MutationToken.VbUuid &&
+			resp.LastSeqNo < opts.MutationToken.SeqNo {
+			// the vbucket failed over before our mutation reached the node
+			// which was promoted, so the mutation can never become durable.
+			return 0, 0, ErrMutationLost
+		}
+
+		if resp.PersistSeqNo >= opts.MutationToken.SeqNo {
+			numPersisted++
+		}
+		if replicaIdx > 0 && resp.CurrentSeqNo >= opts.MutationToken.SeqNo {
+			numReplicated++
+		}
+	}
+
+	return numPersisted, numReplicated, nil
+}
@@ -0,0 +1,183 @@
+package gocbcorex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+func newLegacyDurabilityTestCrud(seqNos map[string]*memdx.ObserveSeqNoResponse) *CrudComponent {
+	endpoints := []string{"endpoint1", "endpoint2"}
+
+	clients := make(map[string]KvClient)
+	for _, endpoint := range endpoints {
+		resp := seqNos[endpoint]
+		clients[endpoint] = &KvClientMock{
+			ObserveSeqNoFunc: func(ctx context.Context, req *memdx.ObserveSeqNoRequest) (*memdx.ObserveSeqNoResponse, error) {
+				return resp, nil
+			},
+			SetFunc: func(ctx context.Context, req *memdx.SetRequest) (*memdx.SetResponse, error) {
+				return &memdx.SetResponse{
+					Cas:           42,
+					MutationToken: memdx.MutationToken{VbUuid: 5, SeqNo: 10},
+				}, nil
+			},
+			HasFeatureFunc: func(feat memdx.HelloFeature) bool {
+				return false
+			},
+		}
+	}
+
+	return &CrudComponent{
+		logger:      zap.NewNop(),
+		retries:     NewRetryManagerFastFail(),
+		compression: &CompressionManagerDefault{disableCompression: true},
+		collections: &CollectionResolverMock{
+			ResolveCollectionIDFunc: func(ctx context.Context, scopeName string, collectionName string) (uint32, uint64, error) {
+				return 8, 1, nil
+			},
+		},
+		vbs: &VbucketRouterMock{
+			DispatchByKeyFunc: func(key []byte, replicaID uint32) (string, uint16, error) {
+				if replicaID >= uint32(len(endpoints)) {
+					return "", 0, invalidReplicaError{
+						RequestedReplica: replicaID,
+						NumServers:       uint32(len(endpoints)),
+					}
+				}
+				return endpoints[replicaID], 12, nil
+			},
+		},
+		connManager: &KvClientManagerMock{
+			GetClientFunc: func(ctx context.Context, endpoint string) (KvClient, error) {
+				return clients[endpoint], nil
+			},
+		},
+	}
+}
+
+func TestCrudWaitForLegacyDurability(t *testing.T) {
+	cc := newLegacyDurabilityTestCrud(map[string]*memdx.ObserveSeqNoResponse{
+		"endpoint1": {VbucketID: 12, VbUuid: 5, PersistSeqNo: 10, CurrentSeqNo: 10},
+		"endpoint2": {VbucketID: 12, VbUuid: 5, PersistSeqNo: 9, CurrentSeqNo: 10},
+	})
+
+	err := cc.WaitForLegacyDurability(context.Background(), &LegacyDurabilityOptions{
+		Key:           []byte("key"),
+		MutationToken: MutationToken{VbID: 12, VbUuid: 5, SeqNo: 10},
+		PersistTo:     1,
+		ReplicateTo:   1,
+	})
+	require.NoError(t, err)
+}
+
+func TestCrudWaitForLegacyDurabilityImpossible(t *testing.T) {
+	cc := newLegacyDurabilityTestCrud(map[string]*memdx.ObserveSeqNoResponse{})
+
+	err := cc.WaitForLegacyDurability(context.Background(), &LegacyDurabilityOptions{
+		Key:           []byte("key"),
+		MutationToken: MutationToken{VbID: 12, VbUuid: 5, SeqNo: 10},
+		ReplicateTo:   2,
+	})
+	assert.ErrorIs(t, err, ErrDurabilityImpossible)
+
+	var kvErr *KeyValueError
+	require.ErrorAs(t, err, &kvErr)
+	assert.Equal(t, memdx.OpCodeObserveSeqNo, kvErr.OpCode)
+	assert.Equal(t, uint16(12), kvErr.VbucketID)
+}
+
+func TestCrudWaitForLegacyDurabilityUnassignedReplica(t *testing.T) {
+	cc := newLegacyDurabilityTestCrud(map[string]*memdx.ObserveSeqNoResponse{
+		"endpoint1": {VbucketID: 12, VbUuid: 5, PersistSeqNo: 10, CurrentSeqNo: 10},
+	})
+	cc.vbs = &VbucketRouterMock{
+		DispatchByKeyFunc: func(key []byte, replicaID uint32) (string, uint16, error) {
+			switch replicaID {
+			case 0:
+				return "endpoint1", 12, nil
+			case 1:
+				return "", 0, noServerAssignedError{RequestedVbId: 12}
+			}
+			return "", 0, invalidReplicaError{
+				RequestedReplica: replicaID,
+				NumServers:       2,
+			}
+		},
+	}
+
+	// the replica was failed over, so waiting for it could only time out
+	err := cc.WaitForLegacyDurability(context.Background(), &LegacyDurabilityOptions{
+		Key:           []byte("key"),
+		MutationToken: MutationToken{VbID: 12, VbUuid: 5, SeqNo: 10},
+		ReplicateTo:   1,
+	})
+	assert.ErrorIs(t, err, ErrDurabilityImpossible)
+
+	err = cc.WaitForLegacyDurability(context.Background(), &LegacyDurabilityOptions{
+		Key:           []byte("key"),
+		MutationToken: MutationToken{VbID: 12, VbUuid: 5, SeqNo: 10},
+		PersistTo:     2,
+	})
+	assert.ErrorIs(t, err, ErrDurabilityImpossible)
+}
+
+func TestCrudWaitForLegacyDurabilityMutationLost(t *testing.T) {
+	cc := newLegacyDurabilityTestCrud(map[string]*memdx.ObserveSeqNoResponse{
+		"endpoint1": {VbucketID: 12, VbUuid: 6, DidFailover: true, OldVbUuid: 5, LastSeqNo: 8},
+		"endpoint2": {VbucketID: 12, VbUuid: 6, DidFailover: true, OldVbUuid: 5, LastSeqNo: 8},
+	})
+
+	err := cc.WaitForLegacyDurability(context.Background(), &LegacyDurabilityOptions{
+		Key:           []byte("key"),
+		MutationToken: MutationToken{VbID: 12, VbUuid: 5, SeqNo: 10},
+		PersistTo:     1,
+	})
+	assert.ErrorIs(t, err, ErrMutationLost)
+}
+
+func TestCrudUpsertRejectsMixedDurability(t *testing.T) {
+	cc := &CrudComponent{}
+
+	_, err := cc.Upsert(context.Background(), &UpsertOptions{
+		Key:             []byte("key"),
+		DurabilityLevel: memdx.DurabilityLevelMajority,
+		PersistTo:       1,
+	})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+
+	var kvErr *KeyValueError
+	require.ErrorAs(t, err, &kvErr)
+	assert.Equal(t, memdx.OpCodeSet, kvErr.OpCode)
+	assert.Equal(t, []byte("key"), kvErr.DocumentKey)
+}
+
+func TestCrudUpsertLegacyDurabilityFailureReturnsResult(t *testing.T) {
+	cc := newLegacyDurabilityTestCrud(map[string]*memdx.ObserveSeqNoResponse{})
+
+	res, err := cc.Upsert(context.Background(), &UpsertOptions{
+		Key:         []byte("key"),
+		Value:       []byte("value"),
+		ReplicateTo: 2,
+	})
+	assert.ErrorIs(t, err, ErrDurabilityImpossible)
+
+	var durabilityErr LegacyDurabilityError
+	require.ErrorAs(t, err, &durabilityErr)
+
+	var kvErr *KeyValueError
+	require.ErrorAs(t, err, &kvErr)
+	assert.Equal(t, memdx.OpCodeSet, kvErr.OpCode)
+	assert.Equal(t, uint16(12), kvErr.VbucketID)
+	assert.Equal(t, uint64(42), durabilityErr.Cas)
+	assert.Equal(t, MutationToken{VbID: 12, VbUuid: 5, SeqNo: 10}, durabilityErr.MutationToken)
+
+	require.NotNil(t, res)
+	assert.Equal(t, uint64(42), res.Cas)
+	assert.Equal(t, MutationToken{VbID: 12, VbUuid: 5, SeqNo: 10}, res.MutationToken)
+}
@@ -0,0 +1,182 @@
+package gocbcorex
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+type GetAllVbSeqnosOptions struct {
+	// ScopeName and CollectionName optionally restrict the returned seqnos
+	// to the high seqno of a single collection.
+	ScopeName      string
+	CollectionName string
+	OnBehalfOf     string
+	RetryManager   RetryManager
+}
+
+type VbucketSeqno struct {
+	VbUuid uint64
+	SeqNo  uint64
+}
+
+type GetAllVbSeqnosResult struct {
+	Vbuckets map[uint16]VbucketSeqno
+}
+
+// GetAllVbSeqnos fetches the current high seqno of every vbucket in the bucket
+// from the node which is active for it, according to the current vbucket map.
+func (cc *CrudComponent) GetAllVbSeqnos(ctx context.Context, opts *GetAllVbSeqnosOptions) (*GetAllVbSeqnosResult, error) {
+	ctx, span := startOpRequestSpan(ctx, cc.tracer, "get_all_vb_seqnos")
+	defer span.End()
+
+	kvErr := &KeyValueError{
+		OpCode:         memdx.OpCodeGetAllVBSeqnos,
+		ScopeName:      opts.ScopeName,
+		CollectionName: opts.CollectionName,
+	}
+
+	ctx = withOperationMeter(ctx, cc.meter, meterServiceKv, memdx.OpCodeGetAllVBSeqnos.String())
+	ctx = withThresholdLogging(ctx, cc.thresholds, meterServiceKv, memdx.OpCodeGetAllVBSeqnos.String())
+
+	res, err := OrchestrateMemdRetries(ctx, cc.retriesFor(opts.RetryManager), func() (*GetAllVbSeqnosResult, error) {
+		if opts.ScopeName == "" && opts.CollectionName == "" {
+			return cc.getAllVbSeqnos(ctx, nil, opts.OnBehalfOf, kvErr)
+		}
+
+		return OrchestrateMemdCollectionID(
+			ctx, cc.collections, opts.ScopeName, opts.CollectionName,
+			func(collectionID uint32, manifestID uint64) (*GetAllVbSeqnosResult, error) {
+				kvErr.CollectionID = collectionID
+				return cc.getAllVbSeqnos(ctx, &collectionID, opts.OnBehalfOf, kvErr)
+			})
+	})
+	if err != nil {
+		return nil, kvErr.withCause(err)
+	}
+
+	return res, nil
+}
+
+// getAllVbSeqnos fetches the seqnos from every server in parallel, recording
+// the endpoint of the first server to fail in kvErr.
+func (cc *CrudComponent) getAllVbSeqnos(
+	ctx context.Context,
+	collectionID *uint32,
+	onBehalfOf string,
+	kvErr *KeyValueError,
+) (*GetAllVbSeqnosResult, error) {
+	vbsByServer, err := cc.vbs.VbucketsByServer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock sync.Mutex
+	var firstErr error
+	vbuckets := make(map[uint16]VbucketSeqno)
+
+	var wg sync.WaitGroup
+	for endpoint, vbIDs := range vbsByServer {
+		wg.Add(1)
+		go func(endpoint string, vbIDs []uint16) {
+			defer wg.Done()
+
+			serverVbuckets, err := OrchestrateMemdClient(ctx, cc.connManager, endpoint, func(client KvClient) (map[uint16]VbucketSeqno, error) {
+				return cc.getServerVbSeqnos(ctx, client, vbIDs, collectionID, onBehalfOf)
+			})
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					kvErr.Endpoint = endpoint
+				}
+				return
+			}
+
+			for vbID, entry := range serverVbuckets {
+				vbuckets[vbID] = entry
+			}
+		}(endpoint, vbIDs)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return &GetAllVbSeqnosResult{
+		Vbuckets: vbuckets,
+	}, nil
+}
+
+// getServerVbSeqnos fetches the seqnos for the vbuckets we expect a server to be
+// active for.  GetAllVbSeqnos does not return vbuuids, so these are fetched
+// beforehand from the vbucket-seqno stats group.  Fetching them first means a
+// failover between the two requests leaves us with the old vbuuid, which any
+// consumer of the result will then detect as a failover.
+func (cc *CrudComponent) getServerVbSeqnos(
+	ctx context.Context,
+	client KvClient,
+	vbIDs []uint16,
+	collectionID *uint32,
+	onBehalfOf string,
+) (map[uint16]VbucketSeqno, error) {
+	statsResp, err := client.Stats(ctx, &memdx.StatsRequest{
+		GroupName:  "vbucket-seqno",
+		OnBehalfOf: onBehalfOf,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seqnosResp, err := client.GetAllVbSeqnos(ctx, &memdx.GetAllVbSeqnosRequest{
+		VbucketState: memdx.VbucketStateActive,
+		CollectionID: collectionID,
+		OnBehalfOf:   onBehalfOf,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seqNos := make(map[uint16]uint64, len(seqnosResp.Entries))
+	for _, entry := range seqnosResp.Entries {
+		seqNos[entry.VbucketID] = entry.SeqNo
+	}
+
+	vbuckets := make(map[uint16]VbucketSeqno, len(vbIDs))
+	for _, vbID := range vbIDs {
+		seqNo, ok := seqNos[vbID]
+		if !ok {
+			// the server is no longer active for a vbucket our vbucket map
+			// says it should be, our vbucket map must be out of date.
+			return nil, &VbucketMapOutdatedError{
+				Cause: fmt.Errorf("vbucket %d is not active on the expected server", vbID),
+			}
+		}
+
+		vbUuidStr, ok := statsResp.Stats[fmt.Sprintf("vb_%d:uuid", vbID)]
+		if !ok {
+			return nil, &VbucketMapOutdatedError{
+				Cause: fmt.Errorf("no vbuuid stat for vbucket %d", vbID),
+			}
+		}
+
+		vbUuid, err := strconv.ParseUint(vbUuidStr, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		vbuckets[vbID] = VbucketSeqno{
+			VbUuid: vbUuid,
+			SeqNo:  seqNo,
+		}
+	}
+
+	return vbuckets, nil
+}
@@ -0,0 +1,83 @@
+package gocbcorex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+func newVbSeqnosTestCrud(clients map[string]KvClient) *CrudComponent {
+	return &CrudComponent{
+		logger:  zap.NewNop(),
+		retries: NewRetryManagerFastFail(),
+		vbs: &VbucketRouterMock{
+			VbucketsByServerFunc: func(replicaIdx uint32) (map[string][]uint16, error) {
+				return map[string][]uint16{
+					"endpoint1": {0, 2},
+					"endpoint2": {1},
+				}, nil
+			},
+		},
+		connManager: &KvClientManagerMock{
+			GetClientFunc: func(ctx context.Context, endpoint string) (KvClient, error) {
+				return clients[endpoint], nil
+			},
+		},
+	}
+}
+
+func newVbSeqnosTestClient(uuids map[string]string, entries []memdx.VbSeqnoEntry) *KvClientMock {
+	return &KvClientMock{
+		StatsFunc: func(ctx context.Context, req *memdx.StatsRequest) (*memdx.StatsResponse, error) {
+			return &memdx.StatsResponse{Stats: uuids}, nil
+		},
+		GetAllVbSeqnosFunc: func(ctx context.Context, req *memdx.GetAllVbSeqnosRequest) (*memdx.GetAllVbSeqnosResponse, error) {
+			return &memdx.GetAllVbSeqnosResponse{Entries: entries}, nil
+		},
+	}
+}
+
+func TestCrudGetAllVbSeqnos(t *testing.T) {
+	cc := newVbSeqnosTestCrud(map[string]KvClient{
+		"endpoint1": newVbSeqnosTestClient(
+			map[string]string{"vb_0:uuid": "10", "vb_2:uuid": "12"},
+			[]memdx.VbSeqnoEntry{{VbucketID: 0, SeqNo: 100}, {VbucketID: 2, SeqNo: 102}}),
+		"endpoint2": newVbSeqnosTestClient(
+			map[string]string{"vb_1:uuid": "11"},
+			// vbucket 2 is still reported here as the server has not yet
+			// finished handing it over, but it must be ignored.
+			[]memdx.VbSeqnoEntry{{VbucketID: 1, SeqNo: 101}, {VbucketID: 2, SeqNo: 50}}),
+	})
+
+	res, err := cc.GetAllVbSeqnos(context.Background(), &GetAllVbSeqnosOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[uint16]VbucketSeqno{
+		0: {VbUuid: 10, SeqNo: 100},
+		1: {VbUuid: 11, SeqNo: 101},
+		2: {VbUuid: 12, SeqNo: 102},
+	}, res.Vbuckets)
+}
+
+func TestCrudGetAllVbSeqnosMissingVbucket(t *testing.T) {
+	cc := newVbSeqnosTestCrud(map[string]KvClient{
+		"endpoint1": newVbSeqnosTestClient(
+			map[string]string{"vb_0:uuid": "10"},
+			[]memdx.VbSeqnoEntry{{VbucketID: 0, SeqNo: 100}}),
+		"endpoint2": newVbSeqnosTestClient(
+			map[string]string{"vb_1:uuid": "11"},
+			[]memdx.VbSeqnoEntry{{VbucketID: 1, SeqNo: 101}}),
+	})
+
+	_, err := cc.GetAllVbSeqnos(context.Background(), &GetAllVbSeqnosOptions{})
+	assert.ErrorIs(t, err, ErrVbucketMapOutdated)
+
+	var kvErr *KeyValueError
+	require.ErrorAs(t, err, &kvErr)
+	assert.Equal(t, memdx.OpCodeGetAllVBSeqnos, kvErr.OpCode)
+	assert.Equal(t, "endpoint1", kvErr.Endpoint)
+}
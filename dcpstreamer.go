@@ -0,0 +1,444 @@
+package gocbcorex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+// DcpEventHandlers holds the handlers invoked for events received on the
+// streams of a DcpStreamer.  Handlers are invoked from the goroutine reading
+// the underlying connection and should avoid blocking.
+type DcpEventHandlers struct {
+	SnapshotMarker func(evt *memdx.DcpSnapshotMarkerEvent)
+	Mutation       func(evt *memdx.DcpMutationEvent)
+	Deletion       func(evt *memdx.DcpDeletionEvent)
+	Expiration     func(evt *memdx.DcpExpirationEvent)
+	SeqNoAdvanced  func(evt *memdx.DcpSeqNoAdvancedEvent)
+
+	// StreamEnd is invoked once a stream has permanently ended.  err is nil
+	// if the stream reached its end seqno or was closed by the application.
+	StreamEnd func(vbID uint16, err error)
+}
+
+type DcpStreamOptions struct {
+	Flags          memdx.DcpStreamReqFlags
+	StartSeqNo     uint64
+	EndSeqNo       uint64
+	VbUuid         uint64
+	SnapStartSeqNo uint64
+	SnapEndSeqNo   uint64
+	Filter         *memdx.DcpStreamFilter
+}
+
+type DcpStreamerOptions struct {
+	Logger      *zap.Logger
+	ConnMgr     KvClientManager
+	VbRouter    VbucketRouter
+	NmvHandler  NotMyVbucketConfigHandler
+	NumVbuckets int
+	Handlers    DcpEventHandlers
+
+	// ReopenBackoff calculates how long to wait between attempts to reopen a
+	// stream which was interrupted, for instance due to a rebalance.
+	ReopenBackoff BackoffCalculator
+}
+
+type dcpStream struct {
+	vbID uint16
+
+	lock       sync.Mutex
+	req        memdx.DcpStreamReqRequest
+	generation uint64
+	endpoint   string
+	client     KvClient
+	closing    bool
+}
+
+// DcpStreamer manages a DCP stream for each requested vbucket, routing each
+// stream to the node which owns the vbucket and transparently reopening
+// streams from their last seen position when they are moved.
+type DcpStreamer struct {
+	logger      *zap.Logger
+	connMgr     KvClientManager
+	vbRouter    VbucketRouter
+	nmvHandler  NotMyVbucketConfigHandler
+	numVbuckets int
+	handlers    DcpEventHandlers
+	backoff     BackoffCalculator
+
+	closeCtx    context.Context
+	closeCancel context.CancelFunc
+	closeHook   func()
+
+	lock    sync.Mutex
+	streams map[uint16]*dcpStream
+	closed  bool
+}
+
+func NewDcpStreamer(opts *DcpStreamerOptions) (*DcpStreamer, error) {
+	if opts == nil {
+		return nil, errors.New("must pass options")
+	}
+	if opts.ConnMgr == nil {
+		return nil, errors.New("must pass a connection manager")
+	}
+	if opts.VbRouter == nil {
+		return nil, errors.New("must pass a vbucket router")
+	}
+
+	backoff := opts.ReopenBackoff
+	if backoff == nil {
+		backoff = ExponentialBackoff(10*time.Millisecond, 5*time.Second, 2)
+	}
+
+	closeCtx, closeCancel := context.WithCancel(context.Background())
+
+	return &DcpStreamer{
+		logger:      loggerOrNop(opts.Logger),
+		connMgr:     opts.ConnMgr,
+		vbRouter:    opts.VbRouter,
+		nmvHandler:  opts.NmvHandler,
+		numVbuckets: opts.NumVbuckets,
+		handlers:    opts.Handlers,
+		backoff:     backoff,
+		closeCtx:    closeCtx,
+		closeCancel: closeCancel,
+		streams:     make(map[uint16]*dcpStream),
+	}, nil
+}
+
+// OpenStream opens a stream for the specified vbucket, returning the failover log
+// of the vbucket at the time the stream was opened.
+func (s *DcpStreamer) OpenStream(ctx context.Context, vbID uint16, opts *DcpStreamOptions) ([]memdx.DcpFailoverEntry, error) {
+	if opts == nil {
+		opts = &DcpStreamOptions{}
+	}
+
+	stream := &dcpStream{
+		vbID: vbID,
+		req: memdx.DcpStreamReqRequest{
+			VbucketID:      vbID,
+			Flags:          opts.Flags,
+			StartSeqNo:     opts.StartSeqNo,
+			EndSeqNo:       opts.EndSeqNo,
+			VbUuid:         opts.VbUuid,
+			SnapStartSeqNo: opts.SnapStartSeqNo,
+			SnapEndSeqNo:   opts.SnapEndSeqNo,
+			Filter:         opts.Filter,
+		},
+	}
+
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil, ErrDcpStreamerClosed
+	}
+	if s.streams[vbID] != nil {
+		s.lock.Unlock()
+		return nil, memdx.ErrDcpStreamExists
+	}
+	s.streams[vbID] = stream
+	s.lock.Unlock()
+
+	failoverLog, err := s.openStream(ctx, stream)
+	if err != nil {
+		s.removeStream(stream)
+		return nil, err
+	}
+
+	return failoverLog, nil
+}
+
+// OpenAllStreams opens a stream for every vbucket in the bucket, using optsFn
+// to determine the options for each vbucket.  If optsFn is nil, every stream
+// starts from the beginning of the vbucket.
+func (s *DcpStreamer) OpenAllStreams(ctx context.Context, optsFn func(vbID uint16) *DcpStreamOptions) error {
+	if s.numVbuckets <= 0 {
+		return errors.New("number of vbuckets is not known")
+	}
+
+	for vbIdx := 0; vbIdx < s.numVbuckets; vbIdx++ {
+		vbID := uint16(vbIdx)
+
+		var opts *DcpStreamOptions
+		if optsFn != nil {
+			opts = optsFn(vbID)
+		}
+
+		_, err := s.OpenStream(ctx, vbID, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CloseStream requests that the stream for a vbucket is closed.  The StreamEnd
+// handler is invoked once the server has confirmed the stream has ended.
+func (s *DcpStreamer) CloseStream(ctx context.Context, vbID uint16) error {
+	s.lock.Lock()
+	stream := s.streams[vbID]
+	s.lock.Unlock()
+
+	if stream == nil {
+		return memdx.ErrDcpStreamNotFound
+	}
+
+	stream.lock.Lock()
+	stream.closing = true
+	client := stream.client
+	stream.lock.Unlock()
+
+	if client == nil {
+		// the stream is currently being reopened, the reopen will notice that
+		// the stream is closing and end it instead.
+		return nil
+	}
+
+	return client.DcpCloseStream(ctx, &memdx.DcpCloseStreamRequest{
+		VbucketID: vbID,
+	})
+}
+
+// Close closes all open streams and prevents any further streams from being opened.
+func (s *DcpStreamer) Close(ctx context.Context) error {
+	s.lock.Lock()
+	s.closed = true
+	vbIDs := make([]uint16, 0, len(s.streams))
+	for vbID := range s.streams {
+		vbIDs = append(vbIDs, vbID)
+	}
+	s.lock.Unlock()
+
+	s.closeCancel()
+
+	var firstErr error
+	for _, vbID := range vbIDs {
+		err := s.CloseStream(ctx, vbID)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if s.closeHook != nil {
+		s.closeHook()
+	}
+
+	return firstErr
+}
+
+func (s *DcpStreamer) removeStream(stream *dcpStream) {
+	s.lock.Lock()
+	if s.streams[stream.vbID] == stream {
+		delete(s.streams, stream.vbID)
+	}
+	s.lock.Unlock()
+}
+
+func (s *DcpStreamer) openStream(ctx context.Context, stream *dcpStream) ([]memdx.DcpFailoverEntry, error) {
+	return OrchestrateMemdVbucketRouting(ctx, s.vbRouter, s.nmvHandler, stream.vbID,
+		func(endpoint string) ([]memdx.DcpFailoverEntry, error) {
+			return OrchestrateMemdClient(ctx, s.connMgr, endpoint, func(client KvClient) ([]memdx.DcpFailoverEntry, error) {
+				stream.lock.Lock()
+				stream.generation++
+				generation := stream.generation
+				req := stream.req
+				stream.lock.Unlock()
+
+				resp, err := client.DcpStreamReq(ctx, &req, s.streamHandlers(stream, generation))
+				if err != nil {
+					return nil, err
+				}
+
+				stream.lock.Lock()
+				stream.endpoint = endpoint
+				stream.client = client
+				if len(resp.FailoverLog) > 0 {
+					stream.req.VbUuid = resp.FailoverLog[0].VbUuid
+				}
+				closing := stream.closing
+				stream.lock.Unlock()
+
+				if closing {
+					// the stream was closed while we were opening it, so we need
+					// to make sure the server knows about it.
+					err := client.DcpCloseStream(ctx, &memdx.DcpCloseStreamRequest{
+						VbucketID: stream.vbID,
+					})
+					if err != nil {
+						s.logger.Debug("failed to close dcp stream after open",
+							zap.Uint16("vbID", stream.vbID),
+							zap.Error(err))
+					}
+				}
+
+				return resp.FailoverLog, nil
+			})
+		})
+}
+
+func (s *DcpStreamer) streamHandlers(stream *dcpStream, generation uint64) memdx.DcpStreamEventHandlers {
+	updateSeqNo := func(seqNo uint64) {
+		stream.lock.Lock()
+		if stream.generation == generation {
+			stream.req.StartSeqNo = seqNo
+		}
+		stream.lock.Unlock()
+	}
+
+	return memdx.DcpStreamEventHandlers{
+		SnapshotMarker: func(evt *memdx.DcpSnapshotMarkerEvent) {
+			stream.lock.Lock()
+			if stream.generation == generation {
+				stream.req.SnapStartSeqNo = evt.StartSeqNo
+				stream.req.SnapEndSeqNo = evt.EndSeqNo
+			}
+			stream.lock.Unlock()
+
+			if s.handlers.SnapshotMarker != nil {
+				s.handlers.SnapshotMarker(evt)
+			}
+		},
+		Mutation: func(evt *memdx.DcpMutationEvent) {
+			updateSeqNo(evt.SeqNo)
+
+			if s.handlers.Mutation != nil {
+				s.handlers.Mutation(evt)
+			}
+		},
+		Deletion: func(evt *memdx.DcpDeletionEvent) {
+			updateSeqNo(evt.SeqNo)
+
+			if s.handlers.Deletion != nil {
+				s.handlers.Deletion(evt)
+			}
+		},
+		Expiration: func(evt *memdx.DcpExpirationEvent) {
+			updateSeqNo(evt.SeqNo)
+
+			if s.handlers.Expiration != nil {
+				s.handlers.Expiration(evt)
+			}
+		},
+		SeqNoAdvanced: func(evt *memdx.DcpSeqNoAdvancedEvent) {
+			updateSeqNo(evt.SeqNo)
+
+			if s.handlers.SeqNoAdvanced != nil {
+				s.handlers.SeqNoAdvanced(evt)
+			}
+		},
+		StreamEnd: func(evt *memdx.DcpStreamEndEvent, err error) {
+			s.handleStreamEnd(stream, generation, evt, err)
+		},
+	}
+}
+
+func (s *DcpStreamer) handleStreamEnd(stream *dcpStream, generation uint64, evt *memdx.DcpStreamEndEvent, err error) {
+	stream.lock.Lock()
+	if stream.generation != generation {
+		// this is the end of a stream which has already been replaced.
+		stream.lock.Unlock()
+		return
+	}
+
+	stream.client = nil
+	closing := stream.closing
+	stream.lock.Unlock()
+
+	if closing {
+		s.endStream(stream, nil)
+		return
+	}
+
+	if evt != nil {
+		switch evt.Reason {
+		case memdx.DcpStreamEndReasonOK, memdx.DcpStreamEndReasonClosed:
+			s.endStream(stream, nil)
+			return
+		case memdx.DcpStreamEndReasonStateChanged,
+			memdx.DcpStreamEndReasonDisconnected,
+			memdx.DcpStreamEndReasonTooSlow,
+			memdx.DcpStreamEndReasonBackfillFail:
+			// these all indicate that the stream can be picked up from where
+			// it left off, possibly on another node following a rebalance.
+		default:
+			s.endStream(stream, DcpStreamEndedError{
+				VbID:   stream.vbID,
+				Reason: evt.Reason,
+			})
+			return
+		}
+	} else {
+		s.logger.Debug("dcp stream failed, reopening",
+			zap.Uint16("vbID", stream.vbID),
+			zap.Error(err))
+	}
+
+	// we cannot block the read goroutine of the connection while we reopen
+	// the stream, since the reopen may need to be dispatched to the same one.
+	go s.reopenStream(stream)
+}
+
+func (s *DcpStreamer) reopenStream(stream *dcpStream) {
+	var retryAttempts uint32
+	for {
+		stream.lock.Lock()
+		closing := stream.closing
+		// if we have reached the end of the current snapshot, the next stream
+		// needs to begin with a snapshot which starts at the current seqno.
+		if stream.req.StartSeqNo >= stream.req.SnapEndSeqNo {
+			stream.req.SnapStartSeqNo = stream.req.StartSeqNo
+			stream.req.SnapEndSeqNo = stream.req.StartSeqNo
+		}
+		stream.lock.Unlock()
+
+		if closing {
+			s.endStream(stream, nil)
+			return
+		}
+
+		_, err := s.openStream(s.closeCtx, stream)
+		if err == nil {
+			return
+		}
+
+		if s.closeCtx.Err() != nil {
+			s.endStream(stream, ErrDcpStreamerClosed)
+			return
+		}
+
+		if errors.Is(err, memdx.ErrDcpRollback) || errors.Is(err, memdx.ErrAccessError) {
+			s.endStream(stream, err)
+			return
+		}
+
+		s.logger.Debug("failed to reopen dcp stream, will retry",
+			zap.Uint16("vbID", stream.vbID),
+			zap.Uint32("attempt", retryAttempts),
+			zap.Error(err))
+
+		select {
+		case <-time.After(s.backoff(retryAttempts)):
+		case <-s.closeCtx.Done():
+			s.endStream(stream, ErrDcpStreamerClosed)
+			return
+		}
+
+		retryAttempts++
+	}
+}
+
+func (s *DcpStreamer) endStream(stream *dcpStream, err error) {
+	s.removeStream(stream)
+
+	if s.handlers.StreamEnd != nil {
+		s.handlers.StreamEnd(stream.vbID, err)
+	}
+}
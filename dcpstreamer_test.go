@@ -0,0 +1,167 @@
+package gocbcorex
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+func TestDcpStreamerReopensOnStateChanged(t *testing.T) {
+	var lock sync.Mutex
+	currentEndpoint := "endpoint1"
+	var streamReqs []*memdx.DcpStreamReqRequest
+	var streamHandlers []memdx.DcpStreamEventHandlers
+	var streamEndpoints []string
+
+	newClient := func(endpoint string) *KvClientMock {
+		return &KvClientMock{
+			DcpStreamReqFunc: func(ctx context.Context, req *memdx.DcpStreamReqRequest, handlers memdx.DcpStreamEventHandlers) (*memdx.DcpStreamReqResponse, error) {
+				lock.Lock()
+				defer lock.Unlock()
+
+				reqCopy := *req
+				streamReqs = append(streamReqs, &reqCopy)
+				streamHandlers = append(streamHandlers, handlers)
+				streamEndpoints = append(streamEndpoints, endpoint)
+
+				return &memdx.DcpStreamReqResponse{
+					FailoverLog: []memdx.DcpFailoverEntry{{VbUuid: 1234, SeqNo: 0}},
+				}, nil
+			},
+		}
+	}
+	clients := map[string]*KvClientMock{
+		"endpoint1": newClient("endpoint1"),
+		"endpoint2": newClient("endpoint2"),
+	}
+
+	connMgr := &KvClientManagerMock{
+		GetClientFunc: func(ctx context.Context, endpoint string) (KvClient, error) {
+			return clients[endpoint], nil
+		},
+	}
+	vbRouter := &VbucketRouterMock{
+		DispatchToVbucketFunc: func(vbID uint16) (string, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return currentEndpoint, nil
+		},
+	}
+
+	var mutations []*memdx.DcpMutationEvent
+	streamer, err := NewDcpStreamer(&DcpStreamerOptions{
+		ConnMgr:  connMgr,
+		VbRouter: vbRouter,
+		Handlers: DcpEventHandlers{
+			Mutation: func(evt *memdx.DcpMutationEvent) {
+				mutations = append(mutations, evt)
+			},
+		},
+		ReopenBackoff: func(retryAttempts uint32) time.Duration {
+			return time.Millisecond
+		},
+	})
+	require.NoError(t, err)
+
+	failoverLog, err := streamer.OpenStream(context.Background(), 3, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []memdx.DcpFailoverEntry{{VbUuid: 1234, SeqNo: 0}}, failoverLog)
+
+	lock.Lock()
+	handlers := streamHandlers[0]
+	currentEndpoint = "endpoint2"
+	lock.Unlock()
+
+	handlers.SnapshotMarker(&memdx.DcpSnapshotMarkerEvent{VbucketID: 3, StartSeqNo: 1, EndSeqNo: 10})
+	handlers.Mutation(&memdx.DcpMutationEvent{VbucketID: 3, SeqNo: 7})
+	handlers.StreamEnd(&memdx.DcpStreamEndEvent{
+		VbucketID: 3,
+		Reason:    memdx.DcpStreamEndReasonStateChanged,
+	}, nil)
+
+	require.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(streamReqs) == 2
+	}, time.Second, time.Millisecond)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	assert.Equal(t, []string{"endpoint1", "endpoint2"}, streamEndpoints)
+	assert.Equal(t, uint64(7), streamReqs[1].StartSeqNo)
+	assert.Equal(t, uint64(1234), streamReqs[1].VbUuid)
+	assert.Equal(t, uint64(1), streamReqs[1].SnapStartSeqNo)
+	assert.Equal(t, uint64(10), streamReqs[1].SnapEndSeqNo)
+	assert.Len(t, mutations, 1)
+}
+
+func TestDcpStreamerEndsOnClose(t *testing.T) {
+	var handlers memdx.DcpStreamEventHandlers
+	var closeReqs []*memdx.DcpCloseStreamRequest
+
+	client := &KvClientMock{
+		DcpStreamReqFunc: func(ctx context.Context, req *memdx.DcpStreamReqRequest, h memdx.DcpStreamEventHandlers) (*memdx.DcpStreamReqResponse, error) {
+			handlers = h
+			return &memdx.DcpStreamReqResponse{}, nil
+		},
+		DcpCloseStreamFunc: func(ctx context.Context, req *memdx.DcpCloseStreamRequest) error {
+			closeReqs = append(closeReqs, req)
+			return nil
+		},
+	}
+
+	var endedVbID uint16
+	var endedErr error
+	endedCh := make(chan struct{}, 1)
+
+	streamer, err := NewDcpStreamer(&DcpStreamerOptions{
+		ConnMgr: &KvClientManagerMock{
+			GetClientFunc: func(ctx context.Context, endpoint string) (KvClient, error) {
+				return client, nil
+			},
+		},
+		VbRouter: &VbucketRouterMock{
+			DispatchToVbucketFunc: func(vbID uint16) (string, error) {
+				return "endpoint1", nil
+			},
+		},
+		Handlers: DcpEventHandlers{
+			StreamEnd: func(vbID uint16, err error) {
+				endedVbID = vbID
+				endedErr = err
+				endedCh <- struct{}{}
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = streamer.OpenStream(context.Background(), 5, nil)
+	require.NoError(t, err)
+
+	_, err = streamer.OpenStream(context.Background(), 5, nil)
+	assert.ErrorIs(t, err, memdx.ErrDcpStreamExists)
+
+	err = streamer.CloseStream(context.Background(), 5)
+	require.NoError(t, err)
+	require.Len(t, closeReqs, 1)
+	assert.Equal(t, uint16(5), closeReqs[0].VbucketID)
+
+	handlers.StreamEnd(&memdx.DcpStreamEndEvent{
+		VbucketID: 5,
+		Reason:    memdx.DcpStreamEndReasonClosed,
+	}, nil)
+
+	<-endedCh
+	assert.Equal(t, uint16(5), endedVbID)
+	assert.NoError(t, endedErr)
+
+	err = streamer.CloseStream(context.Background(), 5)
+	assert.ErrorIs(t, err, memdx.ErrDcpStreamNotFound)
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/couchbase/gocbcorex/memdx"
 )
 
 var (
@@ -41,6 +43,10 @@ func (e CollectionNotFoundError) Error() string {
 	return e.InnerError.Error()
 }
 
+func (e CollectionNotFoundError) Unwrap() error {
+	return e.InnerError
+}
+
 type CollectionManifestOutdatedError struct {
 	Cause             error
 	ManifestUid       uint64
@@ -55,6 +61,81 @@ func (e CollectionManifestOutdatedError) Unwrap() error {
 	return ErrCollectionManifestOutdated
 }
 
+// KeyValueError describes the failure of a KV operation against a document.
+// The fields describe the last attempt at the operation, and are left empty
+// when the failure happened before that information was known.
+type KeyValueError struct {
+	Cause error
+
+	OpCode         memdx.OpCode
+	DocumentKey    []byte
+	ScopeName      string
+	CollectionName string
+	CollectionID   uint32
+	VbucketID      uint16
+	Endpoint       string
+
+	// Opaque and StatusCode are only set when the server responded.
+	Opaque     uint32
+	StatusCode memdx.Status
+
+	// ErrorName is the name of the status code in the server's error map.
+	ErrorName string
+
+	// Context and Ref are the extended error information the server sent
+	// with its response, if any.
+	Context string
+	Ref     string
+
+	RetryAttempts uint32
+}
+
+func (e *KeyValueError) Error() string {
+	// the document key is user data, which is tagged so that it can be
+	// redacted from logs.
+	return fmt.Sprintf("kv error: %s (opcode: %s, key: <ud>%s</ud>, collection: %s.%s, vbucket: %d, endpoint: %s)",
+		e.Cause, e.OpCode, e.DocumentKey, e.ScopeName, e.CollectionName, e.VbucketID, e.Endpoint)
+}
+
+func (e *KeyValueError) Unwrap() error {
+	return e.Cause
+}
+
+// withCause returns a copy of the error with its cause set, and with the
+// details the cause carries about the server's response filled in.
+func (e *KeyValueError) withCause(cause error) *KeyValueError {
+	kvErr := *e
+	kvErr.Cause = cause
+
+	var serverErr memdx.ServerError
+	if errors.As(cause, &serverErr) {
+		if serverErr.DispatchedTo != "" {
+			kvErr.Endpoint = serverErr.DispatchedTo
+		}
+		kvErr.Opaque = serverErr.Opaque
+		kvErr.StatusCode = serverErr.Status
+	}
+
+	var errMapErr memdx.ServerErrorWithErrorMap
+	if errors.As(cause, &errMapErr) {
+		kvErr.ErrorName = errMapErr.Entry.Name
+	}
+
+	var contextErr memdx.ServerErrorWithContext
+	if errors.As(cause, &contextErr) {
+		serverContext := contextErr.ParseContext()
+		kvErr.Context = serverContext.Text
+		kvErr.Ref = serverContext.Ref
+	}
+
+	var summaryErr *RetrySummaryError
+	if errors.As(cause, &summaryErr) && summaryErr.Summary.NumAttempts > 0 {
+		kvErr.RetryAttempts = summaryErr.Summary.NumAttempts - 1
+	}
+
+	return &kvErr
+}
+
 type VbucketMapOutdatedError struct {
 	Cause error
 }
@@ -64,7 +145,7 @@ func (e VbucketMapOutdatedError) Error() string {
 }
 
 func (e VbucketMapOutdatedError) Unwrap() error {
-	return ErrCollectionManifestOutdated
+	return ErrVbucketMapOutdated
 }
 
 type contextualDeadline struct {
@@ -148,8 +229,45 @@ func (e noServerAssignedError) Unwrap() error {
 	return ErrNoServerAssigned
 }
 
+var ErrClientCertificateRequiresTls = errors.New("client certificate authentication requires TLS")
+
 var ErrInvalidArgument = errors.New("invalid argument")
 
+type invalidArgumentError struct {
+	Message string
+}
+
+func (e invalidArgumentError) Error() string {
+	return fmt.Sprintf("invalid argument: %s", e.Message)
+}
+
+func (e invalidArgumentError) Unwrap() error {
+	return ErrInvalidArgument
+}
+
+var (
+	ErrDurabilityImpossible = errors.New("durability requirements are impossible to achieve")
+	ErrMutationLost         = errors.New("mutation was lost during a failover")
+)
+
+// LegacyDurabilityError indicates that a mutation was applied, but could not be
+// confirmed to have met its PersistTo and ReplicateTo requirements.  The Cas
+// and MutationToken of the mutation are also returned alongside the error in
+// the result of the operation.
+type LegacyDurabilityError struct {
+	Cause         error
+	Cas           uint64
+	MutationToken MutationToken
+}
+
+func (e LegacyDurabilityError) Error() string {
+	return fmt.Sprintf("mutation succeeded but its durability requirements were not met: %s", e.Cause)
+}
+
+func (e LegacyDurabilityError) Unwrap() error {
+	return e.Cause
+}
+
 var ErrBootstrapAllFailed = errors.New("all bootstrap hosts failed")
 
 type BootstrapAllFailedError struct {
@@ -167,3 +285,20 @@ func (e BootstrapAllFailedError) Error() string {
 func (e BootstrapAllFailedError) Unwrap() error {
 	return ErrBootstrapAllFailed
 }
+
+var ErrDcpStreamerClosed = errors.New("dcp streamer closed")
+
+var ErrDcpStreamEnded = errors.New("dcp stream ended")
+
+type DcpStreamEndedError struct {
+	VbID   uint16
+	Reason memdx.DcpStreamEndReason
+}
+
+func (e DcpStreamEndedError) Error() string {
+	return fmt.Sprintf("dcp stream for vbucket %d ended (reason: %s)", e.VbID, e.Reason)
+}
+
+func (e DcpStreamEndedError) Unwrap() error {
+	return ErrDcpStreamEnded
+}
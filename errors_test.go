@@ -0,0 +1,80 @@
+package gocbcorex
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+func TestCrudWrapsErrorsInKeyValueError(t *testing.T) {
+	serverErr := memdx.ServerErrorWithContext{
+		Cause: memdx.ServerError{
+			Cause:        memdx.ErrDocNotFound,
+			Status:       memdx.StatusKeyNotFound,
+			DispatchedTo: "endpoint2:11210",
+			Opaque:       42,
+		},
+		ContextJson: []byte(`{"context":"missing","ref":"some-ref"}`),
+	}
+	mappedErr := memdx.ServerErrorWithErrorMap{
+		Cause: serverErr,
+		Entry: &memdx.ErrorMapEntry{
+			Status: memdx.StatusKeyNotFound,
+			Name:   "KEY_ENOENT",
+		},
+	}
+
+	cc := &CrudComponent{
+		logger:  zap.NewNop(),
+		retries: NewRetryManagerFastFail(),
+		collections: &CollectionResolverMock{
+			ResolveCollectionIDFunc: func(ctx context.Context, scopeName string, collectionName string) (uint32, uint64, error) {
+				return 8, 1, nil
+			},
+		},
+		vbs: &VbucketRouterMock{
+			DispatchByKeyFunc: func(key []byte, replicaID uint32) (string, uint16, error) {
+				return "endpoint1", 12, nil
+			},
+		},
+		connManager: &KvClientManagerMock{
+			GetClientFunc: func(ctx context.Context, endpoint string) (KvClient, error) {
+				return &KvClientMock{
+					GetFunc: func(ctx context.Context, req *memdx.GetRequest) (*memdx.GetResponse, error) {
+						return nil, mappedErr
+					},
+				}, nil
+			},
+		},
+	}
+
+	_, err := cc.Get(context.Background(), &GetOptions{
+		Key:            []byte("key"),
+		ScopeName:      "scope",
+		CollectionName: "collection",
+	})
+	assert.ErrorIs(t, err, memdx.ErrDocNotFound)
+
+	var kvErr *KeyValueError
+	require.True(t, errors.As(err, &kvErr))
+	assert.Equal(t, memdx.OpCodeGet, kvErr.OpCode)
+	assert.Equal(t, []byte("key"), kvErr.DocumentKey)
+	assert.Equal(t, "scope", kvErr.ScopeName)
+	assert.Equal(t, "collection", kvErr.CollectionName)
+	assert.Equal(t, uint32(8), kvErr.CollectionID)
+	assert.Equal(t, uint16(12), kvErr.VbucketID)
+	assert.Equal(t, "endpoint2:11210", kvErr.Endpoint)
+	assert.Equal(t, uint32(42), kvErr.Opaque)
+	assert.Equal(t, memdx.StatusKeyNotFound, kvErr.StatusCode)
+	assert.Equal(t, "KEY_ENOENT", kvErr.ErrorName)
+	assert.Equal(t, "missing", kvErr.Context)
+	assert.Equal(t, "some-ref", kvErr.Ref)
+	assert.Equal(t, uint32(0), kvErr.RetryAttempts)
+	assert.Contains(t, kvErr.Error(), "<ud>key</ud>")
+}
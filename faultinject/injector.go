@@ -0,0 +1,262 @@
+// Package faultinject wraps gocbcorex KV clients and client managers so that
+// operations can be made to fail in the ways a real cluster fails: slow
+// responses, error statuses, dropped connections and responses which are lost
+// after the server has already applied the operation.  It is intended for
+// validating retry strategies and timeouts without a misbehaving cluster.
+package faultinject
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+var (
+	ErrInvalidProbability = errors.New("probability must be between 0 and 1")
+	ErrConflictingFaults  = errors.New("a rule may only specify one of status, connection drop or partial response")
+)
+
+// Rule describes a fault and the operations it applies to.  Every selector
+// which is specified must match for the rule to apply to an operation.
+type Rule struct {
+	// OpCodes restricts the rule to operations with one of these opcodes.
+	OpCodes []memdx.OpCode
+
+	// Keys restricts the rule to operations on one of these document keys.
+	Keys [][]byte
+
+	// Endpoints restricts the rule to clients connected to one of these
+	// addresses, as returned by KvClient.RemoteAddress.
+	Endpoints []string
+
+	// Probability is the chance, between 0 and 1, that the rule applies to a
+	// matching operation.  Zero applies it to every matching operation.
+	Probability float64
+
+	// Limit is the maximum number of times the rule is applied, zero being
+	// unlimited.
+	Limit int
+
+	// Latency delays matching operations before they are sent, by a duration
+	// sampled from the distribution.
+	Latency LatencyDistribution
+
+	// Status causes matching operations to fail with this status rather than
+	// being sent to the server.  Value is returned as the response body, which
+	// for StatusNotMyVBucket is the cluster config.
+	Status memdx.Status
+	Value  []byte
+
+	// DropConnection closes the connection of the client before a matching
+	// operation is sent.
+	DropConnection bool
+
+	// PartialResponse sends a matching operation to the server and then closes
+	// the connection before the response is received, leaving the outcome of
+	// the operation unknown to the caller.
+	PartialResponse bool
+}
+
+func (r *Rule) validate() error {
+	if r.Probability < 0 || r.Probability > 1 {
+		return ErrInvalidProbability
+	}
+
+	numFaults := 0
+	if r.Status != memdx.StatusSuccess {
+		numFaults++
+	}
+	if r.DropConnection {
+		numFaults++
+	}
+	if r.PartialResponse {
+		numFaults++
+	}
+	if numFaults > 1 {
+		return ErrConflictingFaults
+	}
+
+	return nil
+}
+
+func (r *Rule) hasFailure() bool {
+	return r.Status != memdx.StatusSuccess || r.DropConnection || r.PartialResponse
+}
+
+func (r *Rule) matches(op *opInfo) bool {
+	if len(r.OpCodes) > 0 {
+		found := false
+		for _, opCode := range r.OpCodes {
+			if opCode == op.OpCode {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(r.Keys) > 0 {
+		found := false
+		for _, key := range r.Keys {
+			if bytes.Equal(key, op.Key) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(r.Endpoints) > 0 {
+		found := false
+		for _, endpoint := range r.Endpoints {
+			if endpoint == op.Endpoint {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+type opInfo struct {
+	OpCode   memdx.OpCode
+	Key      []byte
+	Endpoint string
+}
+
+// injection is the combined effect of all the rules applied to an operation.
+// Latencies accumulate, but only the first failure applies.
+type injection struct {
+	Latency time.Duration
+	Failure *Rule
+}
+
+type InjectorOptions struct {
+	// Seed seeds the random source used for probabilities and latencies,
+	// allowing a run to be reproduced.  Zero seeds from the current time.
+	Seed int64
+
+	// ErrorMap, when specified, is used to annotate injected server errors as
+	// a KvClient annotates real ones, so that they are retried according to
+	// the error map.
+	ErrorMap *memdx.ErrorMap
+}
+
+type injectorRule struct {
+	Rule       *Rule
+	NumApplied int
+}
+
+// Injector holds the set of rules shared by wrapped clients.  Rules may be
+// added and removed while operations are running.
+type Injector struct {
+	errorMap *memdx.ErrorMap
+
+	lock  sync.Mutex
+	rand  *rand.Rand
+	rules []*injectorRule
+}
+
+func NewInjector(opts *InjectorOptions) *Injector {
+	if opts == nil {
+		opts = &InjectorOptions{}
+	}
+
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &Injector{
+		errorMap: opts.ErrorMap,
+		rand:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// AddRule adds a rule after any existing rules.
+func (i *Injector) AddRule(rule *Rule) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+
+	i.lock.Lock()
+	i.rules = append(i.rules, &injectorRule{
+		Rule: rule,
+	})
+	i.lock.Unlock()
+
+	return nil
+}
+
+func (i *Injector) RemoveRule(rule *Rule) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for ruleIdx, foundRule := range i.rules {
+		if foundRule.Rule == rule {
+			i.rules = append(i.rules[:ruleIdx:ruleIdx], i.rules[ruleIdx+1:]...)
+			return
+		}
+	}
+}
+
+func (i *Injector) ClearRules() {
+	i.lock.Lock()
+	i.rules = nil
+	i.lock.Unlock()
+}
+
+// NumApplied returns the number of operations a rule has been applied to.
+func (i *Injector) NumApplied(rule *Rule) int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for _, foundRule := range i.rules {
+		if foundRule.Rule == rule {
+			return foundRule.NumApplied
+		}
+	}
+
+	return 0
+}
+
+func (i *Injector) selectInjection(op *opInfo) injection {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	var inj injection
+	for _, rule := range i.rules {
+		if rule.Rule.Limit > 0 && rule.NumApplied >= rule.Rule.Limit {
+			continue
+		}
+		if !rule.Rule.matches(op) {
+			continue
+		}
+		if rule.Rule.Probability > 0 && i.rand.Float64() >= rule.Rule.Probability {
+			continue
+		}
+
+		rule.NumApplied++
+
+		if rule.Rule.Latency != nil {
+			inj.Latency += rule.Rule.Latency.Sample(i.rand)
+		}
+		if inj.Failure == nil && rule.Rule.hasFailure() {
+			inj.Failure = rule.Rule
+		}
+	}
+
+	return inj
+}
@@ -0,0 +1,372 @@
+package faultinject
+
+import (
+	"context"
+	"time"
+
+	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+// KvClient wraps a gocbcorex.KvClient, applying the rules of an Injector to
+// every operation sent through it.
+type KvClient struct {
+	cli      gocbcorex.KvClient
+	injector *Injector
+}
+
+var _ gocbcorex.KvClient = (*KvClient)(nil)
+
+func NewKvClient(cli gocbcorex.KvClient, injector *Injector) *KvClient {
+	return &KvClient{
+		cli:      cli,
+		injector: injector,
+	}
+}
+
+// WrapNewKvClient wraps every client created by newKvClient, for use with
+// KvClientPoolOptions.NewKvClient.
+func WrapNewKvClient(newKvClient gocbcorex.NewKvClientFunc, injector *Injector) gocbcorex.NewKvClientFunc {
+	return func(ctx context.Context, config *gocbcorex.KvClientConfig) (gocbcorex.KvClient, error) {
+		cli, err := newKvClient(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewKvClient(cli, injector), nil
+	}
+}
+
+// Unwrap returns the client which this client wraps.
+func (c *KvClient) Unwrap() gocbcorex.KvClient {
+	return c.cli
+}
+
+func (c *KvClient) Reconfigure(config *gocbcorex.KvClientConfig, cb func(error)) error {
+	return c.cli.Reconfigure(config, cb)
+}
+
+func (c *KvClient) HasFeature(feat memdx.HelloFeature) bool {
+	return c.cli.HasFeature(feat)
+}
+
+func (c *KvClient) Close() error {
+	return c.cli.Close()
+}
+
+func (c *KvClient) LoadFactor() float64 {
+	return c.cli.LoadFactor()
+}
+
+func (c *KvClient) LoadStats() gocbcorex.KvClientLoadStats {
+	return c.cli.LoadStats()
+}
+
+func (c *KvClient) RemoteAddress() string {
+	return c.cli.RemoteAddress()
+}
+
+// statusDispatcher answers every request with a fixed status, which allows an
+// injected status to be decoded by the same encoder as a real response would
+// be, producing exactly the error the operation would return.
+type statusDispatcher struct {
+	status     memdx.Status
+	value      []byte
+	remoteAddr string
+}
+
+type statusPendingOp struct{}
+
+func (statusPendingOp) Cancel(err error) {}
+
+func (d *statusDispatcher) Dispatch(req *memdx.Packet, cb memdx.DispatchCallback) (memdx.PendingOp, error) {
+	cb(&memdx.Packet{
+		Magic:  memdx.MagicRes,
+		OpCode: req.OpCode,
+		Status: d.status,
+		Opaque: req.Opaque,
+		Value:  d.value,
+	}, nil)
+	return statusPendingOp{}, nil
+}
+
+func (d *statusDispatcher) LocalAddr() string {
+	return ""
+}
+
+func (d *statusDispatcher) RemoteAddr() string {
+	return d.remoteAddr
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	}
+}
+
+func kvClient_InjectedCall[Encoder any, ReqT any, RespT any](
+	ctx context.Context,
+	c *KvClient,
+	o Encoder,
+	opCode memdx.OpCode,
+	key []byte,
+	execFn func(o Encoder, d memdx.Dispatcher, req ReqT, cb func(RespT, error)) (memdx.PendingOp, error),
+	callFn func(ctx context.Context, req ReqT) (RespT, error),
+	req ReqT,
+) (RespT, error) {
+	var emptyResp RespT
+
+	inj := c.injector.selectInjection(&opInfo{
+		OpCode:   opCode,
+		Key:      key,
+		Endpoint: c.cli.RemoteAddress(),
+	})
+
+	if err := sleepContext(ctx, inj.Latency); err != nil {
+		return emptyResp, err
+	}
+
+	fault := inj.Failure
+	switch {
+	case fault == nil:
+		return callFn(ctx, req)
+	case fault.DropConnection:
+		_ = c.cli.Close()
+		return emptyResp, memdx.ErrClosedInFlight
+	case fault.PartialResponse:
+		_, _ = callFn(ctx, req)
+		_ = c.cli.Close()
+
+		// the request reached the server, so it may have been applied even
+		// though its response never arrives.
+		if gocbcorex.IsStateChangingRequest(req) {
+			return emptyResp, gocbcorex.AmbiguousOperationError{
+				Cause:        memdx.ErrClosedInFlight,
+				DispatchedTo: c.cli.RemoteAddress(),
+			}
+		}
+		return emptyResp, memdx.ErrClosedInFlight
+	}
+
+	var resp RespT
+	var respErr error
+	_, err := execFn(o, &statusDispatcher{
+		status:     fault.Status,
+		value:      fault.Value,
+		remoteAddr: c.cli.RemoteAddress(),
+	}, req, func(res RespT, err error) {
+		resp = res
+		respErr = err
+	})
+	if err != nil {
+		return emptyResp, gocbcorex.KvClientDispatchError{Cause: err}
+	}
+
+	return resp, c.injector.errorMap.AnnotateError(respErr)
+}
+
+func (c *KvClient) coreEncoder() memdx.OpsCore {
+	return memdx.OpsCore{}
+}
+
+func (c *KvClient) utilsEncoder() memdx.OpsUtils {
+	return memdx.OpsUtils{
+		ExtFramesEnabled: c.cli.HasFeature(memdx.HelloFeatureAltRequests),
+	}
+}
+
+func (c *KvClient) crudEncoder() memdx.OpsCrud {
+	return memdx.OpsCrud{
+		ExtFramesEnabled:      c.cli.HasFeature(memdx.HelloFeatureAltRequests),
+		CollectionsEnabled:    c.cli.HasFeature(memdx.HelloFeatureCollections),
+		DurabilityEnabled:     c.cli.HasFeature(memdx.HelloFeatureSyncReplication),
+		PreserveExpiryEnabled: c.cli.HasFeature(memdx.HelloFeaturePreserveExpiry),
+	}
+}
+
+func (c *KvClient) dcpEncoder() memdx.OpsDcp {
+	return memdx.OpsDcp{
+		CollectionsEnabled: c.cli.HasFeature(memdx.HelloFeatureCollections),
+	}
+}
+
+func (c *KvClient) GetCollectionID(ctx context.Context, req *memdx.GetCollectionIDRequest) (*memdx.GetCollectionIDResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.utilsEncoder(), memdx.OpCodeCollectionsGetID, nil,
+		memdx.OpsUtils.GetCollectionID, c.cli.GetCollectionID, req)
+}
+
+func (c *KvClient) GetCollectionsManifest(ctx context.Context, req *memdx.GetCollectionsManifestRequest) ([]byte, error) {
+	return kvClient_InjectedCall(ctx, c, c.utilsEncoder(), memdx.OpCodeCollectionsGetManifest, nil,
+		memdx.OpsUtils.GetCollectionsManifest, c.cli.GetCollectionsManifest, req)
+}
+
+func (c *KvClient) Stats(ctx context.Context, req *memdx.StatsRequest) (*memdx.StatsResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.utilsEncoder(), memdx.OpCodeStat, nil,
+		memdx.OpsUtils.Stats, c.cli.Stats, req)
+}
+
+func (c *KvClient) GetAllVbSeqnos(ctx context.Context, req *memdx.GetAllVbSeqnosRequest) (*memdx.GetAllVbSeqnosResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeGetAllVBSeqnos, nil,
+		memdx.OpsCrud.GetAllVbSeqnos, c.cli.GetAllVbSeqnos, req)
+}
+
+func (c *KvClient) GetClusterConfig(ctx context.Context, req *memdx.GetClusterConfigRequest) ([]byte, error) {
+	return kvClient_InjectedCall(ctx, c, c.coreEncoder(), memdx.OpCodeGetClusterConfig, nil,
+		memdx.OpsCore.GetClusterConfig, c.cli.GetClusterConfig, req)
+}
+
+func (c *KvClient) NoOp(ctx context.Context, req *memdx.NoOpRequest) (*memdx.NoOpResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.coreEncoder(), memdx.OpCodeNoOp, nil,
+		memdx.OpsCore.NoOp, c.cli.NoOp, req)
+}
+
+func (c *KvClient) Get(ctx context.Context, req *memdx.GetRequest) (*memdx.GetResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeGet, req.Key,
+		memdx.OpsCrud.Get, c.cli.Get, req)
+}
+
+func (c *KvClient) Set(ctx context.Context, req *memdx.SetRequest) (*memdx.SetResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeSet, req.Key,
+		memdx.OpsCrud.Set, c.cli.Set, req)
+}
+
+func (c *KvClient) Delete(ctx context.Context, req *memdx.DeleteRequest) (*memdx.DeleteResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeDelete, req.Key,
+		memdx.OpsCrud.Delete, c.cli.Delete, req)
+}
+
+func (c *KvClient) GetAndLock(ctx context.Context, req *memdx.GetAndLockRequest) (*memdx.GetAndLockResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeGetLocked, req.Key,
+		memdx.OpsCrud.GetAndLock, c.cli.GetAndLock, req)
+}
+
+func (c *KvClient) GetAndTouch(ctx context.Context, req *memdx.GetAndTouchRequest) (*memdx.GetAndTouchResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeGAT, req.Key,
+		memdx.OpsCrud.GetAndTouch, c.cli.GetAndTouch, req)
+}
+
+func (c *KvClient) GetReplica(ctx context.Context, req *memdx.GetReplicaRequest) (*memdx.GetReplicaResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeGetReplica, req.Key,
+		memdx.OpsCrud.GetReplica, c.cli.GetReplica, req)
+}
+
+func (c *KvClient) GetRandom(ctx context.Context, req *memdx.GetRandomRequest) (*memdx.GetRandomResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeGetRandom, nil,
+		memdx.OpsCrud.GetRandom, c.cli.GetRandom, req)
+}
+
+func (c *KvClient) Unlock(ctx context.Context, req *memdx.UnlockRequest) (*memdx.UnlockResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeUnlockKey, req.Key,
+		memdx.OpsCrud.Unlock, c.cli.Unlock, req)
+}
+
+func (c *KvClient) Touch(ctx context.Context, req *memdx.TouchRequest) (*memdx.TouchResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeTouch, req.Key,
+		memdx.OpsCrud.Touch, c.cli.Touch, req)
+}
+
+func (c *KvClient) Add(ctx context.Context, req *memdx.AddRequest) (*memdx.AddResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeAdd, req.Key,
+		memdx.OpsCrud.Add, c.cli.Add, req)
+}
+
+func (c *KvClient) Replace(ctx context.Context, req *memdx.ReplaceRequest) (*memdx.ReplaceResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeReplace, req.Key,
+		memdx.OpsCrud.Replace, c.cli.Replace, req)
+}
+
+func (c *KvClient) Append(ctx context.Context, req *memdx.AppendRequest) (*memdx.AppendResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeAppend, req.Key,
+		memdx.OpsCrud.Append, c.cli.Append, req)
+}
+
+func (c *KvClient) Prepend(ctx context.Context, req *memdx.PrependRequest) (*memdx.PrependResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodePrepend, req.Key,
+		memdx.OpsCrud.Prepend, c.cli.Prepend, req)
+}
+
+func (c *KvClient) Increment(ctx context.Context, req *memdx.IncrementRequest) (*memdx.IncrementResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeIncrement, req.Key,
+		memdx.OpsCrud.Increment, c.cli.Increment, req)
+}
+
+func (c *KvClient) Decrement(ctx context.Context, req *memdx.DecrementRequest) (*memdx.DecrementResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeDecrement, req.Key,
+		memdx.OpsCrud.Decrement, c.cli.Decrement, req)
+}
+
+func (c *KvClient) GetMeta(ctx context.Context, req *memdx.GetMetaRequest) (*memdx.GetMetaResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeGetMeta, req.Key,
+		memdx.OpsCrud.GetMeta, c.cli.GetMeta, req)
+}
+
+func (c *KvClient) SetMeta(ctx context.Context, req *memdx.SetMetaRequest) (*memdx.SetMetaResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeSetMeta, req.Key,
+		memdx.OpsCrud.SetMeta, c.cli.SetMeta, req)
+}
+
+func (c *KvClient) DeleteMeta(ctx context.Context, req *memdx.DeleteMetaRequest) (*memdx.DeleteMetaResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeDelMeta, req.Key,
+		memdx.OpsCrud.DeleteMeta, c.cli.DeleteMeta, req)
+}
+
+func (c *KvClient) LookupIn(ctx context.Context, req *memdx.LookupInRequest) (*memdx.LookupInResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeSubDocMultiLookup, req.Key,
+		memdx.OpsCrud.LookupIn, c.cli.LookupIn, req)
+}
+
+func (c *KvClient) MutateIn(ctx context.Context, req *memdx.MutateInRequest) (*memdx.MutateInResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeSubDocMultiMutation, req.Key,
+		memdx.OpsCrud.MutateIn, c.cli.MutateIn, req)
+}
+
+func (c *KvClient) Observe(ctx context.Context, req *memdx.ObserveRequest) (*memdx.ObserveResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeObserve, req.Key,
+		memdx.OpsCrud.Observe, c.cli.Observe, req)
+}
+
+func (c *KvClient) ObserveSeqNo(ctx context.Context, req *memdx.ObserveSeqNoRequest) (*memdx.ObserveSeqNoResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.crudEncoder(), memdx.OpCodeObserveSeqNo, nil,
+		memdx.OpsCrud.ObserveSeqNo, c.cli.ObserveSeqNo, req)
+}
+
+func (c *KvClient) DcpStreamReq(
+	ctx context.Context,
+	req *memdx.DcpStreamReqRequest,
+	handlers memdx.DcpStreamEventHandlers,
+) (*memdx.DcpStreamReqResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.dcpEncoder(), memdx.OpCodeDcpStreamReq, nil,
+		func(o memdx.OpsDcp, d memdx.Dispatcher, req *memdx.DcpStreamReqRequest, cb func(*memdx.DcpStreamReqResponse, error)) (memdx.PendingOp, error) {
+			return o.DcpStreamReq(d, req, handlers, cb)
+		},
+		func(ctx context.Context, req *memdx.DcpStreamReqRequest) (*memdx.DcpStreamReqResponse, error) {
+			return c.cli.DcpStreamReq(ctx, req, handlers)
+		}, req)
+}
+
+func (c *KvClient) DcpCloseStream(ctx context.Context, req *memdx.DcpCloseStreamRequest) error {
+	_, err := kvClient_InjectedCall(ctx, c, c.dcpEncoder(), memdx.OpCodeDcpCloseStream, nil,
+		func(o memdx.OpsDcp, d memdx.Dispatcher, req *memdx.DcpCloseStreamRequest, cb func(struct{}, error)) (memdx.PendingOp, error) {
+			return o.DcpCloseStream(d, req, func(err error) {
+				cb(struct{}{}, err)
+			})
+		},
+		func(ctx context.Context, req *memdx.DcpCloseStreamRequest) (struct{}, error) {
+			return struct{}{}, c.cli.DcpCloseStream(ctx, req)
+		}, req)
+	return err
+}
+
+func (c *KvClient) DcpGetFailoverLog(ctx context.Context, req *memdx.DcpGetFailoverLogRequest) (*memdx.DcpGetFailoverLogResponse, error) {
+	return kvClient_InjectedCall(ctx, c, c.dcpEncoder(), memdx.OpCodeDcpGetFailoverLog, nil,
+		memdx.OpsDcp.DcpGetFailoverLog, c.cli.DcpGetFailoverLog, req)
+}
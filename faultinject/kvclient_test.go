@@ -0,0 +1,390 @@
+package faultinject
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/cbmgmtx/mgmttest"
+	"github.com/couchbase/gocbcorex/memdx"
+	"github.com/couchbase/gocbcorex/memdx/memdtest"
+)
+
+const (
+	testUsername = "Administrator"
+	testPassword = "password"
+	testBucket   = "default"
+)
+
+func startTestServer(t *testing.T) *memdtest.Server {
+	srv, err := memdtest.NewServer(&memdtest.ServerOptions{
+		Users:   map[string]string{testUsername: testPassword},
+		Buckets: []*memdtest.Bucket{memdtest.NewBucket(&memdtest.BucketOptions{Name: testBucket})},
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = srv.Close()
+	})
+
+	return srv
+}
+
+func testClientConfig(srv *memdtest.Server) *gocbcorex.KvClientConfig {
+	return &gocbcorex.KvClientConfig{
+		Address: srv.Addr(),
+		Authenticator: &gocbcorex.PasswordAuthenticator{
+			Username: testUsername,
+			Password: testPassword,
+		},
+		SelectedBucket: testBucket,
+	}
+}
+
+func newTestClient(t *testing.T, srv *memdtest.Server) gocbcorex.KvClient {
+	cli, err := gocbcorex.NewKvClient(context.Background(), testClientConfig(srv), &gocbcorex.KvClientOptions{})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = cli.Close()
+	})
+
+	return cli
+}
+
+func testSet(ctx context.Context, cli gocbcorex.KvClient, key string) error {
+	_, err := cli.Set(ctx, &memdx.SetRequest{
+		Key:   []byte(key),
+		Value: []byte(`{"foo":"bar"}`),
+	})
+	return err
+}
+
+func TestStatusInjection(t *testing.T) {
+	ctx := context.Background()
+	srv := startTestServer(t)
+	injector := NewInjector(&InjectorOptions{})
+	cli := NewKvClient(newTestClient(t, srv), injector)
+
+	configJson := []byte(`{"rev":123}`)
+	rules := []*Rule{
+		{Keys: [][]byte{[]byte("tmpfail")}, Status: memdx.StatusTmpFail},
+		{Keys: [][]byte{[]byte("locked")}, Status: memdx.StatusLocked},
+		{Keys: [][]byte{[]byte("nmv")}, Status: memdx.StatusNotMyVBucket, Value: configJson},
+		{Keys: [][]byte{[]byte("unknown-collection")}, Status: memdx.StatusCollectionUnknown},
+	}
+	for _, rule := range rules {
+		require.NoError(t, injector.AddRule(rule))
+	}
+
+	err := testSet(ctx, cli, "tmpfail")
+	var serverErr memdx.ServerError
+	require.True(t, errors.As(err, &serverErr))
+	assert.Equal(t, memdx.StatusTmpFail, serverErr.Status)
+	assert.Equal(t, srv.Addr(), serverErr.DispatchedTo)
+
+	err = testSet(ctx, cli, "locked")
+	require.True(t, errors.As(err, &serverErr))
+	assert.Equal(t, memdx.StatusLocked, serverErr.Status)
+
+	err = testSet(ctx, cli, "nmv")
+	assert.ErrorIs(t, err, memdx.ErrNotMyVbucket)
+	var configErr memdx.ServerErrorWithConfig
+	require.True(t, errors.As(err, &configErr))
+	assert.Equal(t, configJson, configErr.ConfigJson)
+
+	err = testSet(ctx, cli, "unknown-collection")
+	assert.ErrorIs(t, err, memdx.ErrUnknownCollectionID)
+
+	// none of the failed operations should have reached the server
+	for _, key := range []string{"tmpfail", "locked", "nmv", "unknown-collection"} {
+		_, err := cli.Unwrap().Get(ctx, &memdx.GetRequest{
+			Key: []byte(key),
+		})
+		assert.ErrorIs(t, err, memdx.ErrDocNotFound)
+	}
+
+	require.NoError(t, testSet(ctx, cli, "other"))
+
+	for _, rule := range rules {
+		assert.Equal(t, 1, injector.NumApplied(rule))
+	}
+}
+
+func TestRuleSelection(t *testing.T) {
+	ctx := context.Background()
+	srv := startTestServer(t)
+	injector := NewInjector(&InjectorOptions{
+		Seed: 1,
+	})
+	cli := NewKvClient(newTestClient(t, srv), injector)
+
+	opCodeRule := &Rule{
+		OpCodes: []memdx.OpCode{memdx.OpCodeDelete},
+		Status:  memdx.StatusTmpFail,
+	}
+	endpointRule := &Rule{
+		Endpoints: []string{"192.0.2.1:11210"},
+		Status:    memdx.StatusTmpFail,
+	}
+	limitedRule := &Rule{
+		Keys:   [][]byte{[]byte("limited")},
+		Limit:  3,
+		Status: memdx.StatusTmpFail,
+	}
+	randomRule := &Rule{
+		Keys:        [][]byte{[]byte("random")},
+		Probability: 0.25,
+		Status:      memdx.StatusTmpFail,
+	}
+	for _, rule := range []*Rule{opCodeRule, endpointRule, limitedRule, randomRule} {
+		require.NoError(t, injector.AddRule(rule))
+	}
+
+	require.NoError(t, testSet(ctx, cli, "doc"))
+
+	_, err := cli.Delete(ctx, &memdx.DeleteRequest{
+		Key: []byte("doc"),
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, injector.NumApplied(opCodeRule))
+	assert.Equal(t, 0, injector.NumApplied(endpointRule))
+
+	numFailed := 0
+	for i := 0; i < 5; i++ {
+		if testSet(ctx, cli, "limited") != nil {
+			numFailed++
+		}
+	}
+	assert.Equal(t, 3, numFailed)
+
+	numFailed = 0
+	for i := 0; i < 400; i++ {
+		if testSet(ctx, cli, "random") != nil {
+			numFailed++
+		}
+	}
+	assert.InDelta(t, 100, numFailed, 40)
+	assert.Equal(t, numFailed, injector.NumApplied(randomRule))
+
+	injector.RemoveRule(randomRule)
+	require.NoError(t, testSet(ctx, cli, "random"))
+
+	assert.ErrorIs(t, injector.AddRule(&Rule{Probability: 2}), ErrInvalidProbability)
+	assert.ErrorIs(t, injector.AddRule(&Rule{
+		Status:         memdx.StatusTmpFail,
+		DropConnection: true,
+	}), ErrConflictingFaults)
+}
+
+func TestLatencyInjection(t *testing.T) {
+	ctx := context.Background()
+	srv := startTestServer(t)
+	injector := NewInjector(&InjectorOptions{})
+	cli := NewKvClient(newTestClient(t, srv), injector)
+
+	require.NoError(t, injector.AddRule(&Rule{
+		OpCodes: []memdx.OpCode{memdx.OpCodeSet},
+		Latency: FixedLatency(50 * time.Millisecond),
+	}))
+
+	start := time.Now()
+	require.NoError(t, testSet(ctx, cli, "slow"))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	err := testSet(timeoutCtx, cli, "slow")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLatencyDistributions(t *testing.T) {
+	injector := NewInjector(&InjectorOptions{
+		Seed: 1,
+	})
+
+	for i := 0; i < 100; i++ {
+		d := UniformLatency{Min: 10 * time.Millisecond, Max: 20 * time.Millisecond}.Sample(injector.rand)
+		assert.GreaterOrEqual(t, d, 10*time.Millisecond)
+		assert.Less(t, d, 20*time.Millisecond)
+
+		d = NormalLatency{Mean: time.Millisecond, StdDev: 10 * time.Millisecond}.Sample(injector.rand)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+
+		d = LogNormalLatency{Median: time.Millisecond, Sigma: 1}.Sample(injector.rand)
+		assert.Greater(t, d, time.Duration(0))
+	}
+}
+
+func TestConnectionFaults(t *testing.T) {
+	ctx := context.Background()
+	srv := startTestServer(t)
+	injector := NewInjector(&InjectorOptions{})
+
+	partialRule := &Rule{
+		Keys:            [][]byte{[]byte("partial")},
+		PartialResponse: true,
+	}
+	dropRule := &Rule{
+		Keys:           [][]byte{[]byte("dropped")},
+		DropConnection: true,
+	}
+	require.NoError(t, injector.AddRule(partialRule))
+	require.NoError(t, injector.AddRule(dropRule))
+
+	// a partial response is applied by the server but never acknowledged
+	cli := NewKvClient(newTestClient(t, srv), injector)
+	err := testSet(ctx, cli, "partial")
+	assert.ErrorIs(t, err, memdx.ErrClosedInFlight)
+	var ambiguousErr gocbcorex.AmbiguousOperationError
+	assert.True(t, errors.As(err, &ambiguousErr))
+	assert.Error(t, testSet(ctx, cli, "other"))
+
+	// a dropped connection never sends the operation
+	cli = NewKvClient(newTestClient(t, srv), injector)
+	err = testSet(ctx, cli, "dropped")
+	assert.ErrorIs(t, err, memdx.ErrClosedInFlight)
+	assert.False(t, errors.As(err, &ambiguousErr))
+	assert.Error(t, testSet(ctx, cli, "other"))
+
+	checkCli := newTestClient(t, srv)
+	_, err = checkCli.Get(ctx, &memdx.GetRequest{
+		Key: []byte("partial"),
+	})
+	assert.NoError(t, err)
+
+	_, err = checkCli.Get(ctx, &memdx.GetRequest{
+		Key: []byte("dropped"),
+	})
+	assert.ErrorIs(t, err, memdx.ErrDocNotFound)
+}
+
+func TestPartialResponseMutationNotRetried(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cluster, err := mgmttest.NewCluster(&mgmttest.ClusterOptions{
+		Users: map[string]string{testUsername: testPassword},
+	})
+	require.NoError(t, err)
+	defer cluster.Close()
+
+	_, err = cluster.CreateBucket(&mgmttest.BucketOptions{
+		Name: testBucket,
+	})
+	require.NoError(t, err)
+
+	injector := NewInjector(&InjectorOptions{})
+	partialRule := &Rule{
+		Keys:            [][]byte{[]byte("partial")},
+		PartialResponse: true,
+	}
+	require.NoError(t, injector.AddRule(partialRule))
+
+	agent, err := gocbcorex.CreateAgent(ctx, gocbcorex.AgentOptions{
+		BucketName: testBucket,
+		Authenticator: &gocbcorex.PasswordAuthenticator{
+			Username: testUsername,
+			Password: testPassword,
+		},
+		SeedConfig: gocbcorex.SeedConfig{
+			HTTPAddrs: []string{cluster.Nodes()[0].MgmtAddr()},
+		},
+		WrapKvClientManager: func(mgr gocbcorex.KvClientManager) gocbcorex.KvClientManager {
+			return NewKvClientManager(mgr, injector)
+		},
+	})
+	require.NoError(t, err)
+	defer agent.Close()
+
+	// the mutation may have been applied, so sending it again could apply
+	// it twice, and the caller must be told its outcome is unknown instead.
+	_, err = agent.Upsert(ctx, &gocbcorex.UpsertOptions{
+		Key:            []byte("partial"),
+		ScopeName:      "_default",
+		CollectionName: "_default",
+		Value:          []byte(`{"foo":"bar"}`),
+	})
+	var ambiguousErr gocbcorex.AmbiguousOperationError
+	require.True(t, errors.As(err, &ambiguousErr), "unexpected error: %v", err)
+	assert.Equal(t, 1, injector.NumApplied(partialRule))
+}
+
+func TestErrorMapAnnotation(t *testing.T) {
+	ctx := context.Background()
+	srv := startTestServer(t)
+
+	errorMap, err := memdx.ParseErrorMap([]byte(`{
+		"version": 1,
+		"revision": 1,
+		"errors": {
+			"86": {
+				"name": "ETMPFAIL",
+				"desc": "Temporary failure",
+				"attrs": ["temp", "retry-later"]
+			}
+		}
+	}`))
+	require.NoError(t, err)
+
+	injector := NewInjector(&InjectorOptions{
+		ErrorMap: errorMap,
+	})
+	cli := NewKvClient(newTestClient(t, srv), injector)
+
+	require.NoError(t, injector.AddRule(&Rule{
+		Status: memdx.StatusTmpFail,
+		Limit:  2,
+	}))
+
+	res, err := gocbcorex.OrchestrateMemdRetries(ctx, gocbcorex.NewRetryManagerErrorMap(nil), func() (*memdx.SetResponse, error) {
+		return cli.Set(ctx, &memdx.SetRequest{
+			Key:   []byte("retried"),
+			Value: []byte(`{"foo":"bar"}`),
+		})
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, res.Cas)
+}
+
+func TestKvClientManager(t *testing.T) {
+	ctx := context.Background()
+	srv := startTestServer(t)
+	injector := NewInjector(&InjectorOptions{})
+
+	baseMgr, err := gocbcorex.NewKvClientManager(&gocbcorex.KvClientManagerConfig{
+		NumPoolConnections: 1,
+		Clients: map[string]*gocbcorex.KvClientConfig{
+			"node-a": testClientConfig(srv),
+		},
+	}, nil)
+	require.NoError(t, err)
+	mgr := NewKvClientManager(baseMgr, injector)
+
+	require.NoError(t, injector.AddRule(&Rule{
+		Endpoints: []string{srv.Addr()},
+		Status:    memdx.StatusTmpFail,
+	}))
+
+	_, err = gocbcorex.OrchestrateMemdClient(ctx, mgr, "node-a", func(client gocbcorex.KvClient) (*memdx.SetResponse, error) {
+		return client.Set(ctx, &memdx.SetRequest{
+			Key:   []byte("doc"),
+			Value: []byte(`{"foo":"bar"}`),
+		})
+	})
+	var serverErr memdx.ServerError
+	require.True(t, errors.As(err, &serverErr))
+	assert.Equal(t, memdx.StatusTmpFail, serverErr.Status)
+
+	cli, err := mgr.GetRandomClient(ctx)
+	require.NoError(t, err)
+	require.IsType(t, &KvClient{}, cli)
+
+	mgr.ShutdownClient("node-a", cli)
+}
@@ -0,0 +1,58 @@
+package faultinject
+
+import (
+	"context"
+
+	"github.com/couchbase/gocbcorex"
+)
+
+// KvClientManager wraps a gocbcorex.KvClientManager so that every client it
+// returns applies the rules of an Injector.
+type KvClientManager struct {
+	mgr      gocbcorex.KvClientManager
+	injector *Injector
+}
+
+var _ gocbcorex.KvClientManager = (*KvClientManager)(nil)
+
+func NewKvClientManager(mgr gocbcorex.KvClientManager, injector *Injector) *KvClientManager {
+	return &KvClientManager{
+		mgr:      mgr,
+		injector: injector,
+	}
+}
+
+func (m *KvClientManager) ShutdownClient(endpoint string, client gocbcorex.KvClient) {
+	// the wrapped manager only knows about the clients it created itself
+	if wrappedCli, ok := client.(*KvClient); ok {
+		client = wrappedCli.Unwrap()
+	}
+
+	m.mgr.ShutdownClient(endpoint, client)
+}
+
+func (m *KvClientManager) GetClient(ctx context.Context, endpoint string) (gocbcorex.KvClient, error) {
+	cli, err := m.mgr.GetClient(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKvClient(cli, m.injector), nil
+}
+
+func (m *KvClientManager) Reconfigure(opts *gocbcorex.KvClientManagerConfig, cb func(error)) error {
+	return m.mgr.Reconfigure(opts, cb)
+}
+
+func (m *KvClientManager) GetRandomClient(ctx context.Context) (gocbcorex.KvClient, error) {
+	cli, err := m.mgr.GetRandomClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKvClient(cli, m.injector), nil
+}
+
+func (m *KvClientManager) GetEndpoints() []string {
+	return m.mgr.GetEndpoints()
+}
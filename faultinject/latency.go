@@ -0,0 +1,60 @@
+package faultinject
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// LatencyDistribution produces the delays applied to operations.
+type LatencyDistribution interface {
+	Sample(r *rand.Rand) time.Duration
+}
+
+// FixedLatency delays every operation by the same duration.
+type FixedLatency time.Duration
+
+func (l FixedLatency) Sample(r *rand.Rand) time.Duration {
+	return time.Duration(l)
+}
+
+// UniformLatency delays operations by a duration chosen uniformly between Min
+// and Max.
+type UniformLatency struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+func (l UniformLatency) Sample(r *rand.Rand) time.Duration {
+	if l.Max <= l.Min {
+		return l.Min
+	}
+	return l.Min + time.Duration(r.Int63n(int64(l.Max-l.Min)))
+}
+
+// NormalLatency delays operations by a normally distributed duration, which
+// is never negative.
+type NormalLatency struct {
+	Mean   time.Duration
+	StdDev time.Duration
+}
+
+func (l NormalLatency) Sample(r *rand.Rand) time.Duration {
+	d := time.Duration(r.NormFloat64()*float64(l.StdDev)) + l.Mean
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// LogNormalLatency delays operations by a log-normally distributed duration,
+// which produces the long tail typical of real server latencies.  Median is
+// the median delay and Sigma the standard deviation of its logarithm.
+type LogNormalLatency struct {
+	Median time.Duration
+	Sigma  float64
+}
+
+func (l LogNormalLatency) Sample(r *rand.Rand) time.Duration {
+	return time.Duration(float64(l.Median) * math.Exp(r.NormFloat64()*l.Sigma))
+}
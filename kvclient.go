@@ -6,6 +6,7 @@ import (
 	"errors"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/exp/slices"
@@ -24,6 +25,14 @@ type KvClientConfig struct {
 	DisableDefaultFeatures bool
 	DisableErrorMap        bool
 
+	// Dcp, when specified, causes the client to open a DCP producer connection
+	// once bootstrapping has completed.
+	Dcp *KvClientDcpConfig
+
+	// Heartbeat, when specified, causes the client to send NOOPs whenever the
+	// connection is idle, and to close the connection if they go unanswered.
+	Heartbeat *memdx.HeartbeatOptions
+
 	// DisableBootstrap provides a simple way to validate that all bootstrapping
 	// is disabled on the client, mainly used for testing.
 	DisableBootstrap bool
@@ -37,17 +46,43 @@ func (o KvClientConfig) Equals(b *KvClientConfig) bool {
 		o.SelectedBucket == b.SelectedBucket &&
 		o.DisableDefaultFeatures == b.DisableDefaultFeatures &&
 		o.DisableErrorMap == b.DisableErrorMap &&
+		o.Dcp == b.Dcp &&
+		o.Heartbeat == b.Heartbeat &&
 		o.DisableBootstrap == b.DisableBootstrap
 }
 
+// KvClientOptions holds the options of a KvClient which cannot be changed by
+// reconfiguring it.  The handlers are supplied by users, so unlike the fields
+// of KvClientConfig, they may not be comparable.
 type KvClientOptions struct {
 	Logger         *zap.Logger
 	NewMemdxClient GetMemdxClientFunc
+
+	// ClusterMapChangeHandler, when specified, causes the client to request
+	// that the server pushes cluster map changes to it, which are then passed
+	// to the handler.
+	ClusterMapChangeHandler ClusterMapChangeHandler
+
+	// OrphanedResponseHandler, when specified, receives the responses which
+	// arrive after the operation waiting for them was cancelled.
+	OrphanedResponseHandler OrphanedResponseHandler
+
+	// Meter, when specified, receives the number of bytes sent and received
+	// by the client, and the opening and closing of its connection.
+	Meter Meter
+
+	// CloseHandler is invoked once the connection of the client has closed,
+	// whether it was closed by Close or was found to be dead.
+	CloseHandler func(KvClient, error)
 }
 
 type KvClientOps interface {
 	GetCollectionID(ctx context.Context, req *memdx.GetCollectionIDRequest) (*memdx.GetCollectionIDResponse, error)
+	GetCollectionsManifest(ctx context.Context, req *memdx.GetCollectionsManifestRequest) ([]byte, error)
+	Stats(ctx context.Context, req *memdx.StatsRequest) (*memdx.StatsResponse, error)
+	GetAllVbSeqnos(ctx context.Context, req *memdx.GetAllVbSeqnosRequest) (*memdx.GetAllVbSeqnosResponse, error)
 	GetClusterConfig(ctx context.Context, req *memdx.GetClusterConfigRequest) ([]byte, error)
+	NoOp(ctx context.Context, req *memdx.NoOpRequest) (*memdx.NoOpResponse, error)
 	Get(ctx context.Context, req *memdx.GetRequest) (*memdx.GetResponse, error)
 	Set(ctx context.Context, req *memdx.SetRequest) (*memdx.SetResponse, error)
 	Delete(ctx context.Context, req *memdx.DeleteRequest) (*memdx.DeleteResponse, error)
@@ -68,6 +103,11 @@ type KvClientOps interface {
 	DeleteMeta(ctx context.Context, req *memdx.DeleteMetaRequest) (*memdx.DeleteMetaResponse, error)
 	LookupIn(ctx context.Context, req *memdx.LookupInRequest) (*memdx.LookupInResponse, error)
 	MutateIn(ctx context.Context, req *memdx.MutateInRequest) (*memdx.MutateInResponse, error)
+	Observe(ctx context.Context, req *memdx.ObserveRequest) (*memdx.ObserveResponse, error)
+	ObserveSeqNo(ctx context.Context, req *memdx.ObserveSeqNoRequest) (*memdx.ObserveSeqNoResponse, error)
+	DcpStreamReq(ctx context.Context, req *memdx.DcpStreamReqRequest, handlers memdx.DcpStreamEventHandlers) (*memdx.DcpStreamReqResponse, error)
+	DcpCloseStream(ctx context.Context, req *memdx.DcpCloseStreamRequest) error
+	DcpGetFailoverLog(ctx context.Context, req *memdx.DcpGetFailoverLogRequest) (*memdx.DcpGetFailoverLogResponse, error)
 }
 
 // KvClient implements a synchronous wrapper around a memdx.Client.
@@ -79,12 +119,25 @@ type KvClient interface {
 	Close() error
 
 	LoadFactor() float64
+	LoadStats() KvClientLoadStats
 
 	RemoteAddress() string
 
 	KvClientOps
 }
 
+// KvClientLoadStats is a snapshot of the load placed on a KvClient.
+type KvClientLoadStats struct {
+	PendingOperations uint64
+	PendingBytes      uint64
+
+	// CompletedOperations and TotalLatency accumulate over the lifetime of the
+	// client, so the average latency over a period can be found by comparing
+	// two snapshots.
+	CompletedOperations uint64
+	TotalLatency        time.Duration
+}
+
 type MemdxDispatcherCloser interface {
 	memdx.Dispatcher
 	Close() error
@@ -93,13 +146,28 @@ type MemdxDispatcherCloser interface {
 type kvClient struct {
 	logger *zap.Logger
 
-	pendingOperations uint64
-	cli               MemdxDispatcherCloser
+	pendingOperations   uint64
+	pendingBytes        uint64
+	completedOperations uint64
+	totalLatencyNanos   uint64
+	cli                 MemdxDispatcherCloser
+
+	// the counters are created up front, as they are updated for every
+	// packet, and are nil when the client has no meter.
+	bytesSentCounter     MeterCounter
+	bytesReceivedCounter MeterCounter
+
+	clusterMapChangeHandler ClusterMapChangeHandler
+	orphanedResponseHandler OrphanedResponseHandler
 
 	lock          sync.Mutex
 	currentConfig KvClientConfig
 
 	supportedFeatures []memdx.HelloFeature
+	errorMap          *memdx.ErrorMap
+
+	dcpBufferSize   uint32
+	dcpUnackedBytes uint32
 }
 
 var _ KvClient = (*kvClient)(nil)
@@ -108,6 +176,21 @@ func NewKvClient(ctx context.Context, config *KvClientConfig, opts *KvClientOpti
 	kvCli := &kvClient{
 		logger:        loggerOrNop(opts.Logger),
 		currentConfig: *config,
+
+		clusterMapChangeHandler: opts.ClusterMapChangeHandler,
+		orphanedResponseHandler: opts.OrphanedResponseHandler,
+	}
+
+	var connsOpenedCounter, connsClosedCounter MeterCounter
+	if opts.Meter != nil {
+		meterAttribs := map[string]string{
+			meterAttribService:     meterServiceKv,
+			meterAttribNetPeerName: config.Address,
+		}
+		kvCli.bytesSentCounter = opts.Meter.Counter(meterNameBytesSent, meterAttribs)
+		kvCli.bytesReceivedCounter = opts.Meter.Counter(meterNameBytesReceived, meterAttribs)
+		connsOpenedCounter = opts.Meter.Counter(meterNameConnectionsOpened, meterAttribs)
+		connsClosedCounter = opts.Meter.Counter(meterNameConnectionsClosed, meterAttribs)
 	}
 
 	var requestedFeatures []memdx.HelloFeature
@@ -128,8 +211,14 @@ func NewKvClient(ctx context.Context, config *KvClientConfig, opts *KvClientOpti
 			memdx.HelloFeatureCreateAsDeleted,
 			memdx.HelloFeatureAltRequests,
 			memdx.HelloFeatureCollections,
+			memdx.HelloFeatureOpenTracing,
 		}
 	}
+	if opts.ClusterMapChangeHandler != nil {
+		requestedFeatures = append(requestedFeatures,
+			memdx.HelloFeatureDuplex,
+			memdx.HelloFeatureClusterMapNotif)
+	}
 
 	var bootstrapHello *memdx.HelloRequest
 	if config.ClientName != "" || len(requestedFeatures) > 0 {
@@ -146,20 +235,21 @@ func NewKvClient(ctx context.Context, config *KvClientConfig, opts *KvClientOpti
 		}
 	}
 
+	clientCert, err := kvClientClientCertificate(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// a client certificate authenticates the connection during the TLS
+	// handshake, in which case SASL is not used at all.
 	var bootstrapAuth *memdx.SaslAuthAutoOptions
-	if config.Authenticator != nil {
-		username, password, err := config.Authenticator.GetCredentials(ServiceTypeMemd, config.Address)
+	if config.Authenticator != nil && clientCert == nil {
+		authOpts, err := kvClientSaslAuthOptions(config)
 		if err != nil {
 			return nil, err
 		}
 
-		bootstrapAuth = &memdx.SaslAuthAutoOptions{
-			Username: username,
-			Password: password,
-			EnabledMechs: []memdx.AuthMechanism{
-				memdx.ScramSha512AuthMechanism,
-				memdx.ScramSha256AuthMechanism},
-		}
+		bootstrapAuth = authOpts
 	}
 
 	var bootstrapSelectBucket *memdx.SelectBucketRequest
@@ -176,11 +266,22 @@ func NewKvClient(ctx context.Context, config *KvClientConfig, opts *KvClientOpti
 	}
 
 	memdxClientOpts := &memdx.ClientOptions{
-		OrphanHandler: nil,
-		CloseHandler:  nil,
+		OrphanHandler: kvCli.handleOrphan,
+		CloseHandler: func(err error) {
+			if connsClosedCounter != nil {
+				connsClosedCounter.Add(1)
+			}
+
+			if opts.CloseHandler != nil {
+				opts.CloseHandler(kvCli, err)
+			}
+		},
+		Heartbeat: config.Heartbeat,
 	}
 	if opts.NewMemdxClient == nil {
-		conn, err := memdx.DialConn(ctx, config.Address, &memdx.DialConnOptions{TLSConfig: config.TlsConfig})
+		conn, err := memdx.DialConn(ctx, config.Address, &memdx.DialConnOptions{
+			TLSConfig: clientCertificateTlsConfig(config.TlsConfig, clientCert),
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -189,6 +290,9 @@ func NewKvClient(ctx context.Context, config *KvClientConfig, opts *KvClientOpti
 	} else {
 		kvCli.cli = opts.NewMemdxClient(memdxClientOpts)
 	}
+	if connsOpenedCounter != nil {
+		connsOpenedCounter.Add(1)
+	}
 
 	if shouldBootstrap {
 		res, err := kvCli.bootstrap(ctx, &memdx.BootstrapOptions{
@@ -209,13 +313,68 @@ func NewKvClient(ctx context.Context, config *KvClientConfig, opts *KvClientOpti
 			zap.Any("features", res.Hello.EnabledFeatures))
 
 		kvCli.supportedFeatures = res.Hello.EnabledFeatures
+
+		if res.ErrorMap != nil {
+			errorMap, err := memdx.ParseErrorMap(res.ErrorMap)
+			if err != nil {
+				// a broken error map only means we lose the additional error
+				// information, so we do not fail the connection for it.
+				kvCli.logger.Debug("failed to parse error map", zap.Error(err))
+			} else {
+				kvCli.errorMap = errorMap
+			}
+		}
 	} else {
 		kvCli.logger.Debug("skipped bootstrapping new KvClient")
 	}
 
+	if config.Dcp != nil {
+		err := kvCli.dcpOpen(ctx, config.Dcp)
+		if err != nil {
+			if closeErr := kvCli.Close(); closeErr != nil {
+				kvCli.logger.Debug("failed to close connection for KvClient", zap.Error(closeErr))
+			}
+			return nil, err
+		}
+	}
+
 	return kvCli, nil
 }
 
+func kvClientClientCertificate(config *KvClientConfig) (*tls.Certificate, error) {
+	if config.Authenticator == nil {
+		return nil, nil
+	}
+
+	clientCert, err := config.Authenticator.GetClientCertificate(ServiceTypeMemd, config.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	// without TLS the certificate could never be presented, and falling back
+	// to SASL would only fail later with a misleading authentication error.
+	if clientCert != nil && config.TlsConfig == nil {
+		return nil, ErrClientCertificateRequiresTls
+	}
+
+	return clientCert, nil
+}
+
+func kvClientSaslAuthOptions(config *KvClientConfig) (*memdx.SaslAuthAutoOptions, error) {
+	username, password, err := config.Authenticator.GetCredentials(ServiceTypeMemd, config.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memdx.SaslAuthAutoOptions{
+		Username: username,
+		Password: password,
+		EnabledMechs: []memdx.AuthMechanism{
+			memdx.ScramSha512AuthMechanism,
+			memdx.ScramSha256AuthMechanism},
+	}, nil
+}
+
 func (c *kvClient) Reconfigure(config *KvClientConfig, cb func(error)) error {
 	if config == nil {
 		return errors.New("must specify a configuration to reconfigure to")
@@ -230,6 +389,8 @@ func (c *kvClient) Reconfigure(config *KvClientConfig, cb func(error)) error {
 		c.currentConfig.Authenticator != config.Authenticator ||
 		c.currentConfig.DisableDefaultFeatures != config.DisableDefaultFeatures ||
 		c.currentConfig.DisableErrorMap != config.DisableErrorMap ||
+		c.currentConfig.Dcp != config.Dcp ||
+		c.currentConfig.Heartbeat != config.Heartbeat ||
 		c.currentConfig.DisableBootstrap != config.DisableBootstrap {
 		// pretty much everything triggers a reconfigure
 		return errors.New("cannot reconfigure due to conflicting options")
@@ -281,8 +442,26 @@ func (c *kvClient) Close() error {
 	return c.cli.Close()
 }
 
+// kvClientBytesPerLoadUnit is the number of in-flight request bytes which
+// count as much towards the load factor as one in-flight operation.
+const kvClientBytesPerLoadUnit = 16 * 1024
+
+// LoadFactor reflects both the number of operations in flight on the client
+// and their size, so that a connection busy with a large value is considered
+// more loaded than one with a few small requests.
 func (c *kvClient) LoadFactor() float64 {
-	return (float64)(atomic.LoadUint64(&c.pendingOperations))
+	pendingOperations := atomic.LoadUint64(&c.pendingOperations)
+	pendingBytes := atomic.LoadUint64(&c.pendingBytes)
+	return float64(pendingOperations) + float64(pendingBytes)/kvClientBytesPerLoadUnit
+}
+
+func (c *kvClient) LoadStats() KvClientLoadStats {
+	return KvClientLoadStats{
+		PendingOperations:   atomic.LoadUint64(&c.pendingOperations),
+		PendingBytes:        atomic.LoadUint64(&c.pendingBytes),
+		CompletedOperations: atomic.LoadUint64(&c.completedOperations),
+		TotalLatency:        time.Duration(atomic.LoadUint64(&c.totalLatencyNanos)),
+	}
 }
 
 func (c *kvClient) RemoteAddress() string {
@@ -0,0 +1,68 @@
+package gocbcorex
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/contrib/cbconfig"
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+// ClusterMapChangeHandler receives cluster maps which the server pushes to a
+// KvClient over a duplex connection.
+type ClusterMapChangeHandler interface {
+	HandleClusterMapChange(config *cbconfig.TerseConfigJson, sourceHostname string)
+}
+
+func (c *kvClient) handleServerRequest(pak *memdx.Packet) {
+	if pak.Magic != memdx.MagicServerReq {
+		c.logger.Debug("dropping unexpected orphaned packet",
+			zap.Stringer("opcode", pak.OpCode),
+			zap.Uint32("opaque", pak.Opaque))
+		return
+	}
+
+	switch memdx.ServerOpCode(pak.OpCode) {
+	case memdx.ServerOpCodeClustermapChangeNotification:
+		c.handleClustermapChangeNotification(pak)
+	default:
+		c.logger.Debug("dropping unsupported server request",
+			zap.Stringer("opcode", memdx.ServerOpCode(pak.OpCode)))
+	}
+}
+
+func (c *kvClient) handleClustermapChangeNotification(pak *memdx.Packet) {
+	notif, err := memdx.ParseClustermapChangeNotification(pak)
+	if err != nil {
+		c.logger.Debug("failed to parse clustermap change notification", zap.Error(err))
+		return
+	}
+
+	if len(notif.Config) == 0 {
+		// we only apply notifications which carry the config itself, the
+		// config watcher will pick up the change otherwise.
+		c.logger.Debug("ignoring clustermap change notification without a config",
+			zap.Int64("revEpoch", notif.RevEpoch),
+			zap.Int64("rev", notif.Rev))
+		return
+	}
+
+	host := hostnameFromAddress(c.RemoteAddress())
+	if host == "" {
+		c.logger.Debug("failed to identify memd hostname for $HOST replacement")
+		return
+	}
+
+	config, err := parseTerseConfigFromHost(notif.Config, host)
+	if err != nil {
+		c.logger.Debug("failed to unmarshal pushed cluster map", zap.Error(err))
+		return
+	}
+
+	if c.clusterMapChangeHandler == nil {
+		return
+	}
+
+	// applying a config can reconfigure this very client, so we must not do it
+	// from the read thread of the connection.
+	go c.clusterMapChangeHandler.HandleClusterMapChange(config, host)
+}
@@ -0,0 +1,191 @@
+package gocbcorex
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+// KvClientDcpConfig specifies how a KvClient should configure its DCP connection.
+type KvClientDcpConfig struct {
+	ConnectionName string
+	Flags          memdx.DcpConnectionFlags
+
+	// BufferSize enables flow control with the specified buffer size when
+	// non-zero, causing received bytes to be acknowledged automatically.
+	BufferSize uint32
+
+	// NoopInterval enables server-initiated no-ops at the specified interval
+	// when non-zero, allowing the server to detect dead connections.
+	NoopInterval time.Duration
+}
+
+func kvClient_SimpleDcpCall[ReqT any, RespT any](
+	ctx context.Context,
+	c *kvClient,
+	execFn func(o memdx.OpsDcp, d memdx.Dispatcher, req ReqT, cb func(RespT, error)) (memdx.PendingOp, error),
+	req ReqT,
+) (RespT, error) {
+	return kvClient_SimpleCall(ctx, c, memdx.OpsDcp{
+		CollectionsEnabled: c.HasFeature(memdx.HelloFeatureCollections),
+	}, execFn, req)
+}
+
+func dcpOpenConnectionWrapper(o memdx.OpsDcp, d memdx.Dispatcher, req *memdx.DcpOpenConnectionRequest, cb func([]byte, error)) (memdx.PendingOp, error) {
+	return o.DcpOpenConnection(d, req, func(err error) {
+		cb(nil, err)
+	})
+}
+
+func dcpControlWrapper(o memdx.OpsDcp, d memdx.Dispatcher, req *memdx.DcpControlRequest, cb func([]byte, error)) (memdx.PendingOp, error) {
+	return o.DcpControl(d, req, func(err error) {
+		cb(nil, err)
+	})
+}
+
+func dcpCloseStreamWrapper(o memdx.OpsDcp, d memdx.Dispatcher, req *memdx.DcpCloseStreamRequest, cb func([]byte, error)) (memdx.PendingOp, error) {
+	return o.DcpCloseStream(d, req, func(err error) {
+		cb(nil, err)
+	})
+}
+
+func (c *kvClient) dcpOpen(ctx context.Context, config *KvClientDcpConfig) error {
+	_, err := kvClient_SimpleDcpCall(ctx, c, dcpOpenConnectionWrapper, &memdx.DcpOpenConnectionRequest{
+		ConnectionName: config.ConnectionName,
+		Flags:          config.Flags | memdx.DcpConnectionFlagsProducer,
+	})
+	if err != nil {
+		return err
+	}
+
+	controls := []*memdx.DcpControlRequest{
+		// we always want a stream end to be sent when we close a stream, so
+		// that the handlers for the stream are guaranteed to be cleaned up.
+		{Key: "send_stream_end_on_client_close_stream", Value: "true"},
+	}
+
+	if config.BufferSize > 0 {
+		controls = append(controls, &memdx.DcpControlRequest{
+			Key:   "connection_buffer_size",
+			Value: strconv.FormatUint(uint64(config.BufferSize), 10),
+		})
+	}
+
+	if config.NoopInterval > 0 {
+		controls = append(controls,
+			&memdx.DcpControlRequest{
+				Key:   "enable_noop",
+				Value: "true",
+			},
+			&memdx.DcpControlRequest{
+				Key:   "set_noop_interval",
+				Value: strconv.FormatInt(int64(config.NoopInterval/time.Second), 10),
+			})
+	}
+
+	for _, control := range controls {
+		_, err := kvClient_SimpleDcpCall(ctx, c, dcpControlWrapper, control)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.dcpBufferSize = config.BufferSize
+
+	return nil
+}
+
+func (c *kvClient) rawWriter() (memdx.RawWriter, error) {
+	writer, ok := c.cli.(memdx.RawWriter)
+	if !ok {
+		return nil, errors.New("underlying memdx client does not support raw writes")
+	}
+
+	return writer, nil
+}
+
+func (c *kvClient) handleDcpOrphan(pak *memdx.Packet) {
+	if !pak.Magic.IsRequest() || pak.OpCode != memdx.OpCodeDcpNoOp {
+		c.logger.Debug("dropping unexpected orphaned packet on dcp connection",
+			zap.Stringer("opcode", pak.OpCode),
+			zap.Uint32("opaque", pak.Opaque))
+		return
+	}
+
+	writer, err := c.rawWriter()
+	if err != nil {
+		c.logger.Debug("failed to reply to dcp noop", zap.Error(err))
+		return
+	}
+
+	err = memdx.OpsDcp{}.DcpNoOpReply(writer, pak)
+	if err != nil {
+		c.logger.Debug("failed to reply to dcp noop", zap.Error(err))
+	}
+}
+
+func (c *kvClient) dcpBytesReceived(numBytes uint32) {
+	if c.dcpBufferSize == 0 {
+		return
+	}
+
+	// we acknowledge once half the buffer is used, which ensures the server
+	// never stalls waiting for an acknowledgement from us.
+	unackedBytes := atomic.AddUint32(&c.dcpUnackedBytes, numBytes)
+	if unackedBytes < c.dcpBufferSize/2 {
+		return
+	}
+
+	ackBytes := atomic.SwapUint32(&c.dcpUnackedBytes, 0)
+	if ackBytes == 0 {
+		return
+	}
+
+	writer, err := c.rawWriter()
+	if err != nil {
+		c.logger.Debug("failed to send dcp buffer ack", zap.Error(err))
+		return
+	}
+
+	err = memdx.OpsDcp{}.DcpBufferAck(writer, &memdx.DcpBufferAckRequest{
+		NumBytes: ackBytes,
+	})
+	if err != nil {
+		c.logger.Debug("failed to send dcp buffer ack", zap.Error(err))
+	}
+}
+
+func (c *kvClient) DcpStreamReq(
+	ctx context.Context,
+	req *memdx.DcpStreamReqRequest,
+	handlers memdx.DcpStreamEventHandlers,
+) (*memdx.DcpStreamReqResponse, error) {
+	userBytesReceived := handlers.BytesReceived
+	handlers.BytesReceived = func(numBytes uint32) {
+		c.dcpBytesReceived(numBytes)
+
+		if userBytesReceived != nil {
+			userBytesReceived(numBytes)
+		}
+	}
+
+	return kvClient_SimpleDcpCall(ctx, c,
+		func(o memdx.OpsDcp, d memdx.Dispatcher, req *memdx.DcpStreamReqRequest, cb func(*memdx.DcpStreamReqResponse, error)) (memdx.PendingOp, error) {
+			return o.DcpStreamReq(d, req, handlers, cb)
+		}, req)
+}
+
+func (c *kvClient) DcpCloseStream(ctx context.Context, req *memdx.DcpCloseStreamRequest) error {
+	_, err := kvClient_SimpleDcpCall(ctx, c, dcpCloseStreamWrapper, req)
+	return err
+}
+
+func (c *kvClient) DcpGetFailoverLog(ctx context.Context, req *memdx.DcpGetFailoverLogRequest) (*memdx.DcpGetFailoverLogResponse, error) {
+	return kvClient_SimpleDcpCall(ctx, c, memdx.OpsDcp.DcpGetFailoverLog, req)
+}
@@ -2,8 +2,11 @@ package gocbcorex
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/couchbase/gocbcorex/memdx"
 )
@@ -20,6 +23,48 @@ func (e KvClientDispatchError) Unwrap() error {
 	return e.Cause
 }
 
+// AmbiguousOperationError indicates that a request which changes server state
+// was written to the connection, but that its response never arrived, so it
+// may or may not have been applied by the server.
+type AmbiguousOperationError struct {
+	Cause        error
+	DispatchedTo string
+}
+
+func (e AmbiguousOperationError) Error() string {
+	return fmt.Sprintf("ambiguous operation outcome (dispatched to %s): %s", e.DispatchedTo, e.Cause)
+}
+
+func (e AmbiguousOperationError) Unwrap() error {
+	return e.Cause
+}
+
+// IsStateChangingRequest returns whether a request changes state on the
+// server, such as modifying a document or taking or releasing its lock.  The
+// outcome of such a request is ambiguous if its response is lost, so it must
+// not be blindly sent again.
+func IsStateChangingRequest(req interface{}) bool {
+	switch req.(type) {
+	case *memdx.SetRequest,
+		*memdx.AddRequest,
+		*memdx.ReplaceRequest,
+		*memdx.DeleteRequest,
+		*memdx.AppendRequest,
+		*memdx.PrependRequest,
+		*memdx.IncrementRequest,
+		*memdx.DecrementRequest,
+		*memdx.TouchRequest,
+		*memdx.GetAndTouchRequest,
+		*memdx.GetAndLockRequest,
+		*memdx.UnlockRequest,
+		*memdx.MutateInRequest,
+		*memdx.SetMetaRequest,
+		*memdx.DeleteMetaRequest:
+		return true
+	}
+	return false
+}
+
 type syncCrudResult struct {
 	Result interface{}
 	Err    error
@@ -44,6 +89,78 @@ func releaseSyncCrudResulter(v *syncCrudResulter) {
 	syncCrudResulterPool.Put(v)
 }
 
+// kvClientDispatcher dispatches requests on behalf of a kvClient, tracking
+// the operations and bytes in flight until their final response arrives.
+type kvClientDispatcher struct {
+	c *kvClient
+
+	// thresholdOp, when set, receives the timings of the dispatch, with the
+	// encoding of the request having started at encodeStartTime.
+	thresholdOp     *thresholdLogOperation
+	encodeStartTime time.Time
+}
+
+func (d kvClientDispatcher) Dispatch(req *memdx.Packet, cb memdx.DispatchCallback) (memdx.PendingOp, error) {
+	numBytes := uint64(24 + len(req.FramingExtras) + len(req.Extras) + len(req.Key) + len(req.Value))
+	d.c.addPending(numBytes)
+	startTime := time.Now()
+
+	var thresholdDispatch *thresholdLogDispatch
+	if d.thresholdOp != nil {
+		thresholdDispatch = d.thresholdOp.BeginDispatch(startTime.Sub(d.encodeStartTime), d.c)
+	}
+
+	pendingOp, err := d.c.cli.Dispatch(req, func(resp *memdx.Packet, err error) bool {
+		// this must happen before the callback, which hands the result back
+		// to the operation, and resp is reused once we return.
+		thresholdDispatch.End(time.Since(startTime), resp)
+
+		if resp != nil && d.c.bytesReceivedCounter != nil {
+			d.c.bytesReceivedCounter.Add(uint64(24 + len(resp.FramingExtras) + len(resp.Extras) + len(resp.Key) + len(resp.Value)))
+		}
+
+		hasMorePackets := cb(resp, err)
+		if !hasMorePackets {
+			d.c.removePending(numBytes)
+			d.c.recordCompletion(time.Since(startTime))
+		}
+		return hasMorePackets
+	})
+	if err != nil {
+		d.c.removePending(numBytes)
+		return nil, err
+	}
+
+	if d.c.bytesSentCounter != nil {
+		d.c.bytesSentCounter.Add(numBytes)
+	}
+
+	return pendingOp, nil
+}
+
+func (d kvClientDispatcher) LocalAddr() string {
+	return d.c.cli.LocalAddr()
+}
+
+func (d kvClientDispatcher) RemoteAddr() string {
+	return d.c.cli.RemoteAddr()
+}
+
+func (c *kvClient) addPending(numBytes uint64) {
+	atomic.AddUint64(&c.pendingOperations, 1)
+	atomic.AddUint64(&c.pendingBytes, numBytes)
+}
+
+func (c *kvClient) removePending(numBytes uint64) {
+	atomic.AddUint64(&c.pendingOperations, ^uint64(0))
+	atomic.AddUint64(&c.pendingBytes, ^(numBytes - 1))
+}
+
+func (c *kvClient) recordCompletion(latency time.Duration) {
+	atomic.AddUint64(&c.completedOperations, 1)
+	atomic.AddUint64(&c.totalLatencyNanos, uint64(latency))
+}
+
 func kvClient_SimpleCall[Encoder any, ReqT any, RespT any](
 	ctx context.Context,
 	c *kvClient,
@@ -53,7 +170,13 @@ func kvClient_SimpleCall[Encoder any, ReqT any, RespT any](
 ) (RespT, error) {
 	resulter := allocSyncCrudResulter()
 
-	pendingOp, err := execFn(o, c.cli, req, func(resp RespT, err error) {
+	dispatcher := kvClientDispatcher{c: c}
+	if thresholdOp := thresholdLogOperationFromContext(ctx); thresholdOp != nil {
+		dispatcher.thresholdOp = thresholdOp
+		dispatcher.encodeStartTime = time.Now()
+	}
+
+	pendingOp, err := execFn(o, dispatcher, req, func(resp RespT, err error) {
 		resulter.Ch <- syncCrudResult{
 			Result: resp,
 			Err:    err,
@@ -68,14 +191,34 @@ func kvClient_SimpleCall[Encoder any, ReqT any, RespT any](
 	select {
 	case res := <-resulter.Ch:
 		releaseSyncCrudResulter(resulter)
-		return res.Result.(RespT), res.Err
+		return res.Result.(RespT), c.resultError(ctx, req, res.Err)
 	case <-ctx.Done():
 		pendingOp.Cancel(ctx.Err())
 
 		res := <-resulter.Ch
 		releaseSyncCrudResulter(resulter)
-		return res.Result.(RespT), res.Err
+		return res.Result.(RespT), c.resultError(ctx, req, res.Err)
+	}
+}
+
+func (c *kvClient) resultError(ctx context.Context, req interface{}, err error) error {
+	if err == nil {
+		return nil
 	}
+
+	// the request was already written to the connection, so a request which
+	// was given up on, or whose connection closed, may still have been applied.
+	if IsStateChangingRequest(req) {
+		ctxErr := ctx.Err()
+		if errors.Is(err, memdx.ErrClosedInFlight) || (ctxErr != nil && errors.Is(err, ctxErr)) {
+			return AmbiguousOperationError{
+				Cause:        err,
+				DispatchedTo: c.cli.RemoteAddr(),
+			}
+		}
+	}
+
+	return c.errorMap.AnnotateError(err)
 }
 
 func kvClient_SimpleCoreCall[ReqT any, RespT any](
@@ -104,11 +247,29 @@ func kvClient_SimpleCrudCall[ReqT any, RespT any](
 	execFn func(o memdx.OpsCrud, d memdx.Dispatcher, req ReqT, cb func(RespT, error)) (memdx.PendingOp, error),
 	req ReqT,
 ) (RespT, error) {
+	ctx, span := startRequestSpan(ctx, nil, spanNameDispatchToServer)
+	defer span.End()
+
+	var traceContext string
+	if _, isNoop := span.(noopRequestSpan); !isNoop {
+		span.SetAttribute(spanAttribNetPeerName, c.cli.RemoteAddr())
+		span.SetAttribute(spanAttribNetHostName, c.cli.LocalAddr())
+
+		// the trace context is sent in a request frame, which the server only
+		// accepts once alternate requests have been negotiated too.
+		if c.HasFeature(memdx.HelloFeatureOpenTracing) &&
+			c.HasFeature(memdx.HelloFeatureAltRequests) {
+			traceContext = span.TraceParent()
+		}
+	}
+
 	return kvClient_SimpleCall(ctx, c, memdx.OpsCrud{
 		ExtFramesEnabled:      c.HasFeature(memdx.HelloFeatureAltRequests),
 		CollectionsEnabled:    c.HasFeature(memdx.HelloFeatureCollections),
 		DurabilityEnabled:     c.HasFeature(memdx.HelloFeatureSyncReplication),
 		PreserveExpiryEnabled: c.HasFeature(memdx.HelloFeaturePreserveExpiry),
+		TraceContext:          traceContext,
+		Logger:                c.logger,
 	}, execFn, req)
 }
 
@@ -122,6 +283,10 @@ func (c *kvClient) GetClusterConfig(ctx context.Context, req *memdx.GetClusterCo
 	return kvClient_SimpleCoreCall(ctx, c, memdx.OpsCore.GetClusterConfig, req)
 }
 
+func (c *kvClient) NoOp(ctx context.Context, req *memdx.NoOpRequest) (*memdx.NoOpResponse, error) {
+	return kvClient_SimpleCoreCall(ctx, c, memdx.OpsCore.NoOp, req)
+}
+
 func selectBucketWrapper(o memdx.OpsCore, d memdx.Dispatcher, req *memdx.SelectBucketRequest, cb func([]byte, error)) (memdx.PendingOp, error) {
 	return o.SelectBucket(d, req, func(err error) {
 		cb(nil, err)
@@ -137,6 +302,18 @@ func (c *kvClient) GetCollectionID(ctx context.Context, req *memdx.GetCollection
 	return kvClient_SimpleUtilsCall(ctx, c, memdx.OpsUtils.GetCollectionID, req)
 }
 
+func (c *kvClient) GetCollectionsManifest(ctx context.Context, req *memdx.GetCollectionsManifestRequest) ([]byte, error) {
+	return kvClient_SimpleUtilsCall(ctx, c, memdx.OpsUtils.GetCollectionsManifest, req)
+}
+
+func (c *kvClient) Stats(ctx context.Context, req *memdx.StatsRequest) (*memdx.StatsResponse, error) {
+	return kvClient_SimpleUtilsCall(ctx, c, memdx.OpsUtils.Stats, req)
+}
+
+func (c *kvClient) GetAllVbSeqnos(ctx context.Context, req *memdx.GetAllVbSeqnosRequest) (*memdx.GetAllVbSeqnosResponse, error) {
+	return kvClient_SimpleCrudCall(ctx, c, memdx.OpsCrud.GetAllVbSeqnos, req)
+}
+
 func (c *kvClient) Get(ctx context.Context, req *memdx.GetRequest) (*memdx.GetResponse, error) {
 	return kvClient_SimpleCrudCall(ctx, c, memdx.OpsCrud.Get, req)
 }
@@ -216,3 +393,11 @@ func (c *kvClient) LookupIn(ctx context.Context, req *memdx.LookupInRequest) (*m
 func (c *kvClient) MutateIn(ctx context.Context, req *memdx.MutateInRequest) (*memdx.MutateInResponse, error) {
 	return kvClient_SimpleCrudCall(ctx, c, memdx.OpsCrud.MutateIn, req)
 }
+
+func (c *kvClient) Observe(ctx context.Context, req *memdx.ObserveRequest) (*memdx.ObserveResponse, error) {
+	return kvClient_SimpleCrudCall(ctx, c, memdx.OpsCrud.Observe, req)
+}
+
+func (c *kvClient) ObserveSeqNo(ctx context.Context, req *memdx.ObserveSeqNoRequest) (*memdx.ObserveSeqNoResponse, error) {
+	return kvClient_SimpleCrudCall(ctx, c, memdx.OpsCrud.ObserveSeqNo, req)
+}
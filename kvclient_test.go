@@ -3,8 +3,13 @@ package gocbcorex
 import (
 	"context"
 	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/couchbase/gocbcorex/contrib/cbconfig"
 	"github.com/couchbase/gocbcorex/memdx"
 	"github.com/couchbase/gocbcorex/testutils"
 	"github.com/google/uuid"
@@ -21,6 +26,155 @@ func (mpo memdxPendingOpMock) Cancel(err error) {
 	mpo.cancelledCh <- err
 }
 
+func TestKvClientLoadFactor(t *testing.T) {
+	dispatchedCh := make(chan memdx.DispatchCallback, 2)
+	memdxCli := &MemdxDispatcherCloserMock{
+		DispatchFunc: func(packet *memdx.Packet, dispatchCallback memdx.DispatchCallback) (memdx.PendingOp, error) {
+			dispatchedCh <- dispatchCallback
+			return memdxPendingOpMock{
+				cancelledCh: make(chan error, 1),
+			}, nil
+		},
+		RemoteAddrFunc: func() string { return "remote:1" },
+		LocalAddrFunc:  func() string { return "local:2" },
+	}
+
+	cli, err := NewKvClient(context.Background(), &KvClientConfig{
+		Address: "endpoint1",
+
+		// we set these to avoid bootstrapping
+		DisableBootstrap:       true,
+		DisableDefaultFeatures: true,
+		DisableErrorMap:        true,
+	}, &KvClientOptions{
+		NewMemdxClient: func(opts *memdx.ClientOptions) MemdxDispatcherCloser {
+			return memdxCli
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, float64(0), cli.LoadFactor())
+
+	resultCh := make(chan error, 2)
+	go func() {
+		_, err := cli.Get(context.Background(), &memdx.GetRequest{
+			Key: []byte("small"),
+		})
+		resultCh <- err
+	}()
+	smallCb := <-dispatchedCh
+	smallLoad := cli.LoadFactor()
+	assert.Greater(t, smallLoad, float64(1))
+
+	go func() {
+		_, err := cli.Set(context.Background(), &memdx.SetRequest{
+			Key:   []byte("large"),
+			Value: make([]byte, 1024*1024),
+		})
+		resultCh <- err
+	}()
+	largeCb := <-dispatchedCh
+	assert.Greater(t, cli.LoadFactor(), smallLoad+float64(1)+float64(32))
+
+	largeCb(&memdx.Packet{
+		Status: memdx.StatusSuccess,
+	}, nil)
+	require.NoError(t, <-resultCh)
+	assert.Equal(t, smallLoad, cli.LoadFactor())
+
+	smallCb(&memdx.Packet{
+		Status: memdx.StatusKeyNotFound,
+	}, nil)
+	require.ErrorIs(t, <-resultCh, memdx.ErrDocNotFound)
+	assert.Equal(t, float64(0), cli.LoadFactor())
+}
+
+type cancellingPendingOpMock struct {
+	cb memdx.DispatchCallback
+}
+
+func (po cancellingPendingOpMock) Cancel(err error) {
+	po.cb(nil, fmt.Errorf("request cancelled: %w", err))
+}
+
+func TestKvClientAmbiguousMutations(t *testing.T) {
+	var memdxOpts *memdx.ClientOptions
+	memdxCli := &MemdxDispatcherCloserMock{
+		DispatchFunc: func(packet *memdx.Packet, dispatchCallback memdx.DispatchCallback) (memdx.PendingOp, error) {
+			if string(packet.Key) == "closed" {
+				go dispatchCallback(nil, memdx.ErrClosedInFlight)
+			}
+			return cancellingPendingOpMock{
+				cb: dispatchCallback,
+			}, nil
+		},
+		RemoteAddrFunc: func() string { return "remote:1" },
+		LocalAddrFunc:  func() string { return "local:2" },
+	}
+
+	orphans := NewOrphanReporter(nil)
+	cli, err := NewKvClient(context.Background(), &KvClientConfig{
+		Address: "endpoint1",
+
+		// we set these to avoid bootstrapping
+		DisableBootstrap:       true,
+		DisableDefaultFeatures: true,
+		DisableErrorMap:        true,
+	}, &KvClientOptions{
+		NewMemdxClient: func(opts *memdx.ClientOptions) MemdxDispatcherCloser {
+			memdxOpts = opts
+			return memdxCli
+		},
+		OrphanedResponseHandler: orphans,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = cli.Set(ctx, &memdx.SetRequest{
+		Key:   []byte("timeout"),
+		Value: []byte("value"),
+	})
+	var ambiguousErr AmbiguousOperationError
+	require.ErrorAs(t, err, &ambiguousErr)
+	assert.Equal(t, "remote:1", ambiguousErr.DispatchedTo)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = cli.Get(ctx, &memdx.GetRequest{
+		Key: []byte("timeout"),
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, errors.As(err, &ambiguousErr))
+
+	_, err = cli.Delete(context.Background(), &memdx.DeleteRequest{
+		Key: []byte("closed"),
+	})
+	require.ErrorAs(t, err, &ambiguousErr)
+	assert.ErrorIs(t, err, memdx.ErrClosedInFlight)
+
+	// a lost lock or unlock may also have been applied by the server
+	_, err = cli.GetAndLock(context.Background(), &memdx.GetAndLockRequest{
+		Key: []byte("closed"),
+	})
+	require.ErrorAs(t, err, &ambiguousErr)
+	assert.ErrorIs(t, err, memdx.ErrClosedInFlight)
+
+	_, err = cli.Unlock(context.Background(), &memdx.UnlockRequest{
+		Key: []byte("closed"),
+	})
+	require.ErrorAs(t, err, &ambiguousErr)
+
+	// the late response to the timed out mutation is passed to the handler
+	memdxOpts.OrphanHandler(&memdx.Packet{
+		Magic:  memdx.MagicRes,
+		OpCode: memdx.OpCodeSet,
+		Opaque: 7,
+		Status: memdx.StatusSuccess,
+	})
+	assert.Contains(t, string(orphans.Report()), `"last_operation_id":"0x7"`)
+}
+
 func TestKvClientReconfigureBucket(t *testing.T) {
 	testutils.SkipIfShortTest(t)
 
@@ -136,6 +290,73 @@ func TestKvClientReconfigureTLSConfig(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestKvClientCertificateAuthSkipsSasl(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+
+	memdxCli := &MemdxDispatcherCloserMock{
+		DispatchFunc: nil,
+	}
+	tlsConfig := &tls.Config{}
+
+	// bootstrapping is disabled, so this would fail if SASL were attempted
+	cli, err := NewKvClient(context.Background(), &KvClientConfig{
+		Address:   "endpoint1",
+		TlsConfig: tlsConfig,
+		Authenticator: &CertificateAuthenticator{
+			ClientCertificate: &tls.Certificate{},
+		},
+
+		DisableBootstrap:       true,
+		DisableDefaultFeatures: true,
+		DisableErrorMap:        true,
+	}, &KvClientOptions{
+		Logger: logger,
+		NewMemdxClient: func(opts *memdx.ClientOptions) MemdxDispatcherCloser {
+			return memdxCli
+		},
+	})
+	require.NoError(t, err)
+
+	// a new certificate can only be presented by a new connection
+	err = cli.Reconfigure(&KvClientConfig{
+		Address:   "endpoint1",
+		TlsConfig: tlsConfig,
+		Authenticator: &CertificateAuthenticator{
+			ClientCertificate: &tls.Certificate{},
+		},
+
+		DisableBootstrap:       true,
+		DisableDefaultFeatures: true,
+		DisableErrorMap:        true,
+	}, func(error) {})
+	require.Error(t, err)
+}
+
+func TestKvClientCertificateAuthWithoutTls(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+
+	memdxCli := &MemdxDispatcherCloserMock{
+		DispatchFunc: nil,
+	}
+
+	_, err := NewKvClient(context.Background(), &KvClientConfig{
+		Address: "endpoint1",
+		Authenticator: &CertificateAuthenticator{
+			ClientCertificate: &tls.Certificate{},
+		},
+
+		DisableBootstrap:       true,
+		DisableDefaultFeatures: true,
+		DisableErrorMap:        true,
+	}, &KvClientOptions{
+		Logger: logger,
+		NewMemdxClient: func(opts *memdx.ClientOptions) MemdxDispatcherCloser {
+			return memdxCli
+		},
+	})
+	require.ErrorIs(t, err, ErrClientCertificateRequiresTls)
+}
+
 func TestKvClientReconfigureUsername(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 
@@ -234,3 +455,102 @@ func TestKvClientReconfigureAddress(t *testing.T) {
 	}, func(error) {})
 	require.Error(t, err)
 }
+
+type testClusterMapChangeHandler struct {
+	configCh chan *cbconfig.TerseConfigJson
+	hostCh   chan string
+}
+
+func (h *testClusterMapChangeHandler) HandleClusterMapChange(config *cbconfig.TerseConfigJson, sourceHostname string) {
+	h.configCh <- config
+	h.hostCh <- sourceHostname
+}
+
+func TestKvClientClustermapChangeNotification(t *testing.T) {
+	handler := &testClusterMapChangeHandler{
+		configCh: make(chan *cbconfig.TerseConfigJson, 1),
+		hostCh:   make(chan string, 1),
+	}
+
+	cli := &kvClient{
+		logger: zap.NewNop(),
+		currentConfig: KvClientConfig{
+			Address: "10.0.0.1:11210",
+		},
+		clusterMapChangeHandler: handler,
+	}
+
+	extras := make([]byte, 16)
+	binary.BigEndian.PutUint64(extras[8:], 7)
+
+	cli.handleServerRequest(&memdx.Packet{
+		Magic:  memdx.MagicServerReq,
+		OpCode: memdx.OpCode(memdx.ServerOpCodeClustermapChangeNotification),
+		Extras: extras,
+		Value:  []byte(`{"rev":7,"nodesExt":[{"hostname":"$HOST"}]}`),
+	})
+
+	config := <-handler.configCh
+	assert.Equal(t, 7, config.Rev)
+	require.Len(t, config.NodesExt, 1)
+	assert.Equal(t, "10.0.0.1", config.NodesExt[0].Hostname)
+	assert.Equal(t, "10.0.0.1", <-handler.hostCh)
+
+	// IPv6 hosts keep their brackets, as they do for not-my-vbucket configs
+	cli.currentConfig.Address = "[fd00::1]:11210"
+	cli.handleServerRequest(&memdx.Packet{
+		Magic:  memdx.MagicServerReq,
+		OpCode: memdx.OpCode(memdx.ServerOpCodeClustermapChangeNotification),
+		Extras: extras,
+		Value:  []byte(`{"rev":7,"nodesExt":[{"hostname":"$HOST"}]}`),
+	})
+
+	config = <-handler.configCh
+	require.Len(t, config.NodesExt, 1)
+	assert.Equal(t, "[fd00::1]", config.NodesExt[0].Hostname)
+	assert.Equal(t, "[fd00::1]", <-handler.hostCh)
+}
+
+// testMapMeter is deliberately not comparable, as a user's Meter might not be.
+type testMapMeter struct {
+	counters map[string]MeterCounter
+}
+
+func (m testMapMeter) Counter(name string, attributes map[string]string) MeterCounter {
+	return m.counters[name]
+}
+
+func (m testMapMeter) Histogram(name string, attributes map[string]string) MeterHistogram {
+	return nil
+}
+
+func TestKvClientReconfigureNonComparableMeter(t *testing.T) {
+	counter := &aggregatingCounter{}
+	config := &KvClientConfig{
+		Address: "endpoint1",
+
+		// we set these to avoid bootstrapping
+		DisableBootstrap:       true,
+		DisableDefaultFeatures: true,
+		DisableErrorMap:        true,
+	}
+	cli, err := NewKvClient(context.Background(), config, &KvClientOptions{
+		NewMemdxClient: func(opts *memdx.ClientOptions) MemdxDispatcherCloser {
+			return &MemdxDispatcherCloserMock{}
+		},
+		Meter: testMapMeter{counters: map[string]MeterCounter{
+			meterNameConnectionsOpened: counter,
+			meterNameConnectionsClosed: counter,
+			meterNameBytesSent:         counter,
+			meterNameBytesReceived:     counter,
+		}},
+	})
+	require.NoError(t, err)
+
+	reconfigureCh := make(chan error, 1)
+	err = cli.Reconfigure(config, func(err error) {
+		reconfigureCh <- err
+	})
+	require.NoError(t, err)
+	require.NoError(t, <-reconfigureCh)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -19,18 +20,28 @@ type KvClientManager interface {
 	GetClient(ctx context.Context, endpoint string) (KvClient, error)
 	Reconfigure(opts *KvClientManagerConfig, cb func(error)) error
 	GetRandomClient(ctx context.Context) (KvClient, error)
+	GetEndpoints() []string
 }
 
 type NewKvClientProviderFunc func(clientOpts *KvClientPoolConfig) (KvClientPool, error)
 
 type KvClientManagerConfig struct {
-	NumPoolConnections uint
-	Clients            map[string]*KvClientConfig
+	NumPoolConnections   uint
+	PoolScaling          *KvClientPoolScalingConfig
+	Clients              map[string]*KvClientConfig
+	PoolReconnectBackoff BackoffCalculator
+	PoolCircuitBreaker   *KvClientPoolCircuitBreakerConfig
 }
 
 type KvClientManagerOptions struct {
 	Logger                *zap.Logger
 	NewKvClientProviderFn NewKvClientProviderFunc
+
+	// ClusterMapChangeHandler, OrphanedResponseHandler and Meter are passed
+	// to each client of the pools the manager creates, see KvClientOptions.
+	ClusterMapChangeHandler ClusterMapChangeHandler
+	OrphanedResponseHandler OrphanedResponseHandler
+	Meter                   Meter
 }
 
 type kvClientManagerPool struct {
@@ -46,6 +57,10 @@ type kvClientManager struct {
 	logger                *zap.Logger
 	newKvClientProviderFn NewKvClientProviderFunc
 
+	clusterMapChangeHandler ClusterMapChangeHandler
+	orphanedResponseHandler OrphanedResponseHandler
+	meter                   Meter
+
 	lock          sync.Mutex
 	currentConfig KvClientManagerConfig
 	state         AtomicPointer[kvClientManagerState]
@@ -67,6 +82,10 @@ func NewKvClientManager(
 	mgr := &kvClientManager{
 		logger:                loggerOrNop(opts.Logger),
 		newKvClientProviderFn: opts.NewKvClientProviderFn,
+
+		clusterMapChangeHandler: opts.ClusterMapChangeHandler,
+		orphanedResponseHandler: opts.OrphanedResponseHandler,
+		meter:                   opts.Meter,
 	}
 
 	// initialize the client manager to having no clients
@@ -87,7 +106,11 @@ func (m *kvClientManager) newKvClientProvider(poolOpts *KvClientPoolConfig) (KvC
 	if m.newKvClientProviderFn != nil {
 		return m.newKvClientProviderFn(poolOpts)
 	}
-	return NewKvClientPool(poolOpts, nil)
+	return NewKvClientPool(poolOpts, &KvClientPoolOptions{
+		ClusterMapChangeHandler: m.clusterMapChangeHandler,
+		OrphanedResponseHandler: m.orphanedResponseHandler,
+		Meter:                   m.meter,
+	})
 }
 
 func (m *kvClientManager) Reconfigure(config *KvClientManagerConfig, cb func(error)) error {
@@ -114,8 +137,11 @@ func (m *kvClientManager) Reconfigure(config *KvClientManagerConfig, cb func(err
 
 	for endpoint, endpointConfig := range config.Clients {
 		poolConfig := &KvClientPoolConfig{
-			NumConnections: config.NumPoolConnections,
-			ClientConfig:   *endpointConfig,
+			NumConnections:   config.NumPoolConnections,
+			ClientConfig:     *endpointConfig,
+			Scaling:          config.PoolScaling,
+			ReconnectBackoff: config.PoolReconnectBackoff,
+			CircuitBreaker:   config.PoolCircuitBreaker,
 		}
 
 		var pool KvClientPool
@@ -174,6 +200,20 @@ func (m *kvClientManager) GetRandomEndpoint() (KvClientPool, error) {
 	return nil, placeholderError{"no endpoints known, shutdown?"}
 }
 
+func (m *kvClientManager) GetEndpoints() []string {
+	state, err := m.getState()
+	if err != nil {
+		return nil
+	}
+
+	endpoints := make([]string, 0, len(state.ClientPools))
+	for endpoint := range state.ClientPools {
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints
+}
+
 func (m *kvClientManager) GetEndpoint(endpoint string) (KvClientPool, error) {
 	if endpoint == "" {
 		return nil, placeholderError{"endpoint must be specified for GetEndpoint"}
@@ -248,7 +288,9 @@ func OrchestrateMemdClient[RespT any](
 	fn func(client KvClient) (RespT, error),
 ) (RespT, error) {
 	for {
+		waitStart := time.Now()
 		cli, err := cm.GetClient(ctx, endpoint)
+		operationMeterFromContext(ctx).RecordPoolWait(time.Since(waitStart))
 		if err != nil {
 			var emptyResp RespT
 			return emptyResp, err
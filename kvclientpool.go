@@ -3,8 +3,10 @@ package gocbcorex
 import (
 	"context"
 	"errors"
+	"math/rand"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/exp/slices"
@@ -25,15 +27,34 @@ type KvClientPool interface {
 type KvClientPoolConfig struct {
 	NumConnections uint
 	ClientConfig   KvClientConfig
+
+	// Scaling, when specified, makes the pool adjust its number of
+	// connections to its load, in which case NumConnections is ignored.
+	Scaling *KvClientPoolScalingConfig
+
+	// ReconnectBackoff calculates how long to wait between failed attempts
+	// to connect, defaults to a jittered exponential backoff.
+	ReconnectBackoff BackoffCalculator
+
+	// CircuitBreaker, when specified, makes the pool fail operations
+	// immediately once it has repeatedly failed to connect.
+	CircuitBreaker *KvClientPoolCircuitBreakerConfig
 }
 
 type KvClientPoolOptions struct {
 	Logger      *zap.Logger
 	NewKvClient NewKvClientFunc
+
+	// ClusterMapChangeHandler, OrphanedResponseHandler and Meter are passed
+	// to each client the pool creates, see KvClientOptions.
+	ClusterMapChangeHandler ClusterMapChangeHandler
+	OrphanedResponseHandler OrphanedResponseHandler
+	Meter                   Meter
 }
 
 type kvClientPoolFastMap struct {
 	activeConnections []KvClient
+	scalingInterval   time.Duration
 }
 
 type pendingKvClient struct {
@@ -44,8 +65,7 @@ type kvClientPool struct {
 	logger      *zap.Logger
 	newKvClient NewKvClientFunc
 
-	clientIdx uint64
-	fastMap   AtomicPointer[kvClientPoolFastMap]
+	fastMap AtomicPointer[kvClientPoolFastMap]
 
 	lock            sync.Mutex
 	config          KvClientPoolConfig
@@ -55,6 +75,17 @@ type kvClientPool struct {
 	currentClients  []KvClient
 	defunctClients  []KvClient
 	shutdownClients []KvClient
+	drainingClients []KvClient
+
+	numScaledConnections uint
+	numLowLoadChecks     int
+	clientSamples        map[KvClient]*kvClientPoolClientSample
+	scalingCheckArmed    uint32
+
+	numConnectFailures uint32
+	reconnectAt        time.Time
+	reconnectTimer     *time.Timer
+	circuitState       kvClientPoolCircuitState
 
 	needClientSigCh    chan struct{}
 	needNoDefunctSigCh chan struct{}
@@ -70,37 +101,41 @@ func NewKvClientPool(config *KvClientPoolConfig, opts *KvClientPoolOptions) (*kv
 
 	logger := loggerOrNop(opts.Logger)
 
-	var newKvClient NewKvClientFunc
+	p := &kvClientPool{
+		logger: logger,
+		config: *config,
+
+		needClientSigCh: make(chan struct{}, 1),
+	}
+
 	if opts.NewKvClient != nil {
-		newKvClient = opts.NewKvClient
+		p.newKvClient = opts.NewKvClient
 	} else {
-		newKvClient = func(ctx context.Context, config *KvClientConfig) (KvClient, error) {
+		p.newKvClient = func(ctx context.Context, config *KvClientConfig) (KvClient, error) {
 			return NewKvClient(ctx, config, &KvClientOptions{
-				Logger: logger.Named("client"),
+				Logger:                  logger.Named("client"),
+				CloseHandler:            p.handleClientClosed,
+				ClusterMapChangeHandler: opts.ClusterMapChangeHandler,
+				OrphanedResponseHandler: opts.OrphanedResponseHandler,
+				Meter:                   opts.Meter,
 			})
 		}
 	}
 
-	p := &kvClientPool{
-		logger:      logger,
-		newKvClient: newKvClient,
-		config:      *config,
-
-		needClientSigCh: make(chan struct{}, 1),
-	}
-
 	// we need to lock here because checkConnectionsLocked can start goroutines
 	// which potentially access the shared state...
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
+	p.updateScalingLocked()
+	p.rebuildActiveClientsLocked()
 	p.checkConnectionsLocked()
 
 	return p, nil
 }
 
 func (p *kvClientPool) checkConnectionsLocked() {
-	numWantedClients := int(p.config.NumConnections)
+	numWantedClients := p.numWantedClientsLocked()
 	numActiveClients := len(p.currentClients)
 	numDefunctClients := len(p.defunctClients)
 	numPendingClients := len(p.pendingClients)
@@ -113,9 +148,11 @@ func (p *kvClientPool) checkConnectionsLocked() {
 		numClosedClients := 0
 
 		for numExcessClients > 0 && len(p.defunctClients) > 0 {
+			// defunct clients may still have operations in flight, which we
+			// let complete rather than closing the client underneath them.
 			clientToClose := p.defunctClients[0]
 			p.defunctClients = slices.Delete(p.defunctClients, 0, 1)
-			p.shutdownClientLocked(clientToClose)
+			p.retireClientLocked(clientToClose)
 			numExcessClients--
 			numClosedClients++
 		}
@@ -134,7 +171,13 @@ func (p *kvClientPool) checkConnectionsLocked() {
 	}
 
 	numNeededClients := numWantedClients - numAvailableClients - numPendingClients
+	if numNeededClients <= 0 && len(p.defunctClients) > 0 && numPendingClients == 0 {
+		// defunct clients are replaced one at a time, each being retired once
+		// its replacement has connected, so the pool never runs short.
+		numNeededClients = 1
+	}
 	if numNeededClients > 0 {
+		numNeededClients = p.numAllowedConnectsLocked(numNeededClients)
 		for i := 0; i < numNeededClients; i++ {
 			p.startNewClientLocked()
 		}
@@ -172,6 +215,11 @@ func (p *kvClientPool) startNewClientLocked() <-chan struct{} {
 
 	clientConfig := p.config.ClientConfig
 
+	var canaryTimeout time.Duration
+	if p.circuitState == kvClientPoolCircuitHalfOpen {
+		canaryTimeout = p.config.CircuitBreaker.withDefaults().CanaryTimeout
+	}
+
 	completeCh := make(chan struct{}, 1)
 
 	// create the goroutine to actually create the client
@@ -179,6 +227,10 @@ func (p *kvClientPool) startNewClientLocked() <-chan struct{} {
 		client, err := p.newKvClient(cancelCtx, &clientConfig)
 		cancelFn()
 
+		if err == nil && canaryTimeout > 0 {
+			err = p.checkCanaryClient(client, canaryTimeout)
+		}
+
 		p.lock.Lock()
 		defer p.lock.Unlock()
 
@@ -191,7 +243,7 @@ func (p *kvClientPool) startNewClientLocked() <-chan struct{} {
 		if err != nil {
 			p.logger.Warn("failed to create a new client connection", zap.Error(err))
 
-			p.connectErr = err
+			p.handleConnectFailureLocked(err)
 			p.checkConnectionsLocked()
 			completeCh <- struct{}{}
 			return
@@ -203,7 +255,7 @@ func (p *kvClientPool) startNewClientLocked() <-chan struct{} {
 			reconfigureErr := make(chan error, 1)
 
 			p.lock.Unlock()
-			err := client.Reconfigure(&clientConfig, func(error) {
+			err := client.Reconfigure(&clientConfig, func(err error) {
 				reconfigureErr <- err
 			})
 			p.lock.Lock()
@@ -211,6 +263,7 @@ func (p *kvClientPool) startNewClientLocked() <-chan struct{} {
 			if err != nil {
 				p.logger.Warn("failed to reconfigure a new client connection", zap.Error(err))
 
+				p.shutdownClientLocked(client)
 				p.checkConnectionsLocked()
 				completeCh <- struct{}{}
 				return
@@ -220,13 +273,14 @@ func (p *kvClientPool) startNewClientLocked() <-chan struct{} {
 			if err != nil {
 				p.logger.Warn("failed to finalize new configuration on a new client connection", zap.Error(err))
 
+				p.shutdownClientLocked(client)
 				p.checkConnectionsLocked()
 				completeCh <- struct{}{}
 				return
 			}
 		}
 
-		p.connectErr = nil
+		p.handleConnectSuccessLocked()
 		p.addCurrentClientLocked(client)
 		p.rebuildActiveClientsLocked()
 		p.checkConnectionsLocked()
@@ -255,10 +309,10 @@ func (p *kvClientPool) ShutdownClient(client KvClient) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	if !p.removeCurrentClientLocked(client) {
-		if !p.removeDefunctClientLocked(client) {
-			return
-		}
+	if !p.removeCurrentClientLocked(client) &&
+		!p.removeDefunctClientLocked(client) &&
+		!p.removeDrainingClientLocked(client) {
+		return
 	}
 
 	p.shutdownClientLocked(client)
@@ -266,6 +320,26 @@ func (p *kvClientPool) ShutdownClient(client KvClient) {
 	p.checkConnectionsLocked()
 }
 
+// handleClientClosed removes clients whose connection has died from the pool,
+// so that they are replaced rather than continuing to be handed out.
+func (p *kvClientPool) handleClientClosed(client KvClient, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if !p.removeCurrentClientLocked(client) &&
+		!p.removeDefunctClientLocked(client) &&
+		!p.removeDrainingClientLocked(client) {
+		// the pool already shut this client down itself
+		return
+	}
+
+	p.logger.Warn("client connection closed unexpectedly", zap.Error(err))
+
+	delete(p.clientSamples, client)
+	p.rebuildActiveClientsLocked()
+	p.checkConnectionsLocked()
+}
+
 func (p *kvClientPool) rebuildActiveClientsLocked() {
 	p.activeClients = p.activeClients[:0]
 	p.activeClients = append(p.activeClients, p.currentClients...)
@@ -275,8 +349,15 @@ func (p *kvClientPool) rebuildActiveClientsLocked() {
 	// from the slow data into the fast map data and storing it.
 	fastMapConns := make([]KvClient, len(p.activeClients))
 	copy(fastMapConns, p.activeClients)
+
+	var scalingInterval time.Duration
+	if p.config.Scaling != nil {
+		scalingInterval = p.config.Scaling.withDefaults().CheckInterval
+	}
+
 	p.fastMap.Store(&kvClientPoolFastMap{
 		activeConnections: fastMapConns,
+		scalingInterval:   scalingInterval,
 	})
 }
 
@@ -289,8 +370,15 @@ func (p *kvClientPool) Reconfigure(config *KvClientPoolConfig, cb func(error)) e
 	defer p.lock.Unlock()
 
 	p.config = *config
+	p.updateScalingLocked()
+	if p.config.CircuitBreaker == nil {
+		p.circuitState = kvClientPoolCircuitClosed
+	}
 
-	numClientsReconfiguring := int64(len(p.currentClients))
+	// the count starts at one, released once every client has been handed
+	// its new config, so that the callback is invoked even if there are no
+	// current clients to reconfigure.
+	numClientsReconfiguring := int64(len(p.currentClients)) + 1
 	markClientReconfigureDone := func() {
 		if (atomic.AddInt64(&numClientsReconfiguring, -1)) == 0 {
 			// once we are done reconfiguring all the connections, we need to
@@ -311,12 +399,18 @@ func (p *kvClientPool) Reconfigure(config *KvClientPoolConfig, cb func(error)) e
 			// this can be invoke in the same call, where we already have this locked, so
 			// we push it into a goroutine to be handled instead.
 			go func() {
+				if err == nil {
+					markClientReconfigureDone()
+					return
+				}
+
 				// if we fail to reconfigure a client, we need to throw it out.
 				p.lock.Lock()
 				defer p.lock.Unlock()
 
 				if !p.removeCurrentClientLocked(client) {
 					// if the client is no longer current anyways, we have nothing to do...
+					markClientReconfigureDone()
 					return
 				}
 
@@ -339,6 +433,7 @@ func (p *kvClientPool) Reconfigure(config *KvClientPoolConfig, cb func(error)) e
 
 	p.rebuildActiveClientsLocked()
 	p.checkConnectionsLocked()
+	markClientReconfigureDone()
 
 	return nil
 }
@@ -364,28 +459,63 @@ func (p *kvClientPool) WaitUntilNoDefunctClients(ctx context.Context) error {
 func (p *kvClientPool) GetClient(ctx context.Context) (KvClient, error) {
 	fastMap := p.fastMap.Load()
 	if fastMap != nil {
-		fastMapNumConns := uint64(len(fastMap.activeConnections))
-		if fastMapNumConns > 0 {
-			clientIdx := atomic.AddUint64(&p.clientIdx, 1) - 1
-			conn := fastMap.activeConnections[clientIdx%fastMapNumConns]
-			return conn, nil
+		if len(fastMap.activeConnections) > 0 {
+			if fastMap.scalingInterval > 0 {
+				p.startScalingChecks(fastMap.scalingInterval)
+			}
+
+			return selectLeastLoadedClient(fastMap.activeConnections), nil
 		}
 	}
 
 	return p.getClientSlow(ctx)
 }
 
+// selectLeastLoadedClient picks two clients at random and returns the one
+// with the lower load factor.  Unlike always picking the least loaded client,
+// this keeps concurrent callers from all piling onto the same connection,
+// while still steering requests away from a connection which is held up by
+// a slow or large request.
+func selectLeastLoadedClient(clients []KvClient) KvClient {
+	numClients := len(clients)
+	if numClients == 1 {
+		return clients[0]
+	}
+
+	firstIdx := rand.Intn(numClients)
+	secondIdx := rand.Intn(numClients - 1)
+	if secondIdx >= firstIdx {
+		secondIdx++
+	}
+
+	firstClient := clients[firstIdx]
+	secondClient := clients[secondIdx]
+	if secondClient.LoadFactor() < firstClient.LoadFactor() {
+		return secondClient
+	}
+	return firstClient
+}
+
 func (p *kvClientPool) getClientSlow(ctx context.Context) (KvClient, error) {
 	p.lock.Lock()
 
-	numConns := uint64(len(p.activeClients))
-	if numConns > 0 {
-		clientIdx := atomic.AddUint64(&p.clientIdx, 1) - 1
-		conn := p.activeClients[clientIdx%numConns]
+	if len(p.activeClients) > 0 {
+		conn := selectLeastLoadedClient(p.activeClients)
 		p.lock.Unlock()
 		return conn, nil
 	}
 
+	if p.circuitState != kvClientPoolCircuitClosed {
+		// the endpoint is known to be unreachable, so rather than waiting for a
+		// connection we fail immediately.
+		err := CircuitBreakerOpenError{
+			Endpoint: p.config.ClientConfig.Address,
+			Cause:    p.connectErr,
+		}
+		p.lock.Unlock()
+		return nil, err
+	}
+
 	if p.connectErr != nil {
 		// if we have a connect error already, it means we are in error state
 		// and should just return that error directly.
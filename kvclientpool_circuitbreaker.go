@@ -0,0 +1,180 @@
+package gocbcorex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+var (
+	ErrCircuitBreakerOpen = errors.New("circuit breaker open")
+)
+
+// CircuitBreakerOpenError is returned instead of waiting for a connection to
+// an endpoint which the pool has repeatedly failed to connect to.
+type CircuitBreakerOpenError struct {
+	Endpoint string
+	Cause    error
+}
+
+func (e CircuitBreakerOpenError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("circuit breaker open for %s: %s", e.Endpoint, e.Cause)
+	}
+	return fmt.Sprintf("circuit breaker open for %s", e.Endpoint)
+}
+
+func (e CircuitBreakerOpenError) Unwrap() error {
+	return ErrCircuitBreakerOpen
+}
+
+// defaultKvClientPoolReconnectBackoff is used between failed connection
+// attempts when the pool is not configured with a ReconnectBackoff.
+var defaultKvClientPoolReconnectBackoff = JitteredBackoff(ExponentialBackoff(100*time.Millisecond, 10*time.Second, 2))
+
+// KvClientPoolCircuitBreakerConfig makes a pool stop connecting to an endpoint
+// after repeated failures, and fail operations for it immediately until a
+// later canary connection succeeds.
+type KvClientPoolCircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed connection
+	// attempts which open the circuit, defaults to 3.
+	FailureThreshold uint
+
+	// OpenDuration is how long the circuit stays open before a canary
+	// connection is attempted, defaults to 5 seconds.
+	OpenDuration time.Duration
+
+	// CanaryTimeout is how long the NOOP sent on a canary connection may
+	// take before the connection is considered failed, defaults to 2.5
+	// seconds.
+	CanaryTimeout time.Duration
+}
+
+func (c KvClientPoolCircuitBreakerConfig) withDefaults() KvClientPoolCircuitBreakerConfig {
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = 3
+	}
+	if c.OpenDuration <= 0 {
+		c.OpenDuration = 5 * time.Second
+	}
+	if c.CanaryTimeout <= 0 {
+		c.CanaryTimeout = 2500 * time.Millisecond
+	}
+	return c
+}
+
+type kvClientPoolCircuitState int
+
+const (
+	kvClientPoolCircuitClosed kvClientPoolCircuitState = iota
+	kvClientPoolCircuitOpen
+	kvClientPoolCircuitHalfOpen
+)
+
+func (p *kvClientPool) reconnectBackoffLocked() BackoffCalculator {
+	if p.config.ReconnectBackoff != nil {
+		return p.config.ReconnectBackoff
+	}
+	return defaultKvClientPoolReconnectBackoff
+}
+
+// numAllowedConnectsLocked returns how many of the needed connections may be
+// started now.  If reconnecting is being backed off, it instead schedules the
+// connections to be checked again once the backoff has passed.
+func (p *kvClientPool) numAllowedConnectsLocked(numNeededClients int) int {
+	if delay := time.Until(p.reconnectAt); delay > 0 {
+		p.scheduleReconnectLocked(delay)
+		return 0
+	}
+
+	if p.circuitState == kvClientPoolCircuitOpen {
+		p.logger.Debug("circuit breaker half-open, attempting canary connection")
+		p.circuitState = kvClientPoolCircuitHalfOpen
+	}
+
+	if p.circuitState == kvClientPoolCircuitHalfOpen {
+		// only a single canary connection is attempted at a time
+		if len(p.pendingClients) > 0 {
+			return 0
+		}
+		return 1
+	}
+
+	return numNeededClients
+}
+
+func (p *kvClientPool) scheduleReconnectLocked(delay time.Duration) {
+	if p.reconnectTimer != nil {
+		return
+	}
+
+	p.reconnectTimer = time.AfterFunc(delay, func() {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		p.reconnectTimer = nil
+		p.checkConnectionsLocked()
+	})
+}
+
+func (p *kvClientPool) handleConnectFailureLocked(err error) {
+	p.connectErr = err
+	p.numConnectFailures++
+	p.reconnectAt = time.Now().Add(p.reconnectBackoffLocked()(p.numConnectFailures - 1))
+
+	if p.config.CircuitBreaker == nil {
+		return
+	}
+
+	breaker := p.config.CircuitBreaker.withDefaults()
+	if p.circuitState != kvClientPoolCircuitHalfOpen && p.numConnectFailures < uint32(breaker.FailureThreshold) {
+		return
+	}
+
+	if p.circuitState == kvClientPoolCircuitClosed {
+		p.logger.Warn("opening circuit breaker after repeated connection failures",
+			zap.String("address", p.config.ClientConfig.Address),
+			zap.Uint32("failures", p.numConnectFailures))
+	}
+
+	p.circuitState = kvClientPoolCircuitOpen
+	openUntil := time.Now().Add(breaker.OpenDuration)
+	if openUntil.After(p.reconnectAt) {
+		p.reconnectAt = openUntil
+	}
+}
+
+func (p *kvClientPool) handleConnectSuccessLocked() {
+	if p.circuitState != kvClientPoolCircuitClosed {
+		p.logger.Info("closing circuit breaker after successful canary connection",
+			zap.String("address", p.config.ClientConfig.Address))
+	}
+
+	p.connectErr = nil
+	p.numConnectFailures = 0
+	p.reconnectAt = time.Time{}
+	p.circuitState = kvClientPoolCircuitClosed
+}
+
+// checkCanaryClient makes sure a connection made while the circuit breaker is
+// half-open can actually serve operations, since a node which is still coming
+// up may accept connections well before it responds to them.
+func (p *kvClientPool) checkCanaryClient(client KvClient, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := client.NoOp(ctx, &memdx.NoOpRequest{})
+	if err != nil {
+		if closeErr := client.Close(); closeErr != nil {
+			p.logger.Debug("failed to close canary connection", zap.Error(closeErr))
+		}
+		return err
+	}
+
+	return nil
+}
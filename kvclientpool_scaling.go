@@ -0,0 +1,266 @@
+package gocbcorex
+
+import (
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// kvClientPoolScaleDownChecks is the number of consecutive checks for which
+// the load must stay low before a connection is removed, to avoid repeatedly
+// closing and reopening connections under a fluctuating load.
+const kvClientPoolScaleDownChecks = 3
+
+// KvClientPoolScalingConfig makes a pool grow and shrink its number of
+// connections with the load placed on it, instead of keeping a fixed number.
+type KvClientPoolScalingConfig struct {
+	// MinConnections is the number of connections the pool never shrinks
+	// below, defaults to 1.
+	MinConnections uint
+
+	// MaxConnections is the number of connections the pool never grows
+	// beyond.
+	MaxConnections uint
+
+	// ScaleUpLoad is the average load factor per connection above which a
+	// connection is added, defaults to 8.
+	ScaleUpLoad float64
+
+	// ScaleDownLoad is the average load factor per connection below which
+	// a connection is removed, defaults to 1.
+	ScaleDownLoad float64
+
+	// ScaleUpLatency is the average operation latency above which a
+	// connection is added.  Zero disables scaling on latency.
+	ScaleUpLatency time.Duration
+
+	// IdleTimeout is how long a connection may go without any operations
+	// before it is closed, defaults to 1 minute.
+	IdleTimeout time.Duration
+
+	// CheckInterval is how often the load of the pool is checked, defaults
+	// to 1 second.
+	CheckInterval time.Duration
+}
+
+func (c KvClientPoolScalingConfig) withDefaults() KvClientPoolScalingConfig {
+	if c.MinConnections == 0 {
+		c.MinConnections = 1
+	}
+	if c.MaxConnections < c.MinConnections {
+		c.MaxConnections = c.MinConnections
+	}
+	if c.ScaleUpLoad <= 0 {
+		c.ScaleUpLoad = 8
+	}
+	if c.ScaleDownLoad <= 0 {
+		c.ScaleDownLoad = 1
+	}
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = 1 * time.Minute
+	}
+	if c.CheckInterval <= 0 {
+		c.CheckInterval = 1 * time.Second
+	}
+	return c
+}
+
+type kvClientPoolClientSample struct {
+	Stats      KvClientLoadStats
+	LastActive time.Time
+}
+
+// updateScalingLocked brings the number of connections the pool is scaled to
+// within the limits of its current configuration.
+func (p *kvClientPool) updateScalingLocked() {
+	if p.config.Scaling == nil {
+		return
+	}
+
+	scaling := p.config.Scaling.withDefaults()
+	if p.numScaledConnections < scaling.MinConnections {
+		p.numScaledConnections = scaling.MinConnections
+	}
+	if p.numScaledConnections > scaling.MaxConnections {
+		p.numScaledConnections = scaling.MaxConnections
+	}
+}
+
+func (p *kvClientPool) numWantedClientsLocked() int {
+	if p.config.Scaling == nil {
+		return int(p.config.NumConnections)
+	}
+
+	return int(p.numScaledConnections)
+}
+
+// startScalingChecks makes sure the load of the pool is being checked.  The
+// checks stop by themselves once the pool is at its minimum size, so pools
+// which are no longer used do not keep running them.
+func (p *kvClientPool) startScalingChecks(interval time.Duration) {
+	if atomic.CompareAndSwapUint32(&p.scalingCheckArmed, 0, 1) {
+		time.AfterFunc(interval, p.checkScaling)
+	}
+}
+
+func (p *kvClientPool) checkScaling() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.closeDrainedClientsLocked()
+
+	if p.config.Scaling == nil {
+		if len(p.drainingClients) > 0 {
+			time.AfterFunc(KvClientPoolScalingConfig{}.withDefaults().CheckInterval, p.checkScaling)
+		} else {
+			atomic.StoreUint32(&p.scalingCheckArmed, 0)
+		}
+		return
+	}
+
+	scaling := p.config.Scaling.withDefaults()
+	p.scaleLocked(&scaling)
+
+	if len(p.currentClients) > int(scaling.MinConnections) || len(p.drainingClients) > 0 {
+		time.AfterFunc(scaling.CheckInterval, p.checkScaling)
+	} else {
+		atomic.StoreUint32(&p.scalingCheckArmed, 0)
+	}
+}
+
+func (p *kvClientPool) scaleLocked(scaling *KvClientPoolScalingConfig) {
+	numClients := len(p.currentClients)
+	if numClients == 0 {
+		// we are still connecting, so there is nothing to measure yet
+		return
+	}
+
+	now := time.Now()
+	var totalLoad float64
+	var numCompleted uint64
+	var totalLatency time.Duration
+	var idleClients []KvClient
+	var leastLoadedClient KvClient
+	var leastLoad float64
+
+	samples := make(map[KvClient]*kvClientPoolClientSample, numClients)
+	for _, client := range p.currentClients {
+		stats := client.LoadStats()
+		lastActive := now
+
+		if prevSample := p.clientSamples[client]; prevSample != nil {
+			numCompleted += stats.CompletedOperations - prevSample.Stats.CompletedOperations
+			totalLatency += stats.TotalLatency - prevSample.Stats.TotalLatency
+
+			if stats.CompletedOperations == prevSample.Stats.CompletedOperations && stats.PendingOperations == 0 {
+				lastActive = prevSample.LastActive
+			}
+		}
+
+		samples[client] = &kvClientPoolClientSample{
+			Stats:      stats,
+			LastActive: lastActive,
+		}
+
+		if now.Sub(lastActive) >= scaling.IdleTimeout {
+			idleClients = append(idleClients, client)
+		}
+
+		load := client.LoadFactor()
+		totalLoad += load
+		if leastLoadedClient == nil || load < leastLoad {
+			leastLoadedClient = client
+			leastLoad = load
+		}
+	}
+	p.clientSamples = samples
+
+	avgLoad := totalLoad / float64(numClients)
+
+	var avgLatency time.Duration
+	if numCompleted > 0 {
+		avgLatency = totalLatency / time.Duration(numCompleted)
+	}
+	highLatency := scaling.ScaleUpLatency > 0 && avgLatency > scaling.ScaleUpLatency
+
+	if avgLoad > scaling.ScaleUpLoad || highLatency {
+		p.numLowLoadChecks = 0
+
+		if p.numScaledConnections < scaling.MaxConnections {
+			p.numScaledConnections++
+			p.checkConnectionsLocked()
+		}
+		return
+	}
+
+	if avgLoad >= scaling.ScaleDownLoad {
+		p.numLowLoadChecks = 0
+	} else {
+		p.numLowLoadChecks++
+		if p.numLowLoadChecks >= kvClientPoolScaleDownChecks {
+			p.numLowLoadChecks = 0
+
+			if p.numScaledConnections > scaling.MinConnections {
+				p.drainClientLocked(leastLoadedClient)
+			}
+		}
+	}
+
+	for _, client := range idleClients {
+		if p.numScaledConnections <= scaling.MinConnections {
+			break
+		}
+
+		p.drainClientLocked(client)
+	}
+}
+
+// drainClientLocked stops new operations being sent to a client.  It is closed
+// by a later check, once the operations already sent to it have completed.
+func (p *kvClientPool) drainClientLocked(client KvClient) {
+	if !p.removeCurrentClientLocked(client) {
+		return
+	}
+
+	p.numScaledConnections--
+	p.retireClientLocked(client)
+	delete(p.clientSamples, client)
+
+	p.rebuildActiveClientsLocked()
+	p.checkConnectionsLocked()
+}
+
+// retireClientLocked closes a client which is no longer handed out, once the
+// operations already sent to it have completed.
+func (p *kvClientPool) retireClientLocked(client KvClient) {
+	p.drainingClients = append(p.drainingClients, client)
+
+	checkInterval := KvClientPoolScalingConfig{}.withDefaults().CheckInterval
+	if p.config.Scaling != nil {
+		checkInterval = p.config.Scaling.withDefaults().CheckInterval
+	}
+	p.startScalingChecks(checkInterval)
+}
+
+func (p *kvClientPool) closeDrainedClientsLocked() {
+	for clientIdx := 0; clientIdx < len(p.drainingClients); {
+		client := p.drainingClients[clientIdx]
+		if client.LoadStats().PendingOperations > 0 {
+			clientIdx++
+			continue
+		}
+
+		p.drainingClients = slices.Delete(p.drainingClients, clientIdx, clientIdx+1)
+		p.shutdownClientLocked(client)
+	}
+}
+
+func (p *kvClientPool) removeDrainingClientLocked(client KvClient) bool {
+	clientIdx := slices.IndexFunc(p.drainingClients, func(oclient KvClient) bool { return oclient == client })
+	if clientIdx == -1 {
+		return false
+	}
+	p.drainingClients = slices.Delete(p.drainingClients, clientIdx, clientIdx+1)
+	return true
+}
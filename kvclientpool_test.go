@@ -2,12 +2,16 @@ package gocbcorex
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/couchbase/gocbcorex/memdx"
+	"github.com/couchbase/gocbcorex/memdx/memdtest"
 	"github.com/couchbase/gocbcorex/testutils"
 	"go.uber.org/zap"
 
@@ -107,7 +111,9 @@ func TestKvClientPoolCreates5Connections(t *testing.T) {
 
 			atomic.AddUint32(&called, 1)
 
-			return &KvClientMock{}, nil
+			return &KvClientMock{
+				LoadFactorFunc: func() float64 { return 0 },
+			}, nil
 		},
 	})
 	require.NoError(t, err)
@@ -121,13 +127,49 @@ func TestKvClientPoolCreates5Connections(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestKvClientPoolPrefersLeastLoadedClient(t *testing.T) {
+	idleClient := &KvClientMock{
+		LoadFactorFunc: func() float64 { return 0 },
+	}
+	busyClient := &KvClientMock{
+		LoadFactorFunc: func() float64 { return 10 },
+	}
+	clients := []KvClient{busyClient, idleClient}
+
+	var numCreated uint32
+	pool, err := NewKvClientPool(&KvClientPoolConfig{
+		NumConnections: 2,
+		ClientConfig: KvClientConfig{
+			Address: "endpoint1",
+		},
+	}, &KvClientPoolOptions{
+		NewKvClient: func(ctx context.Context, config *KvClientConfig) (KvClient, error) {
+			clientIdx := atomic.AddUint32(&numCreated, 1) - 1
+			return clients[clientIdx], nil
+		},
+	})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(pool.fastMap.Load().activeConnections) == 2
+	}, 50*time.Millisecond, 1*time.Millisecond)
+
+	// with only two clients, both are always compared
+	for i := 0; i < 50; i++ {
+		cli, err := pool.GetClient(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, idleClient, cli)
+	}
+}
+
 func TestKvClientPoolReconfigure(t *testing.T) {
 	mock := &KvClientMock{
 		ReconfigureFunc: func(opts *KvClientConfig, cb func(error)) error {
 			cb(nil)
 			return nil
 		},
-		CloseFunc: func() error { return nil },
+		CloseFunc:      func() error { return nil },
+		LoadFactorFunc: func() float64 { return 0 },
 	}
 	clientConfig := KvClientConfig{
 		Address:        "endpoint1",
@@ -193,6 +235,450 @@ func TestKvClientPoolNewAndGetRace(t *testing.T) {
 	require.ErrorIs(t, err, expectedErr)
 }
 
+func TestKvClientPoolBacksOffReconnects(t *testing.T) {
+	expectedErr := errors.New("connect failure")
+	var numAttempts uint32
+	var lock sync.Mutex
+	var backoffAttempts []uint32
+	_, err := NewKvClientPool(&KvClientPoolConfig{
+		NumConnections: 1,
+		ClientConfig: KvClientConfig{
+			Address: "endpoint1",
+		},
+		ReconnectBackoff: func(retryAttempts uint32) time.Duration {
+			lock.Lock()
+			backoffAttempts = append(backoffAttempts, retryAttempts)
+			lock.Unlock()
+			return 20 * time.Millisecond
+		},
+	}, &KvClientPoolOptions{
+		NewKvClient: func(ctx context.Context, config *KvClientConfig) (KvClient, error) {
+			atomic.AddUint32(&numAttempts, 1)
+			return nil, expectedErr
+		},
+	})
+	require.NoError(t, err)
+
+	time.Sleep(110 * time.Millisecond)
+
+	attempts := atomic.LoadUint32(&numAttempts)
+	assert.GreaterOrEqual(t, attempts, uint32(3))
+	assert.LessOrEqual(t, attempts, uint32(7))
+
+	lock.Lock()
+	defer lock.Unlock()
+	for i, retryAttempts := range backoffAttempts {
+		assert.Equal(t, uint32(i), retryAttempts)
+	}
+}
+
+func TestKvClientPoolCircuitBreaker(t *testing.T) {
+	expectedErr := errors.New("connect failure")
+	var failing uint32 = 1
+	var numAttempts uint32
+	var numNoOps uint32
+	pool, err := NewKvClientPool(&KvClientPoolConfig{
+		NumConnections: 1,
+		ClientConfig: KvClientConfig{
+			Address: "endpoint1",
+		},
+		ReconnectBackoff: func(retryAttempts uint32) time.Duration {
+			return time.Millisecond
+		},
+		CircuitBreaker: &KvClientPoolCircuitBreakerConfig{
+			FailureThreshold: 2,
+			OpenDuration:     50 * time.Millisecond,
+			CanaryTimeout:    50 * time.Millisecond,
+		},
+	}, &KvClientPoolOptions{
+		NewKvClient: func(ctx context.Context, config *KvClientConfig) (KvClient, error) {
+			atomic.AddUint32(&numAttempts, 1)
+			if atomic.LoadUint32(&failing) == 1 {
+				return nil, expectedErr
+			}
+
+			return &KvClientMock{
+				NoOpFunc: func(ctx context.Context, req *memdx.NoOpRequest) (*memdx.NoOpResponse, error) {
+					atomic.AddUint32(&numNoOps, 1)
+					return &memdx.NoOpResponse{}, nil
+				},
+			}, nil
+		},
+	})
+	require.NoError(t, err)
+
+	// once the circuit is open, operations fail immediately and no further
+	// connections are attempted until the open duration has passed
+	var breakerErr CircuitBreakerOpenError
+	assert.Eventually(t, func() bool {
+		_, err := pool.GetClient(context.Background())
+		return errors.As(err, &breakerErr)
+	}, time.Second, time.Millisecond)
+	assert.ErrorIs(t, breakerErr, ErrCircuitBreakerOpen)
+	assert.Equal(t, "endpoint1", breakerErr.Endpoint)
+	assert.Equal(t, expectedErr, breakerErr.Cause)
+
+	attempts := atomic.LoadUint32(&numAttempts)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, attempts, atomic.LoadUint32(&numAttempts))
+
+	// canary connections which fail keep the circuit open
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint32(&numAttempts) > attempts
+	}, time.Second, time.Millisecond)
+	_, err = pool.GetClient(context.Background())
+	assert.ErrorIs(t, err, ErrCircuitBreakerOpen)
+
+	atomic.StoreUint32(&failing, 0)
+
+	assert.Eventually(t, func() bool {
+		cli, err := pool.GetClient(context.Background())
+		return err == nil && cli != nil
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&numNoOps))
+}
+
+func TestKvClientPoolReplacesClosedClients(t *testing.T) {
+	var numCreated uint32
+	pool, err := NewKvClientPool(&KvClientPoolConfig{
+		NumConnections: 1,
+		ClientConfig: KvClientConfig{
+			Address: "endpoint1",
+		},
+	}, &KvClientPoolOptions{
+		NewKvClient: func(ctx context.Context, config *KvClientConfig) (KvClient, error) {
+			atomic.AddUint32(&numCreated, 1)
+			return &KvClientMock{}, nil
+		},
+	})
+	require.NoError(t, err)
+
+	cli, err := pool.GetClient(context.Background())
+	require.NoError(t, err)
+
+	pool.handleClientClosed(cli, memdx.ErrHeartbeatTimeout)
+
+	assert.Eventually(t, func() bool {
+		newCli, err := pool.GetClient(context.Background())
+		return err == nil && newCli != cli
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, uint32(2), atomic.LoadUint32(&numCreated))
+
+	// clients which the pool no longer holds are ignored
+	pool.handleClientClosed(cli, memdx.ErrHeartbeatTimeout)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, uint32(2), atomic.LoadUint32(&numCreated))
+}
+
+func TestKvClientPoolDrainsReplacedClients(t *testing.T) {
+	var pendingOps uint64 = 1
+	var numClosed uint32
+	var numCreated uint32
+	newClient := func(ctx context.Context, config *KvClientConfig) (KvClient, error) {
+		atomic.AddUint32(&numCreated, 1)
+		return &KvClientMock{
+			ReconfigureFunc: func(newConfig *KvClientConfig, cb func(error)) error {
+				if newConfig.TlsConfig != config.TlsConfig {
+					return errors.New("cannot reconfigure due to conflicting options")
+				}
+				cb(nil)
+				return nil
+			},
+			LoadFactorFunc: func() float64 { return 0 },
+			LoadStatsFunc: func() KvClientLoadStats {
+				return KvClientLoadStats{PendingOperations: atomic.LoadUint64(&pendingOps)}
+			},
+			CloseFunc: func() error {
+				atomic.AddUint32(&numClosed, 1)
+				return nil
+			},
+		}, nil
+	}
+
+	poolConfig := &KvClientPoolConfig{
+		ClientConfig: KvClientConfig{
+			Address: "endpoint1",
+		},
+		Scaling: &KvClientPoolScalingConfig{
+			MinConnections: 1,
+			MaxConnections: 1,
+			CheckInterval:  5 * time.Millisecond,
+		},
+	}
+	pool, err := NewKvClientPool(poolConfig, &KvClientPoolOptions{
+		NewKvClient: newClient,
+	})
+	require.NoError(t, err)
+
+	oldCli, err := pool.GetClient(context.Background())
+	require.NoError(t, err)
+
+	// a client which cannot take the new config is replaced, but is only
+	// closed once its operations in flight have completed
+	newConfig := *poolConfig
+	newConfig.ClientConfig.TlsConfig = &tls.Config{}
+	require.NoError(t, pool.Reconfigure(&newConfig, func(error) {}))
+
+	assert.Eventually(t, func() bool {
+		cli, err := pool.GetClient(context.Background())
+		return err == nil && cli != oldCli
+	}, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&numClosed))
+
+	atomic.StoreUint64(&pendingOps, 0)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint32(&numClosed) == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, uint32(2), atomic.LoadUint32(&numCreated))
+}
+
+func TestKvClientPoolRotatesCredentialsUnderLoad(t *testing.T) {
+	srv, err := memdtest.NewServer(&memdtest.ServerOptions{
+		Users: map[string]string{
+			"user1": "pass1",
+			"user2": "pass2",
+		},
+		Buckets: []*memdtest.Bucket{memdtest.NewBucket(&memdtest.BucketOptions{Name: "default"})},
+	})
+	require.NoError(t, err)
+	defer srv.Close()
+
+	var numCreated uint32
+	var numClosed uint32
+	oldAuth := &PasswordAuthenticator{Username: "user1", Password: "pass1"}
+	newAuth := &PasswordAuthenticator{Username: "user2", Password: "pass2"}
+	poolConfig := &KvClientPoolConfig{
+		NumConnections: 2,
+		ClientConfig: KvClientConfig{
+			Address:        srv.Addr(),
+			Authenticator:  oldAuth,
+			SelectedBucket: "default",
+		},
+	}
+	pool, err := NewKvClientPool(poolConfig, &KvClientPoolOptions{
+		NewKvClient: func(ctx context.Context, config *KvClientConfig) (KvClient, error) {
+			atomic.AddUint32(&numCreated, 1)
+			return NewKvClient(ctx, config, &KvClientOptions{
+				CloseHandler: func(KvClient, error) {
+					atomic.AddUint32(&numClosed, 1)
+				},
+			})
+		},
+	})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	connMgr := &KvClientManagerMock{
+		GetClientFunc: func(ctx context.Context, endpoint string) (KvClient, error) {
+			return pool.GetClient(ctx)
+		},
+		ShutdownClientFunc: func(endpoint string, client KvClient) {
+			pool.ShutdownClient(client)
+		},
+	}
+
+	// operations keep running while the credentials are rotated, none of them
+	// may fail because of the connection they were sent on being changed.
+	stopCh := make(chan struct{})
+	errCh := make(chan error, 8)
+	var wg sync.WaitGroup
+	for workerIdx := 0; workerIdx < 8; workerIdx++ {
+		key := []byte(fmt.Sprintf("key-%d", workerIdx))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stopCh:
+					return
+				default:
+				}
+
+				_, err := OrchestrateMemdClient(context.Background(), connMgr, "endpoint1",
+					func(client KvClient) (*memdx.SetResponse, error) {
+						return client.Set(context.Background(), &memdx.SetRequest{
+							Key:   key,
+							Value: []byte("value"),
+						})
+					})
+				if err == nil {
+					_, err = OrchestrateMemdClient(context.Background(), connMgr, "endpoint1",
+						func(client KvClient) (*memdx.GetResponse, error) {
+							return client.Get(context.Background(), &memdx.GetRequest{
+								Key: key,
+							})
+						})
+				}
+				if err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	newConfig := *poolConfig
+	newConfig.ClientConfig.Authenticator = newAuth
+	reconfigureCh := make(chan error, 1)
+	require.NoError(t, pool.Reconfigure(&newConfig, func(err error) {
+		reconfigureCh <- err
+	}))
+	select {
+	case err := <-reconfigureCh:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for the pool to reconfigure")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(stopCh)
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		assert.NoError(t, err)
+	}
+
+	// each connection was replaced by one using the new credentials, and every
+	// other connection was closed once drained.
+	pool.lock.Lock()
+	require.Len(t, pool.currentClients, 2)
+	for _, client := range pool.currentClients {
+		assert.Equal(t, Authenticator(newAuth), client.(*kvClient).currentConfig.Authenticator)
+	}
+	pool.lock.Unlock()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint32(&numClosed) == atomic.LoadUint32(&numCreated)-2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+type scalingTestClients struct {
+	lock      sync.Mutex
+	load      float64
+	stats     KvClientLoadStats
+	numOpened int
+	numClosed int
+}
+
+func (c *scalingTestClients) newKvClient(ctx context.Context, config *KvClientConfig) (KvClient, error) {
+	c.lock.Lock()
+	c.numOpened++
+	c.lock.Unlock()
+
+	return &KvClientMock{
+		LoadFactorFunc: func() float64 {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			return c.load
+		},
+		LoadStatsFunc: func() KvClientLoadStats {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			return c.stats
+		},
+		CloseFunc: func() error {
+			c.lock.Lock()
+			c.numClosed++
+			c.lock.Unlock()
+			return nil
+		},
+	}, nil
+}
+
+func (c *scalingTestClients) setLoad(load float64, stats KvClientLoadStats) {
+	c.lock.Lock()
+	c.load = load
+	c.stats = stats
+	c.lock.Unlock()
+}
+
+func (c *scalingTestClients) numOpen() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.numOpened - c.numClosed
+}
+
+func TestKvClientPoolScalesWithLoad(t *testing.T) {
+	clients := &scalingTestClients{}
+	pool, err := NewKvClientPool(&KvClientPoolConfig{
+		ClientConfig: KvClientConfig{
+			Address: "endpoint1",
+		},
+		Scaling: &KvClientPoolScalingConfig{
+			MinConnections: 1,
+			MaxConnections: 3,
+			CheckInterval:  5 * time.Millisecond,
+		},
+	}, &KvClientPoolOptions{
+		NewKvClient: clients.newKvClient,
+	})
+	require.NoError(t, err)
+
+	clients.setLoad(20, KvClientLoadStats{})
+
+	// the load is only checked while the pool is in use
+	assert.Eventually(t, func() bool {
+		_, err := pool.GetClient(context.Background())
+		require.NoError(t, err)
+		return clients.numOpen() == 3
+	}, time.Second, 5*time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 3, clients.numOpen())
+
+	clients.setLoad(0, KvClientLoadStats{})
+
+	assert.Eventually(t, func() bool {
+		return clients.numOpen() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	// once the pool is at its minimum size the checks stop
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint32(&pool.scalingCheckArmed) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestKvClientPoolScalesWithLatency(t *testing.T) {
+	clients := &scalingTestClients{}
+	pool, err := NewKvClientPool(&KvClientPoolConfig{
+		ClientConfig: KvClientConfig{
+			Address: "endpoint1",
+		},
+		Scaling: &KvClientPoolScalingConfig{
+			MinConnections: 1,
+			MaxConnections: 2,
+			ScaleUpLatency: 10 * time.Millisecond,
+			IdleTimeout:    20 * time.Millisecond,
+			CheckInterval:  5 * time.Millisecond,
+		},
+	}, &KvClientPoolOptions{
+		NewKvClient: clients.newKvClient,
+	})
+	require.NoError(t, err)
+
+	// the load stays between the thresholds, but every check sees another
+	// slow operation complete
+	var numCompleted uint64
+	assert.Eventually(t, func() bool {
+		numCompleted++
+		clients.setLoad(2, KvClientLoadStats{
+			CompletedOperations: numCompleted,
+			TotalLatency:        time.Duration(numCompleted) * 50 * time.Millisecond,
+		})
+
+		_, err := pool.GetClient(context.Background())
+		require.NoError(t, err)
+		return clients.numOpen() == 2
+	}, time.Second, 5*time.Millisecond)
+
+	// once operations stop completing the extra connection is reaped
+	assert.Eventually(t, func() bool {
+		return clients.numOpen() == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
 func TestKvClientPoolGetClientIntegration(t *testing.T) {
 	testutils.SkipIfShortTest(t)
 
@@ -3,6 +3,8 @@ package memdx
 import (
 	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Client is a basic memd client that provides opaque mapping and request dispatch...
@@ -13,16 +15,26 @@ type Client struct {
 	orphanHandler func(*Packet)
 	closeHandler  func(error)
 
-	lock      sync.Mutex
-	opaqueCtr uint32
-	opaqueMap map[uint32]DispatchCallback
+	lastReadNanos     int64
+	heartbeatRttNanos int64
+	closedCh          chan struct{}
+
+	lock        sync.Mutex
+	opaqueCtr   uint32
+	opaqueMap   map[uint32]DispatchCallback
+	closeReason error
 }
 
 var _ Dispatcher = (*Client)(nil)
+var _ RawWriter = (*Client)(nil)
 
 type ClientOptions struct {
 	OrphanHandler func(*Packet)
 	CloseHandler  func(error)
+
+	// Heartbeat, when specified, makes the client check that the connection
+	// is still alive whenever it has been idle.
+	Heartbeat *HeartbeatOptions
 }
 
 func NewClient(conn *Conn, opts *ClientOptions) *Client {
@@ -35,11 +47,18 @@ func NewClient(conn *Conn, opts *ClientOptions) *Client {
 		orphanHandler: opts.OrphanHandler,
 		closeHandler:  opts.CloseHandler,
 
+		lastReadNanos: time.Now().UnixNano(),
+		closedCh:      make(chan struct{}),
+
 		opaqueCtr: 1,
 		opaqueMap: make(map[uint32]DispatchCallback),
 	}
 	go c.run()
 
+	if opts.Heartbeat != nil {
+		go c.runHeartbeats(opts.Heartbeat.withDefaults())
+	}
+
 	return c
 }
 
@@ -53,6 +72,8 @@ func (c *Client) run() {
 			break
 		}
 
+		atomic.StoreInt64(&c.lastReadNanos, time.Now().UnixNano())
+
 		err = c.dispatchCallback(pak)
 		if err != nil {
 			closeErr = err
@@ -60,11 +81,46 @@ func (c *Client) run() {
 		}
 	}
 
+	close(c.closedCh)
+
+	c.lock.Lock()
+	closeReason := c.closeReason
+	c.lock.Unlock()
+
+	if closeReason != nil {
+		closeErr = closeReason
+	}
+
+	c.failPendingHandlers(closeReason)
+
 	if c.closeHandler != nil {
 		c.closeHandler(closeErr)
 	}
 }
 
+// failPendingHandlers notifies every handler which is still waiting for a
+// response that the connection has gone away, since no more packets will
+// ever arrive for them.  closeReason is included in the error when the client
+// closed the connection itself.
+func (c *Client) failPendingHandlers(closeReason error) {
+	c.lock.Lock()
+	handlers := c.opaqueMap
+	c.opaqueMap = make(map[uint32]DispatchCallback)
+	c.lock.Unlock()
+
+	err := ErrClosedInFlight
+	if closeReason != nil {
+		err = closedInFlightError{cause: closeReason}
+	}
+
+	for _, handler := range handlers {
+		hasMorePackets := handler(nil, err)
+		if hasMorePackets {
+			panic("memd packet handler returned hasMorePackets after an error")
+		}
+	}
+}
+
 func (c *Client) registerHandler(handler DispatchCallback) uint32 {
 	c.lock.Lock()
 
@@ -100,6 +156,17 @@ func (c *Client) cancelHandler(opaqueID uint32, err error) {
 }
 
 func (c *Client) dispatchCallback(pak *Packet) error {
+	if pak.Magic == MagicServerReq || (pak.Magic == MagicReq && pak.OpCode == OpCodeDcpNoOp) {
+		// server-initiated requests use their own opaque space, so they can
+		// never be matched against one of our handlers.  DCP no-ops are sent
+		// as normal requests, but their opaque may equal that of an open
+		// stream, which would otherwise swallow them without a reply.
+		if c.orphanHandler != nil {
+			c.orphanHandler(pak)
+		}
+		return nil
+	}
+
 	c.lock.Lock()
 
 	handler, handlerIsValid := c.opaqueMap[pak.Opaque]
@@ -132,6 +199,18 @@ func (c *Client) Close() error {
 	return c.conn.Close()
 }
 
+// closeWithReason closes the connection, failing any requests still in
+// flight with an error which includes the reason.
+func (c *Client) closeWithReason(reason error) error {
+	c.lock.Lock()
+	if c.closeReason == nil {
+		c.closeReason = reason
+	}
+	c.lock.Unlock()
+
+	return c.conn.Close()
+}
+
 func (c *Client) Dispatch(req *Packet, handler DispatchCallback) (PendingOp, error) {
 	opaqueID := c.registerHandler(handler)
 	req.Opaque = opaqueID
@@ -151,6 +230,12 @@ func (c *Client) Dispatch(req *Packet, handler DispatchCallback) (PendingOp, err
 	}, nil
 }
 
+// WritePacket writes a packet to the connection without registering a handler,
+// for use with packets which do not expect a reply.
+func (c *Client) WritePacket(pak *Packet) error {
+	return c.conn.WritePacket(pak)
+}
+
 func (c *Client) LocalAddr() string {
 	return c.conn.LocalAddr()
 }
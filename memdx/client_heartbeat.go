@@ -0,0 +1,91 @@
+package memdx
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// HeartbeatOptions controls the NOOP heartbeats a Client sends to detect
+// connections which have silently died, which would otherwise only be noticed
+// once the operating system gives up on the socket.
+type HeartbeatOptions struct {
+	// Interval is how long the connection must go without receiving any
+	// packets before a heartbeat is sent, defaults to 5 seconds.
+	Interval time.Duration
+
+	// Timeout is how long to wait for the response to a heartbeat before
+	// the connection is closed, defaults to 2.5 seconds.
+	Timeout time.Duration
+}
+
+func (o HeartbeatOptions) withDefaults() HeartbeatOptions {
+	if o.Interval <= 0 {
+		o.Interval = 5 * time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 2500 * time.Millisecond
+	}
+	return o
+}
+
+// HeartbeatRTT returns the round-trip time of the most recent heartbeat, or
+// zero if none has completed yet.
+func (c *Client) HeartbeatRTT() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.heartbeatRttNanos))
+}
+
+func (c *Client) runHeartbeats(opts HeartbeatOptions) {
+	for {
+		lastRead := time.Unix(0, atomic.LoadInt64(&c.lastReadNanos))
+		waitTime := opts.Interval - time.Since(lastRead)
+		if waitTime > 0 {
+			timer := time.NewTimer(waitTime)
+			select {
+			case <-timer.C:
+				continue
+			case <-c.closedCh:
+				timer.Stop()
+				return
+			}
+		}
+
+		err := c.sendHeartbeat(opts.Timeout)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *Client) sendHeartbeat(timeout time.Duration) error {
+	resultCh := make(chan error, 1)
+	startTime := time.Now()
+
+	_, err := OpsCore{}.NoOp(c, &NoOpRequest{}, func(resp *NoOpResponse, err error) {
+		resultCh <- err
+	})
+	if err != nil {
+		// failing to write means the connection is already being torn down
+		return err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-resultCh:
+		// an error status still shows that the server is responding
+		var serverErr ServerError
+		if err != nil && !errors.As(err, &serverErr) {
+			return err
+		}
+
+		atomic.StoreInt64(&c.heartbeatRttNanos, int64(time.Since(startTime)))
+		return nil
+	case <-timer.C:
+		// closing the connection fails the heartbeat along with every other
+		// request in flight, which is then collected by the read loop.
+		_ = c.closeWithReason(ErrHeartbeatTimeout)
+		return ErrHeartbeatTimeout
+	}
+}
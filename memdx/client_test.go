@@ -2,7 +2,10 @@ package memdx
 
 import (
 	"errors"
+	"net"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -71,3 +74,92 @@ func TestClientOpCancellationAfterResult(t *testing.T) {
 
 	op.Cancel(expectedErr)
 }
+
+// startPipeClient creates a client connected to an in-memory server which
+// reads every request, and replies to them using the handler if one is given.
+func startPipeClient(t *testing.T, opts *ClientOptions, handler func(*Packet) *Packet) *Client {
+	cliConn, srvConn := net.Pipe()
+
+	go func() {
+		var reader PacketReader
+		var writer PacketWriter
+		for {
+			pak := &Packet{}
+			err := reader.ReadPacket(srvConn, pak)
+			if err != nil {
+				return
+			}
+
+			if handler != nil {
+				if resp := handler(pak); resp != nil {
+					_ = writer.WritePacket(srvConn, resp)
+				}
+			}
+		}
+	}()
+
+	cli := NewClient(&Conn{conn: cliConn}, opts)
+
+	t.Cleanup(func() {
+		cli.Close()
+		srvConn.Close()
+	})
+
+	return cli
+}
+
+func TestClientHeartbeat(t *testing.T) {
+	var numNoOps uint32
+	cli := startPipeClient(t, &ClientOptions{
+		Heartbeat: &HeartbeatOptions{
+			Interval: 10 * time.Millisecond,
+			Timeout:  time.Second,
+		},
+	}, func(req *Packet) *Packet {
+		if req.OpCode == OpCodeNoOp {
+			atomic.AddUint32(&numNoOps, 1)
+		}
+
+		return &Packet{
+			Magic:  MagicRes,
+			OpCode: req.OpCode,
+			Opaque: req.Opaque,
+			Status: StatusSuccess,
+		}
+	})
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint32(&numNoOps) >= 2
+	}, time.Second, time.Millisecond)
+	assert.NotZero(t, cli.HeartbeatRTT())
+}
+
+func TestClientHeartbeatTimeout(t *testing.T) {
+	closeErrCh := make(chan error, 1)
+	cli := startPipeClient(t, &ClientOptions{
+		CloseHandler: func(err error) {
+			closeErrCh <- err
+		},
+		Heartbeat: &HeartbeatOptions{
+			Interval: 10 * time.Millisecond,
+			Timeout:  20 * time.Millisecond,
+		},
+	}, nil)
+
+	result := make(chan error, 1)
+	_, err := cli.Dispatch(&Packet{
+		Magic:  MagicReq,
+		OpCode: OpCodeGet,
+		Key:    []byte("key"),
+	}, func(packet *Packet, err error) bool {
+		result <- err
+		return false
+	})
+	require.NoError(t, err)
+
+	err = <-result
+	assert.ErrorIs(t, err, ErrClosedInFlight)
+	assert.ErrorIs(t, err, ErrHeartbeatTimeout)
+
+	assert.ErrorIs(t, <-closeErrCh, ErrHeartbeatTimeout)
+}
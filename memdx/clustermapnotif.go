@@ -0,0 +1,40 @@
+package memdx
+
+import (
+	"encoding/binary"
+)
+
+type ClustermapChangeNotification struct {
+	BucketName string
+	RevEpoch   int64
+	Rev        int64
+
+	// Config contains the new cluster map, and may be empty if the server
+	// only notified us of the new revision.
+	Config []byte
+}
+
+// ParseClustermapChangeNotification decodes a server-initiated cluster map
+// change notification.  The server does not expect a response to these.
+func ParseClustermapChangeNotification(pak *Packet) (*ClustermapChangeNotification, error) {
+	if pak.Magic != MagicServerReq ||
+		ServerOpCode(pak.OpCode) != ServerOpCodeClustermapChangeNotification {
+		return nil, invalidArgError{"packet is not a clustermap change notification"}
+	}
+
+	notif := &ClustermapChangeNotification{
+		BucketName: string(pak.Key),
+		Config:     pak.Value,
+	}
+
+	if len(pak.Extras) == 16 {
+		notif.RevEpoch = int64(binary.BigEndian.Uint64(pak.Extras[0:]))
+		notif.Rev = int64(binary.BigEndian.Uint64(pak.Extras[8:]))
+	} else if len(pak.Extras) == 4 {
+		notif.Rev = int64(binary.BigEndian.Uint32(pak.Extras[0:]))
+	} else {
+		return nil, protocolError{"bad extras length"}
+	}
+
+	return notif, nil
+}
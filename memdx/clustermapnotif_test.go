@@ -0,0 +1,60 @@
+package memdx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClustermapChangeNotification(t *testing.T) {
+	extras := make([]byte, 16)
+	binary.BigEndian.PutUint64(extras[0:], 2)
+	binary.BigEndian.PutUint64(extras[8:], 1234)
+
+	// we round-trip through the packet writer and reader to ensure that the
+	// server request magic is correctly encoded and decoded.
+	var buf bytes.Buffer
+	err := (&PacketWriter{}).WritePacket(&buf, &Packet{
+		Magic:  MagicServerReq,
+		OpCode: OpCode(ServerOpCodeClustermapChangeNotification),
+		Opaque: 1,
+		Extras: extras,
+		Key:    []byte("default"),
+		Value:  []byte(`{"rev":1234}`),
+	})
+	require.NoError(t, err)
+
+	var pak Packet
+	err = (&PacketReader{}).ReadPacket(&buf, &pak)
+	require.NoError(t, err)
+	assert.Equal(t, MagicServerReq, pak.Magic)
+	assert.True(t, pak.Magic.IsServerInitiated())
+
+	notif, err := ParseClustermapChangeNotification(&pak)
+	require.NoError(t, err)
+	assert.Equal(t, &ClustermapChangeNotification{
+		BucketName: "default",
+		RevEpoch:   2,
+		Rev:        1234,
+		Config:     []byte(`{"rev":1234}`),
+	}, notif)
+}
+
+func TestParseClustermapChangeNotificationWrongPacket(t *testing.T) {
+	_, err := ParseClustermapChangeNotification(&Packet{
+		Magic:  MagicReq,
+		OpCode: OpCodeSet,
+		Extras: make([]byte, 16),
+	})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+
+	_, err = ParseClustermapChangeNotification(&Packet{
+		Magic:  MagicServerReq,
+		OpCode: OpCode(ServerOpCodeClustermapChangeNotification),
+		Extras: make([]byte, 3),
+	})
+	assert.ErrorIs(t, err, ErrProtocol)
+}
@@ -0,0 +1,125 @@
+package memdx
+
+// DcpConnectionFlags specifies options for an OpenConnection DCP request.
+type DcpConnectionFlags uint32
+
+const (
+	// DcpConnectionFlagsProducer indicates this connection wants the other end to be a producer.
+	DcpConnectionFlagsProducer = DcpConnectionFlags(0x01)
+
+	// DcpConnectionFlagsNotifier indicates this connection wants the other end to be a notifier.
+	DcpConnectionFlagsNotifier = DcpConnectionFlags(0x02)
+
+	// DcpConnectionFlagsIncludeXattrs indicates the client wishes to receive extended attributes.
+	DcpConnectionFlagsIncludeXattrs = DcpConnectionFlags(0x04)
+
+	// DcpConnectionFlagsNoValue indicates the client does not wish to receive mutation values.
+	DcpConnectionFlagsNoValue = DcpConnectionFlags(0x08)
+
+	// DcpConnectionFlagsIncludeDeleteTimes indicates the client wishes to receive delete times.
+	DcpConnectionFlagsIncludeDeleteTimes = DcpConnectionFlags(0x20)
+)
+
+// DcpStreamReqFlags specifies options for a StreamReq DCP request.
+type DcpStreamReqFlags uint32
+
+const (
+	// DcpStreamReqFlagsTakeover indicates the server should perform a vbucket takeover.
+	DcpStreamReqFlagsTakeover = DcpStreamReqFlags(0x01)
+
+	// DcpStreamReqFlagsDiskOnly indicates that the server should only stream data from disk.
+	DcpStreamReqFlagsDiskOnly = DcpStreamReqFlags(0x02)
+
+	// DcpStreamReqFlagsLatest indicates the server should stream up to the latest seqno
+	// at the time the stream is created, ignoring the end seqno.
+	DcpStreamReqFlagsLatest = DcpStreamReqFlags(0x04)
+
+	// DcpStreamReqFlagsActiveOnly indicates the server should only stream from an
+	// active vbucket, failing the request with not-my-vbucket otherwise.
+	DcpStreamReqFlagsActiveOnly = DcpStreamReqFlags(0x10)
+
+	// DcpStreamReqFlagsStrictVbUUID indicates the server should check the vbuuid
+	// even when the start seqno is zero.
+	DcpStreamReqFlagsStrictVbUUID = DcpStreamReqFlags(0x20)
+)
+
+// DcpSnapshotMarkerFlags represents the flags attached to a DCP snapshot marker.
+type DcpSnapshotMarkerFlags uint32
+
+const (
+	// DcpSnapshotMarkerFlagsMemory indicates the snapshot came from memory.
+	DcpSnapshotMarkerFlagsMemory = DcpSnapshotMarkerFlags(0x01)
+
+	// DcpSnapshotMarkerFlagsDisk indicates the snapshot came from disk.
+	DcpSnapshotMarkerFlagsDisk = DcpSnapshotMarkerFlags(0x02)
+
+	// DcpSnapshotMarkerFlagsCheckpoint indicates a checkpoint snapshot.
+	DcpSnapshotMarkerFlagsCheckpoint = DcpSnapshotMarkerFlags(0x04)
+
+	// DcpSnapshotMarkerFlagsAck indicates the snapshot requires an acknowledgement.
+	DcpSnapshotMarkerFlagsAck = DcpSnapshotMarkerFlags(0x08)
+)
+
+// DcpStreamEndReason represents the reason a DCP stream was ended by the server.
+type DcpStreamEndReason uint32
+
+const (
+	// DcpStreamEndReasonOK indicates the stream reached its end seqno.
+	DcpStreamEndReasonOK = DcpStreamEndReason(0x00)
+
+	// DcpStreamEndReasonClosed indicates the stream was closed by the client.
+	DcpStreamEndReasonClosed = DcpStreamEndReason(0x01)
+
+	// DcpStreamEndReasonStateChanged indicates the state of the vbucket changed,
+	// which typically happens when it is moved during a rebalance.
+	DcpStreamEndReasonStateChanged = DcpStreamEndReason(0x02)
+
+	// DcpStreamEndReasonDisconnected indicates the stream was ended due to a disconnection.
+	DcpStreamEndReasonDisconnected = DcpStreamEndReason(0x03)
+
+	// DcpStreamEndReasonTooSlow indicates the client was not consuming the stream fast enough.
+	DcpStreamEndReasonTooSlow = DcpStreamEndReason(0x04)
+
+	// DcpStreamEndReasonBackfillFail indicates the stream ended because a backfill failed.
+	DcpStreamEndReasonBackfillFail = DcpStreamEndReason(0x05)
+
+	// DcpStreamEndReasonRollback indicates the stream ended because a rollback is required.
+	DcpStreamEndReasonRollback = DcpStreamEndReason(0x06)
+
+	// DcpStreamEndReasonFilterEmpty indicates all of the collections in the filter were dropped.
+	DcpStreamEndReasonFilterEmpty = DcpStreamEndReason(0x07)
+
+	// DcpStreamEndReasonLostPrivileges indicates the user lost the privileges to stream.
+	DcpStreamEndReasonLostPrivileges = DcpStreamEndReason(0x08)
+)
+
+func (r DcpStreamEndReason) String() string {
+	switch r {
+	case DcpStreamEndReasonOK:
+		return "OK"
+	case DcpStreamEndReasonClosed:
+		return "Closed"
+	case DcpStreamEndReasonStateChanged:
+		return "StateChanged"
+	case DcpStreamEndReasonDisconnected:
+		return "Disconnected"
+	case DcpStreamEndReasonTooSlow:
+		return "TooSlow"
+	case DcpStreamEndReasonBackfillFail:
+		return "BackfillFail"
+	case DcpStreamEndReasonRollback:
+		return "Rollback"
+	case DcpStreamEndReasonFilterEmpty:
+		return "FilterEmpty"
+	case DcpStreamEndReasonLostPrivileges:
+		return "LostPrivileges"
+	}
+
+	return "Unknown"
+}
+
+// DcpFailoverEntry represents a single entry in the failover log of a vbucket.
+type DcpFailoverEntry struct {
+	VbUuid uint64
+	SeqNo  uint64
+}
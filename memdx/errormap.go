@@ -0,0 +1,210 @@
+package memdx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrorMapAttribute represents an attribute the server attaches to a status
+// code in its error map to describe how clients should handle it.
+type ErrorMapAttribute string
+
+const (
+	ErrorMapAttributeSuccess              = ErrorMapAttribute("success")
+	ErrorMapAttributeItemOnly             = ErrorMapAttribute("item-only")
+	ErrorMapAttributeInvalidInput         = ErrorMapAttribute("invalid-input")
+	ErrorMapAttributeFetchConfig          = ErrorMapAttribute("fetch-config")
+	ErrorMapAttributeConnStateInvalidated = ErrorMapAttribute("conn-state-invalidated")
+	ErrorMapAttributeAuth                 = ErrorMapAttribute("auth")
+	ErrorMapAttributeSpecialHandling      = ErrorMapAttribute("special-handling")
+	ErrorMapAttributeSupport              = ErrorMapAttribute("support")
+	ErrorMapAttributeTemp                 = ErrorMapAttribute("temp")
+	ErrorMapAttributeInternal             = ErrorMapAttribute("internal")
+	ErrorMapAttributeRetryNow             = ErrorMapAttribute("retry-now")
+	ErrorMapAttributeRetryLater           = ErrorMapAttribute("retry-later")
+	ErrorMapAttributeSubdoc               = ErrorMapAttribute("subdoc")
+	ErrorMapAttributeDcp                  = ErrorMapAttribute("dcp")
+	ErrorMapAttributeAutoRetry            = ErrorMapAttribute("auto-retry")
+	ErrorMapAttributeItemLocked           = ErrorMapAttribute("item-locked")
+	ErrorMapAttributeItemDeleted          = ErrorMapAttribute("item-deleted")
+	ErrorMapAttributeRateLimit            = ErrorMapAttribute("rate-limit")
+)
+
+// ErrorMapRetryStrategy represents the backoff strategy the server asks
+// clients to use when retrying a particular status code.
+type ErrorMapRetryStrategy string
+
+const (
+	ErrorMapRetryStrategyConstant    = ErrorMapRetryStrategy("constant")
+	ErrorMapRetryStrategyLinear      = ErrorMapRetryStrategy("linear")
+	ErrorMapRetryStrategyExponential = ErrorMapRetryStrategy("exponential")
+)
+
+type ErrorMapRetrySpec struct {
+	Strategy    ErrorMapRetryStrategy
+	Interval    time.Duration
+	After       time.Duration
+	Ceil        time.Duration
+	MaxDuration time.Duration
+}
+
+// RetryDelay returns how long to wait before performing the given retry
+// attempt, where the first retry is attempt 0.
+func (s ErrorMapRetrySpec) RetryDelay(attempt uint32) time.Duration {
+	if attempt == 0 && s.After > 0 {
+		return s.After
+	}
+
+	var delay time.Duration
+	switch s.Strategy {
+	case ErrorMapRetryStrategyLinear:
+		delay = s.Interval * time.Duration(attempt+1)
+	case ErrorMapRetryStrategyExponential:
+		delay = s.Interval
+		for i := uint32(0); i < attempt && (s.Ceil <= 0 || delay < s.Ceil); i++ {
+			delay *= 2
+		}
+	default:
+		delay = s.Interval
+	}
+
+	if s.Ceil > 0 && delay > s.Ceil {
+		delay = s.Ceil
+	}
+
+	return delay
+}
+
+type ErrorMapEntry struct {
+	Status      Status
+	Name        string
+	Description string
+	Attributes  []ErrorMapAttribute
+	Retry       *ErrorMapRetrySpec
+}
+
+func (e ErrorMapEntry) HasAttribute(attr ErrorMapAttribute) bool {
+	for _, entryAttr := range e.Attributes {
+		if entryAttr == attr {
+			return true
+		}
+	}
+	return false
+}
+
+type ErrorMap struct {
+	Version  int
+	Revision int
+	Errors   map[Status]*ErrorMapEntry
+}
+
+type errorMapRetrySpecJson struct {
+	Strategy    string `json:"strategy"`
+	Interval    int    `json:"interval"`
+	After       int    `json:"after"`
+	Ceil        int    `json:"ceil"`
+	MaxDuration int    `json:"max-duration"`
+}
+
+type errorMapEntryJson struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"desc"`
+	Attributes  []string               `json:"attrs"`
+	Retry       *errorMapRetrySpecJson `json:"retry,omitempty"`
+}
+
+type errorMapJson struct {
+	Version  int                          `json:"version"`
+	Revision int                          `json:"revision"`
+	Errors   map[string]errorMapEntryJson `json:"errors"`
+}
+
+// ParseErrorMap parses the JSON error map returned by the server in response
+// to a GetErrorMap request.
+func ParseErrorMap(data []byte) (*ErrorMap, error) {
+	var errMapJson errorMapJson
+	err := json.Unmarshal(data, &errMapJson)
+	if err != nil {
+		return nil, err
+	}
+
+	errMap := &ErrorMap{
+		Version:  errMapJson.Version,
+		Revision: errMapJson.Revision,
+		Errors:   make(map[Status]*ErrorMapEntry, len(errMapJson.Errors)),
+	}
+
+	for statusStr, entryJson := range errMapJson.Errors {
+		status, err := strconv.ParseUint(statusStr, 16, 16)
+		if err != nil {
+			return nil, protocolError{"invalid status code in error map: " + statusStr}
+		}
+
+		entry := &ErrorMapEntry{
+			Status:      Status(status),
+			Name:        entryJson.Name,
+			Description: entryJson.Description,
+		}
+
+		for _, attr := range entryJson.Attributes {
+			entry.Attributes = append(entry.Attributes, ErrorMapAttribute(attr))
+		}
+
+		if entryJson.Retry != nil {
+			entry.Retry = &ErrorMapRetrySpec{
+				Strategy:    ErrorMapRetryStrategy(entryJson.Retry.Strategy),
+				Interval:    time.Duration(entryJson.Retry.Interval) * time.Millisecond,
+				After:       time.Duration(entryJson.Retry.After) * time.Millisecond,
+				Ceil:        time.Duration(entryJson.Retry.Ceil) * time.Millisecond,
+				MaxDuration: time.Duration(entryJson.Retry.MaxDuration) * time.Millisecond,
+			}
+		}
+
+		errMap.Errors[entry.Status] = entry
+	}
+
+	return errMap, nil
+}
+
+// AnnotateError attaches the error map entry for the status of a server error,
+// if one exists.  Errors which did not originate from a server response are
+// returned unchanged.
+func (m *ErrorMap) AnnotateError(err error) error {
+	if m == nil || err == nil {
+		return err
+	}
+
+	var serverErr ServerError
+	if !errors.As(err, &serverErr) {
+		return err
+	}
+
+	entry, ok := m.Errors[serverErr.Status]
+	if !ok {
+		return err
+	}
+
+	return ServerErrorWithErrorMap{
+		Cause: err,
+		Entry: entry,
+	}
+}
+
+// ServerErrorWithErrorMap annotates a server error with the information the
+// server provided about its status code in the error map.
+type ServerErrorWithErrorMap struct {
+	Cause error
+	Entry *ErrorMapEntry
+}
+
+func (e ServerErrorWithErrorMap) Error() string {
+	return fmt.Sprintf("%s (%s: %s, attributes: %v)",
+		e.Cause, e.Entry.Name, e.Entry.Description, e.Entry.Attributes)
+}
+
+func (e ServerErrorWithErrorMap) Unwrap() error {
+	return e.Cause
+}
@@ -0,0 +1,110 @@
+package memdx
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocbcorex/testutils"
+)
+
+func TestParseErrorMap(t *testing.T) {
+	errMap, err := ParseErrorMap(testutils.LoadTestData(t, "err_map71_v2.json"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, errMap.Version)
+	assert.Equal(t, 1, errMap.Revision)
+
+	tmpFail := errMap.Errors[StatusTmpFail]
+	require.NotNil(t, tmpFail)
+	assert.Equal(t, "ETMPFAIL", tmpFail.Name)
+	assert.True(t, tmpFail.HasAttribute(ErrorMapAttributeTemp))
+	assert.True(t, tmpFail.HasAttribute(ErrorMapAttributeRetryNow))
+	assert.False(t, tmpFail.HasAttribute(ErrorMapAttributeAuth))
+	assert.Nil(t, tmpFail.Retry)
+}
+
+func TestParseErrorMapRetrySpec(t *testing.T) {
+	errMap, err := ParseErrorMap([]byte(`{"version":2,"revision":1,"errors":{
+		"ff01":{"name":"NEW_TEMP","desc":"A new temporary error","attrs":["temp"],
+			"retry":{"strategy":"exponential","interval":10,"after":5,"ceil":70,"max-duration":500}}
+	}}`))
+	require.NoError(t, err)
+
+	entry := errMap.Errors[Status(0xff01)]
+	require.NotNil(t, entry)
+	require.NotNil(t, entry.Retry)
+
+	assert.Equal(t, &ErrorMapRetrySpec{
+		Strategy:    ErrorMapRetryStrategyExponential,
+		Interval:    10 * time.Millisecond,
+		After:       5 * time.Millisecond,
+		Ceil:        70 * time.Millisecond,
+		MaxDuration: 500 * time.Millisecond,
+	}, entry.Retry)
+
+	assert.Equal(t, 5*time.Millisecond, entry.Retry.RetryDelay(0))
+	assert.Equal(t, 20*time.Millisecond, entry.Retry.RetryDelay(1))
+	assert.Equal(t, 40*time.Millisecond, entry.Retry.RetryDelay(2))
+	assert.Equal(t, 70*time.Millisecond, entry.Retry.RetryDelay(3))
+	assert.Equal(t, 70*time.Millisecond, entry.Retry.RetryDelay(100))
+}
+
+func TestErrorMapRetrySpecStrategies(t *testing.T) {
+	constant := ErrorMapRetrySpec{
+		Strategy: ErrorMapRetryStrategyConstant,
+		Interval: 10 * time.Millisecond,
+	}
+	assert.Equal(t, 10*time.Millisecond, constant.RetryDelay(0))
+	assert.Equal(t, 10*time.Millisecond, constant.RetryDelay(5))
+
+	linear := ErrorMapRetrySpec{
+		Strategy: ErrorMapRetryStrategyLinear,
+		Interval: 10 * time.Millisecond,
+		Ceil:     35 * time.Millisecond,
+	}
+	assert.Equal(t, 10*time.Millisecond, linear.RetryDelay(0))
+	assert.Equal(t, 20*time.Millisecond, linear.RetryDelay(1))
+	assert.Equal(t, 30*time.Millisecond, linear.RetryDelay(2))
+	assert.Equal(t, 35*time.Millisecond, linear.RetryDelay(3))
+}
+
+func TestErrorMapAnnotateError(t *testing.T) {
+	errMap, err := ParseErrorMap([]byte(`{"version":2,"revision":1,"errors":{
+		"ff01":{"name":"NEW_TEMP","desc":"A new temporary error","attrs":["temp","retry-later"]}
+	}}`))
+	require.NoError(t, err)
+
+	serverErr := OpsCore{}.decodeError(&Packet{
+		Magic:  MagicRes,
+		Status: Status(0xff01),
+	}, "endpoint1", "local1")
+
+	annotatedErr := errMap.AnnotateError(serverErr)
+
+	var errMapErr ServerErrorWithErrorMap
+	require.True(t, errors.As(annotatedErr, &errMapErr))
+	assert.Equal(t, "NEW_TEMP", errMapErr.Entry.Name)
+	assert.True(t, errMapErr.Entry.HasAttribute(ErrorMapAttributeRetryLater))
+	assert.Contains(t, annotatedErr.Error(), "A new temporary error")
+
+	var baseErr ServerError
+	require.True(t, errors.As(annotatedErr, &baseErr))
+	assert.Equal(t, Status(0xff01), baseErr.Status)
+
+	// errors without a server status, or with an unmapped one, are untouched
+	plainErr := errors.New("not a server error")
+	assert.Equal(t, plainErr, errMap.AnnotateError(plainErr))
+
+	unmappedErr := OpsCore{}.decodeError(&Packet{
+		Magic:  MagicRes,
+		Status: Status(0xff02),
+	}, "endpoint1", "local1")
+	assert.Equal(t, unmappedErr, errMap.AnnotateError(unmappedErr))
+
+	var nilErrMap *ErrorMap
+	assert.Equal(t, serverErr, nilErrMap.AnnotateError(serverErr))
+}
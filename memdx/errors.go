@@ -47,6 +47,11 @@ var (
 	ErrSubDocXattrUnknownVattrMacro        = errors.New("subdoc xattr unknown vattr macro")
 	ErrSubDocCanOnlyReviveDeletedDocuments = errors.New("subdoc can only revive deleted documents")
 	ErrSubDocDeletedDocumentCantHaveValue  = errors.New("subdoc deleted document cant have value")
+	ErrDcpRollback                         = errors.New("dcp rollback required")
+	ErrDcpStreamExists                     = errors.New("dcp stream already exists")
+	ErrDcpStreamNotFound                   = errors.New("dcp stream not found")
+	ErrClosedInFlight                      = errors.New("connection closed while request was in flight")
+	ErrHeartbeatTimeout                    = errors.New("heartbeat timed out")
 )
 
 var ErrProtocol = errors.New("protocol error")
@@ -75,6 +80,24 @@ func (e requestCancelledError) Unwrap() error {
 	return e.cause
 }
 
+// closedInFlightError is used in place of ErrClosedInFlight when the client
+// itself closed the connection, to indicate why it did so.
+type closedInFlightError struct {
+	cause error
+}
+
+func (e closedInFlightError) Error() string {
+	return ErrClosedInFlight.Error() + ": " + e.cause.Error()
+}
+
+func (e closedInFlightError) Is(target error) bool {
+	return target == ErrClosedInFlight
+}
+
+func (e closedInFlightError) Unwrap() error {
+	return e.cause
+}
+
 var ErrInvalidArgument = errors.New("invalid argument")
 
 type invalidArgError struct {
@@ -91,6 +114,7 @@ func (e invalidArgError) Unwrap() error {
 
 type ServerError struct {
 	Cause          error
+	Status         Status
 	DispatchedTo   string
 	DispatchedFrom string
 	Opaque         uint32
@@ -127,6 +151,18 @@ func (e SubDocError) Unwrap() error {
 	return e.Cause
 }
 
+type DcpRollbackError struct {
+	RollbackSeqNo uint64
+}
+
+func (e DcpRollbackError) Error() string {
+	return fmt.Sprintf("dcp rollback required (rollback seqno: %d)", e.RollbackSeqNo)
+}
+
+func (e DcpRollbackError) Unwrap() error {
+	return ErrDcpRollback
+}
+
 type ServerErrorWithConfig struct {
 	Cause      ServerError
 	ConfigJson []byte
@@ -10,7 +10,7 @@ func AppendExtFrame(frameCode ExtFrameCode, frameBody []byte, buf []byte) ([]byt
 	if frameCode < 15 {
 		*hdrBytePtr = *hdrBytePtr | (byte(frameCode&0xF) << 4)
 	} else {
-		if frameCode-15 >= 15 {
+		if frameCode-15 > 255 {
 			return nil, protocolError{"extframe code too large to encode"}
 		}
 
@@ -21,7 +21,7 @@ func AppendExtFrame(frameCode ExtFrameCode, frameBody []byte, buf []byte) ([]byt
 	if frameLen < 15 {
 		*hdrBytePtr = *hdrBytePtr | (byte(frameLen&0xF) << 0)
 	} else {
-		if frameLen-15 >= 15 {
+		if frameLen-15 > 255 {
 			return nil, protocolError{"extframe len too large to encode"}
 		}
 
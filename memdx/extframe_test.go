@@ -0,0 +1,56 @@
+package memdx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtFrameLongBody(t *testing.T) {
+	body := []byte(strings.Repeat("x", 100))
+
+	buf, err := AppendExtFrame(ExtFrameCodeReqOtelContext, body, nil)
+	require.NoError(t, err)
+
+	code, decodedBody, n, err := DecodeExtFrame(buf)
+	require.NoError(t, err)
+	assert.Equal(t, ExtFrameCodeReqOtelContext, code)
+	assert.Equal(t, body, decodedBody)
+	assert.Equal(t, len(buf), n)
+
+	_, err = AppendExtFrame(ExtFrameCodeReqOtelContext, make([]byte, 15+256), nil)
+	assert.ErrorIs(t, err, ErrProtocol)
+}
+
+func TestOpsCrudTraceContextFrame(t *testing.T) {
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	d := &testCaptureDispatcher{}
+	_, err := OpsCrud{
+		ExtFramesEnabled: true,
+		TraceContext:     traceParent,
+	}.Get(d, &GetRequest{
+		Key: []byte("key"),
+	}, func(resp *GetResponse, err error) {})
+	require.NoError(t, err)
+
+	assert.Equal(t, MagicReqExt, d.Packet.Magic)
+
+	var frameBody []byte
+	err = IterExtFrames(d.Packet.FramingExtras, func(code ExtFrameCode, body []byte) {
+		if code == ExtFrameCodeReqOtelContext {
+			frameBody = body
+		}
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte(traceParent), frameBody)
+
+	_, err = OpsCrud{
+		TraceContext: traceParent,
+	}.Get(d, &GetRequest{
+		Key: []byte("key"),
+	}, func(resp *GetResponse, err error) {})
+	assert.ErrorIs(t, err, ErrProtocol)
+}
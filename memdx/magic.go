@@ -16,6 +16,13 @@ const (
 
 	// MagicResExt indicates that the packet is a response with framing extras.
 	MagicResExt = Magic(0x18)
+
+	// MagicServerReq indicates that the packet is a request initiated by the server.
+	MagicServerReq = Magic(0x82)
+
+	// MagicServerRes indicates that the packet is a response to a request
+	// initiated by the server.
+	MagicServerRes = Magic(0x83)
 )
 
 func (m Magic) String() string {
@@ -28,6 +35,10 @@ func (m Magic) String() string {
 		return "ReqExt"
 	case MagicResExt:
 		return "ResExt"
+	case MagicServerReq:
+		return "ServerReq"
+	case MagicServerRes:
+		return "ServerRes"
 	}
 
 	return "x" + hex.EncodeToString([]byte{byte(m)})
@@ -44,3 +55,7 @@ func (m Magic) IsResponse() bool {
 func (m Magic) IsExtended() bool {
 	return m == MagicReqExt || m == MagicResExt
 }
+
+func (m Magic) IsServerInitiated() bool {
+	return m == MagicServerReq || m == MagicServerRes
+}
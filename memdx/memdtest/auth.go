@@ -0,0 +1,260 @@
+package memdtest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+var supportedAuthMechanisms = []memdx.AuthMechanism{
+	memdx.ScramSha512AuthMechanism,
+	memdx.ScramSha256AuthMechanism,
+	memdx.ScramSha1AuthMechanism,
+	memdx.PlainAuthMechanism,
+}
+
+const scramIterationCount = 4096
+
+func (c *serverConn) handleSASLListMechs(req *memdx.Packet) {
+	mechNames := make([]string, len(supportedAuthMechanisms))
+	for mechIdx, mech := range supportedAuthMechanisms {
+		mechNames[mechIdx] = string(mech)
+	}
+
+	c.sendResponse(req, &memdx.Packet{
+		Status: memdx.StatusSuccess,
+		Value:  []byte(strings.Join(mechNames, " ")),
+	})
+}
+
+func (c *serverConn) handleSASLAuth(req *memdx.Packet) {
+	// like memcached, authenticating again takes the connection out of its
+	// bucket, which then needs to be selected again.
+	c.lock.Lock()
+	c.bucket = nil
+	c.lock.Unlock()
+
+	c.authenticated = false
+	c.scram = nil
+
+	mech := memdx.AuthMechanism(req.Key)
+	switch mech {
+	case memdx.PlainAuthMechanism:
+		// PLAIN payloads are formatted as authzid\x00authcid\x00passwd
+		parts := bytes.Split(req.Value, []byte{0})
+		if len(parts) != 3 || !c.srv.checkPassword(string(parts[1]), string(parts[2])) {
+			c.sendStatus(req, memdx.StatusAuthError)
+			return
+		}
+
+		c.authenticated = true
+		c.sendStatus(req, memdx.StatusSuccess)
+	case memdx.ScramSha1AuthMechanism:
+		c.startScram(req, sha1.New)
+	case memdx.ScramSha256AuthMechanism:
+		c.startScram(req, sha256.New)
+	case memdx.ScramSha512AuthMechanism:
+		c.startScram(req, sha512.New)
+	default:
+		c.sendStatus(req, memdx.StatusAuthError)
+	}
+}
+
+func (c *serverConn) startScram(req *memdx.Packet, newHash func() hash.Hash) {
+	scram := &scramServer{
+		newHash: newHash,
+		users:   c.srv.users,
+	}
+
+	out, err := scram.Start(req.Value)
+	if err != nil {
+		c.logger.Debug("scram auth failed")
+		c.sendStatus(req, memdx.StatusAuthError)
+		return
+	}
+
+	c.scram = scram
+	c.sendResponse(req, &memdx.Packet{
+		Status: memdx.StatusAuthContinue,
+		Value:  out,
+	})
+}
+
+func (c *serverConn) handleSASLStep(req *memdx.Packet) {
+	scram := c.scram
+	c.scram = nil
+
+	if scram == nil {
+		c.sendStatus(req, memdx.StatusAuthError)
+		return
+	}
+
+	out, err := scram.Finish(req.Value)
+	if err != nil {
+		c.logger.Debug("scram auth failed")
+		c.sendStatus(req, memdx.StatusAuthError)
+		return
+	}
+
+	c.authenticated = true
+	c.sendResponse(req, &memdx.Packet{
+		Status: memdx.StatusSuccess,
+		Value:  out,
+	})
+}
+
+func (s *Server) checkPassword(username, password string) bool {
+	expectedPassword, ok := s.users[username]
+	if !ok {
+		return false
+	}
+
+	return hmac.Equal([]byte(expectedPassword), []byte(password))
+}
+
+var errScramAuthFailed = errors.New("scram authentication failed")
+
+// scramServer implements the server side of a SCRAM exchange per RFC5802.
+type scramServer struct {
+	newHash func() hash.Hash
+	users   map[string]string
+
+	nonce      []byte
+	saltedPass []byte
+	authMsg    bytes.Buffer
+}
+
+// Start processes the client-first message and returns the server-first
+// message.
+func (s *scramServer) Start(in []byte) ([]byte, error) {
+	// we do not support channel binding, so the gs2 header is always "n,,"
+	clientFirstBare, ok := cutPrefix(in, []byte("n,,"))
+	if !ok {
+		return nil, errScramAuthFailed
+	}
+
+	var username string
+	var clientNonce []byte
+	for _, field := range bytes.Split(clientFirstBare, []byte(",")) {
+		if value, ok := cutPrefix(field, []byte("n=")); ok {
+			username = strings.NewReplacer("=2C", ",", "=3D", "=").Replace(string(value))
+		} else if value, ok := cutPrefix(field, []byte("r=")); ok {
+			clientNonce = value
+		}
+	}
+
+	password, ok := s.users[username]
+	if !ok || len(clientNonce) == 0 {
+		return nil, errScramAuthFailed
+	}
+
+	serverNonce := make([]byte, 18)
+	salt := make([]byte, 16)
+	if _, err := rand.Read(serverNonce); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	s.nonce = append(append([]byte{}, clientNonce...), base64.StdEncoding.EncodeToString(serverNonce)...)
+	s.saltedPass = scramSaltPassword(s.newHash, []byte(password), salt, scramIterationCount)
+
+	out := []byte("r=" + string(s.nonce) +
+		",s=" + base64.StdEncoding.EncodeToString(salt) +
+		",i=" + strconv.Itoa(scramIterationCount))
+
+	s.authMsg.Write(clientFirstBare)
+	s.authMsg.WriteByte(',')
+	s.authMsg.Write(out)
+
+	return out, nil
+}
+
+// Finish verifies the client-final message and returns the server-final
+// message containing the server signature.
+func (s *scramServer) Finish(in []byte) ([]byte, error) {
+	proofIdx := bytes.LastIndex(in, []byte(",p="))
+	if proofIdx < 0 {
+		return nil, errScramAuthFailed
+	}
+
+	clientFinalNoProof := in[:proofIdx]
+	clientProof, err := base64.StdEncoding.DecodeString(string(in[proofIdx+3:]))
+	if err != nil {
+		return nil, errScramAuthFailed
+	}
+
+	var nonce []byte
+	for _, field := range bytes.Split(clientFinalNoProof, []byte(",")) {
+		if value, ok := cutPrefix(field, []byte("r=")); ok {
+			nonce = value
+		}
+	}
+	if !bytes.Equal(nonce, s.nonce) {
+		return nil, errScramAuthFailed
+	}
+
+	s.authMsg.WriteByte(',')
+	s.authMsg.Write(clientFinalNoProof)
+
+	clientKey := scramHmac(s.newHash, s.saltedPass, []byte("Client Key"))
+	storedKey := s.newHash()
+	storedKey.Write(clientKey)
+	clientSignature := scramHmac(s.newHash, storedKey.Sum(nil), s.authMsg.Bytes())
+
+	if len(clientProof) != len(clientSignature) {
+		return nil, errScramAuthFailed
+	}
+	for i := range clientProof {
+		clientProof[i] ^= clientSignature[i]
+	}
+	if !hmac.Equal(clientProof, clientKey) {
+		return nil, errScramAuthFailed
+	}
+
+	serverKey := scramHmac(s.newHash, s.saltedPass, []byte("Server Key"))
+	serverSignature := scramHmac(s.newHash, serverKey, s.authMsg.Bytes())
+
+	return []byte("v=" + base64.StdEncoding.EncodeToString(serverSignature)), nil
+}
+
+func scramHmac(newHash func() hash.Hash, key, data []byte) []byte {
+	mac := hmac.New(newHash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func scramSaltPassword(newHash func() hash.Hash, password, salt []byte, iterCount int) []byte {
+	mac := hmac.New(newHash, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	ui := mac.Sum(nil)
+	hi := append([]byte{}, ui...)
+	for i := 1; i < iterCount; i++ {
+		mac.Reset()
+		mac.Write(ui)
+		ui = mac.Sum(ui[:0])
+		for j, b := range ui {
+			hi[j] ^= b
+		}
+	}
+	return hi
+}
+
+func cutPrefix(s, prefix []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
@@ -0,0 +1,345 @@
+package memdtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocbcorex/cbmgmtx"
+)
+
+var (
+	ErrScopeExists        = errors.New("scope already exists")
+	ErrScopeNotFound      = errors.New("scope not found")
+	ErrCollectionExists   = errors.New("collection already exists")
+	ErrCollectionNotFound = errors.New("collection not found")
+)
+
+const (
+	defaultScopeName      = "_default"
+	defaultCollectionName = "_default"
+
+	// the first id the server assigns to user-created scopes and collections,
+	// ids below this are reserved.
+	firstUserCollectionID = 8
+)
+
+type BucketOptions struct {
+	Name string
+
+	// NumVbuckets is the number of vbuckets in the bucket, defaults to 1024.
+	NumVbuckets int
+}
+
+type collectionDef struct {
+	ID     uint32
+	Name   string
+	MaxTTL uint32
+}
+
+type scopeDef struct {
+	ID          uint32
+	Name        string
+	Collections []*collectionDef
+}
+
+type docKey struct {
+	CollectionID uint32
+	Key          string
+}
+
+type document struct {
+	Value    []byte
+	Xattrs   map[string]json.RawMessage
+	Flags    uint32
+	Datatype uint8
+	Cas      uint64
+	Expiry   uint32
+	SeqNo    uint64
+	Deleted  bool
+
+	LockedUntil time.Time
+	LockCas     uint64
+}
+
+func (d *document) isLocked(now time.Time) bool {
+	return now.Before(d.LockedUntil)
+}
+
+func (d *document) isExpired(now time.Time) bool {
+	return d.Expiry != 0 && now.Unix() >= int64(d.Expiry)
+}
+
+type vbucket struct {
+	VbUuid   uint64
+	MaxSeqNo uint64
+	Docs     map[docKey]*document
+}
+
+// Bucket is an in-memory bucket.  A single Bucket may be served by several
+// servers at once, which then share the same documents.
+type Bucket struct {
+	name string
+
+	lock        sync.Mutex
+	lastCas     uint64
+	vbuckets    []*vbucket
+	manifestUid uint64
+	nextID      uint32
+	scopes      []*scopeDef
+}
+
+func NewBucket(opts *BucketOptions) *Bucket {
+	numVbuckets := opts.NumVbuckets
+	if numVbuckets == 0 {
+		numVbuckets = 1024
+	}
+
+	vbuckets := make([]*vbucket, numVbuckets)
+	for vbID := range vbuckets {
+		vbuckets[vbID] = &vbucket{
+			VbUuid: rand.Uint64(),
+			Docs:   make(map[docKey]*document),
+		}
+	}
+
+	return &Bucket{
+		name:     opts.Name,
+		lastCas:  uint64(time.Now().UnixNano()),
+		vbuckets: vbuckets,
+		nextID:   firstUserCollectionID,
+		scopes: []*scopeDef{
+			{
+				ID:   0,
+				Name: defaultScopeName,
+				Collections: []*collectionDef{
+					{ID: 0, Name: defaultCollectionName},
+				},
+			},
+		},
+	}
+}
+
+// Name returns the name of the bucket.
+func (b *Bucket) Name() string {
+	return b.name
+}
+
+// NumVbuckets returns the number of vbuckets in the bucket.
+func (b *Bucket) NumVbuckets() int {
+	return len(b.vbuckets)
+}
+
+// ManifestUid returns the uid of the current collections manifest.
+func (b *Bucket) ManifestUid() uint64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.manifestUid
+}
+
+// CreateScope creates a new scope and returns its id.
+func (b *Bucket) CreateScope(scopeName string) (uint32, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.findScopeLocked(scopeName) != nil {
+		return 0, ErrScopeExists
+	}
+
+	scope := &scopeDef{
+		ID:   b.nextID,
+		Name: scopeName,
+	}
+	b.nextID++
+	b.scopes = append(b.scopes, scope)
+	b.manifestUid++
+
+	return scope.ID, nil
+}
+
+// DropScope removes a scope along with all of its collections.
+func (b *Bucket) DropScope(scopeName string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for scopeIdx, scope := range b.scopes {
+		if scope.Name == scopeName {
+			for _, collection := range scope.Collections {
+				b.dropCollectionDocsLocked(collection.ID)
+			}
+
+			b.scopes = append(b.scopes[:scopeIdx], b.scopes[scopeIdx+1:]...)
+			b.manifestUid++
+			return nil
+		}
+	}
+
+	return ErrScopeNotFound
+}
+
+// CreateCollection creates a new collection within a scope and returns its id.
+func (b *Bucket) CreateCollection(scopeName, collectionName string, maxTTL uint32) (uint32, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	scope := b.findScopeLocked(scopeName)
+	if scope == nil {
+		return 0, ErrScopeNotFound
+	}
+
+	for _, collection := range scope.Collections {
+		if collection.Name == collectionName {
+			return 0, ErrCollectionExists
+		}
+	}
+
+	collection := &collectionDef{
+		ID:     b.nextID,
+		Name:   collectionName,
+		MaxTTL: maxTTL,
+	}
+	b.nextID++
+	scope.Collections = append(scope.Collections, collection)
+	b.manifestUid++
+
+	return collection.ID, nil
+}
+
+// DropCollection removes a collection and all of its documents.
+func (b *Bucket) DropCollection(scopeName, collectionName string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	scope := b.findScopeLocked(scopeName)
+	if scope == nil {
+		return ErrScopeNotFound
+	}
+
+	for collectionIdx, collection := range scope.Collections {
+		if collection.Name == collectionName {
+			b.dropCollectionDocsLocked(collection.ID)
+
+			scope.Collections = append(scope.Collections[:collectionIdx], scope.Collections[collectionIdx+1:]...)
+			b.manifestUid++
+			return nil
+		}
+	}
+
+	return ErrCollectionNotFound
+}
+
+// Manifest returns the current collections manifest in the format used by
+// both the memcached and ns_server APIs.
+func (b *Bucket) Manifest() *cbmgmtx.CollectionManifestJson {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	manifest := &cbmgmtx.CollectionManifestJson{
+		UID: fmt.Sprintf("%x", b.manifestUid),
+	}
+	for _, scope := range b.scopes {
+		scopeJson := cbmgmtx.CollectionManifestScopeJson{
+			UID:  fmt.Sprintf("%x", scope.ID),
+			Name: scope.Name,
+		}
+		for _, collection := range scope.Collections {
+			scopeJson.Collections = append(scopeJson.Collections, cbmgmtx.CollectionManifestCollectionJson{
+				UID:    fmt.Sprintf("%x", collection.ID),
+				Name:   collection.Name,
+				MaxTTL: collection.MaxTTL,
+			})
+		}
+		manifest.Scopes = append(manifest.Scopes, scopeJson)
+	}
+
+	return manifest
+}
+
+// Flush removes every document from the bucket.
+func (b *Bucket) Flush() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, vb := range b.vbuckets {
+		vb.Docs = make(map[docKey]*document)
+	}
+}
+
+func (b *Bucket) findScopeLocked(scopeName string) *scopeDef {
+	for _, scope := range b.scopes {
+		if scope.Name == scopeName {
+			return scope
+		}
+	}
+	return nil
+}
+
+func (b *Bucket) lookupCollectionID(scopeName, collectionName string) (uint64, uint32, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	scope := b.findScopeLocked(scopeName)
+	if scope == nil {
+		return b.manifestUid, 0, ErrScopeNotFound
+	}
+
+	for _, collection := range scope.Collections {
+		if collection.Name == collectionName {
+			return b.manifestUid, collection.ID, nil
+		}
+	}
+
+	return b.manifestUid, 0, ErrCollectionNotFound
+}
+
+func (b *Bucket) hasCollectionLocked(collectionID uint32) bool {
+	for _, scope := range b.scopes {
+		for _, collection := range scope.Collections {
+			if collection.ID == collectionID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (b *Bucket) dropCollectionDocsLocked(collectionID uint32) {
+	for _, vb := range b.vbuckets {
+		for key := range vb.Docs {
+			if key.CollectionID == collectionID {
+				delete(vb.Docs, key)
+			}
+		}
+	}
+}
+
+func (b *Bucket) nextCasLocked() uint64 {
+	// cas values are derived from the clock like the real server, but are
+	// guaranteed to be unique and increasing.
+	cas := uint64(time.Now().UnixNano())
+	if cas <= b.lastCas {
+		cas = b.lastCas + 1
+	}
+	b.lastCas = cas
+	return cas
+}
+
+// storeLocked assigns a new cas and seqno to doc and stores it in vb.
+func (b *Bucket) storeLocked(vb *vbucket, key docKey, doc *document) {
+	b.storeWithCasLocked(vb, key, doc, b.nextCasLocked())
+}
+
+// storeWithCasLocked stores doc in vb using a cas which was previously
+// allocated with nextCasLocked.
+func (b *Bucket) storeWithCasLocked(vb *vbucket, key docKey, doc *document, cas uint64) {
+	vb.MaxSeqNo++
+	doc.SeqNo = vb.MaxSeqNo
+	doc.Cas = cas
+	doc.LockedUntil = time.Time{}
+	doc.LockCas = 0
+	vb.Docs[key] = doc
+}
@@ -0,0 +1,74 @@
+package memdtest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+func (c *serverConn) handleGetCollectionID(req *memdx.Packet) {
+	bucket := c.selectedBucket()
+	if bucket == nil {
+		c.sendStatus(req, memdx.StatusNoBucket)
+		return
+	}
+
+	// the path may be passed in either the key or the value
+	path := string(req.Value)
+	if path == "" {
+		path = string(req.Key)
+	}
+
+	scopeName, collectionName, ok := strings.Cut(path, ".")
+	if !ok {
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+	if scopeName == "" {
+		scopeName = defaultScopeName
+	}
+	if collectionName == "" {
+		collectionName = defaultCollectionName
+	}
+
+	manifestUid, collectionID, err := bucket.lookupCollectionID(scopeName, collectionName)
+	if errors.Is(err, ErrScopeNotFound) {
+		c.sendStatus(req, memdx.StatusScopeUnknown)
+		return
+	} else if errors.Is(err, ErrCollectionNotFound) {
+		c.sendStatus(req, memdx.StatusCollectionUnknown)
+		return
+	}
+
+	extras := make([]byte, 12)
+	binary.BigEndian.PutUint64(extras[0:], manifestUid)
+	binary.BigEndian.PutUint32(extras[8:], collectionID)
+
+	c.sendResponse(req, &memdx.Packet{
+		Status: memdx.StatusSuccess,
+		Extras: extras,
+	})
+}
+
+func (c *serverConn) handleGetCollectionsManifest(req *memdx.Packet) {
+	bucket := c.selectedBucket()
+	if bucket == nil {
+		c.sendStatus(req, memdx.StatusNoBucket)
+		return
+	}
+
+	manifestBytes, err := json.Marshal(bucket.Manifest())
+	if err != nil {
+		c.sendStatus(req, memdx.StatusInternalError)
+		return
+	}
+
+	c.sendResponse(req, &memdx.Packet{
+		Status:   memdx.StatusSuccess,
+		Datatype: c.responseDatatype(uint8(memdx.DatatypeFlagJSON)),
+		Value:    manifestBytes,
+	})
+}
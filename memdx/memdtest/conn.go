@@ -0,0 +1,365 @@
+package memdtest
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+type serverConn struct {
+	srv     *Server
+	logger  *zap.Logger
+	netConn net.Conn
+
+	writeLock sync.Mutex
+	writer    memdx.PacketWriter
+
+	closeOnce sync.Once
+
+	// the following are only modified by the read thread, but the selected
+	// bucket is also read by the server when a bucket is removed.
+	lock          sync.Mutex
+	bucket        *Bucket
+	features      map[memdx.HelloFeature]bool
+	authenticated bool
+	scram         *scramServer
+}
+
+func newServerConn(srv *Server, netConn net.Conn) *serverConn {
+	return &serverConn{
+		srv:      srv,
+		logger:   srv.logger.With(zap.String("remoteAddr", netConn.RemoteAddr().String())),
+		netConn:  netConn,
+		features: make(map[memdx.HelloFeature]bool),
+	}
+}
+
+func (c *serverConn) close() {
+	c.closeOnce.Do(func() {
+		_ = c.netConn.Close()
+	})
+}
+
+func (c *serverConn) selectedBucketName() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.bucket == nil {
+		return ""
+	}
+	return c.bucket.Name()
+}
+
+func (c *serverConn) selectedBucket() *Bucket {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.bucket
+}
+
+func (c *serverConn) hasFeature(feature memdx.HelloFeature) bool {
+	return c.features[feature]
+}
+
+func (c *serverConn) run() {
+	defer c.close()
+
+	var reader memdx.PacketReader
+	for {
+		pak := &memdx.Packet{}
+		err := reader.ReadPacket(c.netConn, pak)
+		if err != nil {
+			c.logger.Debug("connection read failed", zap.Error(err))
+			return
+		}
+
+		if !pak.Magic.IsRequest() {
+			c.logger.Debug("ignoring unexpected non-request packet",
+				zap.Stringer("magic", pak.Magic),
+				zap.Stringer("opcode", pak.OpCode))
+			continue
+		}
+
+		c.handlePacket(pak)
+	}
+}
+
+func (c *serverConn) writePacket(pak *memdx.Packet) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	err := c.writer.WritePacket(c.netConn, pak)
+	if err != nil {
+		c.logger.Debug("connection write failed", zap.Error(err))
+		c.close()
+	}
+}
+
+// sendResponse writes the response to a request.  The opcode and opaque are
+// always copied from the request.
+func (c *serverConn) sendResponse(req *memdx.Packet, resp *memdx.Packet) {
+	resp.Magic = memdx.MagicRes
+	resp.OpCode = req.OpCode
+	resp.Opaque = req.Opaque
+	c.writePacket(resp)
+}
+
+func (c *serverConn) sendStatus(req *memdx.Packet, status memdx.Status) {
+	c.sendResponse(req, &memdx.Packet{
+		Status: status,
+	})
+}
+
+// sendNotMyVbucket replies with NOT_MY_VBUCKET and the current config of the
+// bucket, which is what clients use to learn about topology changes.
+func (c *serverConn) sendNotMyVbucket(req *memdx.Packet, bucket *Bucket) {
+	c.sendResponse(req, &memdx.Packet{
+		Status:   memdx.StatusNotMyVBucket,
+		Datatype: uint8(memdx.DatatypeFlagJSON),
+		Value:    c.srv.getClusterConfig(bucket.Name()),
+	})
+}
+
+// lockedStatus returns the status the server uses to report that a document
+// is locked, which depends on whether extended errors were negotiated.
+func (c *serverConn) lockedStatus() memdx.Status {
+	if c.hasFeature(memdx.HelloFeatureXerror) {
+		return memdx.StatusLocked
+	}
+	return memdx.StatusTmpFail
+}
+
+// mutationExtras returns the extras carrying the mutation token for a
+// mutation response, which are only sent when seqnos were negotiated.
+func (c *serverConn) mutationExtras(vb *vbucket, seqNo uint64) []byte {
+	if !c.hasFeature(memdx.HelloFeatureSeqNo) {
+		return nil
+	}
+
+	extras := make([]byte, 16)
+	binary.BigEndian.PutUint64(extras[0:], vb.VbUuid)
+	binary.BigEndian.PutUint64(extras[8:], seqNo)
+	return extras
+}
+
+// responseDatatype strips any datatype flags from a value which the client
+// has not negotiated support for.
+func (c *serverConn) responseDatatype(datatype uint8) uint8 {
+	if !c.hasFeature(memdx.HelloFeatureDatatype) {
+		return 0
+	}
+	if !c.hasFeature(memdx.HelloFeatureJSON) {
+		datatype &^= uint8(memdx.DatatypeFlagJSON)
+	}
+	return datatype
+}
+
+// requestFrames contains the framing extras of a request which the server
+// takes notice of.
+type requestFrames struct {
+	PreserveExpiry bool
+}
+
+func (c *serverConn) decodeRequestFrames(req *memdx.Packet) (requestFrames, bool) {
+	var frames requestFrames
+	if req.Magic != memdx.MagicReqExt {
+		return frames, true
+	}
+
+	err := memdx.IterExtFrames(req.FramingExtras, func(code memdx.ExtFrameCode, body []byte) {
+		if code == memdx.ExtFrameCodeReqPreserveTTL {
+			frames.PreserveExpiry = true
+		}
+	})
+	if err != nil {
+		return frames, false
+	}
+
+	return frames, true
+}
+
+// keyedRequest is a decoded request which targets a specific document.
+type keyedRequest struct {
+	Bucket *Bucket
+	Vb     *vbucket
+	Key    docKey
+	Frames requestFrames
+}
+
+// decodeKeyedRequest performs the validation common to all document requests
+// and sends the appropriate error response if the request cannot be served.
+func (c *serverConn) decodeKeyedRequest(req *memdx.Packet) (*keyedRequest, bool) {
+	bucket := c.selectedBucket()
+	if bucket == nil {
+		c.sendStatus(req, memdx.StatusNoBucket)
+		return nil, false
+	}
+
+	frames, ok := c.decodeRequestFrames(req)
+	if !ok {
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return nil, false
+	}
+
+	var collectionID uint32
+	key := req.Key
+	if c.hasFeature(memdx.HelloFeatureCollections) {
+		var err error
+		collectionID, key, err = memdx.DecodeCollectionIDAndKey(req.Key)
+		if err != nil {
+			c.sendStatus(req, memdx.StatusInvalidArgs)
+			return nil, false
+		}
+	}
+
+	if int(req.VbucketID) >= bucket.NumVbuckets() ||
+		!c.srv.vbucketIsActive(bucket.Name(), req.VbucketID) {
+		c.sendNotMyVbucket(req, bucket)
+		return nil, false
+	}
+
+	bucket.lock.Lock()
+	hasCollection := bucket.hasCollectionLocked(collectionID)
+	bucket.lock.Unlock()
+	if !hasCollection {
+		c.sendStatus(req, memdx.StatusCollectionUnknown)
+		return nil, false
+	}
+
+	return &keyedRequest{
+		Bucket: bucket,
+		Vb:     bucket.vbuckets[req.VbucketID],
+		Key: docKey{
+			CollectionID: collectionID,
+			Key:          string(key),
+		},
+		Frames: frames,
+	}, true
+}
+
+func (c *serverConn) handlePacket(req *memdx.Packet) {
+	switch req.OpCode {
+	case memdx.OpCodeNoOp:
+		c.sendStatus(req, memdx.StatusSuccess)
+		return
+	case memdx.OpCodeHello:
+		c.handleHello(req)
+		return
+	case memdx.OpCodeGetErrorMap:
+		c.handleGetErrorMap(req)
+		return
+	case memdx.OpCodeSASLListMechs:
+		c.handleSASLListMechs(req)
+		return
+	case memdx.OpCodeSASLAuth:
+		c.handleSASLAuth(req)
+		return
+	case memdx.OpCodeSASLStep:
+		c.handleSASLStep(req)
+		return
+	}
+
+	if c.srv.users != nil && !c.authenticated {
+		c.sendStatus(req, memdx.StatusAccessError)
+		return
+	}
+
+	switch req.OpCode {
+	case memdx.OpCodeSelectBucket:
+		c.handleSelectBucket(req)
+	case memdx.OpCodeGetClusterConfig:
+		c.handleGetClusterConfig(req)
+	case memdx.OpCodeCollectionsGetID:
+		c.handleGetCollectionID(req)
+	case memdx.OpCodeCollectionsGetManifest:
+		c.handleGetCollectionsManifest(req)
+	case memdx.OpCodeGetAllVBSeqnos:
+		c.handleGetAllVbSeqnos(req)
+	case memdx.OpCodeObserveSeqNo:
+		c.handleObserveSeqNo(req)
+	case memdx.OpCodeGet:
+		c.handleGet(req)
+	case memdx.OpCodeGetReplica:
+		c.handleGetReplica(req)
+	case memdx.OpCodeGAT:
+		c.handleGetAndTouch(req)
+	case memdx.OpCodeTouch:
+		c.handleTouch(req)
+	case memdx.OpCodeGetLocked:
+		c.handleGetLocked(req)
+	case memdx.OpCodeUnlockKey:
+		c.handleUnlock(req)
+	case memdx.OpCodeGetMeta:
+		c.handleGetMeta(req)
+	case memdx.OpCodeSet, memdx.OpCodeAdd, memdx.OpCodeReplace:
+		c.handleStore(req)
+	case memdx.OpCodeDelete:
+		c.handleDelete(req)
+	case memdx.OpCodeAppend, memdx.OpCodePrepend:
+		c.handleConcat(req)
+	case memdx.OpCodeIncrement, memdx.OpCodeDecrement:
+		c.handleCounter(req)
+	case memdx.OpCodeSubDocMultiLookup:
+		c.handleLookupIn(req)
+	case memdx.OpCodeSubDocMultiMutation:
+		c.handleMutateIn(req)
+	default:
+		c.sendStatus(req, memdx.StatusUnknownCommand)
+	}
+}
+
+func (c *serverConn) handleHello(req *memdx.Packet) {
+	if len(req.Value)%2 != 0 {
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+
+	supported := make(map[memdx.HelloFeature]bool, len(c.srv.features))
+	for _, feature := range c.srv.features {
+		supported[feature] = true
+	}
+
+	features := make(map[memdx.HelloFeature]bool)
+	var respValue []byte
+	for i := 0; i < len(req.Value); i += 2 {
+		feature := memdx.HelloFeature(binary.BigEndian.Uint16(req.Value[i:]))
+		if !supported[feature] || features[feature] {
+			continue
+		}
+
+		features[feature] = true
+		respValue = append(respValue, byte(feature>>8), byte(feature))
+	}
+	c.features = features
+
+	c.sendResponse(req, &memdx.Packet{
+		Status: memdx.StatusSuccess,
+		Value:  respValue,
+	})
+}
+
+func (c *serverConn) handleSelectBucket(req *memdx.Packet) {
+	bucket := c.srv.Bucket(string(req.Key))
+	if bucket == nil {
+		c.sendStatus(req, memdx.StatusAccessError)
+		return
+	}
+
+	c.lock.Lock()
+	c.bucket = bucket
+	c.lock.Unlock()
+
+	c.sendStatus(req, memdx.StatusSuccess)
+}
+
+func (c *serverConn) handleGetClusterConfig(req *memdx.Packet) {
+	c.sendResponse(req, &memdx.Packet{
+		Status:   memdx.StatusSuccess,
+		Datatype: c.responseDatatype(uint8(memdx.DatatypeFlagJSON)),
+		Value:    c.srv.getClusterConfig(c.selectedBucketName()),
+	})
+}
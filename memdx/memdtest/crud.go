@@ -0,0 +1,524 @@
+package memdtest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+const (
+	// expiry values up to this many seconds are relative to the current time,
+	// anything larger is an absolute unix timestamp.
+	maxRelativeExpiry = 30 * 24 * 60 * 60
+
+	defaultLockTime = 15 * time.Second
+	maxLockTime     = 30 * time.Second
+
+	// lockedCas is the cas reported for a document which is locked by someone
+	// other than the requester.
+	lockedCas = ^uint64(0)
+)
+
+func absoluteExpiry(expiry uint32, now time.Time) uint32 {
+	if expiry == 0 || expiry > maxRelativeExpiry {
+		return expiry
+	}
+	return uint32(now.Unix()) + expiry
+}
+
+// storedDatatype returns the datatype to store a value with.  Like the real
+// server, JSON values are detected even when the client did not flag them.
+func storedDatatype(datatype uint8, value []byte) uint8 {
+	if json.Valid(value) {
+		return datatype | uint8(memdx.DatatypeFlagJSON)
+	}
+	return datatype &^ uint8(memdx.DatatypeFlagJSON)
+}
+
+// liveDocLocked returns the document stored under key if it exists and has
+// neither been deleted nor expired.
+func (vb *vbucket) liveDocLocked(key docKey, now time.Time) *document {
+	doc := vb.Docs[key]
+	if doc == nil || doc.Deleted || doc.isExpired(now) {
+		return nil
+	}
+	return doc
+}
+
+// checkCasLocked validates that a request with the given cas may modify doc.
+// A locked document can only be modified using the cas returned by the lock.
+func (c *serverConn) checkCasLocked(doc *document, cas uint64, now time.Time) (memdx.Status, bool) {
+	if doc.isLocked(now) {
+		if cas != doc.LockCas {
+			return c.lockedStatus(), false
+		}
+		return memdx.StatusSuccess, true
+	}
+
+	if cas != 0 && cas != doc.Cas {
+		return memdx.StatusKeyExists, false
+	}
+
+	return memdx.StatusSuccess, true
+}
+
+func (c *serverConn) sendGetResponse(req *memdx.Packet, doc *document, cas uint64) {
+	extras := make([]byte, 4)
+	binary.BigEndian.PutUint32(extras, doc.Flags)
+
+	c.sendResponse(req, &memdx.Packet{
+		Status:   memdx.StatusSuccess,
+		Datatype: c.responseDatatype(doc.Datatype),
+		Cas:      cas,
+		Extras:   extras,
+		Value:    doc.Value,
+	})
+}
+
+func (c *serverConn) sendMutationResponse(req *memdx.Packet, kreq *keyedRequest, doc *document, value []byte) {
+	c.sendResponse(req, &memdx.Packet{
+		Status: memdx.StatusSuccess,
+		Cas:    doc.Cas,
+		Extras: c.mutationExtras(kreq.Vb, doc.SeqNo),
+		Value:  value,
+	})
+}
+
+func (c *serverConn) handleGet(req *memdx.Packet) {
+	kreq, ok := c.decodeKeyedRequest(req)
+	if !ok {
+		return
+	}
+
+	bucket := kreq.Bucket
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	now := time.Now()
+	doc := kreq.Vb.liveDocLocked(kreq.Key, now)
+	if doc == nil {
+		c.sendStatus(req, memdx.StatusKeyNotFound)
+		return
+	}
+
+	cas := doc.Cas
+	if doc.isLocked(now) {
+		cas = lockedCas
+	}
+
+	c.sendGetResponse(req, doc, cas)
+}
+
+func (c *serverConn) handleGetReplica(req *memdx.Packet) {
+	bucket := c.selectedBucket()
+	if bucket == nil {
+		c.sendStatus(req, memdx.StatusNoBucket)
+		return
+	}
+
+	// buckets served by this server never have replicas.
+	c.sendNotMyVbucket(req, bucket)
+}
+
+func (c *serverConn) handleGetAndTouch(req *memdx.Packet) {
+	kreq, ok := c.decodeKeyedRequest(req)
+	if !ok {
+		return
+	}
+
+	if len(req.Extras) != 4 {
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+
+	bucket := kreq.Bucket
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	now := time.Now()
+	doc := kreq.Vb.liveDocLocked(kreq.Key, now)
+	if doc == nil {
+		c.sendStatus(req, memdx.StatusKeyNotFound)
+		return
+	}
+	if doc.isLocked(now) {
+		c.sendStatus(req, c.lockedStatus())
+		return
+	}
+
+	newDoc := *doc
+	newDoc.Expiry = absoluteExpiry(binary.BigEndian.Uint32(req.Extras), now)
+	bucket.storeLocked(kreq.Vb, kreq.Key, &newDoc)
+
+	c.sendGetResponse(req, &newDoc, newDoc.Cas)
+}
+
+func (c *serverConn) handleTouch(req *memdx.Packet) {
+	kreq, ok := c.decodeKeyedRequest(req)
+	if !ok {
+		return
+	}
+
+	if len(req.Extras) != 4 {
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+
+	bucket := kreq.Bucket
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	now := time.Now()
+	doc := kreq.Vb.liveDocLocked(kreq.Key, now)
+	if doc == nil {
+		c.sendStatus(req, memdx.StatusKeyNotFound)
+		return
+	}
+	if doc.isLocked(now) {
+		c.sendStatus(req, c.lockedStatus())
+		return
+	}
+
+	newDoc := *doc
+	newDoc.Expiry = absoluteExpiry(binary.BigEndian.Uint32(req.Extras), now)
+	bucket.storeLocked(kreq.Vb, kreq.Key, &newDoc)
+
+	c.sendResponse(req, &memdx.Packet{
+		Status: memdx.StatusSuccess,
+		Cas:    newDoc.Cas,
+	})
+}
+
+func (c *serverConn) handleGetLocked(req *memdx.Packet) {
+	kreq, ok := c.decodeKeyedRequest(req)
+	if !ok {
+		return
+	}
+
+	lockTime := defaultLockTime
+	if len(req.Extras) == 4 {
+		lockSecs := binary.BigEndian.Uint32(req.Extras)
+		if lockSecs > 0 {
+			lockTime = time.Duration(lockSecs) * time.Second
+		}
+	} else if len(req.Extras) != 0 {
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+	if lockTime > maxLockTime {
+		lockTime = defaultLockTime
+	}
+
+	bucket := kreq.Bucket
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	now := time.Now()
+	doc := kreq.Vb.liveDocLocked(kreq.Key, now)
+	if doc == nil {
+		c.sendStatus(req, memdx.StatusKeyNotFound)
+		return
+	}
+	if doc.isLocked(now) {
+		c.sendStatus(req, c.lockedStatus())
+		return
+	}
+
+	doc.LockedUntil = now.Add(lockTime)
+	doc.LockCas = bucket.nextCasLocked()
+
+	c.sendGetResponse(req, doc, doc.LockCas)
+}
+
+func (c *serverConn) handleUnlock(req *memdx.Packet) {
+	kreq, ok := c.decodeKeyedRequest(req)
+	if !ok {
+		return
+	}
+
+	bucket := kreq.Bucket
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	now := time.Now()
+	doc := kreq.Vb.liveDocLocked(kreq.Key, now)
+	if doc == nil {
+		c.sendStatus(req, memdx.StatusKeyNotFound)
+		return
+	}
+	if !doc.isLocked(now) {
+		c.sendStatus(req, memdx.StatusTmpFail)
+		return
+	}
+	if req.Cas != doc.LockCas {
+		c.sendStatus(req, c.lockedStatus())
+		return
+	}
+
+	doc.LockedUntil = time.Time{}
+	doc.LockCas = 0
+
+	c.sendResponse(req, &memdx.Packet{
+		Status: memdx.StatusSuccess,
+		Cas:    doc.Cas,
+	})
+}
+
+func (c *serverConn) handleGetMeta(req *memdx.Packet) {
+	kreq, ok := c.decodeKeyedRequest(req)
+	if !ok {
+		return
+	}
+
+	bucket := kreq.Bucket
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	// unlike other reads, GetMeta also returns the metadata of tombstones.
+	doc := kreq.Vb.Docs[kreq.Key]
+	if doc == nil || (!doc.Deleted && doc.isExpired(time.Now())) {
+		c.sendStatus(req, memdx.StatusKeyNotFound)
+		return
+	}
+
+	extras := make([]byte, 20, 21)
+	if doc.Deleted {
+		binary.BigEndian.PutUint32(extras[0:], 1)
+	}
+	binary.BigEndian.PutUint32(extras[4:], doc.Flags)
+	binary.BigEndian.PutUint32(extras[8:], doc.Expiry)
+	binary.BigEndian.PutUint64(extras[12:], doc.SeqNo)
+	if len(req.Extras) == 1 && req.Extras[0] == 2 {
+		extras = append(extras, c.responseDatatype(doc.Datatype))
+	}
+
+	c.sendResponse(req, &memdx.Packet{
+		Status: memdx.StatusSuccess,
+		Cas:    doc.Cas,
+		Extras: extras,
+	})
+}
+
+func (c *serverConn) handleStore(req *memdx.Packet) {
+	kreq, ok := c.decodeKeyedRequest(req)
+	if !ok {
+		return
+	}
+
+	if len(req.Extras) != 8 {
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+	if req.Datatype&^uint8(memdx.DatatypeFlagJSON) != 0 {
+		// compressed values and values with xattrs are not supported
+		c.sendStatus(req, memdx.StatusNotSupported)
+		return
+	}
+
+	flags := binary.BigEndian.Uint32(req.Extras[0:])
+	expiry := binary.BigEndian.Uint32(req.Extras[4:])
+
+	bucket := kreq.Bucket
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	now := time.Now()
+	doc := kreq.Vb.liveDocLocked(kreq.Key, now)
+
+	switch req.OpCode {
+	case memdx.OpCodeAdd:
+		if doc != nil {
+			c.sendStatus(req, memdx.StatusKeyExists)
+			return
+		}
+	case memdx.OpCodeReplace:
+		if doc == nil {
+			c.sendStatus(req, memdx.StatusKeyNotFound)
+			return
+		}
+	case memdx.OpCodeSet:
+		if doc == nil && req.Cas != 0 {
+			c.sendStatus(req, memdx.StatusKeyNotFound)
+			return
+		}
+	}
+
+	newDoc := &document{
+		Value:    req.Value,
+		Flags:    flags,
+		Datatype: storedDatatype(req.Datatype, req.Value),
+		Expiry:   absoluteExpiry(expiry, now),
+	}
+
+	if doc != nil {
+		status, ok := c.checkCasLocked(doc, req.Cas, now)
+		if !ok {
+			c.sendStatus(req, status)
+			return
+		}
+
+		if kreq.Frames.PreserveExpiry {
+			newDoc.Expiry = doc.Expiry
+		}
+	}
+
+	bucket.storeLocked(kreq.Vb, kreq.Key, newDoc)
+
+	c.sendMutationResponse(req, kreq, newDoc, nil)
+}
+
+func (c *serverConn) handleDelete(req *memdx.Packet) {
+	kreq, ok := c.decodeKeyedRequest(req)
+	if !ok {
+		return
+	}
+
+	bucket := kreq.Bucket
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	now := time.Now()
+	doc := kreq.Vb.liveDocLocked(kreq.Key, now)
+	if doc == nil {
+		c.sendStatus(req, memdx.StatusKeyNotFound)
+		return
+	}
+
+	status, ok := c.checkCasLocked(doc, req.Cas, now)
+	if !ok {
+		c.sendStatus(req, status)
+		return
+	}
+
+	tombstone := newTombstone(doc)
+	bucket.storeLocked(kreq.Vb, kreq.Key, tombstone)
+
+	c.sendMutationResponse(req, kreq, tombstone, nil)
+}
+
+// newTombstone creates the tombstone left behind when doc is deleted.  Only
+// system xattrs survive deletion.
+func newTombstone(doc *document) *document {
+	tombstone := &document{
+		Deleted: true,
+	}
+
+	for key, value := range doc.Xattrs {
+		if len(key) > 0 && key[0] == '_' {
+			if tombstone.Xattrs == nil {
+				tombstone.Xattrs = make(map[string]json.RawMessage)
+			}
+			tombstone.Xattrs[key] = value
+		}
+	}
+
+	return tombstone
+}
+
+func (c *serverConn) handleConcat(req *memdx.Packet) {
+	kreq, ok := c.decodeKeyedRequest(req)
+	if !ok {
+		return
+	}
+
+	bucket := kreq.Bucket
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	now := time.Now()
+	doc := kreq.Vb.liveDocLocked(kreq.Key, now)
+	if doc == nil {
+		c.sendStatus(req, memdx.StatusNotStored)
+		return
+	}
+
+	status, ok := c.checkCasLocked(doc, req.Cas, now)
+	if !ok {
+		c.sendStatus(req, status)
+		return
+	}
+
+	var value []byte
+	if req.OpCode == memdx.OpCodeAppend {
+		value = append(append(value, doc.Value...), req.Value...)
+	} else {
+		value = append(append(value, req.Value...), doc.Value...)
+	}
+
+	newDoc := *doc
+	newDoc.Value = value
+	newDoc.Datatype = storedDatatype(doc.Datatype, value)
+	bucket.storeLocked(kreq.Vb, kreq.Key, &newDoc)
+
+	c.sendMutationResponse(req, kreq, &newDoc, nil)
+}
+
+func (c *serverConn) handleCounter(req *memdx.Packet) {
+	kreq, ok := c.decodeKeyedRequest(req)
+	if !ok {
+		return
+	}
+
+	if len(req.Extras) != 20 {
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+
+	delta := binary.BigEndian.Uint64(req.Extras[0:])
+	initial := binary.BigEndian.Uint64(req.Extras[8:])
+	expiry := binary.BigEndian.Uint32(req.Extras[16:])
+
+	bucket := kreq.Bucket
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	now := time.Now()
+	doc := kreq.Vb.liveDocLocked(kreq.Key, now)
+
+	var newDoc document
+	var newValue uint64
+	if doc == nil {
+		// an expiry of all ones indicates that the document must not be created
+		if expiry == 0xFFFFFFFF {
+			c.sendStatus(req, memdx.StatusKeyNotFound)
+			return
+		}
+
+		newValue = initial
+		newDoc.Expiry = absoluteExpiry(expiry, now)
+	} else {
+		status, ok := c.checkCasLocked(doc, req.Cas, now)
+		if !ok {
+			c.sendStatus(req, status)
+			return
+		}
+
+		currentValue, err := strconv.ParseUint(string(doc.Value), 10, 64)
+		if err != nil {
+			c.sendStatus(req, memdx.StatusBadDelta)
+			return
+		}
+
+		if req.OpCode == memdx.OpCodeIncrement {
+			newValue = currentValue + delta
+		} else if delta > currentValue {
+			newValue = 0
+		} else {
+			newValue = currentValue - delta
+		}
+
+		newDoc = *doc
+	}
+
+	newDoc.Value = []byte(strconv.FormatUint(newValue, 10))
+	newDoc.Datatype = uint8(memdx.DatatypeFlagJSON)
+	bucket.storeLocked(kreq.Vb, kreq.Key, &newDoc)
+
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, newValue)
+
+	c.sendMutationResponse(req, kreq, &newDoc, value)
+}
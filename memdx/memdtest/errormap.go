@@ -0,0 +1,107 @@
+package memdtest
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+type errorMapRetryJson struct {
+	Strategy    string `json:"strategy"`
+	Interval    int    `json:"interval"`
+	After       int    `json:"after"`
+	Ceil        int    `json:"ceil"`
+	MaxDuration int    `json:"max-duration"`
+}
+
+type errorMapEntryJson struct {
+	Name        string             `json:"name"`
+	Description string             `json:"desc"`
+	Attributes  []string           `json:"attrs"`
+	Retry       *errorMapRetryJson `json:"retry,omitempty"`
+}
+
+type errorMapJson struct {
+	Version  int                          `json:"version"`
+	Revision int                          `json:"revision"`
+	Errors   map[string]errorMapEntryJson `json:"errors"`
+}
+
+// errorMapEntries describes the statuses this server can return, using the
+// same names and attributes as the real server.
+var errorMapEntries = map[memdx.Status]errorMapEntryJson{
+	memdx.StatusSuccess:           {"SUCCESS", "Success", []string{"success"}, nil},
+	memdx.StatusKeyNotFound:       {"KEY_ENOENT", "Not Found", []string{"item-only"}, nil},
+	memdx.StatusKeyExists:         {"KEY_EEXISTS", "key already exists, or CAS mismatch", []string{"item-only"}, nil},
+	memdx.StatusTooBig:            {"E2BIG", "Value is too big", []string{"item-only", "invalid-input"}, nil},
+	memdx.StatusInvalidArgs:       {"EINVAL", "Invalid packet", []string{"internal", "invalid-input"}, nil},
+	memdx.StatusNotStored:         {"NOT_STORED", "Not Stored", []string{"item-only"}, nil},
+	memdx.StatusBadDelta:          {"DELTA_BADVAL", "Existing document not a number", []string{"item-only", "invalid-input"}, nil},
+	memdx.StatusNotMyVBucket:      {"NOT_MY_VBUCKET", "Server is not responsible for this command", []string{"fetch-config", "invalid-input"}, nil},
+	memdx.StatusNoBucket:          {"NO_BUCKET", "Not connected to a bucket", []string{"conn-state-invalidated"}, nil},
+	memdx.StatusLocked:            {"LOCKED", "Requested resource is locked", []string{"item-locked", "item-only", "retry-now"}, &errorMapRetryJson{"constant", 10, 0, 0, 0}},
+	memdx.StatusAuthError:         {"AUTH_ERROR", "Authentication failed", []string{"conn-state-invalidated", "auth"}, nil},
+	memdx.StatusAuthContinue:      {"AUTH_CONTINUE", "Authentication continue", []string{"conn-state-invalidated", "auth", "special-handling"}, nil},
+	memdx.StatusAccessError:       {"EACCESS", "No access", []string{"conn-state-invalidated", "support"}, nil},
+	memdx.StatusUnknownCommand:    {"UNKNOWN_COMMAND", "Unknown command", []string{"support"}, nil},
+	memdx.StatusNotSupported:      {"NOT_SUPPORTED", "Operation not supported", []string{"support"}, nil},
+	memdx.StatusInternalError:     {"EINTERNAL", "Internal error", []string{"internal"}, nil},
+	memdx.StatusBusy:              {"EBUSY", "Server is too busy", []string{"temp", "retry-later"}, &errorMapRetryJson{"exponential", 10, 100, 1000, 30000}},
+	memdx.StatusTmpFail:           {"ETMPFAIL", "Temporary failure", []string{"temp", "retry-now"}, &errorMapRetryJson{"exponential", 1, 0, 500, 30000}},
+	memdx.StatusCollectionUnknown: {"UNKNOWN_COLLECTION", "Unknown Collection", []string{"fetch-config", "special-handling"}, nil},
+	memdx.StatusScopeUnknown:      {"UNKNOWN_SCOPE", "Unknown Scope", []string{"fetch-config", "special-handling"}, nil},
+
+	memdx.StatusSubDocPathNotFound:     {"SUBDOC_PATH_ENOENT", "Subdoc: Path not does not exist", []string{"item-only", "subdoc"}, nil},
+	memdx.StatusSubDocPathMismatch:     {"SUBDOC_PATH_MISMATCH", "Subdoc: Path mismatch", []string{"item-only", "subdoc"}, nil},
+	memdx.StatusSubDocPathInvalid:      {"SUBDOC_PATH_EINVAL", "Subdoc: Invalid path", []string{"item-only", "subdoc", "invalid-input"}, nil},
+	memdx.StatusSubDocNotJSON:          {"SUBDOC_DOC_NOT_JSON", "Subdoc: Document is not JSON", []string{"item-only", "subdoc"}, nil},
+	memdx.StatusSubDocCantInsert:       {"SUBDOC_VALUE_CANTINSERT", "Subdoc: Cannot insert specified value", []string{"item-only", "subdoc", "invalid-input"}, nil},
+	memdx.StatusSubDocBadDelta:         {"SUBDOC_DELTA_EINVAL", "Subdoc: Invalid delta", []string{"item-only", "subdoc", "invalid-input"}, nil},
+	memdx.StatusSubDocPathExists:       {"SUBDOC_PATH_EEXISTS", "Subdoc: Path already exists", []string{"item-only", "subdoc"}, nil},
+	memdx.StatusSubDocMultiPathFailure: {"SUBDOC_MULTI_PATH_FAILURE", "Subdoc: One or more paths in a multi-path command failed", []string{"item-only", "subdoc"}, nil},
+	memdx.StatusSubDocSuccessDeleted:   {"SUBDOC_SUCCESS_DELETED", "Subdoc: Operation completed successfully on a deleted document", []string{"success", "subdoc", "item-deleted"}, nil},
+
+	memdx.StatusSubDocXattrUnknownVAttr:       {"SUBDOC_XATTR_UNKNOWN_VATTR", "Subdoc: The provided virtual attribute is not a known virtual attribute", []string{"item-only", "invalid-input", "subdoc"}, nil},
+	memdx.StatusSubDocXattrCannotModifyVAttr:  {"SUBDOC_XATTR_CANT_MODIFY_VATTR", "Subdoc: The provided virtual attribute is read only", []string{"item-only", "invalid-input", "subdoc"}, nil},
+	memdx.StatusSubDocMultiPathFailureDeleted: {"SUBDOC_MULTI_PATH_FAILURE_DELETED", "Subdoc: One or more paths in a multi-path command failed on a deleted document", []string{"item-only", "subdoc", "item-deleted"}, nil},
+}
+
+func buildErrorMap(version uint16) []byte {
+	errMap := errorMapJson{
+		Version:  int(version),
+		Revision: 1,
+		Errors:   make(map[string]errorMapEntryJson, len(errorMapEntries)),
+	}
+	for status, entry := range errorMapEntries {
+		if version < 2 {
+			entry.Retry = nil
+		}
+		errMap.Errors[fmt.Sprintf("%x", uint16(status))] = entry
+	}
+
+	errMapBytes, _ := json.Marshal(errMap)
+	return errMapBytes
+}
+
+func (c *serverConn) handleGetErrorMap(req *memdx.Packet) {
+	if len(req.Value) != 2 {
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+
+	version := uint16(req.Value[0])<<8 | uint16(req.Value[1])
+	if version == 0 {
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+	if version > 2 {
+		version = 2
+	}
+
+	c.sendResponse(req, &memdx.Packet{
+		Status:   memdx.StatusSuccess,
+		Datatype: c.responseDatatype(uint8(memdx.DatatypeFlagJSON)),
+		Value:    buildErrorMap(version),
+	})
+}
@@ -0,0 +1,117 @@
+package memdtest
+
+import (
+	"encoding/binary"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+func (c *serverConn) handleGetAllVbSeqnos(req *memdx.Packet) {
+	bucket := c.selectedBucket()
+	if bucket == nil {
+		c.sendStatus(req, memdx.StatusNoBucket)
+		return
+	}
+
+	vbState := memdx.VbucketStateAny
+	var collectionID *uint32
+	switch len(req.Extras) {
+	case 0:
+	case 8:
+		cid := binary.BigEndian.Uint32(req.Extras[4:])
+		collectionID = &cid
+		fallthrough
+	case 4:
+		vbState = memdx.VbucketState(binary.BigEndian.Uint32(req.Extras[0:]))
+	default:
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	if collectionID != nil && !bucket.hasCollectionLocked(*collectionID) {
+		c.sendStatus(req, memdx.StatusCollectionUnknown)
+		return
+	}
+
+	var value []byte
+	// this server only ever hosts active vbuckets
+	if vbState == memdx.VbucketStateAny || vbState == memdx.VbucketStateActive {
+		for vbID, vb := range bucket.vbuckets {
+			if !c.srv.vbucketIsActive(bucket.Name(), uint16(vbID)) {
+				continue
+			}
+
+			seqNo := vb.MaxSeqNo
+			if collectionID != nil {
+				seqNo = 0
+				for key, doc := range vb.Docs {
+					if key.CollectionID == *collectionID && doc.SeqNo > seqNo {
+						seqNo = doc.SeqNo
+					}
+				}
+			}
+
+			entry := make([]byte, 10)
+			binary.BigEndian.PutUint16(entry[0:], uint16(vbID))
+			binary.BigEndian.PutUint64(entry[2:], seqNo)
+			value = append(value, entry...)
+		}
+	}
+
+	c.sendResponse(req, &memdx.Packet{
+		Status: memdx.StatusSuccess,
+		Value:  value,
+	})
+}
+
+func (c *serverConn) handleObserveSeqNo(req *memdx.Packet) {
+	bucket := c.selectedBucket()
+	if bucket == nil {
+		c.sendStatus(req, memdx.StatusNoBucket)
+		return
+	}
+
+	if len(req.Value) != 8 {
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+
+	if int(req.VbucketID) >= bucket.NumVbuckets() ||
+		!c.srv.vbucketIsActive(bucket.Name(), req.VbucketID) {
+		c.sendNotMyVbucket(req, bucket)
+		return
+	}
+
+	vbUuid := binary.BigEndian.Uint64(req.Value)
+
+	bucket.lock.Lock()
+	vb := bucket.vbuckets[req.VbucketID]
+	currentVbUuid := vb.VbUuid
+	seqNo := vb.MaxSeqNo
+	bucket.lock.Unlock()
+
+	// everything is persisted as soon as it is written.  a vbuuid which does
+	// not match is reported as a failover with no data lost.
+	var value []byte
+	if vbUuid == currentVbUuid {
+		value = make([]byte, 27)
+		value[0] = 0
+	} else {
+		value = make([]byte, 43)
+		value[0] = 1
+		binary.BigEndian.PutUint64(value[27:], vbUuid)
+		binary.BigEndian.PutUint64(value[35:], seqNo)
+	}
+	binary.BigEndian.PutUint16(value[1:], req.VbucketID)
+	binary.BigEndian.PutUint64(value[3:], currentVbUuid)
+	binary.BigEndian.PutUint64(value[11:], seqNo)
+	binary.BigEndian.PutUint64(value[19:], seqNo)
+
+	c.sendResponse(req, &memdx.Packet{
+		Status: memdx.StatusSuccess,
+		Value:  value,
+	})
+}
@@ -0,0 +1,288 @@
+// Package memdtest implements an in-process fake memcached server which speaks
+// enough of the KV protocol to run memdx and gocbcorex against it without a
+// real cluster.
+package memdtest
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/contrib/cbconfig"
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+// DefaultFeatures is the set of HELLO features the server supports when
+// ServerOptions.Features is not specified.
+var DefaultFeatures = []memdx.HelloFeature{
+	memdx.HelloFeatureDatatype,
+	memdx.HelloFeatureSeqNo,
+	memdx.HelloFeatureXattr,
+	memdx.HelloFeatureXerror,
+	memdx.HelloFeatureJSON,
+	memdx.HelloFeatureUnorderedExec,
+	memdx.HelloFeaturePreserveExpiry,
+	memdx.HelloFeatureSyncReplication,
+	memdx.HelloFeatureSelectBucket,
+	memdx.HelloFeatureAltRequests,
+	memdx.HelloFeatureCollections,
+}
+
+type ServerOptions struct {
+	Logger *zap.Logger
+
+	// ListenAddress is the address to listen on, defaults to an ephemeral
+	// port on the loopback interface.
+	ListenAddress string
+
+	// Users maps the usernames which may authenticate to their passwords.
+	Users map[string]string
+
+	Buckets []*Bucket
+
+	// Features is the set of HELLO features the server supports, defaults
+	// to DefaultFeatures.
+	Features []memdx.HelloFeature
+
+	// ClusterConfig returns the cluster config to serve for a bucket, or the
+	// cluster-level config when bucketName is empty.  By default a config
+	// which places every vbucket on this server is generated.
+	ClusterConfig func(s *Server, bucketName string) []byte
+
+	// IsVbucketActive reports whether this server is the active node for a
+	// vbucket.  Key-based operations against any other vbucket fail with
+	// NOT_MY_VBUCKET and the current cluster config.  By default the server
+	// is active for every vbucket.
+	IsVbucketActive func(s *Server, bucketName string, vbID uint16) bool
+}
+
+// Server is a fake memcached server listening on a local socket.
+type Server struct {
+	logger          *zap.Logger
+	listener        net.Listener
+	users           map[string]string
+	features        []memdx.HelloFeature
+	clusterConfig   func(s *Server, bucketName string) []byte
+	isVbucketActive func(s *Server, bucketName string, vbID uint16) bool
+
+	lock    sync.Mutex
+	buckets map[string]*Bucket
+	conns   map[*serverConn]struct{}
+	closed  bool
+
+	wg sync.WaitGroup
+}
+
+func NewServer(opts *ServerOptions) (*Server, error) {
+	if opts == nil {
+		opts = &ServerOptions{}
+	}
+
+	listenAddress := opts.ListenAddress
+	if listenAddress == "" {
+		listenAddress = "127.0.0.1:0"
+	}
+
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	features := opts.Features
+	if features == nil {
+		features = DefaultFeatures
+	}
+
+	s := &Server{
+		logger:          logger,
+		listener:        listener,
+		users:           opts.Users,
+		features:        features,
+		clusterConfig:   opts.ClusterConfig,
+		isVbucketActive: opts.IsVbucketActive,
+		buckets:         make(map[string]*Bucket),
+		conns:           make(map[*serverConn]struct{}),
+	}
+
+	for _, bucket := range opts.Buckets {
+		s.buckets[bucket.Name()] = bucket
+	}
+
+	s.wg.Add(1)
+	go s.acceptThread()
+
+	return s, nil
+}
+
+// Addr returns the host:port address the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Port returns the port the server is listening on.
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// AddBucket makes a bucket available to be selected on this server.
+func (s *Server) AddBucket(bucket *Bucket) {
+	s.lock.Lock()
+	s.buckets[bucket.Name()] = bucket
+	s.lock.Unlock()
+}
+
+// RemoveBucket stops serving a bucket.  Connections which have the bucket
+// selected are closed, as the real server does when a bucket is deleted.
+func (s *Server) RemoveBucket(bucketName string) {
+	s.lock.Lock()
+	delete(s.buckets, bucketName)
+	var conns []*serverConn
+	for conn := range s.conns {
+		if conn.selectedBucketName() == bucketName {
+			conns = append(conns, conn)
+		}
+	}
+	s.lock.Unlock()
+
+	for _, conn := range conns {
+		conn.close()
+	}
+}
+
+// Bucket returns the bucket with the given name, or nil if there is none.
+func (s *Server) Bucket(bucketName string) *Bucket {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.buckets[bucketName]
+}
+
+// CloseConnections forcibly closes every client connection to the server,
+// without stopping the server from accepting new ones.
+func (s *Server) CloseConnections() {
+	s.lock.Lock()
+	conns := make([]*serverConn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.lock.Unlock()
+
+	for _, conn := range conns {
+		conn.close()
+	}
+}
+
+// Close stops the server and closes all client connections.
+func (s *Server) Close() error {
+	s.lock.Lock()
+	s.closed = true
+	s.lock.Unlock()
+
+	err := s.listener.Close()
+	s.CloseConnections()
+	s.wg.Wait()
+
+	return err
+}
+
+func (s *Server) acceptThread() {
+	defer s.wg.Done()
+
+	for {
+		netConn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		conn := newServerConn(s, netConn)
+
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			_ = netConn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.lock.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			conn.run()
+
+			s.lock.Lock()
+			delete(s.conns, conn)
+			s.lock.Unlock()
+		}()
+	}
+}
+
+func (s *Server) getClusterConfig(bucketName string) []byte {
+	if s.clusterConfig != nil {
+		return s.clusterConfig(s, bucketName)
+	}
+
+	return s.defaultClusterConfig(bucketName)
+}
+
+func (s *Server) vbucketIsActive(bucketName string, vbID uint16) bool {
+	if s.isVbucketActive != nil {
+		return s.isVbucketActive(s, bucketName, vbID)
+	}
+
+	return true
+}
+
+// defaultClusterConfig generates a terse config describing a single node
+// cluster made up of just this server.
+func (s *Server) defaultClusterConfig(bucketName string) []byte {
+	config := cbconfig.TerseConfigJson{
+		Rev: 1,
+		NodesExt: []cbconfig.TerseExtNodeJson{
+			{
+				Services: &cbconfig.TerseExtNodePortsJson{
+					Kv: uint16(s.Port()),
+				},
+				ThisNode: true,
+				Hostname: "$HOST",
+			},
+		},
+		ClusterCapabilitiesVer: []int{1, 0},
+		ClusterCapabilities:    map[string][]string{},
+	}
+
+	bucket := s.Bucket(bucketName)
+	if bucket != nil {
+		vbMap := make([][]int, bucket.NumVbuckets())
+		for vbID := range vbMap {
+			vbMap[vbID] = []int{0}
+		}
+
+		config.Name = bucket.Name()
+		config.NodeLocator = "vbucket"
+		config.BucketCapabilitiesVer = ""
+		config.BucketCapabilities = []string{
+			"collections", "durableWrite", "tombstonedUserXAttrs", "couchapi",
+			"subdoc.ReplaceBodyWithXattr", "subdoc.DocumentMacroSupport",
+			"dcp", "cbhello", "touch", "cccp", "xdcrCheckpointing", "nodesExt", "xattr",
+		}
+		config.CollectionsManifestUid = strconv.FormatUint(bucket.ManifestUid(), 16)
+		config.VBucketServerMap = &cbconfig.VBucketServerMapJson{
+			HashAlgorithm: "CRC",
+			NumReplicas:   0,
+			ServerList:    []string{"$HOST:" + strconv.Itoa(s.Port())},
+			VBucketMap:    vbMap,
+		}
+	}
+
+	configBytes, _ := json.Marshal(config)
+	return configBytes
+}
@@ -0,0 +1,415 @@
+package memdtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocbcorex/contrib/cbconfig"
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+const (
+	testUsername = "Administrator"
+	testPassword = "password"
+	testBucket   = "default"
+)
+
+func syncCall[Encoder any, ReqT any, RespT any](
+	e Encoder,
+	fn func(Encoder, memdx.Dispatcher, ReqT, func(RespT, error)) (memdx.PendingOp, error),
+	d memdx.Dispatcher,
+	req ReqT,
+) (RespT, error) {
+	type result struct {
+		Resp RespT
+		Err  error
+	}
+	waitCh := make(chan result, 1)
+
+	_, err := fn(e, d, req, func(resp RespT, err error) {
+		waitCh <- result{resp, err}
+	})
+	if err != nil {
+		var emptyResp RespT
+		return emptyResp, err
+	}
+
+	res := <-waitCh
+	return res.Resp, res.Err
+}
+
+func startTestServer(t *testing.T, opts *ServerOptions) *Server {
+	if opts.Users == nil {
+		opts.Users = map[string]string{testUsername: testPassword}
+	}
+	if opts.Buckets == nil {
+		opts.Buckets = []*Bucket{NewBucket(&BucketOptions{Name: testBucket})}
+	}
+
+	srv, err := NewServer(opts)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = srv.Close()
+	})
+
+	return srv
+}
+
+func bootstrapTestClient(t *testing.T, srv *Server, mech memdx.AuthMechanism, password string) (*memdx.Client, *memdx.BootstrapResult, error) {
+	conn, err := memdx.DialConn(context.Background(), srv.Addr(), nil)
+	require.NoError(t, err)
+
+	cli := memdx.NewClient(conn, &memdx.ClientOptions{})
+	t.Cleanup(func() {
+		_ = cli.Close()
+	})
+
+	res, err := syncCall(memdx.OpBootstrap{
+		Encoder: memdx.OpsCore{},
+	}, memdx.OpBootstrap.Bootstrap, cli, &memdx.BootstrapOptions{
+		Hello: &memdx.HelloRequest{
+			ClientName: []byte("memdtest"),
+			RequestedFeatures: []memdx.HelloFeature{
+				memdx.HelloFeatureCollections,
+				memdx.HelloFeatureJSON,
+				memdx.HelloFeatureSeqNo,
+				memdx.HelloFeatureXattr,
+				memdx.HelloFeatureXerror,
+				memdx.HelloFeatureDatatype,
+				memdx.HelloFeatureAltRequests,
+				memdx.HelloFeatureDuplex,
+			},
+		},
+		GetErrorMap: &memdx.GetErrorMapRequest{
+			Version: 2,
+		},
+		Auth: &memdx.SaslAuthAutoOptions{
+			Username:     testUsername,
+			Password:     password,
+			EnabledMechs: []memdx.AuthMechanism{mech},
+		},
+		SelectBucket: &memdx.SelectBucketRequest{
+			BucketName: testBucket,
+		},
+		GetClusterConfig: &memdx.GetClusterConfigRequest{},
+	})
+
+	return cli, res, err
+}
+
+func newTestClient(t *testing.T, srv *Server) *memdx.Client {
+	cli, _, err := bootstrapTestClient(t, srv, memdx.PlainAuthMechanism, testPassword)
+	require.NoError(t, err)
+	return cli
+}
+
+var testCrud = memdx.OpsCrud{
+	ExtFramesEnabled:   true,
+	CollectionsEnabled: true,
+}
+
+func TestBootstrap(t *testing.T) {
+	srv := startTestServer(t, &ServerOptions{})
+
+	mechs := []memdx.AuthMechanism{
+		memdx.PlainAuthMechanism,
+		memdx.ScramSha1AuthMechanism,
+		memdx.ScramSha256AuthMechanism,
+		memdx.ScramSha512AuthMechanism,
+	}
+	for _, mech := range mechs {
+		t.Run(string(mech), func(t *testing.T) {
+			_, res, err := bootstrapTestClient(t, srv, mech, testPassword)
+			require.NoError(t, err)
+
+			// duplex is not in the default feature set, so must not be enabled
+			assert.Contains(t, res.Hello.EnabledFeatures, memdx.HelloFeatureCollections)
+			assert.NotContains(t, res.Hello.EnabledFeatures, memdx.HelloFeatureDuplex)
+
+			errMap, err := memdx.ParseErrorMap(res.ErrorMap)
+			require.NoError(t, err)
+			assert.Equal(t, 2, errMap.Version)
+			assert.Contains(t, errMap.Errors, memdx.StatusTmpFail)
+
+			var config cbconfig.TerseConfigJson
+			require.NoError(t, json.Unmarshal(res.ClusterConfig, &config))
+			assert.Equal(t, testBucket, config.Name)
+			assert.Len(t, config.VBucketServerMap.VBucketMap, 1024)
+			assert.Equal(t, uint16(srv.Port()), config.NodesExt[0].Services.Kv)
+		})
+	}
+}
+
+func TestBootstrapBadPassword(t *testing.T) {
+	srv := startTestServer(t, &ServerOptions{})
+
+	_, _, err := bootstrapTestClient(t, srv, memdx.PlainAuthMechanism, "wrong")
+	assert.ErrorIs(t, err, memdx.ErrAuthError)
+
+	_, _, err = bootstrapTestClient(t, srv, memdx.ScramSha512AuthMechanism, "wrong")
+	assert.ErrorIs(t, err, memdx.ErrAuthError)
+}
+
+func TestCrud(t *testing.T) {
+	srv := startTestServer(t, &ServerOptions{})
+	cli := newTestClient(t, srv)
+
+	key := []byte("crud-doc")
+
+	setRes, err := syncCall(testCrud, memdx.OpsCrud.Set, cli, &memdx.SetRequest{
+		Key:       key,
+		VbucketID: 12,
+		Flags:     0x01000000,
+		Value:     []byte(`{"foo":"bar"}`),
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, setRes.Cas)
+	assert.NotZero(t, setRes.MutationToken.VbUuid)
+	assert.Equal(t, uint64(1), setRes.MutationToken.SeqNo)
+
+	getRes, err := syncCall(testCrud, memdx.OpsCrud.Get, cli, &memdx.GetRequest{
+		Key:       key,
+		VbucketID: 12,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, setRes.Cas, getRes.Cas)
+	assert.Equal(t, uint32(0x01000000), getRes.Flags)
+	assert.Equal(t, []byte(`{"foo":"bar"}`), getRes.Value)
+	assert.Equal(t, uint8(memdx.DatatypeFlagJSON), getRes.Datatype)
+
+	_, err = syncCall(testCrud, memdx.OpsCrud.Add, cli, &memdx.AddRequest{
+		Key:       key,
+		VbucketID: 12,
+		Value:     []byte(`{}`),
+	})
+	assert.ErrorIs(t, err, memdx.ErrDocExists)
+
+	_, err = syncCall(testCrud, memdx.OpsCrud.Replace, cli, &memdx.ReplaceRequest{
+		Key:       key,
+		VbucketID: 12,
+		Value:     []byte(`{}`),
+		Cas:       setRes.Cas + 1,
+	})
+	assert.ErrorIs(t, err, memdx.ErrCasMismatch)
+
+	lockRes, err := syncCall(testCrud, memdx.OpsCrud.GetAndLock, cli, &memdx.GetAndLockRequest{
+		Key:       key,
+		VbucketID: 12,
+		LockTime:  10,
+	})
+	require.NoError(t, err)
+
+	_, err = syncCall(testCrud, memdx.OpsCrud.Set, cli, &memdx.SetRequest{
+		Key:       key,
+		VbucketID: 12,
+		Value:     []byte(`{}`),
+	})
+	var serverErr memdx.ServerError
+	require.True(t, errors.As(err, &serverErr))
+	assert.Equal(t, memdx.StatusLocked, serverErr.Status)
+
+	_, err = syncCall(testCrud, memdx.OpsCrud.Unlock, cli, &memdx.UnlockRequest{
+		Key:       key,
+		VbucketID: 12,
+		Cas:       lockRes.Cas,
+	})
+	require.NoError(t, err)
+
+	_, err = syncCall(testCrud, memdx.OpsCrud.Append, cli, &memdx.AppendRequest{
+		Key:       []byte("missing-doc"),
+		VbucketID: 12,
+		Value:     []byte("x"),
+	})
+	assert.ErrorIs(t, err, memdx.ErrDocNotFound)
+
+	delRes, err := syncCall(testCrud, memdx.OpsCrud.Delete, cli, &memdx.DeleteRequest{
+		Key:       key,
+		VbucketID: 12,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), delRes.MutationToken.SeqNo)
+
+	_, err = syncCall(testCrud, memdx.OpsCrud.Get, cli, &memdx.GetRequest{
+		Key:       key,
+		VbucketID: 12,
+	})
+	assert.ErrorIs(t, err, memdx.ErrDocNotFound)
+
+	incrRes, err := syncCall(testCrud, memdx.OpsCrud.Increment, cli, &memdx.IncrementRequest{
+		Key:       []byte("counter"),
+		VbucketID: 12,
+		Initial:   5,
+		Delta:     3,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), incrRes.Value)
+
+	incrRes, err = syncCall(testCrud, memdx.OpsCrud.Increment, cli, &memdx.IncrementRequest{
+		Key:       []byte("counter"),
+		VbucketID: 12,
+		Initial:   5,
+		Delta:     3,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), incrRes.Value)
+}
+
+func TestCollections(t *testing.T) {
+	bucket := NewBucket(&BucketOptions{Name: testBucket})
+	srv := startTestServer(t, &ServerOptions{
+		Buckets: []*Bucket{bucket},
+	})
+	cli := newTestClient(t, srv)
+
+	_, err := bucket.CreateScope("inventory")
+	require.NoError(t, err)
+	collectionID, err := bucket.CreateCollection("inventory", "hotels", 0)
+	require.NoError(t, err)
+
+	cidRes, err := syncCall(memdx.OpsUtils{}, memdx.OpsUtils.GetCollectionID, cli, &memdx.GetCollectionIDRequest{
+		ScopeName:      "inventory",
+		CollectionName: "hotels",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, collectionID, cidRes.CollectionID)
+	assert.Equal(t, bucket.ManifestUid(), cidRes.ManifestRev)
+
+	_, err = syncCall(memdx.OpsUtils{}, memdx.OpsUtils.GetCollectionID, cli, &memdx.GetCollectionIDRequest{
+		ScopeName:      "inventory",
+		CollectionName: "missing",
+	})
+	assert.ErrorIs(t, err, memdx.ErrUnknownCollectionName)
+
+	_, err = syncCall(testCrud, memdx.OpsCrud.Set, cli, &memdx.SetRequest{
+		CollectionID: collectionID,
+		Key:          []byte("hotel-1"),
+		Value:        []byte(`{}`),
+	})
+	require.NoError(t, err)
+
+	// the same key in a different collection is a different document
+	_, err = syncCall(testCrud, memdx.OpsCrud.Get, cli, &memdx.GetRequest{
+		Key: []byte("hotel-1"),
+	})
+	assert.ErrorIs(t, err, memdx.ErrDocNotFound)
+
+	require.NoError(t, bucket.DropCollection("inventory", "hotels"))
+
+	_, err = syncCall(testCrud, memdx.OpsCrud.Get, cli, &memdx.GetRequest{
+		CollectionID: collectionID,
+		Key:          []byte("hotel-1"),
+	})
+	assert.ErrorIs(t, err, memdx.ErrUnknownCollectionID)
+}
+
+func TestSubdoc(t *testing.T) {
+	srv := startTestServer(t, &ServerOptions{})
+	cli := newTestClient(t, srv)
+
+	key := []byte("subdoc-doc")
+
+	_, err := syncCall(testCrud, memdx.OpsCrud.Set, cli, &memdx.SetRequest{
+		Key:   key,
+		Value: []byte(`{"name":"fake","tags":["a"],"nested":{"count":1}}`),
+	})
+	require.NoError(t, err)
+
+	mutRes, err := syncCall(testCrud, memdx.OpsCrud.MutateIn, cli, &memdx.MutateInRequest{
+		Key: key,
+		Ops: []memdx.MutateInOp{
+			{
+				Op:    memdx.MutateInOpTypeDictSet,
+				Flags: memdx.SubdocOpFlagXattrPath | memdx.SubdocOpFlagExpandMacros | memdx.SubdocOpFlagMkDirP,
+				Path:  []byte("meta.cas"),
+				Value: []byte(`"${Mutation.CAS}"`),
+			},
+			{
+				Op:    memdx.MutateInOpTypeArrayPushLast,
+				Path:  []byte("tags"),
+				Value: []byte(`"b","c"`),
+			},
+			{
+				Op:    memdx.MutateInOpTypeCounter,
+				Path:  []byte("nested.count"),
+				Value: []byte(`5`),
+			},
+			{
+				Op:    memdx.MutateInOpTypeDelete,
+				Path:  []byte("name"),
+				Value: nil,
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("6"), mutRes.Ops[2].Value)
+
+	lookupRes, err := syncCall(testCrud, memdx.OpsCrud.LookupIn, cli, &memdx.LookupInRequest{
+		Key: key,
+		Ops: []memdx.LookupInOp{
+			{Op: memdx.LookupInOpTypeGet, Flags: memdx.SubdocOpFlagXattrPath, Path: []byte("meta.cas")},
+			{Op: memdx.LookupInOpTypeGet, Flags: memdx.SubdocOpFlagXattrPath, Path: []byte("$document.CAS")},
+			{Op: memdx.LookupInOpTypeGetCount, Path: []byte("tags")},
+			{Op: memdx.LookupInOpTypeGet, Path: []byte("tags[-1]")},
+			{Op: memdx.LookupInOpTypeExists, Path: []byte("name")},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, mutRes.Cas, lookupRes.Cas)
+	assert.Equal(t, lookupRes.Ops[1].Value, lookupRes.Ops[0].Value)
+	assert.Equal(t, []byte("3"), lookupRes.Ops[2].Value)
+	assert.Equal(t, []byte(`"c"`), lookupRes.Ops[3].Value)
+	assert.ErrorIs(t, lookupRes.Ops[4].Err, memdx.ErrSubDocPathNotFound)
+
+	_, err = syncCall(testCrud, memdx.OpsCrud.MutateIn, cli, &memdx.MutateInRequest{
+		Key: key,
+		Ops: []memdx.MutateInOp{
+			{Op: memdx.MutateInOpTypeDictSet, Path: []byte("other"), Value: []byte(`1`)},
+			{Op: memdx.MutateInOpTypeDictAdd, Path: []byte("tags"), Value: []byte(`1`)},
+		},
+	})
+	assert.ErrorIs(t, err, memdx.ErrSubDocPathExists)
+
+	// a failed multi-mutation must not have applied any of its operations
+	getRes, err := syncCall(testCrud, memdx.OpsCrud.Get, cli, &memdx.GetRequest{
+		Key: key,
+	})
+	require.NoError(t, err)
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(getRes.Value, &doc))
+	assert.Equal(t, map[string]interface{}{
+		"tags":   []interface{}{"a", "b", "c"},
+		"nested": map[string]interface{}{"count": float64(6)},
+	}, doc)
+}
+
+func TestNotMyVbucket(t *testing.T) {
+	srv := startTestServer(t, &ServerOptions{
+		IsVbucketActive: func(s *Server, bucketName string, vbID uint16) bool {
+			return vbID%2 == 0
+		},
+	})
+	cli := newTestClient(t, srv)
+
+	_, err := syncCall(testCrud, memdx.OpsCrud.Get, cli, &memdx.GetRequest{
+		Key:       []byte("nmv-doc"),
+		VbucketID: 3,
+	})
+	assert.ErrorIs(t, err, memdx.ErrNotMyVbucket)
+
+	var configErr memdx.ServerErrorWithConfig
+	require.True(t, errors.As(err, &configErr))
+	assert.Contains(t, string(configErr.ConfigJson), testBucket)
+
+	_, err = syncCall(testCrud, memdx.OpsCrud.Get, cli, &memdx.GetRequest{
+		Key:       []byte("nmv-doc"),
+		VbucketID: 4,
+	})
+	assert.ErrorIs(t, err, memdx.ErrDocNotFound)
+}
@@ -0,0 +1,572 @@
+package memdtest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+const maxSubdocOps = 16
+
+// subdocDoc holds the decoded state of a document while a sub-document
+// request is applied to it.  Changes are only written back to the bucket once
+// every operation has succeeded.
+type subdocDoc struct {
+	bodyRaw     []byte
+	body        interface{}
+	bodyDecoded bool
+	bodyIsJSON  bool
+	bodyChanged bool
+
+	xattrs        map[string]interface{}
+	xattrsChanged bool
+}
+
+func newSubdocDoc(doc *document) (*subdocDoc, bool) {
+	sdoc := &subdocDoc{
+		xattrs: make(map[string]interface{}),
+	}
+
+	if doc != nil {
+		sdoc.bodyRaw = doc.Value
+		for key, value := range doc.Xattrs {
+			decoded, ok := decodeSubdocJSON(value)
+			if !ok {
+				return nil, false
+			}
+			sdoc.xattrs[key] = decoded
+		}
+	}
+
+	return sdoc, true
+}
+
+// getBody returns the decoded document body.  An empty body, as found on new
+// documents and tombstones, is treated as an empty object.
+func (d *subdocDoc) getBody() (interface{}, memdx.Status) {
+	if !d.bodyDecoded {
+		d.bodyDecoded = true
+		if len(d.bodyRaw) == 0 {
+			d.body = make(map[string]interface{})
+			d.bodyIsJSON = true
+		} else {
+			d.body, d.bodyIsJSON = decodeSubdocJSON(d.bodyRaw)
+		}
+	}
+
+	if !d.bodyIsJSON {
+		return nil, memdx.StatusSubDocNotJSON
+	}
+
+	return d.body, memdx.StatusSuccess
+}
+
+func (d *subdocDoc) setBody(body interface{}) {
+	d.body = body
+	d.bodyDecoded = true
+	d.bodyIsJSON = true
+	d.bodyChanged = true
+}
+
+func (d *subdocDoc) setBodyRaw(value []byte) {
+	d.bodyRaw = value
+	d.body = nil
+	d.bodyDecoded = false
+	d.bodyChanged = false
+}
+
+// finalBody returns the encoded body once all operations have been applied.
+func (d *subdocDoc) finalBody() []byte {
+	if !d.bodyChanged {
+		return d.bodyRaw
+	}
+
+	bodyBytes, _ := json.Marshal(d.body)
+	return bodyBytes
+}
+
+func (d *subdocDoc) finalXattrs(existing map[string]json.RawMessage) map[string]json.RawMessage {
+	if !d.xattrsChanged {
+		return existing
+	}
+
+	if len(d.xattrs) == 0 {
+		return nil
+	}
+
+	xattrs := make(map[string]json.RawMessage, len(d.xattrs))
+	for key, value := range d.xattrs {
+		valueBytes, _ := json.Marshal(value)
+		xattrs[key] = valueBytes
+	}
+	return xattrs
+}
+
+// parseXattrPath splits an xattr path into the name of the xattr and the
+// path within it.
+func parseXattrPath(path string) (string, []subdocPathComponent, bool) {
+	comps, ok := parseSubdocPath(path)
+	if !ok || len(comps) == 0 || comps[0].IsIndex {
+		return "", nil, false
+	}
+
+	return comps[0].Key, comps, true
+}
+
+func formatHexCas(cas uint64) string {
+	// the server formats cas values using their little-endian byte order
+	casBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(casBytes, cas)
+	return fmt.Sprintf("0x%016x", binary.BigEndian.Uint64(casBytes))
+}
+
+func crc32cOf(value []byte) string {
+	return fmt.Sprintf("0x%08x", crc32.Checksum(value, crc32.MakeTable(crc32.Castagnoli)))
+}
+
+// documentVattr builds the value of the $document virtual xattr.
+func documentVattr(vb *vbucket, doc *document) map[string]interface{} {
+	datatype := []interface{}{}
+	if doc.Datatype&uint8(memdx.DatatypeFlagJSON) != 0 {
+		datatype = append(datatype, "json")
+	} else {
+		datatype = append(datatype, "raw")
+	}
+	if len(doc.Xattrs) > 0 {
+		datatype = append(datatype, "xattr")
+	}
+
+	return map[string]interface{}{
+		"CAS":          formatHexCas(doc.Cas),
+		"vbucket_uuid": fmt.Sprintf("0x%016x", vb.VbUuid),
+		"seqno":        fmt.Sprintf("0x%016x", doc.SeqNo),
+		"exptime":      doc.Expiry,
+		"value_bytes":  len(doc.Value),
+		"value_crc32c": crc32cOf(doc.Value),
+		"deleted":      doc.Deleted,
+		"flags":        doc.Flags,
+		"datatype":     datatype,
+	}
+}
+
+func (c *serverConn) handleLookupIn(req *memdx.Packet) {
+	kreq, ok := c.decodeKeyedRequest(req)
+	if !ok {
+		return
+	}
+
+	var docFlags memdx.SubdocDocFlag
+	if len(req.Extras) == 1 {
+		docFlags = memdx.SubdocDocFlag(req.Extras[0])
+	} else if len(req.Extras) != 0 {
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+
+	type lookupOp struct {
+		Op    memdx.LookupInOpType
+		Flags memdx.SubdocOpFlag
+		Path  string
+	}
+
+	var ops []lookupOp
+	for pos := 0; pos < len(req.Value); {
+		if pos+4 > len(req.Value) {
+			c.sendStatus(req, memdx.StatusInvalidArgs)
+			return
+		}
+
+		pathLen := int(binary.BigEndian.Uint16(req.Value[pos+2:]))
+		if pos+4+pathLen > len(req.Value) {
+			c.sendStatus(req, memdx.StatusInvalidArgs)
+			return
+		}
+
+		ops = append(ops, lookupOp{
+			Op:    memdx.LookupInOpType(req.Value[pos]),
+			Flags: memdx.SubdocOpFlag(req.Value[pos+1]),
+			Path:  string(req.Value[pos+4 : pos+4+pathLen]),
+		})
+		pos += 4 + pathLen
+	}
+	if len(ops) == 0 || len(ops) > maxSubdocOps {
+		c.sendStatus(req, memdx.StatusSubDocInvalidCombo)
+		return
+	}
+
+	bucket := kreq.Bucket
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	now := time.Now()
+	doc := kreq.Vb.Docs[kreq.Key]
+	if doc == nil || doc.isExpired(now) ||
+		(doc.Deleted && docFlags&memdx.SubdocDocFlagAccessDeleted == 0) {
+		c.sendStatus(req, memdx.StatusKeyNotFound)
+		return
+	}
+
+	sdoc, ok := newSubdocDoc(doc)
+	if !ok {
+		c.sendStatus(req, memdx.StatusInternalError)
+		return
+	}
+
+	var respValue []byte
+	anyFailed := false
+	for _, op := range ops {
+		var value []byte
+		status := memdx.StatusSuccess
+
+		if op.Op == memdx.LookupInOpTypeGetDoc {
+			if op.Path != "" || op.Flags&memdx.SubdocOpFlagXattrPath != 0 {
+				status = memdx.StatusSubDocPathInvalid
+			} else {
+				value = doc.Value
+			}
+		} else {
+			var node interface{}
+			var comps []subdocPathComponent
+
+			if op.Flags&memdx.SubdocOpFlagXattrPath != 0 {
+				var xattrKey string
+				xattrKey, comps, ok = parseXattrPath(op.Path)
+				if !ok {
+					status = memdx.StatusSubDocPathInvalid
+				} else if xattrKey == "$document" {
+					node = map[string]interface{}{"$document": documentVattr(kreq.Vb, doc)}
+				} else if strings.HasPrefix(xattrKey, "$") {
+					status = memdx.StatusSubDocXattrUnknownVAttr
+				} else {
+					node = sdoc.xattrs
+				}
+			} else {
+				comps, ok = parseSubdocPath(op.Path)
+				if !ok {
+					status = memdx.StatusSubDocPathInvalid
+				} else {
+					node, status = sdoc.getBody()
+					if status == memdx.StatusSubDocNotJSON {
+						c.sendStatus(req, memdx.StatusSubDocNotJSON)
+						return
+					}
+				}
+			}
+
+			if status == memdx.StatusSuccess {
+				node, status = subdocGet(node, comps)
+			}
+
+			if status == memdx.StatusSuccess {
+				switch op.Op {
+				case memdx.LookupInOpTypeGet:
+					value, _ = json.Marshal(node)
+				case memdx.LookupInOpTypeExists:
+				case memdx.LookupInOpTypeGetCount:
+					switch container := node.(type) {
+					case map[string]interface{}:
+						value = []byte(strconv.Itoa(len(container)))
+					case []interface{}:
+						value = []byte(strconv.Itoa(len(container)))
+					default:
+						status = memdx.StatusSubDocPathMismatch
+					}
+				default:
+					c.sendStatus(req, memdx.StatusSubDocInvalidCombo)
+					return
+				}
+			}
+		}
+
+		if status != memdx.StatusSuccess {
+			anyFailed = true
+			value = nil
+		}
+
+		entry := make([]byte, 6)
+		binary.BigEndian.PutUint16(entry[0:], uint16(status))
+		binary.BigEndian.PutUint32(entry[2:], uint32(len(value)))
+		respValue = append(append(respValue, entry...), value...)
+	}
+
+	respStatus := memdx.StatusSuccess
+	if doc.Deleted {
+		respStatus = memdx.StatusSubDocSuccessDeleted
+		if anyFailed {
+			respStatus = memdx.StatusSubDocMultiPathFailureDeleted
+		}
+	} else if anyFailed {
+		respStatus = memdx.StatusSubDocMultiPathFailure
+	}
+
+	cas := doc.Cas
+	if doc.isLocked(now) {
+		cas = lockedCas
+	}
+
+	c.sendResponse(req, &memdx.Packet{
+		Status: respStatus,
+		Cas:    cas,
+		Value:  respValue,
+	})
+}
+
+func (c *serverConn) handleMutateIn(req *memdx.Packet) {
+	kreq, ok := c.decodeKeyedRequest(req)
+	if !ok {
+		return
+	}
+
+	var expiry uint32
+	var docFlags memdx.SubdocDocFlag
+	switch len(req.Extras) {
+	case 0:
+	case 1:
+		docFlags = memdx.SubdocDocFlag(req.Extras[0])
+	case 4, 5:
+		expiry = binary.BigEndian.Uint32(req.Extras)
+		if len(req.Extras) == 5 {
+			docFlags = memdx.SubdocDocFlag(req.Extras[4])
+		}
+	default:
+		c.sendStatus(req, memdx.StatusInvalidArgs)
+		return
+	}
+
+	type mutateOp struct {
+		Op    memdx.MutateInOpType
+		Flags memdx.SubdocOpFlag
+		Path  string
+		Value []byte
+	}
+
+	var ops []mutateOp
+	for pos := 0; pos < len(req.Value); {
+		if pos+8 > len(req.Value) {
+			c.sendStatus(req, memdx.StatusInvalidArgs)
+			return
+		}
+
+		pathLen := int(binary.BigEndian.Uint16(req.Value[pos+2:]))
+		valueLen := int(binary.BigEndian.Uint32(req.Value[pos+4:]))
+		if pos+8+pathLen+valueLen > len(req.Value) {
+			c.sendStatus(req, memdx.StatusInvalidArgs)
+			return
+		}
+
+		ops = append(ops, mutateOp{
+			Op:    memdx.MutateInOpType(req.Value[pos]),
+			Flags: memdx.SubdocOpFlag(req.Value[pos+1]),
+			Path:  string(req.Value[pos+8 : pos+8+pathLen]),
+			Value: req.Value[pos+8+pathLen : pos+8+pathLen+valueLen],
+		})
+		pos += 8 + pathLen + valueLen
+	}
+	if len(ops) == 0 || len(ops) > maxSubdocOps {
+		c.sendStatus(req, memdx.StatusSubDocInvalidCombo)
+		return
+	}
+
+	bucket := kreq.Bucket
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	now := time.Now()
+	doc := kreq.Vb.Docs[kreq.Key]
+	if doc != nil && doc.isExpired(now) {
+		doc = nil
+	}
+	if doc != nil && doc.Deleted && docFlags&memdx.SubdocDocFlagAccessDeleted == 0 {
+		doc = nil
+	}
+
+	if doc == nil {
+		if docFlags&(memdx.SubdocDocFlagMkDoc|memdx.SubdocDocFlagAddDoc) == 0 {
+			c.sendStatus(req, memdx.StatusKeyNotFound)
+			return
+		}
+		if req.Cas != 0 {
+			c.sendStatus(req, memdx.StatusKeyNotFound)
+			return
+		}
+	} else {
+		if docFlags&memdx.SubdocDocFlagAddDoc != 0 && !doc.Deleted {
+			c.sendStatus(req, memdx.StatusKeyExists)
+			return
+		}
+
+		status, ok := c.checkCasLocked(doc, req.Cas, now)
+		if !ok {
+			c.sendStatus(req, status)
+			return
+		}
+	}
+
+	sdoc, ok := newSubdocDoc(doc)
+	if !ok {
+		c.sendStatus(req, memdx.StatusInternalError)
+		return
+	}
+
+	sendOpFailure := func(opIdx int, status memdx.Status) {
+		value := make([]byte, 3)
+		value[0] = uint8(opIdx)
+		binary.BigEndian.PutUint16(value[1:], uint16(status))
+
+		c.sendResponse(req, &memdx.Packet{
+			Status: memdx.StatusSubDocMultiPathFailure,
+			Value:  value,
+		})
+	}
+
+	deleteDoc := false
+	var macroXattrs []string
+	var respValue []byte
+	for opIdx, op := range ops {
+		mkdirp := op.Flags&memdx.SubdocOpFlagMkDirP != 0
+		isXattr := op.Flags&memdx.SubdocOpFlagXattrPath != 0
+
+		switch op.Op {
+		case memdx.MutateInOpTypeSetDoc, memdx.MutateInOpTypeAddDoc:
+			if op.Path != "" || isXattr {
+				sendOpFailure(opIdx, memdx.StatusSubDocPathInvalid)
+				return
+			}
+			sdoc.setBodyRaw(op.Value)
+			continue
+		case memdx.MutateInOpTypeDeleteDoc:
+			deleteDoc = true
+			continue
+		case memdx.MutateInOpTypeReplaceBodyWithXattr:
+			_, comps, ok := parseXattrPath(op.Path)
+			if !ok || !isXattr {
+				sendOpFailure(opIdx, memdx.StatusSubDocPathInvalid)
+				return
+			}
+
+			value, status := subdocGet(sdoc.xattrs, comps)
+			if status != memdx.StatusSuccess {
+				sendOpFailure(opIdx, status)
+				return
+			}
+
+			valueBytes, _ := json.Marshal(value)
+			sdoc.setBodyRaw(valueBytes)
+			continue
+		}
+
+		if isXattr {
+			xattrKey, comps, ok := parseXattrPath(op.Path)
+			if !ok {
+				sendOpFailure(opIdx, memdx.StatusSubDocPathInvalid)
+				return
+			}
+			if strings.HasPrefix(xattrKey, "$") {
+				c.sendStatus(req, memdx.StatusSubDocXattrCannotModifyVAttr)
+				return
+			}
+
+			newXattrs, result, status := subdocMutation(sdoc.xattrs, op.Op, comps, mkdirp, op.Value)
+			if status != memdx.StatusSuccess {
+				sendOpFailure(opIdx, status)
+				return
+			}
+
+			sdoc.xattrs = newXattrs.(map[string]interface{})
+			sdoc.xattrsChanged = true
+			if op.Flags&memdx.SubdocOpFlagExpandMacros != 0 {
+				macroXattrs = append(macroXattrs, xattrKey)
+			}
+			respValue = appendMutateInResult(respValue, opIdx, result)
+			continue
+		}
+
+		comps, ok := parseSubdocPath(op.Path)
+		if !ok {
+			sendOpFailure(opIdx, memdx.StatusSubDocPathInvalid)
+			return
+		}
+
+		body, status := sdoc.getBody()
+		if status == memdx.StatusSubDocNotJSON {
+			c.sendStatus(req, memdx.StatusSubDocNotJSON)
+			return
+		}
+
+		newBody, result, status := subdocMutation(body, op.Op, comps, mkdirp, op.Value)
+		if status != memdx.StatusSuccess {
+			sendOpFailure(opIdx, status)
+			return
+		}
+
+		sdoc.setBody(newBody)
+		respValue = appendMutateInResult(respValue, opIdx, result)
+	}
+
+	newDoc := &document{
+		Value:  sdoc.finalBody(),
+		Expiry: absoluteExpiry(expiry, now),
+	}
+	if doc != nil {
+		newDoc.Flags = doc.Flags
+		newDoc.Xattrs = sdoc.finalXattrs(doc.Xattrs)
+		newDoc.Deleted = doc.Deleted
+		if expiry == 0 && kreq.Frames.PreserveExpiry {
+			newDoc.Expiry = doc.Expiry
+		}
+	} else {
+		newDoc.Xattrs = sdoc.finalXattrs(nil)
+		newDoc.Deleted = docFlags&memdx.SubdocDocFlagCreateAsDeleted != 0
+	}
+	newDoc.Datatype = storedDatatype(0, newDoc.Value)
+
+	if deleteDoc {
+		newDoc = newTombstone(newDoc)
+	}
+
+	cas := bucket.nextCasLocked()
+	if len(macroXattrs) > 0 {
+		expandXattrMacros(newDoc, macroXattrs, cas, kreq.Vb.MaxSeqNo+1)
+	}
+
+	bucket.storeWithCasLocked(kreq.Vb, kreq.Key, newDoc, cas)
+
+	c.sendMutationResponse(req, kreq, newDoc, respValue)
+}
+
+func appendMutateInResult(buf []byte, opIdx int, result []byte) []byte {
+	if result == nil {
+		return buf
+	}
+
+	entry := make([]byte, 7)
+	entry[0] = uint8(opIdx)
+	binary.BigEndian.PutUint16(entry[1:], uint16(memdx.StatusSuccess))
+	binary.BigEndian.PutUint32(entry[3:], uint32(len(result)))
+	return append(append(buf, entry...), result...)
+}
+
+// expandXattrMacros replaces the mutation macros within the named xattrs with
+// the values they describe.
+func expandXattrMacros(doc *document, xattrKeys []string, cas uint64, seqNo uint64) {
+	replacer := strings.NewReplacer(
+		`"${Mutation.CAS}"`, `"`+formatHexCas(cas)+`"`,
+		`"${Mutation.seqno}"`, `"`+fmt.Sprintf("0x%016x", seqNo)+`"`,
+		`"${Mutation.value_crc32c}"`, `"`+crc32cOf(doc.Value)+`"`,
+	)
+
+	for _, key := range xattrKeys {
+		value, ok := doc.Xattrs[key]
+		if !ok {
+			continue
+		}
+
+		doc.Xattrs[key] = json.RawMessage(replacer.Replace(string(value)))
+	}
+}
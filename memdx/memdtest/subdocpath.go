@@ -0,0 +1,463 @@
+package memdtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+// subdocPathComponent is a single element of a sub-document path, which is
+// either a dictionary key or an array index.  An index of -1 refers to the
+// last element of an array.
+type subdocPathComponent struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// parseSubdocPath parses a path such as `a.b[2].c` or "`a.b`[-1]".  An empty
+// path refers to the root of the document.
+func parseSubdocPath(path string) ([]subdocPathComponent, bool) {
+	var comps []subdocPathComponent
+
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, false
+			}
+
+			index, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil || index < -1 {
+				return nil, false
+			}
+
+			comps = append(comps, subdocPathComponent{Index: index, IsIndex: true})
+			i += end + 1
+		case '`':
+			// quoted keys may contain any character, with `` escaping a backtick
+			var key strings.Builder
+			i++
+			for {
+				if i >= len(path) {
+					return nil, false
+				}
+				if path[i] == '`' {
+					if i+1 < len(path) && path[i+1] == '`' {
+						key.WriteByte('`')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				key.WriteByte(path[i])
+				i++
+			}
+
+			comps = append(comps, subdocPathComponent{Key: key.String()})
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			if end == 0 {
+				return nil, false
+			}
+
+			comps = append(comps, subdocPathComponent{Key: path[i : i+end]})
+			i += end
+		}
+
+		if i < len(path) {
+			switch path[i] {
+			case '.':
+				i++
+				if i >= len(path) {
+					return nil, false
+				}
+			case '[':
+			default:
+				return nil, false
+			}
+		}
+	}
+
+	return comps, true
+}
+
+// decodeSubdocJSON decodes a single JSON value, preserving numbers exactly.
+func decodeSubdocJSON(data []byte) (interface{}, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false
+	}
+
+	return value, true
+}
+
+// decodeSubdocMultiValue decodes a comma-separated list of JSON values, as
+// accepted by the array operations.
+func decodeSubdocMultiValue(data []byte) ([]interface{}, bool) {
+	value, ok := decodeSubdocJSON([]byte("[" + string(data) + "]"))
+	if !ok {
+		return nil, false
+	}
+
+	values := value.([]interface{})
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	return values, true
+}
+
+func subdocGet(node interface{}, comps []subdocPathComponent) (interface{}, memdx.Status) {
+	for _, comp := range comps {
+		switch container := node.(type) {
+		case map[string]interface{}:
+			if comp.IsIndex {
+				return nil, memdx.StatusSubDocPathMismatch
+			}
+
+			child, ok := container[comp.Key]
+			if !ok {
+				return nil, memdx.StatusSubDocPathNotFound
+			}
+			node = child
+		case []interface{}:
+			if !comp.IsIndex {
+				return nil, memdx.StatusSubDocPathMismatch
+			}
+
+			index := comp.Index
+			if index == -1 {
+				index = len(container) - 1
+			}
+			if index < 0 || index >= len(container) {
+				return nil, memdx.StatusSubDocPathNotFound
+			}
+			node = container[index]
+		default:
+			return nil, memdx.StatusSubDocPathMismatch
+		}
+	}
+
+	return node, memdx.StatusSuccess
+}
+
+// subdocUpdateFn computes the replacement for the value at a path.  exists
+// indicates whether there is currently a value at the path at all.
+type subdocUpdateFn func(value interface{}, exists bool) (newValue interface{}, remove bool, status memdx.Status)
+
+// subdocUpdate walks comps from node and replaces the value at the end of the
+// path with the result of fn, returning the updated node.  Missing
+// intermediate dictionaries are created when mkdirp is set.  Containers are
+// modified in place.
+func subdocUpdate(node interface{}, comps []subdocPathComponent, mkdirp bool, fn subdocUpdateFn) (interface{}, memdx.Status) {
+	if len(comps) == 0 {
+		newValue, remove, status := fn(node, true)
+		if status != memdx.StatusSuccess {
+			return nil, status
+		}
+		if remove {
+			return nil, memdx.StatusSubDocPathInvalid
+		}
+		return newValue, memdx.StatusSuccess
+	}
+
+	comp := comps[0]
+	switch container := node.(type) {
+	case map[string]interface{}:
+		if comp.IsIndex {
+			return nil, memdx.StatusSubDocPathMismatch
+		}
+
+		child, exists := container[comp.Key]
+		if len(comps) == 1 {
+			newValue, remove, status := fn(child, exists)
+			if status != memdx.StatusSuccess {
+				return nil, status
+			}
+
+			if remove {
+				delete(container, comp.Key)
+			} else {
+				container[comp.Key] = newValue
+			}
+			return container, memdx.StatusSuccess
+		}
+
+		if !exists {
+			if !mkdirp {
+				return nil, memdx.StatusSubDocPathNotFound
+			}
+			child = make(map[string]interface{})
+		}
+
+		newChild, status := subdocUpdate(child, comps[1:], mkdirp, fn)
+		if status != memdx.StatusSuccess {
+			return nil, status
+		}
+
+		container[comp.Key] = newChild
+		return container, memdx.StatusSuccess
+	case []interface{}:
+		if !comp.IsIndex {
+			return nil, memdx.StatusSubDocPathMismatch
+		}
+
+		index := comp.Index
+		if index == -1 {
+			index = len(container) - 1
+		}
+		if index < 0 || index >= len(container) {
+			return nil, memdx.StatusSubDocPathNotFound
+		}
+
+		if len(comps) == 1 {
+			newValue, remove, status := fn(container[index], true)
+			if status != memdx.StatusSuccess {
+				return nil, status
+			}
+
+			if remove {
+				container = append(container[:index], container[index+1:]...)
+			} else {
+				container[index] = newValue
+			}
+			return container, memdx.StatusSuccess
+		}
+
+		newChild, status := subdocUpdate(container[index], comps[1:], mkdirp, fn)
+		if status != memdx.StatusSuccess {
+			return nil, status
+		}
+
+		container[index] = newChild
+		return container, memdx.StatusSuccess
+	default:
+		return nil, memdx.StatusSubDocPathMismatch
+	}
+}
+
+func isSubdocPrimitive(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	default:
+		return true
+	}
+}
+
+// subdocMutation applies a single mutation to a JSON tree, returning the new
+// root and, for counters, the value to return to the client.
+func subdocMutation(
+	root interface{},
+	op memdx.MutateInOpType,
+	comps []subdocPathComponent,
+	mkdirp bool,
+	value []byte,
+) (interface{}, []byte, memdx.Status) {
+	lastIsIndex := len(comps) > 0 && comps[len(comps)-1].IsIndex
+
+	switch op {
+	case memdx.MutateInOpTypeDictAdd, memdx.MutateInOpTypeDictSet:
+		if len(comps) == 0 || lastIsIndex {
+			return nil, nil, memdx.StatusSubDocPathInvalid
+		}
+
+		newValue, ok := decodeSubdocJSON(value)
+		if !ok {
+			return nil, nil, memdx.StatusSubDocCantInsert
+		}
+
+		root, status := subdocUpdate(root, comps, mkdirp, func(current interface{}, exists bool) (interface{}, bool, memdx.Status) {
+			if exists && op == memdx.MutateInOpTypeDictAdd {
+				return nil, false, memdx.StatusSubDocPathExists
+			}
+			return newValue, false, memdx.StatusSuccess
+		})
+		return root, nil, status
+
+	case memdx.MutateInOpTypeReplace:
+		if len(comps) == 0 {
+			return nil, nil, memdx.StatusSubDocPathInvalid
+		}
+
+		newValue, ok := decodeSubdocJSON(value)
+		if !ok {
+			return nil, nil, memdx.StatusSubDocCantInsert
+		}
+
+		root, status := subdocUpdate(root, comps, false, func(current interface{}, exists bool) (interface{}, bool, memdx.Status) {
+			if !exists {
+				return nil, false, memdx.StatusSubDocPathNotFound
+			}
+			return newValue, false, memdx.StatusSuccess
+		})
+		return root, nil, status
+
+	case memdx.MutateInOpTypeDelete:
+		if len(comps) == 0 {
+			return nil, nil, memdx.StatusSubDocPathInvalid
+		}
+
+		root, status := subdocUpdate(root, comps, false, func(current interface{}, exists bool) (interface{}, bool, memdx.Status) {
+			if !exists {
+				return nil, false, memdx.StatusSubDocPathNotFound
+			}
+			return nil, true, memdx.StatusSuccess
+		})
+		return root, nil, status
+
+	case memdx.MutateInOpTypeArrayPushLast, memdx.MutateInOpTypeArrayPushFirst:
+		newValues, ok := decodeSubdocMultiValue(value)
+		if !ok {
+			return nil, nil, memdx.StatusSubDocCantInsert
+		}
+
+		root, status := subdocUpdate(root, comps, mkdirp, func(current interface{}, exists bool) (interface{}, bool, memdx.Status) {
+			if !exists {
+				if !mkdirp {
+					return nil, false, memdx.StatusSubDocPathNotFound
+				}
+				return newValues, false, memdx.StatusSuccess
+			}
+
+			array, ok := current.([]interface{})
+			if !ok {
+				return nil, false, memdx.StatusSubDocPathMismatch
+			}
+
+			if op == memdx.MutateInOpTypeArrayPushLast {
+				return append(array, newValues...), false, memdx.StatusSuccess
+			}
+			return append(append([]interface{}{}, newValues...), array...), false, memdx.StatusSuccess
+		})
+		return root, nil, status
+
+	case memdx.MutateInOpTypeArrayInsert:
+		if !lastIsIndex {
+			return nil, nil, memdx.StatusSubDocPathInvalid
+		}
+
+		insertIndex := comps[len(comps)-1].Index
+		if insertIndex < 0 {
+			return nil, nil, memdx.StatusSubDocPathInvalid
+		}
+
+		newValues, ok := decodeSubdocMultiValue(value)
+		if !ok {
+			return nil, nil, memdx.StatusSubDocCantInsert
+		}
+
+		root, status := subdocUpdate(root, comps[:len(comps)-1], false, func(current interface{}, exists bool) (interface{}, bool, memdx.Status) {
+			if !exists {
+				return nil, false, memdx.StatusSubDocPathNotFound
+			}
+
+			array, ok := current.([]interface{})
+			if !ok {
+				return nil, false, memdx.StatusSubDocPathMismatch
+			}
+			if insertIndex > len(array) {
+				return nil, false, memdx.StatusSubDocPathNotFound
+			}
+
+			newArray := make([]interface{}, 0, len(array)+len(newValues))
+			newArray = append(newArray, array[:insertIndex]...)
+			newArray = append(newArray, newValues...)
+			newArray = append(newArray, array[insertIndex:]...)
+			return newArray, false, memdx.StatusSuccess
+		})
+		return root, nil, status
+
+	case memdx.MutateInOpTypeArrayAddUnique:
+		newValue, ok := decodeSubdocJSON(value)
+		if !ok || !isSubdocPrimitive(newValue) {
+			return nil, nil, memdx.StatusSubDocCantInsert
+		}
+		newValueBytes, _ := json.Marshal(newValue)
+
+		root, status := subdocUpdate(root, comps, mkdirp, func(current interface{}, exists bool) (interface{}, bool, memdx.Status) {
+			if !exists {
+				if !mkdirp {
+					return nil, false, memdx.StatusSubDocPathNotFound
+				}
+				return []interface{}{newValue}, false, memdx.StatusSuccess
+			}
+
+			array, ok := current.([]interface{})
+			if !ok {
+				return nil, false, memdx.StatusSubDocPathMismatch
+			}
+
+			for _, elem := range array {
+				if !isSubdocPrimitive(elem) {
+					return nil, false, memdx.StatusSubDocPathMismatch
+				}
+
+				elemBytes, _ := json.Marshal(elem)
+				if bytes.Equal(elemBytes, newValueBytes) {
+					return nil, false, memdx.StatusSubDocPathExists
+				}
+			}
+
+			return append(array, newValue), false, memdx.StatusSuccess
+		})
+		return root, nil, status
+
+	case memdx.MutateInOpTypeCounter:
+		if len(comps) == 0 {
+			return nil, nil, memdx.StatusSubDocPathInvalid
+		}
+
+		delta, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil || delta == 0 {
+			return nil, nil, memdx.StatusSubDocBadDelta
+		}
+
+		var result int64
+		root, status := subdocUpdate(root, comps, mkdirp, func(current interface{}, exists bool) (interface{}, bool, memdx.Status) {
+			if !exists {
+				result = delta
+				return json.Number(strconv.FormatInt(result, 10)), false, memdx.StatusSuccess
+			}
+
+			number, ok := current.(json.Number)
+			if !ok {
+				return nil, false, memdx.StatusSubDocPathMismatch
+			}
+
+			currentValue, err := strconv.ParseInt(string(number), 10, 64)
+			if err != nil {
+				return nil, false, memdx.StatusSubDocPathMismatch
+			}
+
+			result = currentValue + delta
+			if (delta > 0 && result < currentValue) || (delta < 0 && result > currentValue) {
+				return nil, false, memdx.StatusSubDocCantInsert
+			}
+
+			return json.Number(strconv.FormatInt(result, 10)), false, memdx.StatusSuccess
+		})
+		if status != memdx.StatusSuccess {
+			return nil, nil, status
+		}
+		return root, []byte(strconv.FormatInt(result, 10)), status
+	}
+
+	return nil, nil, memdx.StatusSubDocInvalidCombo
+}
@@ -137,6 +137,10 @@ func (c OpCode) String() string {
 		return "DcpDeletion"
 	case OpCodeDcpExpiration:
 		return "DcpExpiration"
+	case OpCodeDcpSeqNoAdvanced:
+		return "DcpSeqNoAdvanced"
+	case OpCodeDcpOsoSnapshot:
+		return "DcpOsoSnapshot"
 	case OpCodeDcpFlush:
 		return "DcpFlush"
 	case OpCodeDcpSetVbucketState:
@@ -147,6 +151,8 @@ func (c OpCode) String() string {
 		return "DcpBufferAck"
 	case OpCodeDcpControl:
 		return "DcpControl"
+	case OpCodeDcpEvent:
+		return "DcpEvent"
 	case OpCodeGetReplica:
 		return "GetReplica"
 	case OpCodeSelectBucket:
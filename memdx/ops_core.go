@@ -14,6 +14,7 @@ type OpsCore struct {
 func (o OpsCore) decodeErrorContext(resp *Packet, err error, dispatchedTo string, dispatchedFrom string) error {
 	baseCause := ServerError{
 		Cause:          err,
+		Status:         resp.Status,
 		DispatchedTo:   dispatchedTo,
 		DispatchedFrom: dispatchedFrom,
 		Opaque:         resp.Opaque,
@@ -153,6 +154,30 @@ func (o OpsCore) GetClusterConfig(d Dispatcher, req *GetClusterConfigRequest, cb
 	})
 }
 
+type NoOpRequest struct{}
+
+type NoOpResponse struct{}
+
+func (o OpsCore) NoOp(d Dispatcher, req *NoOpRequest, cb func(*NoOpResponse, error)) (PendingOp, error) {
+	return d.Dispatch(&Packet{
+		Magic:  MagicReq,
+		OpCode: OpCodeNoOp,
+	}, func(resp *Packet, err error) bool {
+		if err != nil {
+			cb(nil, err)
+			return false
+		}
+
+		if resp.Status != StatusSuccess {
+			cb(nil, o.decodeError(resp, d.RemoteAddr(), d.LocalAddr()))
+			return false
+		}
+
+		cb(&NoOpResponse{}, nil)
+		return false
+	})
+}
+
 type SelectBucketRequest struct {
 	BucketName string
 }
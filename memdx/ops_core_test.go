@@ -93,6 +93,7 @@ func TestOpsCoreDecodeError(t *testing.T) {
 			ExpectedError: ServerErrorWithConfig{
 				Cause: ServerError{
 					Cause:          ErrNotMyVbucket,
+					Status:         StatusNotMyVBucket,
 					DispatchedTo:   dispatchedTo,
 					DispatchedFrom: dispatchedFrom,
 					Opaque:         0x34,
@@ -110,3 +111,70 @@ func TestOpsCoreDecodeError(t *testing.T) {
 		})
 	}
 }
+
+func TestOpsCoreStatsBasic(t *testing.T) {
+	testutils.SkipIfShortTest(t)
+
+	cli := createTestClient(t)
+
+	resp, err := syncUnaryCall(OpsUtils{
+		ExtFramesEnabled: false,
+	}, OpsUtils.Stats, cli, &StatsRequest{
+		GroupName: "",
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Stats["pid"])
+}
+
+func TestOpsCoreStatsCollectsPackets(t *testing.T) {
+	d := &testCaptureDispatcher{}
+
+	var statsResp *StatsResponse
+	_, err := OpsUtils{}.Stats(d, &StatsRequest{
+		GroupName: "vbucket-details 12",
+	}, func(resp *StatsResponse, err error) {
+		require.NoError(t, err)
+		statsResp = resp
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, OpCodeStat, d.Packet.OpCode)
+	assert.Equal(t, []byte("vbucket-details 12"), d.Packet.Key)
+
+	assert.True(t, d.Callback(&Packet{
+		Magic:  MagicRes,
+		OpCode: OpCodeStat,
+		Key:    []byte("vb_12"),
+		Value:  []byte("active"),
+	}, nil))
+	assert.True(t, d.Callback(&Packet{
+		Magic:  MagicRes,
+		OpCode: OpCodeStat,
+		Key:    []byte("vb_12:high_seqno"),
+		Value:  []byte("42"),
+	}, nil))
+	assert.Nil(t, statsResp)
+
+	assert.False(t, d.Callback(&Packet{
+		Magic:  MagicRes,
+		OpCode: OpCodeStat,
+	}, nil))
+
+	require.NotNil(t, statsResp)
+	assert.Equal(t, map[string]string{
+		"vb_12":            "active",
+		"vb_12:high_seqno": "42",
+	}, statsResp.Stats)
+}
+
+func TestOpsCoreGetCollectionsManifestBasic(t *testing.T) {
+	testutils.SkipIfShortTest(t)
+
+	cli := createTestClient(t)
+
+	resp, err := syncUnaryCall(OpsUtils{
+		ExtFramesEnabled: false,
+	}, OpsUtils.GetCollectionsManifest, cli, &GetCollectionsManifestRequest{})
+	require.NoError(t, err)
+	assert.Contains(t, string(resp), `"_default"`)
+}
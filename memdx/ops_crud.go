@@ -4,6 +4,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"time"
+
+	"go.uber.org/zap"
 )
 
 type OpsCrud struct {
@@ -11,6 +13,14 @@ type OpsCrud struct {
 	CollectionsEnabled    bool
 	DurabilityEnabled     bool
 	PreserveExpiryEnabled bool
+
+	// TraceContext, if set, is sent with each request in the OTel context
+	// frame so the server can link its work to the client trace.
+	TraceContext string
+
+	// Logger, if set, is used to report response metadata which could not be
+	// decoded and was discarded.
+	Logger *zap.Logger
 }
 
 func (o OpsCrud) encodeCollectionAndKey(collectionID uint32, key []byte, buf []byte) ([]byte, error) {
@@ -82,6 +92,13 @@ func (o OpsCrud) encodeReqExtFrames(
 		}
 	}
 
+	if o.TraceContext != "" {
+		buf, err = AppendExtFrame(ExtFrameCodeReqOtelContext, []byte(o.TraceContext), buf)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
 	if len(buf) > 0 {
 		if !o.ExtFramesEnabled {
 			return 0, nil, protocolError{"cannot use framing extras when its not enabled"}
@@ -125,6 +142,8 @@ type GetResponse struct {
 	Flags    uint32
 	Value    []byte
 	Datatype uint8
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) Get(d Dispatcher, req *GetRequest, cb func(*GetResponse, error)) (PendingOp, error) {
@@ -167,11 +186,14 @@ func (o OpsCrud) Get(d Dispatcher, req *GetRequest, cb func(*GetResponse, error)
 
 		flags := binary.BigEndian.Uint32(resp.Extras[0:])
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&GetResponse{
 			Cas:      resp.Cas,
 			Flags:    flags,
 			Value:    resp.Value,
 			Datatype: resp.Datatype,
+			Meta:     meta,
 		}, nil)
 		return false
 	})
@@ -190,6 +212,8 @@ type GetAndTouchResponse struct {
 	Flags    uint32
 	Value    []byte
 	Datatype uint8
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) GetAndTouch(d Dispatcher, req *GetAndTouchRequest, cb func(*GetAndTouchResponse, error)) (PendingOp, error) {
@@ -238,11 +262,14 @@ func (o OpsCrud) GetAndTouch(d Dispatcher, req *GetAndTouchRequest, cb func(*Get
 
 		flags := binary.BigEndian.Uint32(resp.Extras[0:])
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&GetAndTouchResponse{
 			Cas:      resp.Cas,
 			Flags:    flags,
 			Value:    resp.Value,
 			Datatype: resp.Datatype,
+			Meta:     meta,
 		}, nil)
 		return false
 	})
@@ -260,6 +287,8 @@ type GetReplicaResponse struct {
 	Flags    uint32
 	Value    []byte
 	Datatype uint8
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) GetReplica(d Dispatcher, req *GetReplicaRequest, cb func(*GetReplicaResponse, error)) (PendingOp, error) {
@@ -304,11 +333,14 @@ func (o OpsCrud) GetReplica(d Dispatcher, req *GetReplicaRequest, cb func(*GetRe
 
 		flags := binary.BigEndian.Uint32(resp.Extras[0:])
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&GetReplicaResponse{
 			Cas:      resp.Cas,
 			Flags:    flags,
 			Value:    resp.Value,
 			Datatype: resp.Datatype,
+			Meta:     meta,
 		}, nil)
 		return false
 	})
@@ -328,6 +360,8 @@ type GetAndLockResponse struct {
 	Flags    uint32
 	Value    []byte
 	Datatype uint8
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) GetAndLock(d Dispatcher, req *GetAndLockRequest, cb func(*GetAndLockResponse, error)) (PendingOp, error) {
@@ -376,11 +410,14 @@ func (o OpsCrud) GetAndLock(d Dispatcher, req *GetAndLockRequest, cb func(*GetAn
 
 		flags := binary.BigEndian.Uint32(resp.Extras[0:])
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&GetAndLockResponse{
 			Cas:      resp.Cas,
 			Flags:    flags,
 			Value:    resp.Value,
 			Datatype: resp.Datatype,
+			Meta:     meta,
 		}, nil)
 		return false
 	})
@@ -398,6 +435,8 @@ type GetRandomResponse struct {
 	Flags    uint32
 	Value    []byte
 	Datatype uint8
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) GetRandom(d Dispatcher, req *GetRandomRequest, cb func(*GetRandomResponse, error)) (PendingOp, error) {
@@ -441,12 +480,15 @@ func (o OpsCrud) GetRandom(d Dispatcher, req *GetRandomRequest, cb func(*GetRand
 
 		flags := binary.BigEndian.Uint32(resp.Extras[0:])
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&GetRandomResponse{
 			Key:      resp.Key,
 			Cas:      resp.Cas,
 			Flags:    flags,
 			Value:    resp.Value,
 			Datatype: resp.Datatype,
+			Meta:     meta,
 		}, nil)
 		return false
 	})
@@ -470,6 +512,8 @@ type SetRequest struct {
 type SetResponse struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) Set(d Dispatcher, req *SetRequest, cb func(*SetResponse, error)) (PendingOp, error) {
@@ -527,9 +571,12 @@ func (o OpsCrud) Set(d Dispatcher, req *SetRequest, cb func(*SetResponse, error)
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&SetResponse{
 			Cas:           resp.Cas,
 			MutationToken: mutToken,
+			Meta:          meta,
 		}, nil)
 		return false
 	})
@@ -546,6 +593,8 @@ type UnlockRequest struct {
 
 type UnlockResponse struct {
 	MutationToken MutationToken
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) Unlock(d Dispatcher, req *UnlockRequest, cb func(*UnlockResponse, error)) (PendingOp, error) {
@@ -594,8 +643,11 @@ func (o OpsCrud) Unlock(d Dispatcher, req *UnlockRequest, cb func(*UnlockRespons
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&UnlockResponse{
 			MutationToken: mutToken,
+			Meta:          meta,
 		}, nil)
 		return false
 	})
@@ -611,6 +663,8 @@ type TouchRequest struct {
 
 type TouchResponse struct {
 	Cas uint64
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) Touch(d Dispatcher, req *TouchRequest, cb func(*TouchResponse, error)) (PendingOp, error) {
@@ -657,8 +711,11 @@ func (o OpsCrud) Touch(d Dispatcher, req *TouchRequest, cb func(*TouchResponse,
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&TouchResponse{
-			Cas: resp.Cas,
+			Cas:  resp.Cas,
+			Meta: meta,
 		}, nil)
 		return false
 	})
@@ -677,6 +734,8 @@ type DeleteRequest struct {
 type DeleteResponse struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) Delete(d Dispatcher, req *DeleteRequest, cb func(*DeleteResponse, error)) (PendingOp, error) {
@@ -727,9 +786,12 @@ func (o OpsCrud) Delete(d Dispatcher, req *DeleteRequest, cb func(*DeleteRespons
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&DeleteResponse{
 			Cas:           resp.Cas,
 			MutationToken: mutToken,
+			Meta:          meta,
 		}, nil)
 		return false
 	})
@@ -751,6 +813,8 @@ type AddRequest struct {
 type AddResponse struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) Add(d Dispatcher, req *AddRequest, cb func(*AddResponse, error)) (PendingOp, error) {
@@ -806,9 +870,12 @@ func (o OpsCrud) Add(d Dispatcher, req *AddRequest, cb func(*AddResponse, error)
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&AddResponse{
 			Cas:           resp.Cas,
 			MutationToken: mutToken,
+			Meta:          meta,
 		}, nil)
 		return false
 	})
@@ -832,6 +899,8 @@ type ReplaceRequest struct {
 type ReplaceResponse struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) Replace(d Dispatcher, req *ReplaceRequest, cb func(*ReplaceResponse, error)) (PendingOp, error) {
@@ -897,9 +966,12 @@ func (o OpsCrud) Replace(d Dispatcher, req *ReplaceRequest, cb func(*ReplaceResp
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&ReplaceResponse{
 			Cas:           resp.Cas,
 			MutationToken: mutToken,
+			Meta:          meta,
 		}, nil)
 		return false
 	})
@@ -920,6 +992,8 @@ type AppendRequest struct {
 type AppendResponse struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) Append(d Dispatcher, req *AppendRequest, cb func(*AppendResponse, error)) (PendingOp, error) {
@@ -971,9 +1045,12 @@ func (o OpsCrud) Append(d Dispatcher, req *AppendRequest, cb func(*AppendRespons
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&AppendResponse{
 			Cas:           resp.Cas,
 			MutationToken: mutToken,
+			Meta:          meta,
 		}, nil)
 		return false
 	})
@@ -994,6 +1071,8 @@ type PrependRequest struct {
 type PrependResponse struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) Prepend(d Dispatcher, req *PrependRequest, cb func(*PrependResponse, error)) (PendingOp, error) {
@@ -1045,9 +1124,12 @@ func (o OpsCrud) Prepend(d Dispatcher, req *PrependRequest, cb func(*PrependResp
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&PrependResponse{
 			Cas:           resp.Cas,
 			MutationToken: mutToken,
+			Meta:          meta,
 		}, nil)
 		return false
 	})
@@ -1069,6 +1151,8 @@ type IncrementResponse struct {
 	Cas           uint64
 	MutationToken MutationToken
 	Value         uint64
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) Increment(d Dispatcher, req *IncrementRequest, cb func(*IncrementResponse, error)) (PendingOp, error) {
@@ -1135,10 +1219,13 @@ func (o OpsCrud) Increment(d Dispatcher, req *IncrementRequest, cb func(*Increme
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&IncrementResponse{
 			Cas:           resp.Cas,
 			MutationToken: mutToken,
 			Value:         intVal,
+			Meta:          meta,
 		}, nil)
 		return false
 	})
@@ -1160,6 +1247,8 @@ type DecrementResponse struct {
 	Cas           uint64
 	MutationToken MutationToken
 	Value         uint64
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) Decrement(d Dispatcher, req *DecrementRequest, cb func(*DecrementResponse, error)) (PendingOp, error) {
@@ -1226,10 +1315,13 @@ func (o OpsCrud) Decrement(d Dispatcher, req *DecrementRequest, cb func(*Decreme
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&DecrementResponse{
 			Cas:           resp.Cas,
 			MutationToken: mutToken,
 			Value:         intVal,
+			Meta:          meta,
 		}, nil)
 		return false
 	})
@@ -1251,6 +1343,8 @@ type GetMetaResponse struct {
 	SeqNo    uint64
 	Datatype uint8
 	Deleted  bool
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) GetMeta(d Dispatcher, req *GetMetaRequest, cb func(*GetMetaResponse, error)) (PendingOp, error) {
@@ -1297,9 +1391,12 @@ func (o OpsCrud) GetMeta(d Dispatcher, req *GetMetaRequest, cb func(*GetMetaResp
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		res := &GetMetaResponse{
 			Value: resp.Value,
 			Cas:   resp.Cas,
+			Meta:  meta,
 		}
 		res.Deleted = binary.BigEndian.Uint32(resp.Extras[0:]) != 0
 		res.Flags = binary.BigEndian.Uint32(resp.Extras[4:])
@@ -1330,6 +1427,8 @@ type SetMetaRequest struct {
 type SetMetaResponse struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) SetMeta(d Dispatcher, req *SetMetaRequest, cb func(*SetMetaResponse, error)) (PendingOp, error) {
@@ -1387,9 +1486,12 @@ func (o OpsCrud) SetMeta(d Dispatcher, req *SetMetaRequest, cb func(*SetMetaResp
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&SetMetaResponse{
 			Cas:           resp.Cas,
 			MutationToken: mutToken,
+			Meta:          meta,
 		}, nil)
 		return false
 	})
@@ -1411,6 +1513,8 @@ type DeleteMetaRequest struct {
 type DeleteMetaResponse struct {
 	Cas           uint64
 	MutationToken MutationToken
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) DeleteMeta(d Dispatcher, req *DeleteMetaRequest, cb func(*DeleteMetaResponse, error)) (PendingOp, error) {
@@ -1466,9 +1570,12 @@ func (o OpsCrud) DeleteMeta(d Dispatcher, req *DeleteMetaRequest, cb func(*Delet
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&DeleteMetaResponse{
 			Cas:           resp.Cas,
 			MutationToken: mutToken,
+			Meta:          meta,
 		}, nil)
 		return false
 	})
@@ -1488,6 +1595,8 @@ type LookupInResponse struct {
 	Ops          []SubDocResult
 	DocIsDeleted bool
 	Cas          uint64
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) LookupIn(d Dispatcher, req *LookupInRequest, cb func(*LookupInResponse, error)) (PendingOp, error) {
@@ -1623,10 +1732,13 @@ func (o OpsCrud) LookupIn(d Dispatcher, req *LookupInRequest, cb func(*LookupInR
 			}
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		res := &LookupInResponse{
 			Ops:          results,
 			Cas:          resp.Cas,
 			DocIsDeleted: docIsDeleted,
+			Meta:         meta,
 		}
 
 		cb(res, nil)
@@ -1652,6 +1764,8 @@ type MutateInResponse struct {
 	Cas           uint64
 	MutationToken MutationToken
 	Ops           []SubDocResult
+
+	Meta ResponseMeta
 }
 
 func (o OpsCrud) MutateIn(d Dispatcher, req *MutateInRequest, cb func(*MutateInResponse, error)) (PendingOp, error) {
@@ -1837,10 +1951,13 @@ func (o OpsCrud) MutateIn(d Dispatcher, req *MutateInRequest, cb func(*MutateInR
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&MutateInResponse{
 			Cas:           resp.Cas,
 			MutationToken: mutToken,
 			Ops:           results,
+			Meta:          meta,
 		}, nil)
 		return false
 	})
@@ -0,0 +1,184 @@
+package memdx
+
+import (
+	"encoding/binary"
+)
+
+// ObserveKeyState represents the state of a document as reported by Observe.
+type ObserveKeyState uint8
+
+const (
+	// ObserveKeyStateNotPersisted indicates the document is in memory but not yet persisted.
+	ObserveKeyStateNotPersisted = ObserveKeyState(0x00)
+
+	// ObserveKeyStatePersisted indicates the document has been persisted to disk.
+	ObserveKeyStatePersisted = ObserveKeyState(0x01)
+
+	// ObserveKeyStateNotFound indicates the document was not found.
+	ObserveKeyStateNotFound = ObserveKeyState(0x80)
+
+	// ObserveKeyStateDeleted indicates the document has been deleted, but the
+	// deletion has not yet been persisted.
+	ObserveKeyStateDeleted = ObserveKeyState(0x81)
+)
+
+type ObserveRequest struct {
+	CollectionID uint32
+	Key          []byte
+	VbucketID    uint16
+
+	OnBehalfOf string
+}
+
+type ObserveResponse struct {
+	KeyState ObserveKeyState
+	Cas      uint64
+
+	Meta ResponseMeta
+}
+
+func (o OpsCrud) Observe(d Dispatcher, req *ObserveRequest, cb func(*ObserveResponse, error)) (PendingOp, error) {
+	reqMagic, extFramesBuf, err := o.encodeReqExtFrames(req.OnBehalfOf, 0, 0, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	reqKey, err := o.encodeCollectionAndKey(req.CollectionID, req.Key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	valueBuf := make([]byte, 4, 4+len(reqKey))
+	binary.BigEndian.PutUint16(valueBuf[0:], req.VbucketID)
+	binary.BigEndian.PutUint16(valueBuf[2:], uint16(len(reqKey)))
+	valueBuf = append(valueBuf, reqKey...)
+
+	return d.Dispatch(&Packet{
+		Magic:         reqMagic,
+		OpCode:        OpCodeObserve,
+		VbucketID:     req.VbucketID,
+		FramingExtras: extFramesBuf,
+		Value:         valueBuf,
+	}, func(resp *Packet, err error) bool {
+		if err != nil {
+			cb(nil, err)
+			return false
+		}
+
+		if resp.Status != StatusSuccess {
+			cb(nil, OpsCrud{}.decodeCommonError(resp, d.RemoteAddr(), d.LocalAddr()))
+			return false
+		}
+
+		if len(resp.Value) < 4 {
+			cb(nil, protocolError{"bad value length"})
+			return false
+		}
+
+		keyLen := int(binary.BigEndian.Uint16(resp.Value[2:]))
+		if len(resp.Value) != 4+keyLen+1+8 {
+			cb(nil, protocolError{"bad value length"})
+			return false
+		}
+
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
+		cb(&ObserveResponse{
+			KeyState: ObserveKeyState(resp.Value[4+keyLen]),
+			Cas:      binary.BigEndian.Uint64(resp.Value[4+keyLen+1:]),
+			Meta:     meta,
+		}, nil)
+		return false
+	})
+}
+
+type ObserveSeqNoRequest struct {
+	VbucketID uint16
+	VbUuid    uint64
+
+	OnBehalfOf string
+}
+
+type ObserveSeqNoResponse struct {
+	VbucketID    uint16
+	VbUuid       uint64
+	PersistSeqNo uint64
+	CurrentSeqNo uint64
+	DidFailover  bool
+	OldVbUuid    uint64
+	LastSeqNo    uint64
+
+	Meta ResponseMeta
+}
+
+func (o OpsCrud) ObserveSeqNo(d Dispatcher, req *ObserveSeqNoRequest, cb func(*ObserveSeqNoResponse, error)) (PendingOp, error) {
+	reqMagic, extFramesBuf, err := o.encodeReqExtFrames(req.OnBehalfOf, 0, 0, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	valueBuf := make([]byte, 8)
+	binary.BigEndian.PutUint64(valueBuf[0:], req.VbUuid)
+
+	return d.Dispatch(&Packet{
+		Magic:         reqMagic,
+		OpCode:        OpCodeObserveSeqNo,
+		VbucketID:     req.VbucketID,
+		FramingExtras: extFramesBuf,
+		Value:         valueBuf,
+	}, func(resp *Packet, err error) bool {
+		if err != nil {
+			cb(nil, err)
+			return false
+		}
+
+		if resp.Status != StatusSuccess {
+			cb(nil, OpsCrud{}.decodeCommonError(resp, d.RemoteAddr(), d.LocalAddr()))
+			return false
+		}
+
+		if len(resp.Value) < 1 {
+			cb(nil, protocolError{"bad value length"})
+			return false
+		}
+
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
+		formatType := resp.Value[0]
+		if formatType == 0 {
+			if len(resp.Value) != 27 {
+				cb(nil, protocolError{"bad value length"})
+				return false
+			}
+
+			cb(&ObserveSeqNoResponse{
+				VbucketID:    binary.BigEndian.Uint16(resp.Value[1:]),
+				VbUuid:       binary.BigEndian.Uint64(resp.Value[3:]),
+				PersistSeqNo: binary.BigEndian.Uint64(resp.Value[11:]),
+				CurrentSeqNo: binary.BigEndian.Uint64(resp.Value[19:]),
+				Meta:         meta,
+			}, nil)
+			return false
+		} else if formatType == 1 {
+			if len(resp.Value) != 43 {
+				cb(nil, protocolError{"bad value length"})
+				return false
+			}
+
+			cb(&ObserveSeqNoResponse{
+				VbucketID:    binary.BigEndian.Uint16(resp.Value[1:]),
+				VbUuid:       binary.BigEndian.Uint64(resp.Value[3:]),
+				PersistSeqNo: binary.BigEndian.Uint64(resp.Value[11:]),
+				CurrentSeqNo: binary.BigEndian.Uint64(resp.Value[19:]),
+				DidFailover:  true,
+				OldVbUuid:    binary.BigEndian.Uint64(resp.Value[27:]),
+				LastSeqNo:    binary.BigEndian.Uint64(resp.Value[35:]),
+				Meta:         meta,
+			}, nil)
+			return false
+		}
+
+		cb(nil, protocolError{"unexpected observe seqno format type"})
+		return false
+	})
+}
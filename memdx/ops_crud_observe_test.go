@@ -0,0 +1,97 @@
+package memdx
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/couchbase/gocbcorex/testutils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpsCrudObserveSeqNoAfterSet(t *testing.T) {
+	testutils.SkipIfShortTest(t)
+
+	key := []byte(uuid.NewString())
+
+	cli := createTestClient(t)
+
+	setResp, err := syncUnaryCall(OpsCrud{
+		CollectionsEnabled: true,
+		ExtFramesEnabled:   true,
+	}, OpsCrud.Set, cli, &SetRequest{
+		Key:       key,
+		Value:     key,
+		VbucketID: defaultTestVbucketID,
+	})
+	require.NoError(t, err)
+
+	observeResp, err := syncUnaryCall(OpsCrud{
+		CollectionsEnabled: true,
+		ExtFramesEnabled:   true,
+	}, OpsCrud.ObserveSeqNo, cli, &ObserveSeqNoRequest{
+		VbucketID: defaultTestVbucketID,
+		VbUuid:    setResp.MutationToken.VbUuid,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, uint16(defaultTestVbucketID), observeResp.VbucketID)
+	assert.Equal(t, setResp.MutationToken.VbUuid, observeResp.VbUuid)
+	assert.GreaterOrEqual(t, observeResp.CurrentSeqNo, setResp.MutationToken.SeqNo)
+
+	obsResp, err := syncUnaryCall(OpsCrud{
+		CollectionsEnabled: true,
+		ExtFramesEnabled:   true,
+	}, OpsCrud.Observe, cli, &ObserveRequest{
+		Key:       key,
+		VbucketID: defaultTestVbucketID,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, setResp.Cas, obsResp.Cas)
+	assert.Contains(t, []ObserveKeyState{ObserveKeyStateNotPersisted, ObserveKeyStatePersisted}, obsResp.KeyState)
+}
+
+func TestOpsCrudObserveSeqNoFailoverDecode(t *testing.T) {
+	d := &testCaptureDispatcher{}
+
+	var observeResp *ObserveSeqNoResponse
+	_, err := OpsCrud{}.ObserveSeqNo(d, &ObserveSeqNoRequest{
+		VbucketID: 4,
+		VbUuid:    77,
+	}, func(resp *ObserveSeqNoResponse, err error) {
+		require.NoError(t, err)
+		observeResp = resp
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, OpCodeObserveSeqNo, d.Packet.OpCode)
+	assert.Equal(t, uint64(77), binary.BigEndian.Uint64(d.Packet.Value))
+
+	value := make([]byte, 43)
+	value[0] = 1
+	binary.BigEndian.PutUint16(value[1:], 4)
+	binary.BigEndian.PutUint64(value[3:], 88)
+	binary.BigEndian.PutUint64(value[11:], 10)
+	binary.BigEndian.PutUint64(value[19:], 12)
+	binary.BigEndian.PutUint64(value[27:], 77)
+	binary.BigEndian.PutUint64(value[35:], 9)
+	d.Callback(&Packet{
+		Magic:  MagicRes,
+		OpCode: OpCodeObserveSeqNo,
+		Status: StatusSuccess,
+		Value:  value,
+	}, nil)
+
+	require.NotNil(t, observeResp)
+	assert.Equal(t, &ObserveSeqNoResponse{
+		VbucketID:    4,
+		VbUuid:       88,
+		PersistSeqNo: 10,
+		CurrentSeqNo: 12,
+		DidFailover:  true,
+		OldVbUuid:    77,
+		LastSeqNo:    9,
+	}, observeResp)
+}
@@ -49,8 +49,11 @@ func (o OpsCrud) RangeScanCreate(d Dispatcher, req *RangeScanCreateRequest, cb f
 			return false
 		}
 
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
 		cb(&RangeScanCreateResponse{
 			ScanUUUID: resp.Value,
+			Meta:      meta,
 		}, nil)
 		return false
 	})
@@ -119,9 +122,12 @@ func (o OpsCrud) RangeScanContinue(d Dispatcher, req *RangeScanContinueRequest,
 
 		if resp.Status == StatusRangeScanMore ||
 			resp.Status == StatusRangeScanComplete {
+			meta := o.decodeResExtFrames(resp.FramingExtras)
+
 			actionCb(&RangeScanActionResponse{
 				More:     resp.Status == StatusRangeScanMore,
 				Complete: resp.Status == StatusRangeScanComplete,
+				Meta:     meta,
 			}, nil)
 		}
 
@@ -165,7 +171,11 @@ func (o OpsCrud) RangeScanCancel(d Dispatcher, req *RangeScanCancelRequest, cb f
 			return false
 		}
 
-		cb(&RangeScanCancelResponse{}, nil)
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
+		cb(&RangeScanCancelResponse{
+			Meta: meta,
+		}, nil)
 		return false
 	})
 }
@@ -299,6 +309,8 @@ func (opts RangeScanCreateRequest) toJSON() ([]byte, error) {
 
 type RangeScanCreateResponse struct {
 	ScanUUUID []byte
+
+	Meta ResponseMeta
 }
 
 // RangeScanItem encapsulates an iterm returned during a range scan.
@@ -330,6 +342,8 @@ type RangeScanDataResponse struct {
 type RangeScanActionResponse struct {
 	More     bool
 	Complete bool
+
+	Meta ResponseMeta
 }
 
 type RangeScanCancelRequest struct {
@@ -339,7 +353,9 @@ type RangeScanCancelRequest struct {
 	OnBehalfOf string
 }
 
-type RangeScanCancelResponse struct{}
+type RangeScanCancelResponse struct {
+	Meta ResponseMeta
+}
 
 type rangeScanCreateRequestJSON struct {
 	Collection string                       `json:"collection,omitempty"`
@@ -0,0 +1,106 @@
+package memdx
+
+import (
+	"encoding/binary"
+)
+
+// VbucketState represents the state of a vbucket on a particular node.
+type VbucketState uint32
+
+const (
+	// VbucketStateAny matches vbuckets in any state.
+	VbucketStateAny = VbucketState(0x00)
+
+	// VbucketStateActive indicates the vbucket is active on the node.
+	VbucketStateActive = VbucketState(0x01)
+
+	// VbucketStateReplica indicates the vbucket is a replica on the node.
+	VbucketStateReplica = VbucketState(0x02)
+
+	// VbucketStatePending indicates the vbucket is pending on the node.
+	VbucketStatePending = VbucketState(0x03)
+
+	// VbucketStateDead indicates the vbucket is dead on the node.
+	VbucketStateDead = VbucketState(0x04)
+)
+
+type GetAllVbSeqnosRequest struct {
+	VbucketState VbucketState
+
+	// CollectionID optionally restricts the returned seqnos to the high
+	// seqno of a single collection, rather than of the whole vbucket.
+	CollectionID *uint32
+
+	OnBehalfOf string
+}
+
+type VbSeqnoEntry struct {
+	VbucketID uint16
+	SeqNo     uint64
+}
+
+type GetAllVbSeqnosResponse struct {
+	Entries []VbSeqnoEntry
+
+	Meta ResponseMeta
+}
+
+func (o OpsCrud) GetAllVbSeqnos(d Dispatcher, req *GetAllVbSeqnosRequest, cb func(*GetAllVbSeqnosResponse, error)) (PendingOp, error) {
+	reqMagic, extFramesBuf, err := o.encodeReqExtFrames(req.OnBehalfOf, 0, 0, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var extrasBuf []byte
+	if req.CollectionID != nil {
+		if !o.CollectionsEnabled {
+			return nil, ErrCollectionsNotEnabled
+		}
+
+		extrasBuf = make([]byte, 8)
+		binary.BigEndian.PutUint32(extrasBuf[0:], uint32(req.VbucketState))
+		binary.BigEndian.PutUint32(extrasBuf[4:], *req.CollectionID)
+	} else if req.VbucketState != VbucketStateAny {
+		extrasBuf = make([]byte, 4)
+		binary.BigEndian.PutUint32(extrasBuf[0:], uint32(req.VbucketState))
+	}
+
+	return d.Dispatch(&Packet{
+		Magic:         reqMagic,
+		OpCode:        OpCodeGetAllVBSeqnos,
+		FramingExtras: extFramesBuf,
+		Extras:        extrasBuf,
+	}, func(resp *Packet, err error) bool {
+		if err != nil {
+			cb(nil, err)
+			return false
+		}
+
+		if resp.Status != StatusSuccess {
+			cb(nil, OpsCrud{}.decodeCommonError(resp, d.RemoteAddr(), d.LocalAddr()))
+			return false
+		}
+
+		if len(resp.Value)%10 != 0 {
+			cb(nil, protocolError{"bad value length"})
+			return false
+		}
+
+		entries := make([]VbSeqnoEntry, len(resp.Value)/10)
+		for i := range entries {
+			entryBuf := resp.Value[i*10:]
+			entries[i] = VbSeqnoEntry{
+				VbucketID: binary.BigEndian.Uint16(entryBuf[0:]),
+				SeqNo:     binary.BigEndian.Uint64(entryBuf[2:]),
+			}
+		}
+
+		meta := o.decodeResExtFrames(resp.FramingExtras)
+
+		cb(&GetAllVbSeqnosResponse{
+			Entries: entries,
+			Meta:    meta,
+		}, nil)
+		return false
+	})
+}
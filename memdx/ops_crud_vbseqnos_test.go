@@ -0,0 +1,96 @@
+package memdx
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/couchbase/gocbcorex/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpsCrudGetAllVbSeqnosBasic(t *testing.T) {
+	testutils.SkipIfShortTest(t)
+
+	cli := createTestClient(t)
+
+	resp, err := syncUnaryCall(OpsCrud{
+		CollectionsEnabled: true,
+		ExtFramesEnabled:   true,
+	}, OpsCrud.GetAllVbSeqnos, cli, &GetAllVbSeqnosRequest{
+		VbucketState: VbucketStateActive,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Entries)
+
+	collectionID := uint32(0)
+	resp, err = syncUnaryCall(OpsCrud{
+		CollectionsEnabled: true,
+		ExtFramesEnabled:   true,
+	}, OpsCrud.GetAllVbSeqnos, cli, &GetAllVbSeqnosRequest{
+		VbucketState: VbucketStateActive,
+		CollectionID: &collectionID,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Entries)
+}
+
+func TestOpsCrudGetAllVbSeqnosEncoding(t *testing.T) {
+	d := &testCaptureDispatcher{}
+
+	_, err := OpsCrud{}.GetAllVbSeqnos(d, &GetAllVbSeqnosRequest{}, func(resp *GetAllVbSeqnosResponse, err error) {})
+	require.NoError(t, err)
+	assert.Equal(t, OpCodeGetAllVBSeqnos, d.Packet.OpCode)
+	assert.Empty(t, d.Packet.Extras)
+
+	_, err = OpsCrud{}.GetAllVbSeqnos(d, &GetAllVbSeqnosRequest{
+		VbucketState: VbucketStateActive,
+	}, func(resp *GetAllVbSeqnosResponse, err error) {})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 1}, d.Packet.Extras)
+
+	collectionID := uint32(9)
+	_, err = OpsCrud{}.GetAllVbSeqnos(d, &GetAllVbSeqnosRequest{
+		VbucketState: VbucketStateActive,
+		CollectionID: &collectionID,
+	}, func(resp *GetAllVbSeqnosResponse, err error) {})
+	assert.ErrorIs(t, err, ErrCollectionsNotEnabled)
+
+	_, err = OpsCrud{
+		CollectionsEnabled: true,
+	}.GetAllVbSeqnos(d, &GetAllVbSeqnosRequest{
+		VbucketState: VbucketStateActive,
+		CollectionID: &collectionID,
+	}, func(resp *GetAllVbSeqnosResponse, err error) {})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 1, 0, 0, 0, 9}, d.Packet.Extras)
+}
+
+func TestOpsCrudGetAllVbSeqnosDecoding(t *testing.T) {
+	d := &testCaptureDispatcher{}
+
+	var seqnosResp *GetAllVbSeqnosResponse
+	_, err := OpsCrud{}.GetAllVbSeqnos(d, &GetAllVbSeqnosRequest{}, func(resp *GetAllVbSeqnosResponse, err error) {
+		require.NoError(t, err)
+		seqnosResp = resp
+	})
+	require.NoError(t, err)
+
+	value := make([]byte, 20)
+	binary.BigEndian.PutUint16(value[0:], 3)
+	binary.BigEndian.PutUint64(value[2:], 100)
+	binary.BigEndian.PutUint16(value[10:], 7)
+	binary.BigEndian.PutUint64(value[12:], 200)
+	d.Callback(&Packet{
+		Magic:  MagicRes,
+		OpCode: OpCodeGetAllVBSeqnos,
+		Status: StatusSuccess,
+		Value:  value,
+	}, nil)
+
+	require.NotNil(t, seqnosResp)
+	assert.Equal(t, []VbSeqnoEntry{
+		{VbucketID: 3, SeqNo: 100},
+		{VbucketID: 7, SeqNo: 200},
+	}, seqnosResp.Entries)
+}
@@ -0,0 +1,552 @@
+package memdx
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// RawWriter is implemented by connections which are able to write packets
+// which do not expect a reply, such as DCP buffer acknowledgements and the
+// replies to server-initiated DCP requests.
+type RawWriter interface {
+	WritePacket(pak *Packet) error
+}
+
+type OpsDcp struct {
+	CollectionsEnabled bool
+}
+
+type DcpOpenConnectionRequest struct {
+	ConnectionName string
+	Flags          DcpConnectionFlags
+}
+
+func (o OpsDcp) DcpOpenConnection(d Dispatcher, req *DcpOpenConnectionRequest, cb func(error)) (PendingOp, error) {
+	extrasBuf := make([]byte, 8)
+	binary.BigEndian.PutUint32(extrasBuf[0:], 0)
+	binary.BigEndian.PutUint32(extrasBuf[4:], uint32(req.Flags))
+
+	return d.Dispatch(&Packet{
+		Magic:  MagicReq,
+		OpCode: OpCodeDcpOpenConnection,
+		Key:    []byte(req.ConnectionName),
+		Extras: extrasBuf,
+	}, func(resp *Packet, err error) bool {
+		if err != nil {
+			cb(err)
+			return false
+		}
+
+		if resp.Status == StatusAccessError {
+			cb(ErrAccessError)
+			return false
+		}
+
+		if resp.Status != StatusSuccess {
+			cb(OpsCore{}.decodeError(resp, d.RemoteAddr(), d.LocalAddr()))
+			return false
+		}
+
+		cb(nil)
+		return false
+	})
+}
+
+type DcpControlRequest struct {
+	Key   string
+	Value string
+}
+
+func (o OpsDcp) DcpControl(d Dispatcher, req *DcpControlRequest, cb func(error)) (PendingOp, error) {
+	return d.Dispatch(&Packet{
+		Magic:  MagicReq,
+		OpCode: OpCodeDcpControl,
+		Key:    []byte(req.Key),
+		Value:  []byte(req.Value),
+	}, func(resp *Packet, err error) bool {
+		if err != nil {
+			cb(err)
+			return false
+		}
+
+		if resp.Status != StatusSuccess {
+			cb(OpsCore{}.decodeError(resp, d.RemoteAddr(), d.LocalAddr()))
+			return false
+		}
+
+		cb(nil)
+		return false
+	})
+}
+
+type DcpGetFailoverLogRequest struct {
+	VbucketID uint16
+}
+
+type DcpGetFailoverLogResponse struct {
+	Entries []DcpFailoverEntry
+}
+
+func (o OpsDcp) DcpGetFailoverLog(d Dispatcher, req *DcpGetFailoverLogRequest, cb func(*DcpGetFailoverLogResponse, error)) (PendingOp, error) {
+	return d.Dispatch(&Packet{
+		Magic:     MagicReq,
+		OpCode:    OpCodeDcpGetFailoverLog,
+		VbucketID: req.VbucketID,
+	}, func(resp *Packet, err error) bool {
+		if err != nil {
+			cb(nil, err)
+			return false
+		}
+
+		if resp.Status != StatusSuccess {
+			cb(nil, OpsCore{}.decodeError(resp, d.RemoteAddr(), d.LocalAddr()))
+			return false
+		}
+
+		entries, err := o.decodeFailoverLog(resp.Value)
+		if err != nil {
+			cb(nil, err)
+			return false
+		}
+
+		cb(&DcpGetFailoverLogResponse{
+			Entries: entries,
+		}, nil)
+		return false
+	})
+}
+
+func (o OpsDcp) decodeFailoverLog(buf []byte) ([]DcpFailoverEntry, error) {
+	if len(buf)%16 != 0 {
+		return nil, protocolError{"invalid failover log length"}
+	}
+
+	entries := make([]DcpFailoverEntry, len(buf)/16)
+	for entryIdx := range entries {
+		entries[entryIdx] = DcpFailoverEntry{
+			VbUuid: binary.BigEndian.Uint64(buf[entryIdx*16:]),
+			SeqNo:  binary.BigEndian.Uint64(buf[entryIdx*16+8:]),
+		}
+	}
+
+	return entries, nil
+}
+
+// DcpStreamFilter specifies which collections should be included in a stream.
+type DcpStreamFilter struct {
+	ManifestUid   uint64
+	ScopeID       *uint32
+	CollectionIDs []uint32
+}
+
+func (f DcpStreamFilter) toJSON() ([]byte, error) {
+	var filterJson struct {
+		ManifestUid   string   `json:"uid,omitempty"`
+		ScopeID       string   `json:"scope,omitempty"`
+		CollectionIDs []string `json:"collections,omitempty"`
+	}
+
+	if f.ManifestUid > 0 {
+		filterJson.ManifestUid = fmt.Sprintf("%x", f.ManifestUid)
+	}
+	if f.ScopeID != nil {
+		if len(f.CollectionIDs) > 0 {
+			return nil, invalidArgError{"cannot specify both a scope and collections in a stream filter"}
+		}
+
+		filterJson.ScopeID = fmt.Sprintf("%x", *f.ScopeID)
+	}
+	for _, collectionID := range f.CollectionIDs {
+		filterJson.CollectionIDs = append(filterJson.CollectionIDs, fmt.Sprintf("%x", collectionID))
+	}
+
+	return json.Marshal(filterJson)
+}
+
+type DcpStreamReqRequest struct {
+	VbucketID      uint16
+	Flags          DcpStreamReqFlags
+	StartSeqNo     uint64
+	EndSeqNo       uint64
+	VbUuid         uint64
+	SnapStartSeqNo uint64
+	SnapEndSeqNo   uint64
+	Filter         *DcpStreamFilter
+}
+
+type DcpStreamReqResponse struct {
+	FailoverLog []DcpFailoverEntry
+}
+
+type DcpSnapshotMarkerEvent struct {
+	VbucketID    uint16
+	StartSeqNo   uint64
+	EndSeqNo     uint64
+	SnapshotType DcpSnapshotMarkerFlags
+}
+
+type DcpMutationEvent struct {
+	VbucketID    uint16
+	CollectionID uint32
+	Key          []byte
+	Value        []byte
+	Datatype     uint8
+	Cas          uint64
+	SeqNo        uint64
+	RevNo        uint64
+	Flags        uint32
+	Expiry       uint32
+	LockTime     uint32
+}
+
+type DcpDeletionEvent struct {
+	VbucketID    uint16
+	CollectionID uint32
+	Key          []byte
+	Value        []byte
+	Datatype     uint8
+	Cas          uint64
+	SeqNo        uint64
+	RevNo        uint64
+	DeleteTime   uint32
+}
+
+type DcpExpirationEvent struct {
+	VbucketID    uint16
+	CollectionID uint32
+	Key          []byte
+	Cas          uint64
+	SeqNo        uint64
+	RevNo        uint64
+	DeleteTime   uint32
+}
+
+type DcpSeqNoAdvancedEvent struct {
+	VbucketID uint16
+	SeqNo     uint64
+}
+
+type DcpStreamEndEvent struct {
+	VbucketID uint16
+	Reason    DcpStreamEndReason
+}
+
+// DcpStreamEventHandlers holds the handlers invoked for the events received on a
+// DCP stream.  All handlers are invoked from the connections read goroutine, and
+// a nil handler causes the corresponding events to be discarded.
+type DcpStreamEventHandlers struct {
+	SnapshotMarker func(evt *DcpSnapshotMarkerEvent)
+	Mutation       func(evt *DcpMutationEvent)
+	Deletion       func(evt *DcpDeletionEvent)
+	Expiration     func(evt *DcpExpirationEvent)
+	SeqNoAdvanced  func(evt *DcpSeqNoAdvancedEvent)
+
+	// StreamEnd is invoked exactly once after a stream was successfully opened,
+	// either with the end event sent by the server or with the error that
+	// caused the stream to terminate (such as the connection closing).
+	StreamEnd func(evt *DcpStreamEndEvent, err error)
+
+	// BytesReceived is invoked with the size of every packet received on the
+	// stream after its handler has been invoked, for use with flow control.
+	BytesReceived func(numBytes uint32)
+}
+
+func (o OpsDcp) DcpStreamReq(
+	d Dispatcher,
+	req *DcpStreamReqRequest,
+	handlers DcpStreamEventHandlers,
+	cb func(*DcpStreamReqResponse, error),
+) (PendingOp, error) {
+	extrasBuf := make([]byte, 48)
+	binary.BigEndian.PutUint32(extrasBuf[0:], uint32(req.Flags))
+	binary.BigEndian.PutUint32(extrasBuf[4:], 0)
+	binary.BigEndian.PutUint64(extrasBuf[8:], req.StartSeqNo)
+	binary.BigEndian.PutUint64(extrasBuf[16:], req.EndSeqNo)
+	binary.BigEndian.PutUint64(extrasBuf[24:], req.VbUuid)
+	binary.BigEndian.PutUint64(extrasBuf[32:], req.SnapStartSeqNo)
+	binary.BigEndian.PutUint64(extrasBuf[40:], req.SnapEndSeqNo)
+
+	var valueBuf []byte
+	var datatype uint8
+	if req.Filter != nil {
+		if !o.CollectionsEnabled {
+			return nil, ErrCollectionsNotEnabled
+		}
+
+		filterBytes, err := req.Filter.toJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		valueBuf = filterBytes
+		datatype = uint8(DatatypeFlagJSON)
+	}
+
+	streamOpen := false
+
+	return d.Dispatch(&Packet{
+		Magic:     MagicReq,
+		OpCode:    OpCodeDcpStreamReq,
+		Datatype:  datatype,
+		VbucketID: req.VbucketID,
+		Extras:    extrasBuf,
+		Value:     valueBuf,
+	}, func(resp *Packet, err error) bool {
+		if !streamOpen {
+			if err != nil {
+				cb(nil, err)
+				return false
+			}
+
+			if resp.Status == StatusRollback {
+				if len(resp.Value) != 8 {
+					cb(nil, protocolError{"invalid rollback seqno length"})
+					return false
+				}
+
+				cb(nil, DcpRollbackError{
+					RollbackSeqNo: binary.BigEndian.Uint64(resp.Value),
+				})
+				return false
+			} else if resp.Status == StatusKeyExists {
+				cb(nil, ErrDcpStreamExists)
+				return false
+			}
+
+			if resp.Status != StatusSuccess {
+				cb(nil, OpsCrud{}.decodeCommonError(resp, d.RemoteAddr(), d.LocalAddr()))
+				return false
+			}
+
+			failoverLog, err := o.decodeFailoverLog(resp.Value)
+			if err != nil {
+				cb(nil, err)
+				return false
+			}
+
+			streamOpen = true
+			cb(&DcpStreamReqResponse{
+				FailoverLog: failoverLog,
+			}, nil)
+			return true
+		}
+
+		if err != nil {
+			if handlers.StreamEnd != nil {
+				handlers.StreamEnd(nil, err)
+			}
+			return false
+		}
+
+		hasMorePackets, err := o.handleStreamPacket(resp, handlers)
+		if err != nil {
+			// once we fail to decode a packet we can no longer make any
+			// guarantees about the state of the stream, so we terminate it.
+			if handlers.StreamEnd != nil {
+				handlers.StreamEnd(nil, err)
+			}
+			return false
+		}
+
+		if handlers.BytesReceived != nil {
+			handlers.BytesReceived(packetSize(resp))
+		}
+
+		return hasMorePackets
+	})
+}
+
+func packetSize(pak *Packet) uint32 {
+	return uint32(24 + len(pak.FramingExtras) + len(pak.Extras) + len(pak.Key) + len(pak.Value))
+}
+
+func (o OpsDcp) decodeKey(key []byte) (uint32, []byte, error) {
+	if !o.CollectionsEnabled {
+		return 0, key, nil
+	}
+
+	return DecodeCollectionIDAndKey(key)
+}
+
+func (o OpsDcp) handleStreamPacket(pak *Packet, handlers DcpStreamEventHandlers) (bool, error) {
+	switch pak.OpCode {
+	case OpCodeDcpSnapshotMarker:
+		if len(pak.Extras) != 20 {
+			return false, protocolError{"invalid snapshot marker extras length"}
+		}
+
+		if handlers.SnapshotMarker != nil {
+			handlers.SnapshotMarker(&DcpSnapshotMarkerEvent{
+				VbucketID:    pak.VbucketID,
+				StartSeqNo:   binary.BigEndian.Uint64(pak.Extras[0:]),
+				EndSeqNo:     binary.BigEndian.Uint64(pak.Extras[8:]),
+				SnapshotType: DcpSnapshotMarkerFlags(binary.BigEndian.Uint32(pak.Extras[16:])),
+			})
+		}
+		return true, nil
+	case OpCodeDcpMutation:
+		if len(pak.Extras) != 31 {
+			return false, protocolError{"invalid mutation extras length"}
+		}
+
+		collectionID, key, err := o.decodeKey(pak.Key)
+		if err != nil {
+			return false, err
+		}
+
+		if handlers.Mutation != nil {
+			handlers.Mutation(&DcpMutationEvent{
+				VbucketID:    pak.VbucketID,
+				CollectionID: collectionID,
+				Key:          key,
+				Value:        pak.Value,
+				Datatype:     pak.Datatype,
+				Cas:          pak.Cas,
+				SeqNo:        binary.BigEndian.Uint64(pak.Extras[0:]),
+				RevNo:        binary.BigEndian.Uint64(pak.Extras[8:]),
+				Flags:        binary.BigEndian.Uint32(pak.Extras[16:]),
+				Expiry:       binary.BigEndian.Uint32(pak.Extras[20:]),
+				LockTime:     binary.BigEndian.Uint32(pak.Extras[24:]),
+			})
+		}
+		return true, nil
+	case OpCodeDcpDeletion:
+		// deletions are 18 bytes normally, and 21 bytes when delete times
+		// were requested when the connection was opened.
+		var deleteTime uint32
+		if len(pak.Extras) == 21 {
+			deleteTime = binary.BigEndian.Uint32(pak.Extras[16:])
+		} else if len(pak.Extras) != 18 {
+			return false, protocolError{"invalid deletion extras length"}
+		}
+
+		collectionID, key, err := o.decodeKey(pak.Key)
+		if err != nil {
+			return false, err
+		}
+
+		if handlers.Deletion != nil {
+			handlers.Deletion(&DcpDeletionEvent{
+				VbucketID:    pak.VbucketID,
+				CollectionID: collectionID,
+				Key:          key,
+				Value:        pak.Value,
+				Datatype:     pak.Datatype,
+				Cas:          pak.Cas,
+				SeqNo:        binary.BigEndian.Uint64(pak.Extras[0:]),
+				RevNo:        binary.BigEndian.Uint64(pak.Extras[8:]),
+				DeleteTime:   deleteTime,
+			})
+		}
+		return true, nil
+	case OpCodeDcpExpiration:
+		if len(pak.Extras) != 20 {
+			return false, protocolError{"invalid expiration extras length"}
+		}
+
+		collectionID, key, err := o.decodeKey(pak.Key)
+		if err != nil {
+			return false, err
+		}
+
+		if handlers.Expiration != nil {
+			handlers.Expiration(&DcpExpirationEvent{
+				VbucketID:    pak.VbucketID,
+				CollectionID: collectionID,
+				Key:          key,
+				Cas:          pak.Cas,
+				SeqNo:        binary.BigEndian.Uint64(pak.Extras[0:]),
+				RevNo:        binary.BigEndian.Uint64(pak.Extras[8:]),
+				DeleteTime:   binary.BigEndian.Uint32(pak.Extras[16:]),
+			})
+		}
+		return true, nil
+	case OpCodeDcpSeqNoAdvanced:
+		if len(pak.Extras) != 8 {
+			return false, protocolError{"invalid seqno advanced extras length"}
+		}
+
+		if handlers.SeqNoAdvanced != nil {
+			handlers.SeqNoAdvanced(&DcpSeqNoAdvancedEvent{
+				VbucketID: pak.VbucketID,
+				SeqNo:     binary.BigEndian.Uint64(pak.Extras[0:]),
+			})
+		}
+		return true, nil
+	case OpCodeDcpStreamEnd:
+		if len(pak.Extras) != 4 {
+			return false, protocolError{"invalid stream end extras length"}
+		}
+
+		if handlers.StreamEnd != nil {
+			handlers.StreamEnd(&DcpStreamEndEvent{
+				VbucketID: pak.VbucketID,
+				Reason:    DcpStreamEndReason(binary.BigEndian.Uint32(pak.Extras[0:])),
+			}, nil)
+		}
+		return false, nil
+	}
+
+	// we intentionally ignore events that we do not understand, such as
+	// system events, since they do not affect the state of the stream.
+	return true, nil
+}
+
+type DcpCloseStreamRequest struct {
+	VbucketID uint16
+}
+
+func (o OpsDcp) DcpCloseStream(d Dispatcher, req *DcpCloseStreamRequest, cb func(error)) (PendingOp, error) {
+	return d.Dispatch(&Packet{
+		Magic:     MagicReq,
+		OpCode:    OpCodeDcpCloseStream,
+		VbucketID: req.VbucketID,
+	}, func(resp *Packet, err error) bool {
+		if err != nil {
+			cb(err)
+			return false
+		}
+
+		if resp.Status == StatusKeyNotFound {
+			cb(ErrDcpStreamNotFound)
+			return false
+		}
+
+		if resp.Status != StatusSuccess {
+			cb(OpsCore{}.decodeError(resp, d.RemoteAddr(), d.LocalAddr()))
+			return false
+		}
+
+		cb(nil)
+		return false
+	})
+}
+
+type DcpBufferAckRequest struct {
+	NumBytes uint32
+}
+
+// DcpBufferAck acknowledges that bytes received on a connection have been
+// processed. The server does not reply to buffer acknowledgements.
+func (o OpsDcp) DcpBufferAck(w RawWriter, req *DcpBufferAckRequest) error {
+	extrasBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(extrasBuf[0:], req.NumBytes)
+
+	return w.WritePacket(&Packet{
+		Magic:  MagicReq,
+		OpCode: OpCodeDcpBufferAck,
+		Extras: extrasBuf,
+	})
+}
+
+// DcpNoOpReply replies to a server-initiated DCP NOOP, which the server uses
+// to verify that the connection is still alive when no-op is enabled.
+func (o OpsDcp) DcpNoOpReply(w RawWriter, req *Packet) error {
+	return w.WritePacket(&Packet{
+		Magic:  MagicRes,
+		OpCode: OpCodeDcpNoOp,
+		Opaque: req.Opaque,
+		Status: StatusSuccess,
+	})
+}
@@ -0,0 +1,286 @@
+package memdx
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCaptureDispatcher records the last dispatched packet and its callback so
+// that tests can feed responses back to the op.
+type testCaptureDispatcher struct {
+	Packet   *Packet
+	Callback DispatchCallback
+}
+
+func (t *testCaptureDispatcher) Dispatch(packet *Packet, callback DispatchCallback) (PendingOp, error) {
+	t.Packet = packet
+	t.Callback = callback
+	return pendingOpNoop{}, nil
+}
+
+func (t *testCaptureDispatcher) LocalAddr() string {
+	return "localaddr"
+}
+
+func (t *testCaptureDispatcher) RemoteAddr() string {
+	return "remoteaddr"
+}
+
+func TestOpsDcpStreamReqEvents(t *testing.T) {
+	d := &testCaptureDispatcher{}
+
+	var mutations []*DcpMutationEvent
+	var snapshots []*DcpSnapshotMarkerEvent
+	var endEvt *DcpStreamEndEvent
+	var numBytes uint32
+	var streamResp *DcpStreamReqResponse
+
+	_, err := OpsDcp{
+		CollectionsEnabled: true,
+	}.DcpStreamReq(d, &DcpStreamReqRequest{
+		VbucketID:  12,
+		StartSeqNo: 4,
+		EndSeqNo:   0xffffffffffffffff,
+		VbUuid:     99,
+	}, DcpStreamEventHandlers{
+		SnapshotMarker: func(evt *DcpSnapshotMarkerEvent) {
+			snapshots = append(snapshots, evt)
+		},
+		Mutation: func(evt *DcpMutationEvent) {
+			mutations = append(mutations, evt)
+		},
+		StreamEnd: func(evt *DcpStreamEndEvent, err error) {
+			require.NoError(t, err)
+			endEvt = evt
+		},
+		BytesReceived: func(n uint32) {
+			numBytes += n
+		},
+	}, func(resp *DcpStreamReqResponse, err error) {
+		require.NoError(t, err)
+		streamResp = resp
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, OpCodeDcpStreamReq, d.Packet.OpCode)
+	assert.Equal(t, uint16(12), d.Packet.VbucketID)
+	require.Len(t, d.Packet.Extras, 48)
+	assert.Equal(t, uint64(4), binary.BigEndian.Uint64(d.Packet.Extras[8:]))
+	assert.Equal(t, uint64(99), binary.BigEndian.Uint64(d.Packet.Extras[24:]))
+
+	failoverLog := make([]byte, 16)
+	binary.BigEndian.PutUint64(failoverLog[0:], 99)
+	binary.BigEndian.PutUint64(failoverLog[8:], 0)
+	assert.True(t, d.Callback(&Packet{
+		Magic:  MagicRes,
+		OpCode: OpCodeDcpStreamReq,
+		Status: StatusSuccess,
+		Value:  failoverLog,
+	}, nil))
+	require.NotNil(t, streamResp)
+	assert.Equal(t, []DcpFailoverEntry{{VbUuid: 99, SeqNo: 0}}, streamResp.FailoverLog)
+
+	snapExtras := make([]byte, 20)
+	binary.BigEndian.PutUint64(snapExtras[0:], 5)
+	binary.BigEndian.PutUint64(snapExtras[8:], 10)
+	binary.BigEndian.PutUint32(snapExtras[16:], uint32(DcpSnapshotMarkerFlagsMemory))
+	assert.True(t, d.Callback(&Packet{
+		Magic:     MagicReq,
+		OpCode:    OpCodeDcpSnapshotMarker,
+		VbucketID: 12,
+		Extras:    snapExtras,
+	}, nil))
+
+	mutationExtras := make([]byte, 31)
+	binary.BigEndian.PutUint64(mutationExtras[0:], 5)
+	binary.BigEndian.PutUint64(mutationExtras[8:], 1)
+	binary.BigEndian.PutUint32(mutationExtras[16:], 0x01000000)
+	mutationKey, _ := AppendCollectionIDAndKey(8, []byte("key"), nil)
+	assert.True(t, d.Callback(&Packet{
+		Magic:     MagicReq,
+		OpCode:    OpCodeDcpMutation,
+		VbucketID: 12,
+		Cas:       1234,
+		Extras:    mutationExtras,
+		Key:       mutationKey,
+		Value:     []byte("value"),
+	}, nil))
+
+	endExtras := make([]byte, 4)
+	binary.BigEndian.PutUint32(endExtras[0:], uint32(DcpStreamEndReasonStateChanged))
+	assert.False(t, d.Callback(&Packet{
+		Magic:     MagicReq,
+		OpCode:    OpCodeDcpStreamEnd,
+		VbucketID: 12,
+		Extras:    endExtras,
+	}, nil))
+
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, uint64(5), snapshots[0].StartSeqNo)
+	assert.Equal(t, uint64(10), snapshots[0].EndSeqNo)
+	assert.Equal(t, DcpSnapshotMarkerFlagsMemory, snapshots[0].SnapshotType)
+
+	require.Len(t, mutations, 1)
+	assert.Equal(t, uint32(8), mutations[0].CollectionID)
+	assert.Equal(t, []byte("key"), mutations[0].Key)
+	assert.Equal(t, []byte("value"), mutations[0].Value)
+	assert.Equal(t, uint64(1234), mutations[0].Cas)
+	assert.Equal(t, uint64(5), mutations[0].SeqNo)
+	assert.Equal(t, uint32(0x01000000), mutations[0].Flags)
+
+	require.NotNil(t, endEvt)
+	assert.Equal(t, DcpStreamEndReasonStateChanged, endEvt.Reason)
+
+	// every packet after the stream req response counts towards flow control
+	assert.Equal(t, uint32((24+20)+(24+31+len(mutationKey)+5)+(24+4)), numBytes)
+}
+
+func TestOpsDcpStreamReqRollback(t *testing.T) {
+	d := &testCaptureDispatcher{}
+
+	var streamErr error
+	_, err := OpsDcp{}.DcpStreamReq(d, &DcpStreamReqRequest{
+		VbucketID:  1,
+		StartSeqNo: 100,
+	}, DcpStreamEventHandlers{}, func(resp *DcpStreamReqResponse, err error) {
+		streamErr = err
+	})
+	require.NoError(t, err)
+
+	rollbackValue := make([]byte, 8)
+	binary.BigEndian.PutUint64(rollbackValue, 50)
+	assert.False(t, d.Callback(&Packet{
+		Magic:  MagicRes,
+		OpCode: OpCodeDcpStreamReq,
+		Status: StatusRollback,
+		Value:  rollbackValue,
+	}, nil))
+
+	assert.ErrorIs(t, streamErr, ErrDcpRollback)
+
+	var rollbackErr DcpRollbackError
+	require.True(t, errors.As(streamErr, &rollbackErr))
+	assert.Equal(t, uint64(50), rollbackErr.RollbackSeqNo)
+}
+
+func TestOpsDcpStreamReqClosedAfterOpen(t *testing.T) {
+	d := &testCaptureDispatcher{}
+
+	var endErr error
+	_, err := OpsDcp{}.DcpStreamReq(d, &DcpStreamReqRequest{
+		VbucketID: 1,
+	}, DcpStreamEventHandlers{
+		StreamEnd: func(evt *DcpStreamEndEvent, err error) {
+			assert.Nil(t, evt)
+			endErr = err
+		},
+	}, func(resp *DcpStreamReqResponse, err error) {
+		require.NoError(t, err)
+	})
+	require.NoError(t, err)
+
+	assert.True(t, d.Callback(&Packet{
+		Magic:  MagicRes,
+		OpCode: OpCodeDcpStreamReq,
+		Status: StatusSuccess,
+	}, nil))
+	assert.False(t, d.Callback(nil, ErrClosedInFlight))
+
+	assert.ErrorIs(t, endErr, ErrClosedInFlight)
+}
+
+func TestOpsDcpStreamFilterJSON(t *testing.T) {
+	filterBytes, err := DcpStreamFilter{
+		ManifestUid:   0x1a,
+		CollectionIDs: []uint32{8, 9},
+	}.toJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"uid":"1a","collections":["8","9"]}`, string(filterBytes))
+
+	scopeID := uint32(0x10)
+	_, err = DcpStreamFilter{
+		ScopeID:       &scopeID,
+		CollectionIDs: []uint32{8},
+	}.toJSON()
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestOpsDcpNoOpWithStreamOpaque(t *testing.T) {
+	cliConn, srvConn := net.Pipe()
+	defer srvConn.Close()
+	require.NoError(t, srvConn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	var cli *Client
+	cli = NewClient(&Conn{conn: cliConn}, &ClientOptions{
+		OrphanHandler: func(pak *Packet) {
+			if pak.OpCode == OpCodeDcpNoOp {
+				assert.NoError(t, OpsDcp{}.DcpNoOpReply(cli, pak))
+			}
+		},
+	})
+	defer cli.Close()
+
+	var reader PacketReader
+	var writer PacketWriter
+
+	var numSnapshots uint32
+	streamErrCh := make(chan error, 1)
+	go func() {
+		_, err := OpsDcp{}.DcpStreamReq(cli, &DcpStreamReqRequest{
+			VbucketID: 1,
+		}, DcpStreamEventHandlers{
+			SnapshotMarker: func(evt *DcpSnapshotMarkerEvent) {
+				atomic.AddUint32(&numSnapshots, 1)
+			},
+		}, func(resp *DcpStreamReqResponse, err error) {
+			streamErrCh <- err
+		})
+		if err != nil {
+			streamErrCh <- err
+		}
+	}()
+
+	streamReq := &Packet{}
+	require.NoError(t, reader.ReadPacket(srvConn, streamReq))
+	require.Equal(t, OpCodeDcpStreamReq, streamReq.OpCode)
+	require.NoError(t, writer.WritePacket(srvConn, &Packet{
+		Magic:  MagicRes,
+		OpCode: OpCodeDcpStreamReq,
+		Opaque: streamReq.Opaque,
+		Status: StatusSuccess,
+	}))
+	require.NoError(t, <-streamErrCh)
+
+	// the server picks the opaque of its no-ops, which can match the opaque
+	// of the open stream, but must still be replied to.
+	require.NoError(t, writer.WritePacket(srvConn, &Packet{
+		Magic:  MagicReq,
+		OpCode: OpCodeDcpNoOp,
+		Opaque: streamReq.Opaque,
+	}))
+
+	noOpResp := &Packet{}
+	require.NoError(t, reader.ReadPacket(srvConn, noOpResp))
+	assert.Equal(t, MagicRes, noOpResp.Magic)
+	assert.Equal(t, OpCodeDcpNoOp, noOpResp.OpCode)
+	assert.Equal(t, streamReq.Opaque, noOpResp.Opaque)
+
+	// the stream keeps receiving its events afterwards
+	require.NoError(t, writer.WritePacket(srvConn, &Packet{
+		Magic:     MagicReq,
+		OpCode:    OpCodeDcpSnapshotMarker,
+		Opaque:    streamReq.Opaque,
+		VbucketID: 1,
+		Extras:    make([]byte, 20),
+	}))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint32(&numSnapshots) == 1
+	}, time.Second, time.Millisecond)
+}
@@ -37,19 +37,23 @@ type StatsRequest struct {
 }
 
 type StatsResponse struct {
-	Key   string
-	Value string
+	Stats map[string]string
 }
 
+// Stats requests a group of statistics from the server.  The server streams
+// each statistic back as a separate packet, terminated by a packet with an
+// empty key and value, and these are collected into a single response.
 func (o OpsUtils) Stats(d Dispatcher, req *StatsRequest, cb func(*StatsResponse, error)) (PendingOp, error) {
 	reqMagic, extFramesBuf, err := o.encodeReqExtFrames(req.OnBehalfOf, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	stats := make(map[string]string)
+
 	return d.Dispatch(&Packet{
 		Magic:         reqMagic,
-		OpCode:        OpCodeSASLAuth,
+		OpCode:        OpCodeStat,
 		Key:           []byte(req.GroupName),
 		FramingExtras: extFramesBuf,
 	}, func(resp *Packet, err error) bool {
@@ -63,15 +67,14 @@ func (o OpsUtils) Stats(d Dispatcher, req *StatsRequest, cb func(*StatsResponse,
 			return false
 		}
 
-		if resp.Key == nil && resp.Value == nil {
-			cb(nil, nil)
+		if len(resp.Key) == 0 && len(resp.Value) == 0 {
+			cb(&StatsResponse{
+				Stats: stats,
+			}, nil)
 			return false
 		}
 
-		cb(&StatsResponse{
-			Key:   string(resp.Key),
-			Value: string(resp.Value),
-		}, nil)
+		stats[string(resp.Key)] = string(resp.Value)
 		return true
 	})
 }
@@ -135,3 +138,33 @@ func (o OpsUtils) GetCollectionID(d Dispatcher, req *GetCollectionIDRequest, cb
 		return false
 	})
 }
+
+type GetCollectionsManifestRequest struct {
+	OnBehalfOf string
+}
+
+func (o OpsUtils) GetCollectionsManifest(d Dispatcher, req *GetCollectionsManifestRequest, cb func([]byte, error)) (PendingOp, error) {
+	reqMagic, extFramesBuf, err := o.encodeReqExtFrames(req.OnBehalfOf, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Dispatch(&Packet{
+		Magic:         reqMagic,
+		OpCode:        OpCodeCollectionsGetManifest,
+		FramingExtras: extFramesBuf,
+	}, func(resp *Packet, err error) bool {
+		if err != nil {
+			cb(nil, err)
+			return false
+		}
+
+		if resp.Status != StatusSuccess {
+			cb(nil, OpsCore{}.decodeError(resp, d.RemoteAddr(), d.LocalAddr()))
+			return false
+		}
+
+		cb(resp.Value, nil)
+		return false
+	})
+}
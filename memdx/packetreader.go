@@ -29,7 +29,8 @@ func (pr *PacketReader) ReadPacket(r io.Reader, pak *Packet) error {
 
 	var extFramesLen int
 	var keyLen int
-	if pak.Magic == MagicReq || pak.Magic == MagicRes {
+	if pak.Magic == MagicReq || pak.Magic == MagicRes ||
+		pak.Magic == MagicServerReq || pak.Magic == MagicServerRes {
 		extFramesLen = 0
 		keyLen = int(binary.BigEndian.Uint16(headerBuf[2:]))
 	} else if pak.Magic == MagicReqExt || pak.Magic == MagicResExt {
@@ -43,10 +44,10 @@ func (pr *PacketReader) ReadPacket(r io.Reader, pak *Packet) error {
 
 	pak.Datatype = headerBuf[5]
 
-	if pak.Magic == MagicReq || pak.Magic == MagicReqExt {
+	if pak.Magic == MagicReq || pak.Magic == MagicReqExt || pak.Magic == MagicServerReq {
 		pak.VbucketID = binary.BigEndian.Uint16(headerBuf[6:])
 		pak.Status = 0
-	} else if pak.Magic == MagicRes || pak.Magic == MagicResExt {
+	} else if pak.Magic == MagicRes || pak.Magic == MagicResExt || pak.Magic == MagicServerRes {
 		pak.VbucketID = 0
 		pak.Status = Status(binary.BigEndian.Uint16(headerBuf[6:]))
 	} else {
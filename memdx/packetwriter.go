@@ -24,7 +24,8 @@ func (pw *PacketWriter) WritePacket(w io.Writer, pak *Packet) error {
 	headerBuf[0] = uint8(pak.Magic)
 	headerBuf[1] = uint8(pak.OpCode)
 
-	if pak.Magic == MagicReq || pak.Magic == MagicRes {
+	if pak.Magic == MagicReq || pak.Magic == MagicRes ||
+		pak.Magic == MagicServerReq || pak.Magic == MagicServerRes {
 		if extFramesLen > 0 {
 			return protocolError{"cannot use framing extras with non-ext packets"}
 		}
@@ -56,13 +57,13 @@ func (pw *PacketWriter) WritePacket(w io.Writer, pak *Packet) error {
 
 	headerBuf[5] = pak.Datatype
 
-	if pak.Magic == MagicReq || pak.Magic == MagicReqExt {
+	if pak.Magic == MagicReq || pak.Magic == MagicReqExt || pak.Magic == MagicServerReq {
 		if pak.Status != 0 {
 			return protocolError{"cannot specify status in a request packet"}
 		}
 
 		binary.BigEndian.PutUint16(headerBuf[6:], pak.VbucketID)
-	} else if pak.Magic == MagicRes || pak.Magic == MagicResExt {
+	} else if pak.Magic == MagicRes || pak.Magic == MagicResExt || pak.Magic == MagicServerRes {
 		if pak.VbucketID != 0 {
 			return protocolError{"cannot specify vbucket in a response packet"}
 		}
@@ -0,0 +1,90 @@
+package memdx
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ResponseMeta contains the per-operation metadata the server attaches to a
+// response using framing extras.  Fields are left as their zero value when the
+// server did not include the corresponding frame.
+type ResponseMeta struct {
+	ServerDuration   time.Duration
+	ReadUnits        uint16
+	WriteUnits       uint16
+	ThrottleDuration time.Duration
+}
+
+// DecodeServerDuration decodes the 2-byte encoded duration format used by the
+// server duration and throttle duration frames.
+func DecodeServerDuration(encoded uint16) time.Duration {
+	return time.Duration(math.Pow(float64(encoded), 1.74)/2) * time.Microsecond
+}
+
+// ParseResponseMeta decodes the metadata from the framing extras of a
+// response packet which was not handled by one of the operation decoders.
+func ParseResponseMeta(pak *Packet) (ResponseMeta, error) {
+	return parseResExtFrames(pak.FramingExtras)
+}
+
+// decodeResExtFrames decodes the metadata of a response.  The metadata is
+// informational only, so if it is malformed it is discarded rather than
+// failing an operation which the server otherwise completed.
+func (o OpsCrud) decodeResExtFrames(buf []byte) ResponseMeta {
+	meta, err := parseResExtFrames(buf)
+	if err != nil {
+		if o.Logger != nil {
+			o.Logger.Debug("discarding malformed response metadata", zap.Error(err))
+		}
+		return ResponseMeta{}
+	}
+
+	return meta
+}
+
+func parseResExtFrames(buf []byte) (ResponseMeta, error) {
+	var meta ResponseMeta
+	if len(buf) == 0 {
+		return meta, nil
+	}
+
+	var frameErr error
+	err := IterExtFrames(buf, func(code ExtFrameCode, body []byte) {
+		switch code {
+		case ExtFrameCodeResServerDuration,
+			ExtFrameCodeResReadUnits,
+			ExtFrameCodeResWriteUnits,
+			ExtFrameCodeResThrottleDuration:
+			if len(body) != 2 {
+				frameErr = protocolError{"bad " + code.StringReqRes(false) + " frame length"}
+				return
+			}
+		default:
+			// unknown frames are ignored for forwards compatibility
+			return
+		}
+
+		value := binary.BigEndian.Uint16(body)
+		switch code {
+		case ExtFrameCodeResServerDuration:
+			meta.ServerDuration = DecodeServerDuration(value)
+		case ExtFrameCodeResReadUnits:
+			meta.ReadUnits = value
+		case ExtFrameCodeResWriteUnits:
+			meta.WriteUnits = value
+		case ExtFrameCodeResThrottleDuration:
+			meta.ThrottleDuration = DecodeServerDuration(value)
+		}
+	})
+	if err != nil {
+		return ResponseMeta{}, err
+	}
+	if frameErr != nil {
+		return ResponseMeta{}, frameErr
+	}
+
+	return meta, nil
+}
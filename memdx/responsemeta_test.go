@@ -0,0 +1,103 @@
+package memdx
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeServerDuration(t *testing.T) {
+	assert.Equal(t, time.Duration(0), DecodeServerDuration(0))
+	// 100^1.74/2 = 1509.98us
+	assert.Equal(t, 1509*time.Microsecond, DecodeServerDuration(100))
+}
+
+func TestOpsCrudDecodeResExtFrames(t *testing.T) {
+	encodeUint16 := func(v uint16) []byte {
+		buf := make([]byte, 2)
+		binary.BigEndian.PutUint16(buf, v)
+		return buf
+	}
+
+	var buf []byte
+	buf, _ = AppendExtFrame(ExtFrameCodeResServerDuration, encodeUint16(100), buf)
+	buf, _ = AppendExtFrame(ExtFrameCodeResReadUnits, encodeUint16(3), buf)
+	buf, _ = AppendExtFrame(ExtFrameCodeResWriteUnits, encodeUint16(5), buf)
+	buf, _ = AppendExtFrame(ExtFrameCodeResThrottleDuration, encodeUint16(100), buf)
+	// unknown frames should be skipped
+	buf, _ = AppendExtFrame(ExtFrameCode(9), []byte{1, 2, 3}, buf)
+
+	meta := OpsCrud{}.decodeResExtFrames(buf)
+	assert.Equal(t, ResponseMeta{
+		ServerDuration:   1509 * time.Microsecond,
+		ReadUnits:        3,
+		WriteUnits:       5,
+		ThrottleDuration: 1509 * time.Microsecond,
+	}, meta)
+
+	badBuf, _ := AppendExtFrame(ExtFrameCodeResReadUnits, []byte{1}, nil)
+	_, err := ParseResponseMeta(&Packet{FramingExtras: badBuf})
+	assert.ErrorIs(t, err, ErrProtocol)
+
+	// malformed metadata is discarded rather than failing the operation
+	assert.Equal(t, ResponseMeta{}, OpsCrud{}.decodeResExtFrames(badBuf))
+}
+
+func TestOpsCrudGetResponseMeta(t *testing.T) {
+	d := &testCaptureDispatcher{}
+
+	var getResp *GetResponse
+	_, err := OpsCrud{}.Get(d, &GetRequest{
+		Key: []byte("key"),
+	}, func(resp *GetResponse, err error) {
+		require.NoError(t, err)
+		getResp = resp
+	})
+	require.NoError(t, err)
+
+	framingExtras, _ := AppendExtFrame(ExtFrameCodeResReadUnits, []byte{0, 2}, nil)
+	d.Callback(&Packet{
+		Magic:         MagicResExt,
+		OpCode:        OpCodeGet,
+		Status:        StatusSuccess,
+		Extras:        make([]byte, 4),
+		FramingExtras: framingExtras,
+		Value:         []byte("value"),
+	}, nil)
+
+	require.NotNil(t, getResp)
+	assert.Equal(t, []byte("value"), getResp.Value)
+	assert.Equal(t, uint16(2), getResp.Meta.ReadUnits)
+}
+
+func TestOpsCrudGetMalformedResponseMeta(t *testing.T) {
+	d := &testCaptureDispatcher{}
+
+	var getResp *GetResponse
+	var getErr error
+	_, err := OpsCrud{}.Get(d, &GetRequest{
+		Key: []byte("key"),
+	}, func(resp *GetResponse, err error) {
+		getResp = resp
+		getErr = err
+	})
+	require.NoError(t, err)
+
+	framingExtras, _ := AppendExtFrame(ExtFrameCodeResReadUnits, []byte{2}, nil)
+	d.Callback(&Packet{
+		Magic:         MagicResExt,
+		OpCode:        OpCodeGet,
+		Status:        StatusSuccess,
+		Extras:        make([]byte, 4),
+		FramingExtras: framingExtras,
+		Value:         []byte("value"),
+	}, nil)
+
+	require.NoError(t, getErr)
+	require.NotNil(t, getResp)
+	assert.Equal(t, []byte("value"), getResp.Value)
+	assert.Equal(t, ResponseMeta{}, getResp.Meta)
+}
@@ -0,0 +1,28 @@
+package memdx
+
+import "encoding/hex"
+
+// ServerOpCode represents the specific command of a request which was
+// initiated by the server over a duplex connection.  These occupy a separate
+// space from the client-initiated OpCode values.
+type ServerOpCode uint8
+
+const (
+	ServerOpCodeClustermapChangeNotification = ServerOpCode(0x01)
+	ServerOpCodeAuthenticate                 = ServerOpCode(0x02)
+	ServerOpCodeActiveExternalUsers          = ServerOpCode(0x03)
+)
+
+// String returns the string representation of the ServerOpCode.
+func (c ServerOpCode) String() string {
+	switch c {
+	case ServerOpCodeClustermapChangeNotification:
+		return "ClustermapChangeNotification"
+	case ServerOpCodeAuthenticate:
+		return "Authenticate"
+	case ServerOpCodeActiveExternalUsers:
+		return "ActiveExternalUsers"
+	}
+
+	return "x" + hex.EncodeToString([]byte{byte(c)})
+}
@@ -0,0 +1,151 @@
+package gocbcorex
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/couchbase/gocbcorex/cbqueryx"
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+// MeterCounter is a metric which only ever increases, such as a number of
+// retries or bytes.
+type MeterCounter interface {
+	Add(value uint64)
+}
+
+// MeterHistogram is a metric recording the distribution of a value, such as
+// the latency of operations.
+type MeterHistogram interface {
+	Record(value float64)
+}
+
+// Meter creates the metrics recorded by the SDK.  It maps directly onto an
+// OpenTelemetry Meter: each distinct set of attributes identifies a separate
+// series of the named metric.  Durations are recorded in seconds.
+type Meter interface {
+	Counter(name string, attributes map[string]string) MeterCounter
+	Histogram(name string, attributes map[string]string) MeterHistogram
+}
+
+const (
+	meterNameOperationDuration = "db.couchbase.operation.duration"
+	meterNameOperationRetries  = "db.couchbase.operation.retries"
+	meterNameOperationErrors   = "db.couchbase.operation.errors"
+	meterNamePoolWaitDuration  = "db.couchbase.pool.wait_duration"
+	meterNameBytesSent         = "db.couchbase.network.bytes_sent"
+	meterNameBytesReceived     = "db.couchbase.network.bytes_received"
+	meterNameConnectionsOpened = "db.couchbase.connections.opened"
+	meterNameConnectionsClosed = "db.couchbase.connections.closed"
+
+	meterAttribService     = "db.couchbase.service"
+	meterAttribOperation   = "db.operation"
+	meterAttribOutcome     = "outcome"
+	meterAttribRetryReason = "db.couchbase.retry_reason"
+	meterAttribErrorClass  = "error.type"
+	meterAttribNetPeerName = "net.peer.name"
+
+	meterServiceKv         = "kv"
+	meterServiceQuery      = "query"
+	meterServiceManagement = "management"
+)
+
+type operationMeterCtxKey struct{}
+
+// operationMeter records the metrics of a single SDK operation.  A nil
+// operationMeter records nothing.
+type operationMeter struct {
+	meter     Meter
+	service   string
+	operation string
+}
+
+// withOperationMeter returns a context which causes the orchestrators to
+// record the metrics of the operation it is used for.  If meter is nil, ctx is
+// returned unchanged.
+func withOperationMeter(ctx context.Context, meter Meter, service, operation string) context.Context {
+	if meter == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, operationMeterCtxKey{}, &operationMeter{
+		meter:     meter,
+		service:   service,
+		operation: operation,
+	})
+}
+
+func operationMeterFromContext(ctx context.Context) *operationMeter {
+	opMeter, _ := ctx.Value(operationMeterCtxKey{}).(*operationMeter)
+	return opMeter
+}
+
+func (m *operationMeter) attributes() map[string]string {
+	return map[string]string{
+		meterAttribService:   m.service,
+		meterAttribOperation: m.operation,
+	}
+}
+
+func (m *operationMeter) RecordOperation(duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	attribs := m.attributes()
+	if err != nil {
+		errAttribs := m.attributes()
+		errAttribs[meterAttribErrorClass] = meterErrorClass(err)
+		m.meter.Counter(meterNameOperationErrors, errAttribs).Add(1)
+
+		attribs[meterAttribOutcome] = "error"
+	} else {
+		attribs[meterAttribOutcome] = "success"
+	}
+
+	m.meter.Histogram(meterNameOperationDuration, attribs).Record(duration.Seconds())
+}
+
+func (m *operationMeter) RecordRetry(err error) {
+	if m == nil {
+		return
+	}
+
+	reason, _ := retryReasonForError(err)
+
+	attribs := m.attributes()
+	attribs[meterAttribRetryReason] = reason.String()
+	m.meter.Counter(meterNameOperationRetries, attribs).Add(1)
+}
+
+func (m *operationMeter) RecordPoolWait(duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.meter.Histogram(meterNamePoolWaitDuration, m.attributes()).Record(duration.Seconds())
+}
+
+// meterErrorClass groups errors into a small number of classes, so that the
+// number of series recorded for errors stays bounded.
+func meterErrorClass(err error) string {
+	var ambiguousErr AmbiguousOperationError
+	var serverErr memdx.ServerError
+	var queryErr *cbqueryx.QueryError
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &ambiguousErr):
+		return "ambiguous"
+	case errors.Is(err, ErrCircuitBreakerOpen), errors.Is(err, ErrServiceNotAvailable):
+		return "unavailable"
+	case errors.As(err, &serverErr), errors.As(err, &queryErr):
+		return "server"
+	}
+
+	return "other"
+}
@@ -0,0 +1,269 @@
+package gocbcorex
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/exp/slices"
+)
+
+// defaultAggregatingMeterBuckets are suited to durations in seconds, spanning
+// from fast KV operations to slow queries.
+var defaultAggregatingMeterBuckets = []float64{
+	0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025,
+	0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 75,
+}
+
+type AggregatingMeterOptions struct {
+	// HistogramBuckets are the upper bounds of the buckets which histogram
+	// values are counted into, defaults to buckets suited to durations.
+	HistogramBuckets []float64
+}
+
+// AggregatingMeter is a Meter which keeps the totals of the metrics recorded
+// to it in memory, so that they can be exposed to Prometheus.
+type AggregatingMeter struct {
+	buckets []float64
+
+	lock       sync.Mutex
+	counters   map[string]*aggregatingCounter
+	histograms map[string]*aggregatingHistogram
+}
+
+var _ Meter = (*AggregatingMeter)(nil)
+
+func NewAggregatingMeter(opts *AggregatingMeterOptions) *AggregatingMeter {
+	if opts == nil {
+		opts = &AggregatingMeterOptions{}
+	}
+
+	buckets := opts.HistogramBuckets
+	if len(buckets) == 0 {
+		buckets = defaultAggregatingMeterBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+
+	return &AggregatingMeter{
+		buckets:    buckets,
+		counters:   make(map[string]*aggregatingCounter),
+		histograms: make(map[string]*aggregatingHistogram),
+	}
+}
+
+type aggregatingSeries struct {
+	name   string
+	labels string
+}
+
+type aggregatingCounter struct {
+	aggregatingSeries
+	value uint64
+}
+
+func (c *aggregatingCounter) Add(value uint64) {
+	atomic.AddUint64(&c.value, value)
+}
+
+type aggregatingHistogram struct {
+	aggregatingSeries
+	buckets []float64
+
+	lock   sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *aggregatingHistogram) Record(value float64) {
+	bucketIdx, _ := slices.BinarySearch(h.buckets, value)
+
+	h.lock.Lock()
+	if bucketIdx < len(h.counts) {
+		h.counts[bucketIdx]++
+	}
+	h.sum += value
+	h.count++
+	h.lock.Unlock()
+}
+
+func (m *AggregatingMeter) Counter(name string, attributes map[string]string) MeterCounter {
+	series := newAggregatingSeries(prometheusCounterName(name), attributes)
+	seriesKey := series.name + series.labels
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	counter := m.counters[seriesKey]
+	if counter == nil {
+		counter = &aggregatingCounter{
+			aggregatingSeries: series,
+		}
+		m.counters[seriesKey] = counter
+	}
+
+	return counter
+}
+
+func (m *AggregatingMeter) Histogram(name string, attributes map[string]string) MeterHistogram {
+	series := newAggregatingSeries(prometheusName(name), attributes)
+	seriesKey := series.name + series.labels
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	histogram := m.histograms[seriesKey]
+	if histogram == nil {
+		histogram = &aggregatingHistogram{
+			aggregatingSeries: series,
+			buckets:           m.buckets,
+			counts:            make([]uint64, len(m.buckets)),
+		}
+		m.histograms[seriesKey] = histogram
+	}
+
+	return histogram
+}
+
+// WritePrometheus writes the current totals of every metric in the Prometheus
+// text exposition format.
+func (m *AggregatingMeter) WritePrometheus(w io.Writer) error {
+	m.lock.Lock()
+	counters := make([]*aggregatingCounter, 0, len(m.counters))
+	for _, counter := range m.counters {
+		counters = append(counters, counter)
+	}
+	histograms := make([]*aggregatingHistogram, 0, len(m.histograms))
+	for _, histogram := range m.histograms {
+		histograms = append(histograms, histogram)
+	}
+	m.lock.Unlock()
+
+	slices.SortFunc(counters, func(a, b *aggregatingCounter) bool {
+		return a.less(&b.aggregatingSeries)
+	})
+	slices.SortFunc(histograms, func(a, b *aggregatingHistogram) bool {
+		return a.less(&b.aggregatingSeries)
+	})
+
+	bw := bufio.NewWriter(w)
+
+	lastName := ""
+	for _, counter := range counters {
+		if counter.name != lastName {
+			writePrometheusType(bw, counter.name, "counter")
+			lastName = counter.name
+		}
+
+		writePrometheusSample(bw, counter.name, counter.labels, "",
+			strconv.FormatUint(atomic.LoadUint64(&counter.value), 10))
+	}
+
+	lastName = ""
+	for _, histogram := range histograms {
+		if histogram.name != lastName {
+			writePrometheusType(bw, histogram.name, "histogram")
+			lastName = histogram.name
+		}
+
+		histogram.lock.Lock()
+		counts := slices.Clone(histogram.counts)
+		sum := histogram.sum
+		count := histogram.count
+		histogram.lock.Unlock()
+
+		// prometheus buckets are cumulative, each counting every value less
+		// than or equal to its upper bound.
+		var cumulativeCount uint64
+		for bucketIdx, bound := range histogram.buckets {
+			cumulativeCount += counts[bucketIdx]
+			writePrometheusSample(bw, histogram.name+"_bucket", histogram.labels,
+				`le="`+formatPrometheusFloat(bound)+`"`, strconv.FormatUint(cumulativeCount, 10))
+		}
+		writePrometheusSample(bw, histogram.name+"_bucket", histogram.labels,
+			`le="+Inf"`, strconv.FormatUint(count, 10))
+		writePrometheusSample(bw, histogram.name+"_sum", histogram.labels, "", formatPrometheusFloat(sum))
+		writePrometheusSample(bw, histogram.name+"_count", histogram.labels, "", strconv.FormatUint(count, 10))
+	}
+
+	return bw.Flush()
+}
+
+func (s *aggregatingSeries) less(o *aggregatingSeries) bool {
+	if s.name != o.name {
+		return s.name < o.name
+	}
+	return s.labels < o.labels
+}
+
+func newAggregatingSeries(name string, attributes map[string]string) aggregatingSeries {
+	labelNames := make([]string, 0, len(attributes))
+	for attribName := range attributes {
+		labelNames = append(labelNames, attribName)
+	}
+	slices.Sort(labelNames)
+
+	labels := make([]string, len(labelNames))
+	for labelIdx, attribName := range labelNames {
+		labels[labelIdx] = prometheusName(attribName) + `="` + escapePrometheusLabelValue(attributes[attribName]) + `"`
+	}
+
+	return aggregatingSeries{
+		name:   name,
+		labels: strings.Join(labels, ","),
+	}
+}
+
+func writePrometheusType(w *bufio.Writer, name, metricType string) {
+	_, _ = w.WriteString("# TYPE " + name + " " + metricType + "\n")
+}
+
+func writePrometheusSample(w *bufio.Writer, name, labels, extraLabel, value string) {
+	if extraLabel != "" {
+		if labels != "" {
+			labels += ","
+		}
+		labels += extraLabel
+	}
+
+	_, _ = w.WriteString(name)
+	if labels != "" {
+		_, _ = w.WriteString("{" + labels + "}")
+	}
+	_, _ = w.WriteString(" " + value + "\n")
+}
+
+// prometheusName converts a metric or attribute name into one which is valid
+// for Prometheus, which does not allow the dots OpenTelemetry names use.
+func prometheusName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func prometheusCounterName(name string) string {
+	promName := prometheusName(name)
+	if !strings.HasSuffix(promName, "_total") {
+		promName += "_total"
+	}
+	return promName
+}
+
+func escapePrometheusLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatPrometheusFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,85 @@
+package gocbcorex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+func TestAggregatingMeterWritesPrometheus(t *testing.T) {
+	meter := NewAggregatingMeter(&AggregatingMeterOptions{
+		HistogramBuckets: []float64{0.5, 0.1},
+	})
+
+	attribs := map[string]string{
+		"db.operation":         "Get",
+		"db.couchbase.service": "kv",
+	}
+	meter.Counter("db.couchbase.operation.retries", attribs).Add(2)
+	meter.Counter("db.couchbase.operation.retries", attribs).Add(1)
+	meter.Counter("db.couchbase.operation.retries", map[string]string{"note": "a \"quoted\" value"}).Add(1)
+	meter.Histogram("db.couchbase.operation.duration", attribs).Record(0.05)
+	meter.Histogram("db.couchbase.operation.duration", attribs).Record(0.25)
+	meter.Histogram("db.couchbase.operation.duration", attribs).Record(2)
+
+	var buf bytes.Buffer
+	require.NoError(t, meter.WritePrometheus(&buf))
+
+	assert.Equal(t, strings.Join([]string{
+		`# TYPE db_couchbase_operation_retries_total counter`,
+		`db_couchbase_operation_retries_total{db_couchbase_service="kv",db_operation="Get"} 3`,
+		`db_couchbase_operation_retries_total{note="a \"quoted\" value"} 1`,
+		`# TYPE db_couchbase_operation_duration histogram`,
+		`db_couchbase_operation_duration_bucket{db_couchbase_service="kv",db_operation="Get",le="0.1"} 1`,
+		`db_couchbase_operation_duration_bucket{db_couchbase_service="kv",db_operation="Get",le="0.5"} 2`,
+		`db_couchbase_operation_duration_bucket{db_couchbase_service="kv",db_operation="Get",le="+Inf"} 3`,
+		`db_couchbase_operation_duration_sum{db_couchbase_service="kv",db_operation="Get"} 2.3`,
+		`db_couchbase_operation_duration_count{db_couchbase_service="kv",db_operation="Get"} 3`,
+		``,
+	}, "\n"), buf.String())
+}
+
+func TestOrchestrateMemdRetriesRecordsMetrics(t *testing.T) {
+	meter := NewAggregatingMeter(nil)
+
+	mockMgr := &RetryManagerMock{
+		NewRetryControllerFunc: func() RetryController {
+			return &RetryControllerMock{
+				ShouldRetryFunc: func(err error) (time.Duration, bool) {
+					return 0, !errors.Is(err, memdx.ErrDocNotFound)
+				},
+			}
+		},
+	}
+
+	errs := []error{
+		memdx.ServerError{Status: memdx.StatusTmpFail},
+		memdx.ServerError{Cause: memdx.ErrDocNotFound, Status: memdx.StatusKeyNotFound},
+	}
+	fnCalls := 0
+	ctx := withOperationMeter(context.Background(), meter, meterServiceKv, memdx.OpCodeGet.String())
+	_, err := OrchestrateMemdRetries(ctx, mockMgr, func() (int, error) {
+		fnCalls++
+		return 0, errs[fnCalls-1]
+	})
+	require.ErrorIs(t, err, memdx.ErrDocNotFound)
+
+	var buf bytes.Buffer
+	require.NoError(t, meter.WritePrometheus(&buf))
+	output := buf.String()
+
+	assert.Contains(t, output,
+		`db_couchbase_operation_retries_total{db_couchbase_retry_reason="temporary_failure",db_couchbase_service="kv",db_operation="Get"} 1`)
+	assert.Contains(t, output,
+		`db_couchbase_operation_errors_total{db_couchbase_service="kv",db_operation="Get",error_type="server"} 1`)
+	assert.Contains(t, output,
+		`db_couchbase_operation_duration_count{db_couchbase_service="kv",db_operation="Get",outcome="error"} 1`)
+}
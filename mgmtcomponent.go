@@ -3,6 +3,7 @@ package gocbcorex
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/couchbase/gocbcorex/cbmgmtx"
 	"go.uber.org/zap"
@@ -11,7 +12,10 @@ import (
 type MgmtComponent struct {
 	baseHttpComponent
 
-	logger *zap.Logger
+	logger     *zap.Logger
+	tracer     RequestTracer
+	meter      Meter
+	thresholds *ThresholdLogger
 }
 
 type MgmtComponentConfig struct {
@@ -21,8 +25,11 @@ type MgmtComponentConfig struct {
 }
 
 type MgmtComponentOptions struct {
-	Logger    *zap.Logger
-	UserAgent string
+	Logger          *zap.Logger
+	Tracer          RequestTracer
+	Meter           Meter
+	ThresholdLogger *ThresholdLogger
+	UserAgent       string
 }
 
 func OrchestrateMgmtEndpoint[RespT any](
@@ -41,6 +48,8 @@ func OrchestrateMgmtEndpoint[RespT any](
 		return emptyResp, ErrServiceNotAvailable
 	}
 
+	thresholdLogOperationFromContext(ctx).SetEndpoint(endpoint)
+
 	return fn(roundTripper, endpoint, username, password)
 }
 
@@ -55,7 +64,10 @@ func NewMgmtComponent(retries RetryManager, config *MgmtComponentConfig, opts *M
 				authenticator:    config.Authenticator,
 			},
 		},
-		logger: opts.Logger,
+		logger:     opts.Logger,
+		tracer:     opts.Tracer,
+		meter:      opts.Meter,
+		thresholds: opts.ThresholdLogger,
 	}
 }
 
@@ -73,80 +85,103 @@ func (w *MgmtComponent) Reconfigure(config *MgmtComponentConfig) error {
 func OrchestrateSimpleMgmtCall[OptsT any, RespT any](
 	ctx context.Context,
 	w *MgmtComponent,
+	opName string,
 	execFn func(o cbmgmtx.Management, ctx context.Context, req OptsT) (RespT, error),
 	opts OptsT,
 ) (RespT, error) {
-	return OrchestrateMgmtEndpoint(ctx, w,
+	ctx, span := startOpRequestSpan(ctx, w.tracer, opName)
+	defer span.End()
+
+	ctx = withOperationMeter(ctx, w.meter, meterServiceManagement, opName)
+	ctx = withThresholdLogging(ctx, w.thresholds, meterServiceManagement, opName)
+	startTime := time.Now()
+
+	res, err := OrchestrateMgmtEndpoint(ctx, w,
 		func(roundTripper http.RoundTripper, endpoint, username, password string) (RespT, error) {
 			return execFn(cbmgmtx.Management{
-				UserAgent: w.userAgent,
-				Transport: roundTripper,
-				Endpoint:  endpoint,
-				Username:  username,
-				Password:  password,
+				UserAgent:   w.userAgent,
+				Transport:   roundTripper,
+				Endpoint:    endpoint,
+				Username:    username,
+				Password:    password,
+				TraceParent: span.TraceParent(),
 			}, ctx, opts)
 		})
+	operationMeterFromContext(ctx).RecordOperation(time.Since(startTime), err)
+	thresholdLogOperationFromContext(ctx).End()
+	return res, err
 }
 
 func OrchestrateNoResMgmtCall[OptsT any](
 	ctx context.Context,
 	w *MgmtComponent,
+	opName string,
 	execFn func(o cbmgmtx.Management, ctx context.Context, req OptsT) error,
 	opts OptsT,
 ) error {
+	ctx, span := startOpRequestSpan(ctx, w.tracer, opName)
+	defer span.End()
+
+	ctx = withOperationMeter(ctx, w.meter, meterServiceManagement, opName)
+	ctx = withThresholdLogging(ctx, w.thresholds, meterServiceManagement, opName)
+	startTime := time.Now()
+
 	_, err := OrchestrateMgmtEndpoint(ctx, w,
 		func(roundTripper http.RoundTripper, endpoint, username, password string) (interface{}, error) {
 			return nil, execFn(cbmgmtx.Management{
-				UserAgent: w.userAgent,
-				Transport: roundTripper,
-				Endpoint:  endpoint,
-				Username:  username,
-				Password:  password,
+				UserAgent:   w.userAgent,
+				Transport:   roundTripper,
+				Endpoint:    endpoint,
+				Username:    username,
+				Password:    password,
+				TraceParent: span.TraceParent(),
 			}, ctx, opts)
 		})
+	operationMeterFromContext(ctx).RecordOperation(time.Since(startTime), err)
+	thresholdLogOperationFromContext(ctx).End()
 	return err
 }
 
 func (w *MgmtComponent) GetCollectionManifest(ctx context.Context, opts *cbmgmtx.GetCollectionManifestOptions) (*cbmgmtx.CollectionManifestJson, error) {
-	return OrchestrateSimpleMgmtCall(ctx, w, cbmgmtx.Management.GetCollectionManifest, opts)
+	return OrchestrateSimpleMgmtCall(ctx, w, "manager_get_collection_manifest", cbmgmtx.Management.GetCollectionManifest, opts)
 }
 
 func (w *MgmtComponent) CreateScope(ctx context.Context, opts *cbmgmtx.CreateScopeOptions) error {
-	return OrchestrateNoResMgmtCall(ctx, w, cbmgmtx.Management.CreateScope, opts)
+	return OrchestrateNoResMgmtCall(ctx, w, "manager_create_scope", cbmgmtx.Management.CreateScope, opts)
 }
 
 func (w *MgmtComponent) DeleteScope(ctx context.Context, opts *cbmgmtx.DeleteScopeOptions) error {
-	return OrchestrateNoResMgmtCall(ctx, w, cbmgmtx.Management.DeleteScope, opts)
+	return OrchestrateNoResMgmtCall(ctx, w, "manager_delete_scope", cbmgmtx.Management.DeleteScope, opts)
 }
 
 func (w *MgmtComponent) CreateCollection(ctx context.Context, opts *cbmgmtx.CreateCollectionOptions) error {
-	return OrchestrateNoResMgmtCall(ctx, w, cbmgmtx.Management.CreateCollection, opts)
+	return OrchestrateNoResMgmtCall(ctx, w, "manager_create_collection", cbmgmtx.Management.CreateCollection, opts)
 }
 
 func (w *MgmtComponent) DeleteCollection(ctx context.Context, opts *cbmgmtx.DeleteCollectionOptions) error {
-	return OrchestrateNoResMgmtCall(ctx, w, cbmgmtx.Management.DeleteCollection, opts)
+	return OrchestrateNoResMgmtCall(ctx, w, "manager_delete_collection", cbmgmtx.Management.DeleteCollection, opts)
 }
 
 func (w *MgmtComponent) GetAllBuckets(ctx context.Context, opts *cbmgmtx.GetAllBucketsOptions) ([]*cbmgmtx.BucketDef, error) {
-	return OrchestrateSimpleMgmtCall(ctx, w, cbmgmtx.Management.GetAllBuckets, opts)
+	return OrchestrateSimpleMgmtCall(ctx, w, "manager_get_all_buckets", cbmgmtx.Management.GetAllBuckets, opts)
 }
 
 func (w *MgmtComponent) GetBucket(ctx context.Context, opts *cbmgmtx.GetBucketOptions) (*cbmgmtx.BucketDef, error) {
-	return OrchestrateSimpleMgmtCall(ctx, w, cbmgmtx.Management.GetBucket, opts)
+	return OrchestrateSimpleMgmtCall(ctx, w, "manager_get_bucket", cbmgmtx.Management.GetBucket, opts)
 }
 
 func (w *MgmtComponent) CreateBucket(ctx context.Context, opts *cbmgmtx.CreateBucketOptions) error {
-	return OrchestrateNoResMgmtCall(ctx, w, cbmgmtx.Management.CreateBucket, opts)
+	return OrchestrateNoResMgmtCall(ctx, w, "manager_create_bucket", cbmgmtx.Management.CreateBucket, opts)
 }
 
 func (w *MgmtComponent) UpdateBucket(ctx context.Context, opts *cbmgmtx.UpdateBucketOptions) error {
-	return OrchestrateNoResMgmtCall(ctx, w, cbmgmtx.Management.UpdateBucket, opts)
+	return OrchestrateNoResMgmtCall(ctx, w, "manager_update_bucket", cbmgmtx.Management.UpdateBucket, opts)
 }
 
 func (w *MgmtComponent) FlushBucket(ctx context.Context, opts *cbmgmtx.FlushBucketOptions) error {
-	return OrchestrateNoResMgmtCall(ctx, w, cbmgmtx.Management.FlushBucket, opts)
+	return OrchestrateNoResMgmtCall(ctx, w, "manager_flush_bucket", cbmgmtx.Management.FlushBucket, opts)
 }
 
 func (w *MgmtComponent) DeleteBucket(ctx context.Context, opts *cbmgmtx.DeleteBucketOptions) error {
-	return OrchestrateNoResMgmtCall(ctx, w, cbmgmtx.Management.DeleteBucket, opts)
+	return OrchestrateNoResMgmtCall(ctx, w, "manager_delete_bucket", cbmgmtx.Management.DeleteBucket, opts)
 }
@@ -5,9 +5,8 @@ package gocbcorex
 
 import (
 	"context"
-	"sync"
-
 	"github.com/couchbase/gocbcorex/memdx"
+	"sync"
 )
 
 // Ensure, that KvClientMock does implement KvClient.
@@ -29,6 +28,15 @@ var _ KvClient = &KvClientMock{}
 //			CloseFunc: func() error {
 //				panic("mock out the Close method")
 //			},
+//			DcpCloseStreamFunc: func(ctx context.Context, req *memdx.DcpCloseStreamRequest) error {
+//				panic("mock out the DcpCloseStream method")
+//			},
+//			DcpGetFailoverLogFunc: func(ctx context.Context, req *memdx.DcpGetFailoverLogRequest) (*memdx.DcpGetFailoverLogResponse, error) {
+//				panic("mock out the DcpGetFailoverLog method")
+//			},
+//			DcpStreamReqFunc: func(ctx context.Context, req *memdx.DcpStreamReqRequest, handlers memdx.DcpStreamEventHandlers) (*memdx.DcpStreamReqResponse, error) {
+//				panic("mock out the DcpStreamReq method")
+//			},
 //			DecrementFunc: func(ctx context.Context, req *memdx.DecrementRequest) (*memdx.DecrementResponse, error) {
 //				panic("mock out the Decrement method")
 //			},
@@ -41,6 +49,9 @@ var _ KvClient = &KvClientMock{}
 //			GetFunc: func(ctx context.Context, req *memdx.GetRequest) (*memdx.GetResponse, error) {
 //				panic("mock out the Get method")
 //			},
+//			GetAllVbSeqnosFunc: func(ctx context.Context, req *memdx.GetAllVbSeqnosRequest) (*memdx.GetAllVbSeqnosResponse, error) {
+//				panic("mock out the GetAllVbSeqnos method")
+//			},
 //			GetAndLockFunc: func(ctx context.Context, req *memdx.GetAndLockRequest) (*memdx.GetAndLockResponse, error) {
 //				panic("mock out the GetAndLock method")
 //			},
@@ -53,6 +64,9 @@ var _ KvClient = &KvClientMock{}
 //			GetCollectionIDFunc: func(ctx context.Context, req *memdx.GetCollectionIDRequest) (*memdx.GetCollectionIDResponse, error) {
 //				panic("mock out the GetCollectionID method")
 //			},
+//			GetCollectionsManifestFunc: func(ctx context.Context, req *memdx.GetCollectionsManifestRequest) ([]byte, error) {
+//				panic("mock out the GetCollectionsManifest method")
+//			},
 //			GetMetaFunc: func(ctx context.Context, req *memdx.GetMetaRequest) (*memdx.GetMetaResponse, error) {
 //				panic("mock out the GetMeta method")
 //			},
@@ -71,12 +85,24 @@ var _ KvClient = &KvClientMock{}
 //			LoadFactorFunc: func() float64 {
 //				panic("mock out the LoadFactor method")
 //			},
+//			LoadStatsFunc: func() KvClientLoadStats {
+//				panic("mock out the LoadStats method")
+//			},
 //			LookupInFunc: func(ctx context.Context, req *memdx.LookupInRequest) (*memdx.LookupInResponse, error) {
 //				panic("mock out the LookupIn method")
 //			},
 //			MutateInFunc: func(ctx context.Context, req *memdx.MutateInRequest) (*memdx.MutateInResponse, error) {
 //				panic("mock out the MutateIn method")
 //			},
+//			NoOpFunc: func(ctx context.Context, req *memdx.NoOpRequest) (*memdx.NoOpResponse, error) {
+//				panic("mock out the NoOp method")
+//			},
+//			ObserveFunc: func(ctx context.Context, req *memdx.ObserveRequest) (*memdx.ObserveResponse, error) {
+//				panic("mock out the Observe method")
+//			},
+//			ObserveSeqNoFunc: func(ctx context.Context, req *memdx.ObserveSeqNoRequest) (*memdx.ObserveSeqNoResponse, error) {
+//				panic("mock out the ObserveSeqNo method")
+//			},
 //			PrependFunc: func(ctx context.Context, req *memdx.PrependRequest) (*memdx.PrependResponse, error) {
 //				panic("mock out the Prepend method")
 //			},
@@ -95,6 +121,9 @@ var _ KvClient = &KvClientMock{}
 //			SetMetaFunc: func(ctx context.Context, req *memdx.SetMetaRequest) (*memdx.SetMetaResponse, error) {
 //				panic("mock out the SetMeta method")
 //			},
+//			StatsFunc: func(ctx context.Context, req *memdx.StatsRequest) (*memdx.StatsResponse, error) {
+//				panic("mock out the Stats method")
+//			},
 //			TouchFunc: func(ctx context.Context, req *memdx.TouchRequest) (*memdx.TouchResponse, error) {
 //				panic("mock out the Touch method")
 //			},
@@ -117,6 +146,15 @@ type KvClientMock struct {
 	// CloseFunc mocks the Close method.
 	CloseFunc func() error
 
+	// DcpCloseStreamFunc mocks the DcpCloseStream method.
+	DcpCloseStreamFunc func(ctx context.Context, req *memdx.DcpCloseStreamRequest) error
+
+	// DcpGetFailoverLogFunc mocks the DcpGetFailoverLog method.
+	DcpGetFailoverLogFunc func(ctx context.Context, req *memdx.DcpGetFailoverLogRequest) (*memdx.DcpGetFailoverLogResponse, error)
+
+	// DcpStreamReqFunc mocks the DcpStreamReq method.
+	DcpStreamReqFunc func(ctx context.Context, req *memdx.DcpStreamReqRequest, handlers memdx.DcpStreamEventHandlers) (*memdx.DcpStreamReqResponse, error)
+
 	// DecrementFunc mocks the Decrement method.
 	DecrementFunc func(ctx context.Context, req *memdx.DecrementRequest) (*memdx.DecrementResponse, error)
 
@@ -129,6 +167,9 @@ type KvClientMock struct {
 	// GetFunc mocks the Get method.
 	GetFunc func(ctx context.Context, req *memdx.GetRequest) (*memdx.GetResponse, error)
 
+	// GetAllVbSeqnosFunc mocks the GetAllVbSeqnos method.
+	GetAllVbSeqnosFunc func(ctx context.Context, req *memdx.GetAllVbSeqnosRequest) (*memdx.GetAllVbSeqnosResponse, error)
+
 	// GetAndLockFunc mocks the GetAndLock method.
 	GetAndLockFunc func(ctx context.Context, req *memdx.GetAndLockRequest) (*memdx.GetAndLockResponse, error)
 
@@ -141,6 +182,9 @@ type KvClientMock struct {
 	// GetCollectionIDFunc mocks the GetCollectionID method.
 	GetCollectionIDFunc func(ctx context.Context, req *memdx.GetCollectionIDRequest) (*memdx.GetCollectionIDResponse, error)
 
+	// GetCollectionsManifestFunc mocks the GetCollectionsManifest method.
+	GetCollectionsManifestFunc func(ctx context.Context, req *memdx.GetCollectionsManifestRequest) ([]byte, error)
+
 	// GetMetaFunc mocks the GetMeta method.
 	GetMetaFunc func(ctx context.Context, req *memdx.GetMetaRequest) (*memdx.GetMetaResponse, error)
 
@@ -159,12 +203,24 @@ type KvClientMock struct {
 	// LoadFactorFunc mocks the LoadFactor method.
 	LoadFactorFunc func() float64
 
+	// LoadStatsFunc mocks the LoadStats method.
+	LoadStatsFunc func() KvClientLoadStats
+
 	// LookupInFunc mocks the LookupIn method.
 	LookupInFunc func(ctx context.Context, req *memdx.LookupInRequest) (*memdx.LookupInResponse, error)
 
 	// MutateInFunc mocks the MutateIn method.
 	MutateInFunc func(ctx context.Context, req *memdx.MutateInRequest) (*memdx.MutateInResponse, error)
 
+	// NoOpFunc mocks the NoOp method.
+	NoOpFunc func(ctx context.Context, req *memdx.NoOpRequest) (*memdx.NoOpResponse, error)
+
+	// ObserveFunc mocks the Observe method.
+	ObserveFunc func(ctx context.Context, req *memdx.ObserveRequest) (*memdx.ObserveResponse, error)
+
+	// ObserveSeqNoFunc mocks the ObserveSeqNo method.
+	ObserveSeqNoFunc func(ctx context.Context, req *memdx.ObserveSeqNoRequest) (*memdx.ObserveSeqNoResponse, error)
+
 	// PrependFunc mocks the Prepend method.
 	PrependFunc func(ctx context.Context, req *memdx.PrependRequest) (*memdx.PrependResponse, error)
 
@@ -183,6 +239,9 @@ type KvClientMock struct {
 	// SetMetaFunc mocks the SetMeta method.
 	SetMetaFunc func(ctx context.Context, req *memdx.SetMetaRequest) (*memdx.SetMetaResponse, error)
 
+	// StatsFunc mocks the Stats method.
+	StatsFunc func(ctx context.Context, req *memdx.StatsRequest) (*memdx.StatsResponse, error)
+
 	// TouchFunc mocks the Touch method.
 	TouchFunc func(ctx context.Context, req *memdx.TouchRequest) (*memdx.TouchResponse, error)
 
@@ -208,6 +267,29 @@ type KvClientMock struct {
 		// Close holds details about calls to the Close method.
 		Close []struct {
 		}
+		// DcpCloseStream holds details about calls to the DcpCloseStream method.
+		DcpCloseStream []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req *memdx.DcpCloseStreamRequest
+		}
+		// DcpGetFailoverLog holds details about calls to the DcpGetFailoverLog method.
+		DcpGetFailoverLog []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req *memdx.DcpGetFailoverLogRequest
+		}
+		// DcpStreamReq holds details about calls to the DcpStreamReq method.
+		DcpStreamReq []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req *memdx.DcpStreamReqRequest
+			// Handlers is the handlers argument value.
+			Handlers memdx.DcpStreamEventHandlers
+		}
 		// Decrement holds details about calls to the Decrement method.
 		Decrement []struct {
 			// Ctx is the ctx argument value.
@@ -236,6 +318,13 @@ type KvClientMock struct {
 			// Req is the req argument value.
 			Req *memdx.GetRequest
 		}
+		// GetAllVbSeqnos holds details about calls to the GetAllVbSeqnos method.
+		GetAllVbSeqnos []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req *memdx.GetAllVbSeqnosRequest
+		}
 		// GetAndLock holds details about calls to the GetAndLock method.
 		GetAndLock []struct {
 			// Ctx is the ctx argument value.
@@ -264,6 +353,13 @@ type KvClientMock struct {
 			// Req is the req argument value.
 			Req *memdx.GetCollectionIDRequest
 		}
+		// GetCollectionsManifest holds details about calls to the GetCollectionsManifest method.
+		GetCollectionsManifest []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req *memdx.GetCollectionsManifestRequest
+		}
 		// GetMeta holds details about calls to the GetMeta method.
 		GetMeta []struct {
 			// Ctx is the ctx argument value.
@@ -300,6 +396,9 @@ type KvClientMock struct {
 		// LoadFactor holds details about calls to the LoadFactor method.
 		LoadFactor []struct {
 		}
+		// LoadStats holds details about calls to the LoadStats method.
+		LoadStats []struct {
+		}
 		// LookupIn holds details about calls to the LookupIn method.
 		LookupIn []struct {
 			// Ctx is the ctx argument value.
@@ -314,6 +413,27 @@ type KvClientMock struct {
 			// Req is the req argument value.
 			Req *memdx.MutateInRequest
 		}
+		// NoOp holds details about calls to the NoOp method.
+		NoOp []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req *memdx.NoOpRequest
+		}
+		// Observe holds details about calls to the Observe method.
+		Observe []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req *memdx.ObserveRequest
+		}
+		// ObserveSeqNo holds details about calls to the ObserveSeqNo method.
+		ObserveSeqNo []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req *memdx.ObserveSeqNoRequest
+		}
 		// Prepend holds details about calls to the Prepend method.
 		Prepend []struct {
 			// Ctx is the ctx argument value.
@@ -352,6 +472,13 @@ type KvClientMock struct {
 			// Req is the req argument value.
 			Req *memdx.SetMetaRequest
 		}
+		// Stats holds details about calls to the Stats method.
+		Stats []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Req is the req argument value.
+			Req *memdx.StatsRequest
+		}
 		// Touch holds details about calls to the Touch method.
 		Touch []struct {
 			// Ctx is the ctx argument value.
@@ -367,33 +494,43 @@ type KvClientMock struct {
 			Req *memdx.UnlockRequest
 		}
 	}
-	lockAdd              sync.RWMutex
-	lockAppend           sync.RWMutex
-	lockClose            sync.RWMutex
-	lockDecrement        sync.RWMutex
-	lockDelete           sync.RWMutex
-	lockDeleteMeta       sync.RWMutex
-	lockGet              sync.RWMutex
-	lockGetAndLock       sync.RWMutex
-	lockGetAndTouch      sync.RWMutex
-	lockGetClusterConfig sync.RWMutex
-	lockGetCollectionID  sync.RWMutex
-	lockGetMeta          sync.RWMutex
-	lockGetRandom        sync.RWMutex
-	lockGetReplica       sync.RWMutex
-	lockHasFeature       sync.RWMutex
-	lockIncrement        sync.RWMutex
-	lockLoadFactor       sync.RWMutex
-	lockLookupIn         sync.RWMutex
-	lockMutateIn         sync.RWMutex
-	lockPrepend          sync.RWMutex
-	lockReconfigure      sync.RWMutex
-	lockRemoteAddress    sync.RWMutex
-	lockReplace          sync.RWMutex
-	lockSet              sync.RWMutex
-	lockSetMeta          sync.RWMutex
-	lockTouch            sync.RWMutex
-	lockUnlock           sync.RWMutex
+	lockAdd                    sync.RWMutex
+	lockAppend                 sync.RWMutex
+	lockClose                  sync.RWMutex
+	lockDcpCloseStream         sync.RWMutex
+	lockDcpGetFailoverLog      sync.RWMutex
+	lockDcpStreamReq           sync.RWMutex
+	lockDecrement              sync.RWMutex
+	lockDelete                 sync.RWMutex
+	lockDeleteMeta             sync.RWMutex
+	lockGet                    sync.RWMutex
+	lockGetAllVbSeqnos         sync.RWMutex
+	lockGetAndLock             sync.RWMutex
+	lockGetAndTouch            sync.RWMutex
+	lockGetClusterConfig       sync.RWMutex
+	lockGetCollectionID        sync.RWMutex
+	lockGetCollectionsManifest sync.RWMutex
+	lockGetMeta                sync.RWMutex
+	lockGetRandom              sync.RWMutex
+	lockGetReplica             sync.RWMutex
+	lockHasFeature             sync.RWMutex
+	lockIncrement              sync.RWMutex
+	lockLoadFactor             sync.RWMutex
+	lockLoadStats              sync.RWMutex
+	lockLookupIn               sync.RWMutex
+	lockMutateIn               sync.RWMutex
+	lockNoOp                   sync.RWMutex
+	lockObserve                sync.RWMutex
+	lockObserveSeqNo           sync.RWMutex
+	lockPrepend                sync.RWMutex
+	lockReconfigure            sync.RWMutex
+	lockRemoteAddress          sync.RWMutex
+	lockReplace                sync.RWMutex
+	lockSet                    sync.RWMutex
+	lockSetMeta                sync.RWMutex
+	lockStats                  sync.RWMutex
+	lockTouch                  sync.RWMutex
+	lockUnlock                 sync.RWMutex
 }
 
 // Add calls AddFunc.
@@ -495,6 +632,118 @@ func (mock *KvClientMock) CloseCalls() []struct {
 	return calls
 }
 
+// DcpCloseStream calls DcpCloseStreamFunc.
+func (mock *KvClientMock) DcpCloseStream(ctx context.Context, req *memdx.DcpCloseStreamRequest) error {
+	if mock.DcpCloseStreamFunc == nil {
+		panic("KvClientMock.DcpCloseStreamFunc: method is nil but KvClient.DcpCloseStream was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req *memdx.DcpCloseStreamRequest
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	mock.lockDcpCloseStream.Lock()
+	mock.calls.DcpCloseStream = append(mock.calls.DcpCloseStream, callInfo)
+	mock.lockDcpCloseStream.Unlock()
+	return mock.DcpCloseStreamFunc(ctx, req)
+}
+
+// DcpCloseStreamCalls gets all the calls that were made to DcpCloseStream.
+// Check the length with:
+//
+//	len(mockedKvClient.DcpCloseStreamCalls())
+func (mock *KvClientMock) DcpCloseStreamCalls() []struct {
+	Ctx context.Context
+	Req *memdx.DcpCloseStreamRequest
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req *memdx.DcpCloseStreamRequest
+	}
+	mock.lockDcpCloseStream.RLock()
+	calls = mock.calls.DcpCloseStream
+	mock.lockDcpCloseStream.RUnlock()
+	return calls
+}
+
+// DcpGetFailoverLog calls DcpGetFailoverLogFunc.
+func (mock *KvClientMock) DcpGetFailoverLog(ctx context.Context, req *memdx.DcpGetFailoverLogRequest) (*memdx.DcpGetFailoverLogResponse, error) {
+	if mock.DcpGetFailoverLogFunc == nil {
+		panic("KvClientMock.DcpGetFailoverLogFunc: method is nil but KvClient.DcpGetFailoverLog was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req *memdx.DcpGetFailoverLogRequest
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	mock.lockDcpGetFailoverLog.Lock()
+	mock.calls.DcpGetFailoverLog = append(mock.calls.DcpGetFailoverLog, callInfo)
+	mock.lockDcpGetFailoverLog.Unlock()
+	return mock.DcpGetFailoverLogFunc(ctx, req)
+}
+
+// DcpGetFailoverLogCalls gets all the calls that were made to DcpGetFailoverLog.
+// Check the length with:
+//
+//	len(mockedKvClient.DcpGetFailoverLogCalls())
+func (mock *KvClientMock) DcpGetFailoverLogCalls() []struct {
+	Ctx context.Context
+	Req *memdx.DcpGetFailoverLogRequest
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req *memdx.DcpGetFailoverLogRequest
+	}
+	mock.lockDcpGetFailoverLog.RLock()
+	calls = mock.calls.DcpGetFailoverLog
+	mock.lockDcpGetFailoverLog.RUnlock()
+	return calls
+}
+
+// DcpStreamReq calls DcpStreamReqFunc.
+func (mock *KvClientMock) DcpStreamReq(ctx context.Context, req *memdx.DcpStreamReqRequest, handlers memdx.DcpStreamEventHandlers) (*memdx.DcpStreamReqResponse, error) {
+	if mock.DcpStreamReqFunc == nil {
+		panic("KvClientMock.DcpStreamReqFunc: method is nil but KvClient.DcpStreamReq was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Req      *memdx.DcpStreamReqRequest
+		Handlers memdx.DcpStreamEventHandlers
+	}{
+		Ctx:      ctx,
+		Req:      req,
+		Handlers: handlers,
+	}
+	mock.lockDcpStreamReq.Lock()
+	mock.calls.DcpStreamReq = append(mock.calls.DcpStreamReq, callInfo)
+	mock.lockDcpStreamReq.Unlock()
+	return mock.DcpStreamReqFunc(ctx, req, handlers)
+}
+
+// DcpStreamReqCalls gets all the calls that were made to DcpStreamReq.
+// Check the length with:
+//
+//	len(mockedKvClient.DcpStreamReqCalls())
+func (mock *KvClientMock) DcpStreamReqCalls() []struct {
+	Ctx      context.Context
+	Req      *memdx.DcpStreamReqRequest
+	Handlers memdx.DcpStreamEventHandlers
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Req      *memdx.DcpStreamReqRequest
+		Handlers memdx.DcpStreamEventHandlers
+	}
+	mock.lockDcpStreamReq.RLock()
+	calls = mock.calls.DcpStreamReq
+	mock.lockDcpStreamReq.RUnlock()
+	return calls
+}
+
 // Decrement calls DecrementFunc.
 func (mock *KvClientMock) Decrement(ctx context.Context, req *memdx.DecrementRequest) (*memdx.DecrementResponse, error) {
 	if mock.DecrementFunc == nil {
@@ -639,6 +888,42 @@ func (mock *KvClientMock) GetCalls() []struct {
 	return calls
 }
 
+// GetAllVbSeqnos calls GetAllVbSeqnosFunc.
+func (mock *KvClientMock) GetAllVbSeqnos(ctx context.Context, req *memdx.GetAllVbSeqnosRequest) (*memdx.GetAllVbSeqnosResponse, error) {
+	if mock.GetAllVbSeqnosFunc == nil {
+		panic("KvClientMock.GetAllVbSeqnosFunc: method is nil but KvClient.GetAllVbSeqnos was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req *memdx.GetAllVbSeqnosRequest
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	mock.lockGetAllVbSeqnos.Lock()
+	mock.calls.GetAllVbSeqnos = append(mock.calls.GetAllVbSeqnos, callInfo)
+	mock.lockGetAllVbSeqnos.Unlock()
+	return mock.GetAllVbSeqnosFunc(ctx, req)
+}
+
+// GetAllVbSeqnosCalls gets all the calls that were made to GetAllVbSeqnos.
+// Check the length with:
+//
+//	len(mockedKvClient.GetAllVbSeqnosCalls())
+func (mock *KvClientMock) GetAllVbSeqnosCalls() []struct {
+	Ctx context.Context
+	Req *memdx.GetAllVbSeqnosRequest
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req *memdx.GetAllVbSeqnosRequest
+	}
+	mock.lockGetAllVbSeqnos.RLock()
+	calls = mock.calls.GetAllVbSeqnos
+	mock.lockGetAllVbSeqnos.RUnlock()
+	return calls
+}
+
 // GetAndLock calls GetAndLockFunc.
 func (mock *KvClientMock) GetAndLock(ctx context.Context, req *memdx.GetAndLockRequest) (*memdx.GetAndLockResponse, error) {
 	if mock.GetAndLockFunc == nil {
@@ -783,6 +1068,42 @@ func (mock *KvClientMock) GetCollectionIDCalls() []struct {
 	return calls
 }
 
+// GetCollectionsManifest calls GetCollectionsManifestFunc.
+func (mock *KvClientMock) GetCollectionsManifest(ctx context.Context, req *memdx.GetCollectionsManifestRequest) ([]byte, error) {
+	if mock.GetCollectionsManifestFunc == nil {
+		panic("KvClientMock.GetCollectionsManifestFunc: method is nil but KvClient.GetCollectionsManifest was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req *memdx.GetCollectionsManifestRequest
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	mock.lockGetCollectionsManifest.Lock()
+	mock.calls.GetCollectionsManifest = append(mock.calls.GetCollectionsManifest, callInfo)
+	mock.lockGetCollectionsManifest.Unlock()
+	return mock.GetCollectionsManifestFunc(ctx, req)
+}
+
+// GetCollectionsManifestCalls gets all the calls that were made to GetCollectionsManifest.
+// Check the length with:
+//
+//	len(mockedKvClient.GetCollectionsManifestCalls())
+func (mock *KvClientMock) GetCollectionsManifestCalls() []struct {
+	Ctx context.Context
+	Req *memdx.GetCollectionsManifestRequest
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req *memdx.GetCollectionsManifestRequest
+	}
+	mock.lockGetCollectionsManifest.RLock()
+	calls = mock.calls.GetCollectionsManifest
+	mock.lockGetCollectionsManifest.RUnlock()
+	return calls
+}
+
 // GetMeta calls GetMetaFunc.
 func (mock *KvClientMock) GetMeta(ctx context.Context, req *memdx.GetMetaRequest) (*memdx.GetMetaResponse, error) {
 	if mock.GetMetaFunc == nil {
@@ -986,6 +1307,33 @@ func (mock *KvClientMock) LoadFactorCalls() []struct {
 	return calls
 }
 
+// LoadStats calls LoadStatsFunc.
+func (mock *KvClientMock) LoadStats() KvClientLoadStats {
+	if mock.LoadStatsFunc == nil {
+		panic("KvClientMock.LoadStatsFunc: method is nil but KvClient.LoadStats was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockLoadStats.Lock()
+	mock.calls.LoadStats = append(mock.calls.LoadStats, callInfo)
+	mock.lockLoadStats.Unlock()
+	return mock.LoadStatsFunc()
+}
+
+// LoadStatsCalls gets all the calls that were made to LoadStats.
+// Check the length with:
+//
+//	len(mockedKvClient.LoadStatsCalls())
+func (mock *KvClientMock) LoadStatsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockLoadStats.RLock()
+	calls = mock.calls.LoadStats
+	mock.lockLoadStats.RUnlock()
+	return calls
+}
+
 // LookupIn calls LookupInFunc.
 func (mock *KvClientMock) LookupIn(ctx context.Context, req *memdx.LookupInRequest) (*memdx.LookupInResponse, error) {
 	if mock.LookupInFunc == nil {
@@ -1058,6 +1406,114 @@ func (mock *KvClientMock) MutateInCalls() []struct {
 	return calls
 }
 
+// NoOp calls NoOpFunc.
+func (mock *KvClientMock) NoOp(ctx context.Context, req *memdx.NoOpRequest) (*memdx.NoOpResponse, error) {
+	if mock.NoOpFunc == nil {
+		panic("KvClientMock.NoOpFunc: method is nil but KvClient.NoOp was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req *memdx.NoOpRequest
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	mock.lockNoOp.Lock()
+	mock.calls.NoOp = append(mock.calls.NoOp, callInfo)
+	mock.lockNoOp.Unlock()
+	return mock.NoOpFunc(ctx, req)
+}
+
+// NoOpCalls gets all the calls that were made to NoOp.
+// Check the length with:
+//
+//	len(mockedKvClient.NoOpCalls())
+func (mock *KvClientMock) NoOpCalls() []struct {
+	Ctx context.Context
+	Req *memdx.NoOpRequest
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req *memdx.NoOpRequest
+	}
+	mock.lockNoOp.RLock()
+	calls = mock.calls.NoOp
+	mock.lockNoOp.RUnlock()
+	return calls
+}
+
+// Observe calls ObserveFunc.
+func (mock *KvClientMock) Observe(ctx context.Context, req *memdx.ObserveRequest) (*memdx.ObserveResponse, error) {
+	if mock.ObserveFunc == nil {
+		panic("KvClientMock.ObserveFunc: method is nil but KvClient.Observe was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req *memdx.ObserveRequest
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	mock.lockObserve.Lock()
+	mock.calls.Observe = append(mock.calls.Observe, callInfo)
+	mock.lockObserve.Unlock()
+	return mock.ObserveFunc(ctx, req)
+}
+
+// ObserveCalls gets all the calls that were made to Observe.
+// Check the length with:
+//
+//	len(mockedKvClient.ObserveCalls())
+func (mock *KvClientMock) ObserveCalls() []struct {
+	Ctx context.Context
+	Req *memdx.ObserveRequest
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req *memdx.ObserveRequest
+	}
+	mock.lockObserve.RLock()
+	calls = mock.calls.Observe
+	mock.lockObserve.RUnlock()
+	return calls
+}
+
+// ObserveSeqNo calls ObserveSeqNoFunc.
+func (mock *KvClientMock) ObserveSeqNo(ctx context.Context, req *memdx.ObserveSeqNoRequest) (*memdx.ObserveSeqNoResponse, error) {
+	if mock.ObserveSeqNoFunc == nil {
+		panic("KvClientMock.ObserveSeqNoFunc: method is nil but KvClient.ObserveSeqNo was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req *memdx.ObserveSeqNoRequest
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	mock.lockObserveSeqNo.Lock()
+	mock.calls.ObserveSeqNo = append(mock.calls.ObserveSeqNo, callInfo)
+	mock.lockObserveSeqNo.Unlock()
+	return mock.ObserveSeqNoFunc(ctx, req)
+}
+
+// ObserveSeqNoCalls gets all the calls that were made to ObserveSeqNo.
+// Check the length with:
+//
+//	len(mockedKvClient.ObserveSeqNoCalls())
+func (mock *KvClientMock) ObserveSeqNoCalls() []struct {
+	Ctx context.Context
+	Req *memdx.ObserveSeqNoRequest
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req *memdx.ObserveSeqNoRequest
+	}
+	mock.lockObserveSeqNo.RLock()
+	calls = mock.calls.ObserveSeqNo
+	mock.lockObserveSeqNo.RUnlock()
+	return calls
+}
+
 // Prepend calls PrependFunc.
 func (mock *KvClientMock) Prepend(ctx context.Context, req *memdx.PrependRequest) (*memdx.PrependResponse, error) {
 	if mock.PrependFunc == nil {
@@ -1265,6 +1721,42 @@ func (mock *KvClientMock) SetMetaCalls() []struct {
 	return calls
 }
 
+// Stats calls StatsFunc.
+func (mock *KvClientMock) Stats(ctx context.Context, req *memdx.StatsRequest) (*memdx.StatsResponse, error) {
+	if mock.StatsFunc == nil {
+		panic("KvClientMock.StatsFunc: method is nil but KvClient.Stats was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Req *memdx.StatsRequest
+	}{
+		Ctx: ctx,
+		Req: req,
+	}
+	mock.lockStats.Lock()
+	mock.calls.Stats = append(mock.calls.Stats, callInfo)
+	mock.lockStats.Unlock()
+	return mock.StatsFunc(ctx, req)
+}
+
+// StatsCalls gets all the calls that were made to Stats.
+// Check the length with:
+//
+//	len(mockedKvClient.StatsCalls())
+func (mock *KvClientMock) StatsCalls() []struct {
+	Ctx context.Context
+	Req *memdx.StatsRequest
+} {
+	var calls []struct {
+		Ctx context.Context
+		Req *memdx.StatsRequest
+	}
+	mock.lockStats.RLock()
+	calls = mock.calls.Stats
+	mock.lockStats.RUnlock()
+	return calls
+}
+
 // Touch calls TouchFunc.
 func (mock *KvClientMock) Touch(ctx context.Context, req *memdx.TouchRequest) (*memdx.TouchResponse, error) {
 	if mock.TouchFunc == nil {
@@ -21,6 +21,9 @@ var _ KvClientManager = &KvClientManagerMock{}
 //			GetClientFunc: func(ctx context.Context, endpoint string) (KvClient, error) {
 //				panic("mock out the GetClient method")
 //			},
+//			GetEndpointsFunc: func() []string {
+//				panic("mock out the GetEndpoints method")
+//			},
 //			GetRandomClientFunc: func(ctx context.Context) (KvClient, error) {
 //				panic("mock out the GetRandomClient method")
 //			},
@@ -40,6 +43,9 @@ type KvClientManagerMock struct {
 	// GetClientFunc mocks the GetClient method.
 	GetClientFunc func(ctx context.Context, endpoint string) (KvClient, error)
 
+	// GetEndpointsFunc mocks the GetEndpoints method.
+	GetEndpointsFunc func() []string
+
 	// GetRandomClientFunc mocks the GetRandomClient method.
 	GetRandomClientFunc func(ctx context.Context) (KvClient, error)
 
@@ -58,6 +64,9 @@ type KvClientManagerMock struct {
 			// Endpoint is the endpoint argument value.
 			Endpoint string
 		}
+		// GetEndpoints holds details about calls to the GetEndpoints method.
+		GetEndpoints []struct {
+		}
 		// GetRandomClient holds details about calls to the GetRandomClient method.
 		GetRandomClient []struct {
 			// Ctx is the ctx argument value.
@@ -79,6 +88,7 @@ type KvClientManagerMock struct {
 		}
 	}
 	lockGetClient       sync.RWMutex
+	lockGetEndpoints    sync.RWMutex
 	lockGetRandomClient sync.RWMutex
 	lockReconfigure     sync.RWMutex
 	lockShutdownClient  sync.RWMutex
@@ -120,6 +130,33 @@ func (mock *KvClientManagerMock) GetClientCalls() []struct {
 	return calls
 }
 
+// GetEndpoints calls GetEndpointsFunc.
+func (mock *KvClientManagerMock) GetEndpoints() []string {
+	if mock.GetEndpointsFunc == nil {
+		panic("KvClientManagerMock.GetEndpointsFunc: method is nil but KvClientManager.GetEndpoints was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetEndpoints.Lock()
+	mock.calls.GetEndpoints = append(mock.calls.GetEndpoints, callInfo)
+	mock.lockGetEndpoints.Unlock()
+	return mock.GetEndpointsFunc()
+}
+
+// GetEndpointsCalls gets all the calls that were made to GetEndpoints.
+// Check the length with:
+//
+//	len(mockedKvClientManager.GetEndpointsCalls())
+func (mock *KvClientManagerMock) GetEndpointsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetEndpoints.RLock()
+	calls = mock.calls.GetEndpoints
+	mock.lockGetEndpoints.RUnlock()
+	return calls
+}
+
 // GetRandomClient calls GetRandomClientFunc.
 func (mock *KvClientManagerMock) GetRandomClient(ctx context.Context) (KvClient, error) {
 	if mock.GetRandomClientFunc == nil {
@@ -26,6 +26,9 @@ var _ VbucketRouter = &VbucketRouterMock{}
 //			UpdateRoutingInfoFunc: func(vbucketRoutingInfo *VbucketRoutingInfo)  {
 //				panic("mock out the UpdateRoutingInfo method")
 //			},
+//			VbucketsByServerFunc: func(replicaIdx uint32) (map[string][]uint16, error) {
+//				panic("mock out the VbucketsByServer method")
+//			},
 //		}
 //
 //		// use mockedVbucketRouter in code that requires VbucketRouter
@@ -42,6 +45,9 @@ type VbucketRouterMock struct {
 	// UpdateRoutingInfoFunc mocks the UpdateRoutingInfo method.
 	UpdateRoutingInfoFunc func(vbucketRoutingInfo *VbucketRoutingInfo)
 
+	// VbucketsByServerFunc mocks the VbucketsByServer method.
+	VbucketsByServerFunc func(replicaIdx uint32) (map[string][]uint16, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// DispatchByKey holds details about calls to the DispatchByKey method.
@@ -61,10 +67,16 @@ type VbucketRouterMock struct {
 			// VbucketRoutingInfo is the vbucketRoutingInfo argument value.
 			VbucketRoutingInfo *VbucketRoutingInfo
 		}
+		// VbucketsByServer holds details about calls to the VbucketsByServer method.
+		VbucketsByServer []struct {
+			// ReplicaIdx is the replicaIdx argument value.
+			ReplicaIdx uint32
+		}
 	}
 	lockDispatchByKey     sync.RWMutex
 	lockDispatchToVbucket sync.RWMutex
 	lockUpdateRoutingInfo sync.RWMutex
+	lockVbucketsByServer  sync.RWMutex
 }
 
 // DispatchByKey calls DispatchByKeyFunc.
@@ -166,3 +178,35 @@ func (mock *VbucketRouterMock) UpdateRoutingInfoCalls() []struct {
 	mock.lockUpdateRoutingInfo.RUnlock()
 	return calls
 }
+
+// VbucketsByServer calls VbucketsByServerFunc.
+func (mock *VbucketRouterMock) VbucketsByServer(replicaIdx uint32) (map[string][]uint16, error) {
+	if mock.VbucketsByServerFunc == nil {
+		panic("VbucketRouterMock.VbucketsByServerFunc: method is nil but VbucketRouter.VbucketsByServer was just called")
+	}
+	callInfo := struct {
+		ReplicaIdx uint32
+	}{
+		ReplicaIdx: replicaIdx,
+	}
+	mock.lockVbucketsByServer.Lock()
+	mock.calls.VbucketsByServer = append(mock.calls.VbucketsByServer, callInfo)
+	mock.lockVbucketsByServer.Unlock()
+	return mock.VbucketsByServerFunc(replicaIdx)
+}
+
+// VbucketsByServerCalls gets all the calls that were made to VbucketsByServer.
+// Check the length with:
+//
+//	len(mockedVbucketRouter.VbucketsByServerCalls())
+func (mock *VbucketRouterMock) VbucketsByServerCalls() []struct {
+	ReplicaIdx uint32
+} {
+	var calls []struct {
+		ReplicaIdx uint32
+	}
+	mock.lockVbucketsByServer.RLock()
+	calls = mock.calls.VbucketsByServer
+	mock.lockVbucketsByServer.RUnlock()
+	return calls
+}
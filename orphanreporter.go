@@ -0,0 +1,228 @@
+package gocbcorex
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+// OrphanedResponse describes a response which arrived after the request it
+// belongs to was no longer being waited for, typically because it timed out.
+type OrphanedResponse struct {
+	Endpoint       string
+	OpCode         memdx.OpCode
+	Opaque         uint32
+	Status         memdx.Status
+	ServerDuration time.Duration
+}
+
+// OrphanedResponseHandler receives the orphaned responses seen by a KvClient.
+type OrphanedResponseHandler interface {
+	HandleOrphanedResponse(resp *OrphanedResponse)
+}
+
+func (c *kvClient) handleOrphan(pak *memdx.Packet) {
+	// responses without a handler belong to requests which were cancelled,
+	// anything else was sent to us by the server.
+	if pak.Magic.IsResponse() {
+		c.handleOrphanedResponse(pak)
+		return
+	}
+
+	if c.currentConfig.Dcp != nil {
+		c.handleDcpOrphan(pak)
+	} else {
+		c.handleServerRequest(pak)
+	}
+}
+
+func (c *kvClient) handleOrphanedResponse(pak *memdx.Packet) {
+	resp := &OrphanedResponse{
+		Endpoint: c.cli.RemoteAddr(),
+		OpCode:   pak.OpCode,
+		Opaque:   pak.Opaque,
+		Status:   pak.Status,
+	}
+
+	meta, err := memdx.ParseResponseMeta(pak)
+	if err == nil {
+		resp.ServerDuration = meta.ServerDuration
+	}
+
+	c.logger.Debug("received orphaned response",
+		zap.Stringer("opcode", resp.OpCode),
+		zap.Uint32("opaque", resp.Opaque),
+		zap.Stringer("status", resp.Status),
+		zap.Duration("serverDuration", resp.ServerDuration))
+
+	if c.orphanedResponseHandler != nil {
+		c.orphanedResponseHandler.HandleOrphanedResponse(resp)
+	}
+}
+
+type OrphanReporterOptions struct {
+	Logger *zap.Logger
+
+	// ReportInterval is how often a report is logged, defaults to 10 seconds.
+	ReportInterval time.Duration
+
+	// SampleSize is the number of responses included in each report, those
+	// with the longest server duration being kept, defaults to 10.
+	SampleSize int
+}
+
+// OrphanReporter collects orphaned responses and periodically logs a report
+// of them, so that requests which time out on the client but still complete
+// on the server can be identified.
+type OrphanReporter struct {
+	logger         *zap.Logger
+	reportInterval time.Duration
+	sampleSize     int
+
+	lock      sync.Mutex
+	count     uint64
+	samples   []*OrphanedResponse
+	stopSigCh chan struct{}
+}
+
+var _ OrphanedResponseHandler = (*OrphanReporter)(nil)
+
+func NewOrphanReporter(opts *OrphanReporterOptions) *OrphanReporter {
+	if opts == nil {
+		opts = &OrphanReporterOptions{}
+	}
+
+	reportInterval := opts.ReportInterval
+	if reportInterval <= 0 {
+		reportInterval = 10 * time.Second
+	}
+
+	sampleSize := opts.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = 10
+	}
+
+	return &OrphanReporter{
+		logger:         loggerOrNop(opts.Logger),
+		reportInterval: reportInterval,
+		sampleSize:     sampleSize,
+	}
+}
+
+func (r *OrphanReporter) HandleOrphanedResponse(resp *OrphanedResponse) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.count++
+
+	// the samples are kept sorted by descending server duration, so once we
+	// have enough of them, the last one is the one to replace.
+	if len(r.samples) >= r.sampleSize {
+		if resp.ServerDuration <= r.samples[len(r.samples)-1].ServerDuration {
+			return
+		}
+		r.samples = r.samples[:len(r.samples)-1]
+	}
+
+	r.samples = append(r.samples, resp)
+	slices.SortStableFunc(r.samples, func(a, b *OrphanedResponse) bool {
+		return a.ServerDuration > b.ServerDuration
+	})
+}
+
+type orphanReportItemJson struct {
+	OperationName        string `json:"operation_name"`
+	LastDispatchedTo     string `json:"last_dispatched_to"`
+	LastOperationID      string `json:"last_operation_id"`
+	LastStatus           string `json:"last_status"`
+	LastServerDurationUs uint64 `json:"last_server_duration_us"`
+}
+
+type orphanReportServiceJson struct {
+	TotalCount  uint64                 `json:"total_count"`
+	TopRequests []orphanReportItemJson `json:"top_requests"`
+}
+
+// Report returns a JSON report of the orphaned responses collected since the
+// previous report, or nil if there were none.
+func (r *OrphanReporter) Report() []byte {
+	r.lock.Lock()
+	count := r.count
+	samples := r.samples
+	r.count = 0
+	r.samples = nil
+	r.lock.Unlock()
+
+	if count == 0 {
+		return nil
+	}
+
+	report := map[string]orphanReportServiceJson{}
+	kvReport := orphanReportServiceJson{
+		TotalCount: count,
+	}
+	for _, sample := range samples {
+		kvReport.TopRequests = append(kvReport.TopRequests, orphanReportItemJson{
+			OperationName:        sample.OpCode.String(),
+			LastDispatchedTo:     sample.Endpoint,
+			LastOperationID:      fmt.Sprintf("0x%x", sample.Opaque),
+			LastStatus:           sample.Status.String(),
+			LastServerDurationUs: uint64(sample.ServerDuration / time.Microsecond),
+		})
+	}
+	report["kv"] = kvReport
+
+	reportJson, err := json.Marshal(report)
+	if err != nil {
+		r.logger.Debug("failed to marshal orphan report", zap.Error(err))
+		return nil
+	}
+
+	return reportJson
+}
+
+// Start begins periodically logging reports, until Close is called.
+func (r *OrphanReporter) Start() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.stopSigCh != nil {
+		return
+	}
+
+	stopSigCh := make(chan struct{})
+	r.stopSigCh = stopSigCh
+
+	go func() {
+		ticker := time.NewTicker(r.reportInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if report := r.Report(); report != nil {
+					r.logger.Warn("orphaned responses observed",
+						zap.ByteString("report", report))
+				}
+			case <-stopSigCh:
+				return
+			}
+		}
+	}()
+}
+
+func (r *OrphanReporter) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.stopSigCh != nil {
+		close(r.stopSigCh)
+		r.stopSigCh = nil
+	}
+}
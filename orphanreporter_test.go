@@ -0,0 +1,54 @@
+package gocbcorex
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+func TestOrphanReporterKeepsSlowestResponses(t *testing.T) {
+	reporter := NewOrphanReporter(&OrphanReporterOptions{
+		SampleSize: 2,
+	})
+
+	assert.Nil(t, reporter.Report())
+
+	for i, duration := range []time.Duration{3, 1, 5, 2} {
+		reporter.HandleOrphanedResponse(&OrphanedResponse{
+			Endpoint:       "endpoint1",
+			OpCode:         memdx.OpCodeGet,
+			Opaque:         uint32(i),
+			Status:         memdx.StatusSuccess,
+			ServerDuration: duration * time.Millisecond,
+		})
+	}
+
+	var report map[string]orphanReportServiceJson
+	require.NoError(t, json.Unmarshal(reporter.Report(), &report))
+
+	assert.Equal(t, uint64(4), report["kv"].TotalCount)
+	assert.Equal(t, []orphanReportItemJson{
+		{
+			OperationName:        "Get",
+			LastDispatchedTo:     "endpoint1",
+			LastOperationID:      "0x2",
+			LastStatus:           memdx.StatusSuccess.String(),
+			LastServerDurationUs: 5000,
+		},
+		{
+			OperationName:        "Get",
+			LastDispatchedTo:     "endpoint1",
+			LastOperationID:      "0x0",
+			LastStatus:           memdx.StatusSuccess.String(),
+			LastServerDurationUs: 3000,
+		},
+	}, report["kv"].TopRequests)
+
+	// each report only covers the responses since the previous one
+	assert.Nil(t, reporter.Report())
+}
@@ -2,7 +2,9 @@ package gocbcorex
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"sync"
 
 	"github.com/couchbase/gocbcorex/cbqueryx"
 	"go.uber.org/zap"
@@ -16,6 +18,9 @@ type QueryComponent struct {
 	baseHttpComponent
 
 	logger        *zap.Logger
+	tracer        RequestTracer
+	meter         Meter
+	thresholds    *ThresholdLogger
 	retries       RetryManager
 	preparedCache *PreparedStatementCache
 }
@@ -27,8 +32,11 @@ type QueryComponentConfig struct {
 }
 
 type QueryComponentOptions struct {
-	Logger    *zap.Logger
-	UserAgent string
+	Logger          *zap.Logger
+	Tracer          RequestTracer
+	Meter           Meter
+	ThresholdLogger *ThresholdLogger
+	UserAgent       string
 }
 
 func OrchestrateQueryEndpoint[RespT any](
@@ -47,6 +55,8 @@ func OrchestrateQueryEndpoint[RespT any](
 		return emptyResp, ErrServiceNotAvailable
 	}
 
+	thresholdLogOperationFromContext(ctx).SetEndpoint(endpoint)
+
 	return fn(roundTripper, endpoint, username, password)
 }
 
@@ -62,6 +72,9 @@ func NewQueryComponent(retries RetryManager, config *QueryComponentConfig, opts
 			},
 		},
 		logger:        opts.Logger,
+		tracer:        opts.Tracer,
+		meter:         opts.Meter,
+		thresholds:    opts.ThresholdLogger,
 		retries:       retries,
 		preparedCache: cbqueryx.NewPreparedStatementCache(),
 	}
@@ -79,36 +92,116 @@ func (w *QueryComponent) Reconfigure(config *QueryComponentConfig) error {
 }
 
 func (w *QueryComponent) Query(ctx context.Context, opts *QueryOptions) (QueryResultStream, error) {
-	return OrchestrateQueryRetries(ctx, w.retries, func() (QueryResultStream, error) {
+	ctx, span := startOpRequestSpan(ctx, w.tracer, "query")
+	ctx = withOperationMeter(ctx, w.meter, meterServiceQuery, "query")
+	ctx = withThresholdLogging(ctx, w.thresholds, meterServiceQuery, "query")
+
+	res, err := OrchestrateQueryRetries(ctx, w.retries, func() (QueryResultStream, error) {
 		return OrchestrateQueryEndpoint(ctx, w,
 			func(roundTripper http.RoundTripper, endpoint, username, password string) (QueryResultStream, error) {
+				ctx, dispatchSpan := startQueryDispatchSpan(ctx, endpoint)
+				defer dispatchSpan.End()
+
 				return cbqueryx.Query{
-					Logger:    w.logger,
-					UserAgent: w.userAgent,
-					Transport: roundTripper,
-					Endpoint:  endpoint,
-					Username:  username,
-					Password:  password,
+					Logger:      w.logger,
+					UserAgent:   w.userAgent,
+					Transport:   roundTripper,
+					Endpoint:    endpoint,
+					Username:    username,
+					Password:    password,
+					TraceParent: dispatchSpan.TraceParent(),
 				}.Query(ctx, opts)
 			})
 	})
+	if err != nil {
+		span.End()
+		return nil, err
+	}
+
+	return newQueryResultStream(res, span.End), nil
 }
 
 func (w *QueryComponent) PreparedQuery(ctx context.Context, opts *QueryOptions) (QueryResultStream, error) {
-	return OrchestrateQueryRetries(ctx, w.retries, func() (QueryResultStream, error) {
+	ctx, span := startOpRequestSpan(ctx, w.tracer, "query")
+	ctx = withOperationMeter(ctx, w.meter, meterServiceQuery, "query")
+	ctx = withThresholdLogging(ctx, w.thresholds, meterServiceQuery, "query")
+
+	res, err := OrchestrateQueryRetries(ctx, w.retries, func() (QueryResultStream, error) {
 		return OrchestrateQueryEndpoint(ctx, w,
 			func(roundTripper http.RoundTripper, endpoint, username, password string) (QueryResultStream, error) {
+				ctx, dispatchSpan := startQueryDispatchSpan(ctx, endpoint)
+				defer dispatchSpan.End()
+
 				return cbqueryx.PreparedQuery{
 					Executor: cbqueryx.Query{
-						Logger:    w.logger,
-						UserAgent: w.userAgent,
-						Transport: roundTripper,
-						Endpoint:  endpoint,
-						Username:  username,
-						Password:  password,
+						Logger:      w.logger,
+						UserAgent:   w.userAgent,
+						Transport:   roundTripper,
+						Endpoint:    endpoint,
+						Username:    username,
+						Password:    password,
+						TraceParent: dispatchSpan.TraceParent(),
 					},
 					Cache: w.preparedCache,
 				}.PreparedQuery(ctx, opts)
 			})
 	})
+	if err != nil {
+		span.End()
+		return nil, err
+	}
+
+	return newQueryResultStream(res, span.End), nil
+}
+
+// startQueryDispatchSpan starts the span covering the HTTP request sent to a
+// query node, which lasts until the response headers and early meta-data have
+// been received.
+func startQueryDispatchSpan(ctx context.Context, endpoint string) (context.Context, RequestSpan) {
+	ctx, span := startRequestSpan(ctx, nil, spanNameDispatchToServer)
+	span.SetAttribute(spanAttribNetPeerName, endpoint)
+	return ctx, span
+}
+
+// queryResultStream wraps the stream of a query, invoking onDone once its rows
+// have all been read, reading them has failed, or it has been closed.  This
+// lets work which must outlive the call that started the query, such as its
+// span, be completed once the query is.
+type queryResultStream struct {
+	QueryResultStream
+
+	onDone   func()
+	doneOnce sync.Once
+}
+
+func newQueryResultStream(stream QueryResultStream, onDone func()) *queryResultStream {
+	s := &queryResultStream{
+		QueryResultStream: stream,
+		onDone:            onDone,
+	}
+
+	if !stream.HasMoreRows() {
+		s.done()
+	}
+
+	return s
+}
+
+func (s *queryResultStream) done() {
+	s.doneOnce.Do(s.onDone)
+}
+
+func (s *queryResultStream) ReadRow() (json.RawMessage, error) {
+	row, err := s.QueryResultStream.ReadRow()
+	if err != nil || !s.QueryResultStream.HasMoreRows() {
+		s.done()
+	}
+
+	return row, err
+}
+
+func (s *queryResultStream) Close() error {
+	err := s.QueryResultStream.Close()
+	s.done()
+	return err
 }
@@ -0,0 +1,12 @@
+package gocbcorex
+
+import (
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+// ResultMeta contains per-operation metadata reported by the server alongside
+// a KV result.  ServerDuration is the time the server spent processing the
+// request, ReadUnits and WriteUnits are the units the operation was billed and
+// ThrottleDuration is the time the request was held back by throttling.  Each
+// field is left as its zero value when the server did not report it.
+type ResultMeta = memdx.ResponseMeta
@@ -0,0 +1,61 @@
+package gocbcorex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+func TestCrudResultMeta(t *testing.T) {
+	client := &KvClientMock{
+		TouchFunc: func(ctx context.Context, req *memdx.TouchRequest) (*memdx.TouchResponse, error) {
+			return &memdx.TouchResponse{
+				Cas: 4,
+				Meta: memdx.ResponseMeta{
+					ServerDuration:   150 * time.Microsecond,
+					ReadUnits:        1,
+					WriteUnits:       2,
+					ThrottleDuration: 10 * time.Microsecond,
+				},
+			}, nil
+		},
+	}
+
+	cc := &CrudComponent{
+		logger:  zap.NewNop(),
+		retries: NewRetryManagerFastFail(),
+		collections: &CollectionResolverMock{
+			ResolveCollectionIDFunc: func(ctx context.Context, scopeName string, collectionName string) (uint32, uint64, error) {
+				return 0, 0, nil
+			},
+		},
+		vbs: &VbucketRouterMock{
+			DispatchByKeyFunc: func(key []byte, replicaIdx uint32) (string, uint16, error) {
+				return "endpoint1", 1, nil
+			},
+		},
+		connManager: &KvClientManagerMock{
+			GetClientFunc: func(ctx context.Context, endpoint string) (KvClient, error) {
+				return client, nil
+			},
+		},
+	}
+
+	res, err := cc.Touch(context.Background(), &TouchOptions{
+		Key: []byte("key"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), res.Cas)
+	assert.Equal(t, ResultMeta{
+		ServerDuration:   150 * time.Microsecond,
+		ReadUnits:        1,
+		WriteUnits:       2,
+		ThrottleDuration: 10 * time.Microsecond,
+	}, res.Meta)
+}
@@ -3,7 +3,14 @@ package gocbcorex
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/couchbase/gocbcorex/cbqueryx"
+	"github.com/couchbase/gocbcorex/memdx"
 )
 
 type RetryController interface {
@@ -14,18 +21,123 @@ type RetryManager interface {
 	NewRetryController() RetryController
 }
 
+// RetrySummary describes the attempts which were made at an operation.
+type RetrySummary struct {
+	// NumAttempts is the number of times the operation was attempted.
+	NumAttempts uint32
+
+	// Reasons are the distinct reasons the operation was retried for.
+	Reasons []RetryReason
+
+	// Endpoints are the distinct endpoints the failed attempts were
+	// dispatched to, where they are known.
+	Endpoints []string
+
+	// BackoffDuration is the total time spent waiting between attempts.
+	BackoffDuration time.Duration
+}
+
+// RetrySummaryError is returned by an operation which failed, describing the
+// attempts which were made at it before it did.
+type RetrySummaryError struct {
+	Cause   error
+	Summary RetrySummary
+}
+
+func (e *RetrySummaryError) Error() string {
+	if e.Summary.NumAttempts <= 1 {
+		return e.Cause.Error()
+	}
+
+	reasons := make([]string, len(e.Summary.Reasons))
+	for reasonIdx, reason := range e.Summary.Reasons {
+		reasons[reasonIdx] = reason.String()
+	}
+
+	return fmt.Sprintf("%s (attempts: %d, retry reasons: %s, backoff: %s)",
+		e.Cause, e.Summary.NumAttempts, strings.Join(reasons, ","), e.Summary.BackoffDuration)
+}
+
+func (e *RetrySummaryError) Unwrap() error {
+	return e.Cause
+}
+
+type retrySummaryBuilder struct {
+	summary RetrySummary
+}
+
+func (b *retrySummaryBuilder) AddAttempt(err error) {
+	b.summary.NumAttempts++
+
+	endpoint := errorEndpoint(err)
+	if endpoint != "" && !slices.Contains(b.summary.Endpoints, endpoint) {
+		b.summary.Endpoints = append(b.summary.Endpoints, endpoint)
+	}
+}
+
+func (b *retrySummaryBuilder) AddRetry(err error, backoff time.Duration) {
+	reason, _ := retryReasonForError(err)
+	if !slices.Contains(b.summary.Reasons, reason) {
+		b.summary.Reasons = append(b.summary.Reasons, reason)
+	}
+
+	b.summary.BackoffDuration += backoff
+}
+
+func (b *retrySummaryBuilder) Wrap(err error) error {
+	return &RetrySummaryError{
+		Cause:   err,
+		Summary: b.summary,
+	}
+}
+
+// errorEndpoint returns the endpoint an error was received from, if the error
+// records it.
+func errorEndpoint(err error) string {
+	var ambiguousErr AmbiguousOperationError
+	if errors.As(err, &ambiguousErr) {
+		return ambiguousErr.DispatchedTo
+	}
+
+	var serverErr memdx.ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.DispatchedTo
+	}
+
+	var queryErr *cbqueryx.QueryError
+	if errors.As(err, &queryErr) {
+		return queryErr.Endpoint
+	}
+
+	return ""
+}
+
 func OrchestrateMemdRetries[RespT any](
 	ctx context.Context,
 	rs RetryManager,
 	fn func() (RespT, error),
-) (RespT, error) {
+) (res RespT, err error) {
 	var opRetryController RetryController
 	var lastErr error
+	var retryAttempts uint32
+	var summary retrySummaryBuilder
+	opMeter := operationMeterFromContext(ctx)
+	startTime := time.Now()
+	defer func() {
+		if retryAttempts > 0 {
+			requestSpanFromContext(ctx).SetAttribute(spanAttribRetryAttempts, retryAttempts)
+		}
+		opMeter.RecordOperation(time.Since(startTime), err)
+		thresholdLogOperationFromContext(ctx).End()
+	}()
+
 	for {
-		res, err := fn()
+		res, err = fn()
 		if err != nil {
+			summary.AddAttempt(err)
+
 			if errors.Is(err, context.DeadlineExceeded) {
-				return res, retrierDeadlineError{err, lastErr}
+				return res, summary.Wrap(retrierDeadlineError{err, lastErr})
 			}
 
 			if opRetryController == nil {
@@ -34,22 +146,28 @@ func OrchestrateMemdRetries[RespT any](
 
 			retryTime, shouldRetry := opRetryController.ShouldRetry(err)
 			if shouldRetry {
+				backoffStart := time.Now()
 				select {
 				case <-time.After(retryTime):
 				case <-ctx.Done():
+					summary.AddRetry(err, time.Since(backoffStart))
+
 					ctxErr := ctx.Err()
 					if errors.Is(ctxErr, context.DeadlineExceeded) {
-						return res, retrierDeadlineError{ctxErr, err}
+						return res, summary.Wrap(retrierDeadlineError{ctxErr, err})
 					} else {
-						return res, err
+						return res, summary.Wrap(err)
 					}
 				}
+				summary.AddRetry(err, time.Since(backoffStart))
+				opMeter.RecordRetry(err)
 
 				lastErr = err
+				retryAttempts++
 				continue
 			}
 
-			return res, err
+			return res, summary.Wrap(err)
 		}
 
 		return res, nil
@@ -60,14 +178,24 @@ func OrchestrateQueryRetries[RespT any](
 	ctx context.Context,
 	rs RetryManager,
 	fn func() (RespT, error),
-) (RespT, error) {
+) (res RespT, err error) {
 	var opRetryController RetryController
 	var lastErr error
+	var summary retrySummaryBuilder
+	opMeter := operationMeterFromContext(ctx)
+	startTime := time.Now()
+	defer func() {
+		opMeter.RecordOperation(time.Since(startTime), err)
+		thresholdLogOperationFromContext(ctx).End()
+	}()
+
 	for {
-		res, err := fn()
+		res, err = fn()
 		if err != nil {
+			summary.AddAttempt(err)
+
 			if errors.Is(err, context.DeadlineExceeded) {
-				return res, retrierDeadlineError{err, lastErr}
+				return res, summary.Wrap(retrierDeadlineError{err, lastErr})
 			}
 
 			if opRetryController == nil {
@@ -76,22 +204,27 @@ func OrchestrateQueryRetries[RespT any](
 
 			retryTime, shouldRetry := opRetryController.ShouldRetry(err)
 			if shouldRetry {
+				backoffStart := time.Now()
 				select {
 				case <-time.After(retryTime):
 				case <-ctx.Done():
+					summary.AddRetry(err, time.Since(backoffStart))
+
 					ctxErr := ctx.Err()
 					if errors.Is(ctxErr, context.DeadlineExceeded) {
-						return res, retrierDeadlineError{ctxErr, err}
+						return res, summary.Wrap(retrierDeadlineError{ctxErr, err})
 					} else {
-						return res, err
+						return res, summary.Wrap(err)
 					}
 				}
+				summary.AddRetry(err, time.Since(backoffStart))
+				opMeter.RecordRetry(err)
 
 				lastErr = err
 				continue
 			}
 
-			return res, err
+			return res, summary.Wrap(err)
 		}
 
 		return res, nil
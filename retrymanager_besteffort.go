@@ -0,0 +1,40 @@
+package gocbcorex
+
+import (
+	"time"
+)
+
+// RetryManagerBestEffort retries only the errors which are known to be safe to
+// retry, classifying each into the RetryReason it is retried for.  Operations
+// which fail ambiguously are only retried if they are idempotent.
+type RetryManagerBestEffort struct {
+	calc BackoffCalculator
+}
+
+func NewRetryManagerBestEffort() *RetryManagerBestEffort {
+	return &RetryManagerBestEffort{
+		calc: ExponentialBackoff(1*time.Millisecond, 500*time.Millisecond, 2),
+	}
+}
+
+func (m *RetryManagerBestEffort) NewRetryController() RetryController {
+	return &retryControllerBestEffort{
+		parent: m,
+	}
+}
+
+type retryControllerBestEffort struct {
+	parent     *RetryManagerBestEffort
+	retryCount uint32
+}
+
+func (rc *retryControllerBestEffort) ShouldRetry(err error) (time.Duration, bool) {
+	_, ok := retryReasonForError(err)
+	if !ok {
+		return 0, false
+	}
+
+	retryTime := rc.parent.calc(rc.retryCount)
+	rc.retryCount++
+	return retryTime, true
+}
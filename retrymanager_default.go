@@ -30,7 +30,8 @@ type retryControllerDefault struct {
 func (rc *retryControllerDefault) isRetriableError(err error) bool {
 	// Implement the default classification of retriable errors...
 	if errors.Is(err, context.DeadlineExceeded) ||
-		errors.Is(err, context.Canceled) {
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, ErrCircuitBreakerOpen) {
 		return false
 	}
 
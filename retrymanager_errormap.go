@@ -0,0 +1,83 @@
+package gocbcorex
+
+import (
+	"errors"
+	"time"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+// RetryManagerErrorMap retries server errors according to the retry
+// information the server provided for them in its error map.  Any errors which
+// the error map does not mark as retriable are passed to the fallback.
+type RetryManagerErrorMap struct {
+	fallback RetryManager
+	calc     BackoffCalculator
+}
+
+func NewRetryManagerErrorMap(fallback RetryManager) *RetryManagerErrorMap {
+	if fallback == nil {
+		fallback = NewRetryManagerFastFail()
+	}
+
+	return &RetryManagerErrorMap{
+		fallback: fallback,
+		calc:     ExponentialBackoff(1*time.Millisecond, 500*time.Millisecond, 2),
+	}
+}
+
+func (m *RetryManagerErrorMap) NewRetryController() RetryController {
+	return &retryControllerErrorMap{
+		parent: m,
+	}
+}
+
+type retryControllerErrorMap struct {
+	parent     *RetryManagerErrorMap
+	fallback   RetryController
+	retryCount uint32
+	firstRetry time.Time
+}
+
+func (rc *retryControllerErrorMap) ShouldRetry(err error) (time.Duration, bool) {
+	var errMapErr memdx.ServerErrorWithErrorMap
+	if errors.As(err, &errMapErr) {
+		entry := errMapErr.Entry
+
+		if entry.Retry != nil {
+			if rc.firstRetry.IsZero() {
+				rc.firstRetry = time.Now()
+			} else if entry.Retry.MaxDuration > 0 &&
+				time.Since(rc.firstRetry) >= entry.Retry.MaxDuration {
+				return 0, false
+			}
+
+			retryTime := entry.Retry.RetryDelay(rc.retryCount)
+			rc.retryCount++
+			return retryTime, true
+		}
+
+		if entry.HasAttribute(memdx.ErrorMapAttributeRetryNow) {
+			// only the first retry is immediate, so that an error which
+			// persists does not have us sending requests in a tight loop.
+			var retryTime time.Duration
+			if rc.retryCount > 0 {
+				retryTime = rc.parent.calc(rc.retryCount - 1)
+			}
+			rc.retryCount++
+			return retryTime, true
+		}
+
+		if entry.HasAttribute(memdx.ErrorMapAttributeRetryLater) {
+			retryTime := rc.parent.calc(rc.retryCount)
+			rc.retryCount++
+			return retryTime, true
+		}
+	}
+
+	if rc.fallback == nil {
+		rc.fallback = rc.parent.fallback.NewRetryController()
+	}
+
+	return rc.fallback.ShouldRetry(err)
+}
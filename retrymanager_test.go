@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocbcorex/cbqueryx"
+	"github.com/couchbase/gocbcorex/memdx"
 )
 
 func TestOrchestrateMemdRetriesDeadlinesInOp(t *testing.T) {
@@ -79,3 +82,134 @@ func TestOrchestrateMemdRetriesDeadlinesInWait(t *testing.T) {
 	require.ErrorIs(t, err, context.DeadlineExceeded)
 	require.ErrorContains(t, err, testErrMsg)
 }
+
+func TestRetryManagerErrorMapHonoursRetrySpec(t *testing.T) {
+	mgr := NewRetryManagerErrorMap(NewRetryManagerFastFail())
+	ctrl := mgr.NewRetryController()
+
+	err := memdx.ServerErrorWithErrorMap{
+		Cause: memdx.ServerError{Status: memdx.Status(0xff01)},
+		Entry: &memdx.ErrorMapEntry{
+			Name: "NEW_TEMP",
+			Retry: &memdx.ErrorMapRetrySpec{
+				Strategy:    memdx.ErrorMapRetryStrategyLinear,
+				Interval:    5 * time.Millisecond,
+				After:       1 * time.Millisecond,
+				MaxDuration: 20 * time.Millisecond,
+			},
+		},
+	}
+
+	retryTime, shouldRetry := ctrl.ShouldRetry(err)
+	require.True(t, shouldRetry)
+	require.Equal(t, 1*time.Millisecond, retryTime)
+
+	retryTime, shouldRetry = ctrl.ShouldRetry(err)
+	require.True(t, shouldRetry)
+	require.Equal(t, 10*time.Millisecond, retryTime)
+
+	time.Sleep(25 * time.Millisecond)
+
+	_, shouldRetry = ctrl.ShouldRetry(err)
+	require.False(t, shouldRetry)
+}
+
+func TestRetryManagerErrorMapAttributes(t *testing.T) {
+	mgr := NewRetryManagerErrorMap(NewRetryManagerFastFail())
+
+	retryNowErr := memdx.ServerErrorWithErrorMap{
+		Cause: memdx.ServerError{Status: memdx.StatusTmpFail},
+		Entry: &memdx.ErrorMapEntry{
+			Attributes: []memdx.ErrorMapAttribute{memdx.ErrorMapAttributeTemp, memdx.ErrorMapAttributeRetryNow},
+		},
+	}
+	retryTime, shouldRetry := mgr.NewRetryController().ShouldRetry(retryNowErr)
+	require.True(t, shouldRetry)
+	require.Zero(t, retryTime)
+
+	// a persistent retry-now error is backed off rather than retried in a
+	// tight loop.
+	ctrl := mgr.NewRetryController()
+	_, _ = ctrl.ShouldRetry(retryNowErr)
+	retryTime, shouldRetry = ctrl.ShouldRetry(retryNowErr)
+	require.True(t, shouldRetry)
+	require.Equal(t, 1*time.Millisecond, retryTime)
+	retryTime, shouldRetry = ctrl.ShouldRetry(retryNowErr)
+	require.True(t, shouldRetry)
+	require.Equal(t, 2*time.Millisecond, retryTime)
+
+	authErr := memdx.ServerErrorWithErrorMap{
+		Cause: memdx.ServerError{Status: memdx.StatusAuthError},
+		Entry: &memdx.ErrorMapEntry{
+			Attributes: []memdx.ErrorMapAttribute{memdx.ErrorMapAttributeAuth},
+		},
+	}
+	_, shouldRetry = mgr.NewRetryController().ShouldRetry(authErr)
+	require.False(t, shouldRetry)
+
+	_, shouldRetry = mgr.NewRetryController().ShouldRetry(errors.New("plain error"))
+	require.False(t, shouldRetry)
+}
+
+func TestRetryManagerBestEffortClassifiesErrors(t *testing.T) {
+	mgr := NewRetryManagerBestEffort()
+
+	retriable := map[string]error{
+		"not my vbucket":      &VbucketMapOutdatedError{Cause: memdx.ErrNotMyVbucket},
+		"collection outdated": &CollectionManifestOutdatedError{},
+		"tmpfail":             memdx.ServerError{Status: memdx.StatusTmpFail},
+		"locked":              memdx.ErrDocLocked,
+		"sync write":          memdx.ServerError{Status: memdx.StatusSyncWriteInProgress},
+		"service unavailable": ErrServiceNotAvailable,
+		"read closed":         memdx.ErrClosedInFlight,
+		"query prepared":      &cbqueryx.QueryServerError{InnerError: cbqueryx.ErrPreparedStatementFailure, Code: 4050},
+		"query retry":         &cbqueryx.QueryServerError{InnerError: cbqueryx.ErrInternalServerError, Code: 5010, Retry: true},
+	}
+	for name, err := range retriable {
+		_, shouldRetry := mgr.NewRetryController().ShouldRetry(err)
+		require.True(t, shouldRetry, name)
+	}
+
+	notRetriable := map[string]error{
+		"plain error":     errors.New("plain error"),
+		"ambiguous write": AmbiguousOperationError{Cause: memdx.ErrClosedInFlight},
+		"doc not found":   memdx.ErrDocNotFound,
+		"circuit breaker": CircuitBreakerOpenError{Endpoint: "endpoint1"},
+		"query parsing":   &cbqueryx.QueryServerError{InnerError: cbqueryx.ErrParsingFailure, Code: 3000},
+	}
+	for name, err := range notRetriable {
+		_, shouldRetry := mgr.NewRetryController().ShouldRetry(err)
+		require.False(t, shouldRetry, name)
+	}
+}
+
+func TestOrchestrateMemdRetriesSummarisesRetries(t *testing.T) {
+	mockCtrl := &RetryControllerMock{
+		ShouldRetryFunc: func(err error) (time.Duration, bool) {
+			return 1 * time.Millisecond, !errors.Is(err, memdx.ErrDocNotFound)
+		},
+	}
+	mockMgr := &RetryManagerMock{
+		NewRetryControllerFunc: func() RetryController { return mockCtrl },
+	}
+
+	errs := []error{
+		memdx.ServerError{Status: memdx.StatusTmpFail, DispatchedTo: "endpoint1"},
+		memdx.ServerError{Status: memdx.StatusLocked, DispatchedTo: "endpoint2"},
+		memdx.ServerError{Status: memdx.StatusTmpFail, DispatchedTo: "endpoint1"},
+		memdx.ServerError{Cause: memdx.ErrDocNotFound, Status: memdx.StatusKeyNotFound, DispatchedTo: "endpoint1"},
+	}
+	fnCalls := 0
+	_, err := OrchestrateMemdRetries(context.Background(), mockMgr, func() (int, error) {
+		fnCalls++
+		return 0, errs[fnCalls-1]
+	})
+	require.ErrorIs(t, err, memdx.ErrDocNotFound)
+
+	var summaryErr *RetrySummaryError
+	require.ErrorAs(t, err, &summaryErr)
+	require.Equal(t, uint32(4), summaryErr.Summary.NumAttempts)
+	require.Equal(t, []RetryReason{RetryReasonTemporaryFailure, RetryReasonDocumentLocked}, summaryErr.Summary.Reasons)
+	require.Equal(t, []string{"endpoint1", "endpoint2"}, summaryErr.Summary.Endpoints)
+	require.GreaterOrEqual(t, summaryErr.Summary.BackoffDuration, 3*time.Millisecond)
+}
@@ -0,0 +1,111 @@
+package gocbcorex
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/couchbase/gocbcorex/cbqueryx"
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+// RetryReason describes why a failed operation can be retried.
+type RetryReason int
+
+const (
+	RetryReasonUnknown RetryReason = iota
+	RetryReasonNotMyVbucket
+	RetryReasonCollectionOutdated
+	RetryReasonTemporaryFailure
+	RetryReasonDocumentLocked
+	RetryReasonSyncWriteInProgress
+	RetryReasonPoolStillConnecting
+	RetryReasonServiceNotAvailable
+	RetryReasonSocketClosedInFlight
+	RetryReasonQueryPreparedStatementFailure
+	RetryReasonQueryIndexNotFound
+	RetryReasonQueryErrorRetryable
+)
+
+func (r RetryReason) String() string {
+	switch r {
+	case RetryReasonNotMyVbucket:
+		return "not_my_vbucket"
+	case RetryReasonCollectionOutdated:
+		return "collection_outdated"
+	case RetryReasonTemporaryFailure:
+		return "temporary_failure"
+	case RetryReasonDocumentLocked:
+		return "document_locked"
+	case RetryReasonSyncWriteInProgress:
+		return "sync_write_in_progress"
+	case RetryReasonPoolStillConnecting:
+		return "pool_still_connecting"
+	case RetryReasonServiceNotAvailable:
+		return "service_not_available"
+	case RetryReasonSocketClosedInFlight:
+		return "socket_closed_in_flight"
+	case RetryReasonQueryPreparedStatementFailure:
+		return "query_prepared_statement_failure"
+	case RetryReasonQueryIndexNotFound:
+		return "query_index_not_found"
+	case RetryReasonQueryErrorRetryable:
+		return "query_error_retryable"
+	}
+
+	return "unknown"
+}
+
+// retryReasonForError classifies an error into the reason it can be retried
+// for, returning false if it is not known to be safe to retry.
+func retryReasonForError(err error) (RetryReason, bool) {
+	// a mutation which may already have been applied cannot be sent again,
+	// only the idempotent operations which failed in flight are retried.
+	var ambiguousErr AmbiguousOperationError
+	if errors.As(err, &ambiguousErr) {
+		return RetryReasonUnknown, false
+	}
+
+	switch {
+	case errors.Is(err, ErrVbucketMapOutdated),
+		errors.Is(err, memdx.ErrNotMyVbucket):
+		return RetryReasonNotMyVbucket, true
+	case errors.Is(err, ErrCollectionManifestOutdated):
+		return RetryReasonCollectionOutdated, true
+	case errors.Is(err, memdx.ErrDocLocked):
+		return RetryReasonDocumentLocked, true
+	case errors.Is(err, ErrPoolStillConnecting):
+		return RetryReasonPoolStillConnecting, true
+	case errors.Is(err, ErrServiceNotAvailable):
+		return RetryReasonServiceNotAvailable, true
+	case errors.Is(err, memdx.ErrClosedInFlight):
+		return RetryReasonSocketClosedInFlight, true
+	}
+
+	var serverErr memdx.ServerError
+	if errors.As(err, &serverErr) {
+		switch serverErr.Status {
+		case memdx.StatusTmpFail, memdx.StatusBusy:
+			return RetryReasonTemporaryFailure, true
+		case memdx.StatusLocked:
+			return RetryReasonDocumentLocked, true
+		case memdx.StatusSyncWriteInProgress, memdx.StatusSyncWriteReCommitInProgress:
+			return RetryReasonSyncWriteInProgress, true
+		}
+	}
+
+	var queryErr *cbqueryx.QueryServerError
+	if errors.As(err, &queryErr) {
+		switch {
+		case queryErr.Code == 4040 || queryErr.Code == 4050 || queryErr.Code == 4070:
+			// the prepared statement is unknown to, or stale on, this node
+			// and is prepared again when retried.
+			return RetryReasonQueryPreparedStatementFailure, true
+		case queryErr.Code == 5000 && strings.Contains(queryErr.Msg, "queryport.indexNotFound"):
+			return RetryReasonQueryIndexNotFound, true
+		case queryErr.Retry:
+			return RetryReasonQueryErrorRetryable, true
+		}
+	}
+
+	return RetryReasonUnknown, false
+}
@@ -0,0 +1,80 @@
+package gocbcorex
+
+import (
+	"context"
+	"sync"
+
+	"github.com/couchbase/gocbcorex/memdx"
+	"go.uber.org/zap"
+)
+
+type StatsComponent struct {
+	logger      *zap.Logger
+	retries     RetryManager
+	connManager KvClientManager
+}
+
+type StatsOptions struct {
+	GroupName  string
+	OnBehalfOf string
+}
+
+// StatsServerResult holds the statistics returned by a single node, or the
+// error which occurred while fetching them.
+type StatsServerResult struct {
+	Stats map[string]string
+	Err   error
+}
+
+type StatsResult struct {
+	Servers map[string]StatsServerResult
+}
+
+// Stats fetches a group of statistics from every node the connection manager
+// knows about.  A failure to fetch from one node does not fail the whole
+// operation, instead the error is reported against that node in the result.
+func (sc *StatsComponent) Stats(ctx context.Context, opts *StatsOptions) (*StatsResult, error) {
+	endpoints := sc.connManager.GetEndpoints()
+	if len(endpoints) == 0 {
+		return nil, ErrNoClientsAvailable
+	}
+
+	var lock sync.Mutex
+	servers := make(map[string]StatsServerResult, len(endpoints))
+
+	var wg sync.WaitGroup
+	for _, endpoint := range endpoints {
+		wg.Add(1)
+		go func(endpoint string) {
+			defer wg.Done()
+
+			resp, err := OrchestrateMemdRetries(ctx, sc.retries, func() (*memdx.StatsResponse, error) {
+				return OrchestrateMemdClient(ctx, sc.connManager, endpoint, func(client KvClient) (*memdx.StatsResponse, error) {
+					return client.Stats(ctx, &memdx.StatsRequest{
+						GroupName:  opts.GroupName,
+						OnBehalfOf: opts.OnBehalfOf,
+					})
+				})
+			})
+
+			var serverResult StatsServerResult
+			if err != nil {
+				sc.logger.Debug("failed to fetch stats from endpoint",
+					zap.String("endpoint", endpoint),
+					zap.Error(err))
+				serverResult.Err = err
+			} else {
+				serverResult.Stats = resp.Stats
+			}
+
+			lock.Lock()
+			servers[endpoint] = serverResult
+			lock.Unlock()
+		}(endpoint)
+	}
+	wg.Wait()
+
+	return &StatsResult{
+		Servers: servers,
+	}, nil
+}
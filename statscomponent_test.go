@@ -0,0 +1,73 @@
+package gocbcorex
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+func TestStatsComponentFansOut(t *testing.T) {
+	expectedErr := errors.New("stats failed")
+
+	clients := map[string]KvClient{
+		"endpoint1": &KvClientMock{
+			StatsFunc: func(ctx context.Context, req *memdx.StatsRequest) (*memdx.StatsResponse, error) {
+				assert.Equal(t, "collections", req.GroupName)
+				return &memdx.StatsResponse{
+					Stats: map[string]string{"manifest_uid": "5"},
+				}, nil
+			},
+		},
+		"endpoint2": &KvClientMock{
+			StatsFunc: func(ctx context.Context, req *memdx.StatsRequest) (*memdx.StatsResponse, error) {
+				return nil, expectedErr
+			},
+		},
+	}
+
+	sc := &StatsComponent{
+		logger:  zap.NewNop(),
+		retries: NewRetryManagerFastFail(),
+		connManager: &KvClientManagerMock{
+			GetEndpointsFunc: func() []string {
+				return []string{"endpoint1", "endpoint2"}
+			},
+			GetClientFunc: func(ctx context.Context, endpoint string) (KvClient, error) {
+				return clients[endpoint], nil
+			},
+		},
+	}
+
+	res, err := sc.Stats(context.Background(), &StatsOptions{
+		GroupName: "collections",
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Servers, 2)
+
+	assert.NoError(t, res.Servers["endpoint1"].Err)
+	assert.Equal(t, map[string]string{"manifest_uid": "5"}, res.Servers["endpoint1"].Stats)
+
+	assert.ErrorIs(t, res.Servers["endpoint2"].Err, expectedErr)
+	assert.Nil(t, res.Servers["endpoint2"].Stats)
+}
+
+func TestStatsComponentNoEndpoints(t *testing.T) {
+	sc := &StatsComponent{
+		logger:  zap.NewNop(),
+		retries: NewRetryManagerFastFail(),
+		connManager: &KvClientManagerMock{
+			GetEndpointsFunc: func() []string {
+				return nil
+			},
+		},
+	}
+
+	_, err := sc.Stats(context.Background(), &StatsOptions{})
+	assert.ErrorIs(t, err, ErrNoClientsAvailable)
+}
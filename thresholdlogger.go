@@ -0,0 +1,335 @@
+package gocbcorex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+type ThresholdLoggerOptions struct {
+	Logger *zap.Logger
+
+	// ReportInterval is how often a report is logged, defaults to 10 seconds.
+	ReportInterval time.Duration
+
+	// SampleSize is the number of operations of each service included in
+	// each report, the slowest being kept, defaults to 10.
+	SampleSize int
+
+	// KvThreshold defaults to 500 milliseconds.
+	KvThreshold time.Duration
+
+	// QueryThreshold defaults to 1 second.
+	QueryThreshold time.Duration
+
+	// ManagementThreshold defaults to 1 second.
+	ManagementThreshold time.Duration
+}
+
+// ThresholdLogger collects the operations which take longer than the threshold
+// for their service and periodically logs a report of the slowest of them,
+// including where the time was spent on their last dispatch.
+type ThresholdLogger struct {
+	logger         *zap.Logger
+	reportInterval time.Duration
+	sampleSize     int
+	thresholds     map[string]time.Duration
+
+	lock      sync.Mutex
+	services  map[string]*thresholdLogService
+	stopSigCh chan struct{}
+}
+
+type thresholdLogService struct {
+	count   uint64
+	samples []*thresholdLogOperation
+}
+
+func NewThresholdLogger(opts *ThresholdLoggerOptions) *ThresholdLogger {
+	if opts == nil {
+		opts = &ThresholdLoggerOptions{}
+	}
+
+	reportInterval := opts.ReportInterval
+	if reportInterval <= 0 {
+		reportInterval = 10 * time.Second
+	}
+
+	sampleSize := opts.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = 10
+	}
+
+	kvThreshold := opts.KvThreshold
+	if kvThreshold <= 0 {
+		kvThreshold = 500 * time.Millisecond
+	}
+
+	queryThreshold := opts.QueryThreshold
+	if queryThreshold <= 0 {
+		queryThreshold = 1 * time.Second
+	}
+
+	managementThreshold := opts.ManagementThreshold
+	if managementThreshold <= 0 {
+		managementThreshold = 1 * time.Second
+	}
+
+	return &ThresholdLogger{
+		logger:         loggerOrNop(opts.Logger),
+		reportInterval: reportInterval,
+		sampleSize:     sampleSize,
+		thresholds: map[string]time.Duration{
+			meterServiceKv:         kvThreshold,
+			meterServiceQuery:      queryThreshold,
+			meterServiceManagement: managementThreshold,
+		},
+		services: make(map[string]*thresholdLogService),
+	}
+}
+
+// thresholdLogOperation records the timings of a single operation, so that it
+// can be reported if it turns out to be slow.  A nil thresholdLogOperation
+// records nothing.
+type thresholdLogOperation struct {
+	logger    *ThresholdLogger
+	service   string
+	operation string
+	startTime time.Time
+
+	totalDuration time.Duration
+	endpoint      string
+	lastDispatch  *thresholdLogDispatch
+}
+
+// thresholdLogDispatch describes a single request sent to the server for an
+// operation.
+type thresholdLogDispatch struct {
+	EncodeDuration   time.Duration
+	DispatchDuration time.Duration
+	ServerDuration   time.Duration
+	Opaque           uint32
+
+	// the addresses are only looked up for operations which are reported,
+	// as formatting them for every operation would be wasteful.
+	client *kvClient
+}
+
+type thresholdLogOperationCtxKey struct{}
+
+// withThresholdLogging returns a context which causes the time spent on the
+// operation it is used for to be recorded.  If logger is nil, ctx is returned
+// unchanged.
+func withThresholdLogging(ctx context.Context, logger *ThresholdLogger, service, operation string) context.Context {
+	if logger == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, thresholdLogOperationCtxKey{}, &thresholdLogOperation{
+		logger:    logger,
+		service:   service,
+		operation: operation,
+		startTime: time.Now(),
+	})
+}
+
+func thresholdLogOperationFromContext(ctx context.Context) *thresholdLogOperation {
+	op, _ := ctx.Value(thresholdLogOperationCtxKey{}).(*thresholdLogOperation)
+	return op
+}
+
+func (op *thresholdLogOperation) SetEndpoint(endpoint string) {
+	if op == nil {
+		return
+	}
+
+	op.endpoint = endpoint
+}
+
+// BeginDispatch records a new request being sent for the operation, the
+// returned dispatch being filled in once its response arrives.
+func (op *thresholdLogOperation) BeginDispatch(encodeDuration time.Duration, client *kvClient) *thresholdLogDispatch {
+	if op == nil {
+		return nil
+	}
+
+	dispatch := &thresholdLogDispatch{
+		EncodeDuration: encodeDuration,
+		client:         client,
+	}
+	op.lastDispatch = dispatch
+	return dispatch
+}
+
+func (d *thresholdLogDispatch) End(dispatchDuration time.Duration, resp *memdx.Packet) {
+	if d == nil {
+		return
+	}
+
+	d.DispatchDuration = dispatchDuration
+	if resp != nil {
+		d.Opaque = resp.Opaque
+
+		meta, err := memdx.ParseResponseMeta(resp)
+		if err == nil {
+			d.ServerDuration = meta.ServerDuration
+		}
+	}
+}
+
+// End completes the operation, passing it to the logger if it exceeded the
+// threshold of its service.
+func (op *thresholdLogOperation) End() {
+	if op == nil {
+		return
+	}
+
+	op.totalDuration = time.Since(op.startTime)
+	op.logger.recordOperation(op)
+}
+
+func (l *ThresholdLogger) recordOperation(op *thresholdLogOperation) {
+	if op.totalDuration < l.thresholds[op.service] {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	service := l.services[op.service]
+	if service == nil {
+		service = &thresholdLogService{}
+		l.services[op.service] = service
+	}
+
+	service.count++
+
+	// the samples are kept sorted by descending duration, so once we have
+	// enough of them, the last one is the one to replace.
+	if len(service.samples) >= l.sampleSize {
+		if op.totalDuration <= service.samples[len(service.samples)-1].totalDuration {
+			return
+		}
+		service.samples = service.samples[:len(service.samples)-1]
+	}
+
+	service.samples = append(service.samples, op)
+	slices.SortStableFunc(service.samples, func(a, b *thresholdLogOperation) bool {
+		return a.totalDuration > b.totalDuration
+	})
+}
+
+type thresholdLogItemJson struct {
+	OperationName          string `json:"operation_name"`
+	TotalDurationUs        uint64 `json:"total_duration_us"`
+	EncodeDurationUs       uint64 `json:"encode_duration_us,omitempty"`
+	LastDispatchDurationUs uint64 `json:"last_dispatch_duration_us,omitempty"`
+	LastServerDurationUs   uint64 `json:"last_server_duration_us,omitempty"`
+	LastDispatchedTo       string `json:"last_dispatched_to,omitempty"`
+	LastLocalSocket        string `json:"last_local_socket,omitempty"`
+	LastRemoteSocket       string `json:"last_remote_socket,omitempty"`
+	LastOperationID        string `json:"last_operation_id,omitempty"`
+}
+
+type thresholdLogServiceJson struct {
+	TotalCount  uint64                 `json:"total_count"`
+	TopRequests []thresholdLogItemJson `json:"top_requests"`
+}
+
+// Report returns a JSON report of the operations which exceeded their
+// threshold since the previous report, or nil if there were none.
+func (l *ThresholdLogger) Report() []byte {
+	l.lock.Lock()
+	services := l.services
+	l.services = make(map[string]*thresholdLogService)
+	l.lock.Unlock()
+
+	if len(services) == 0 {
+		return nil
+	}
+
+	report := make(map[string]thresholdLogServiceJson, len(services))
+	for serviceName, service := range services {
+		serviceReport := thresholdLogServiceJson{
+			TotalCount: service.count,
+		}
+		for _, sample := range service.samples {
+			item := thresholdLogItemJson{
+				OperationName:    sample.operation,
+				TotalDurationUs:  uint64(sample.totalDuration / time.Microsecond),
+				LastDispatchedTo: sample.endpoint,
+			}
+			if dispatch := sample.lastDispatch; dispatch != nil {
+				item.EncodeDurationUs = uint64(dispatch.EncodeDuration / time.Microsecond)
+				item.LastDispatchDurationUs = uint64(dispatch.DispatchDuration / time.Microsecond)
+				item.LastServerDurationUs = uint64(dispatch.ServerDuration / time.Microsecond)
+				if dispatch.client != nil {
+					item.LastDispatchedTo = dispatch.client.RemoteAddress()
+					item.LastLocalSocket = dispatch.client.cli.LocalAddr()
+					item.LastRemoteSocket = dispatch.client.cli.RemoteAddr()
+				}
+				// opaques start from 1, so zero means no response arrived
+				if dispatch.Opaque != 0 {
+					item.LastOperationID = fmt.Sprintf("0x%x", dispatch.Opaque)
+				}
+			}
+			serviceReport.TopRequests = append(serviceReport.TopRequests, item)
+		}
+		report[serviceName] = serviceReport
+	}
+
+	reportJson, err := json.Marshal(report)
+	if err != nil {
+		l.logger.Debug("failed to marshal threshold log report", zap.Error(err))
+		return nil
+	}
+
+	return reportJson
+}
+
+// Start begins periodically logging reports, until Close is called.
+func (l *ThresholdLogger) Start() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.stopSigCh != nil {
+		return
+	}
+
+	stopSigCh := make(chan struct{})
+	l.stopSigCh = stopSigCh
+
+	go func() {
+		ticker := time.NewTicker(l.reportInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if report := l.Report(); report != nil {
+					l.logger.Warn("operations exceeded their threshold",
+						zap.ByteString("report", report))
+				}
+			case <-stopSigCh:
+				return
+			}
+		}
+	}()
+}
+
+func (l *ThresholdLogger) Close() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.stopSigCh != nil {
+		close(l.stopSigCh)
+		l.stopSigCh = nil
+	}
+}
@@ -0,0 +1,93 @@
+package gocbcorex
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocbcorex/memdx"
+	"github.com/couchbase/gocbcorex/memdx/memdtest"
+)
+
+func TestThresholdLoggerKeepsSlowestOperations(t *testing.T) {
+	logger := NewThresholdLogger(&ThresholdLoggerOptions{
+		SampleSize:  2,
+		KvThreshold: 10 * time.Millisecond,
+	})
+
+	assert.Nil(t, logger.Report())
+
+	for i, duration := range []time.Duration{30, 5, 50, 20} {
+		ctx := withThresholdLogging(context.Background(), logger, meterServiceKv, "Get")
+		op := thresholdLogOperationFromContext(ctx)
+		op.startTime = time.Now().Add(-duration * time.Millisecond)
+
+		dispatch := op.BeginDispatch(time.Duration(i)*time.Microsecond, nil)
+		dispatch.DispatchDuration = duration * time.Millisecond / 2
+		dispatch.ServerDuration = 1 * time.Millisecond
+		dispatch.Opaque = uint32(i + 1)
+
+		op.End()
+	}
+
+	var report map[string]thresholdLogServiceJson
+	require.NoError(t, json.Unmarshal(logger.Report(), &report))
+
+	assert.Equal(t, uint64(3), report["kv"].TotalCount)
+	require.Len(t, report["kv"].TopRequests, 2)
+
+	slowest := report["kv"].TopRequests[0]
+	assert.Equal(t, "Get", slowest.OperationName)
+	assert.GreaterOrEqual(t, slowest.TotalDurationUs, uint64(50000))
+	assert.Equal(t, uint64(2), slowest.EncodeDurationUs)
+	assert.Equal(t, uint64(25000), slowest.LastDispatchDurationUs)
+	assert.Equal(t, uint64(1000), slowest.LastServerDurationUs)
+	assert.Equal(t, "0x3", slowest.LastOperationID)
+
+	assert.Equal(t, "0x1", report["kv"].TopRequests[1].LastOperationID)
+
+	// each report only covers the operations since the previous one
+	assert.Nil(t, logger.Report())
+}
+
+func TestThresholdLoggerRecordsKvDispatch(t *testing.T) {
+	srv, err := memdtest.NewServer(&memdtest.ServerOptions{
+		Buckets: []*memdtest.Bucket{memdtest.NewBucket(&memdtest.BucketOptions{Name: "default"})},
+	})
+	require.NoError(t, err)
+	defer srv.Close()
+
+	cli, err := NewKvClient(context.Background(), &KvClientConfig{
+		Address:        srv.Addr(),
+		SelectedBucket: "default",
+	}, &KvClientOptions{})
+	require.NoError(t, err)
+	defer cli.Close()
+
+	logger := NewThresholdLogger(&ThresholdLoggerOptions{
+		KvThreshold: 1 * time.Nanosecond,
+	})
+
+	ctx := withThresholdLogging(context.Background(), logger, meterServiceKv, "Set")
+	_, err = cli.Set(ctx, &memdx.SetRequest{
+		Key:   []byte("key"),
+		Value: []byte("value"),
+	})
+	require.NoError(t, err)
+	thresholdLogOperationFromContext(ctx).End()
+
+	var report map[string]thresholdLogServiceJson
+	require.NoError(t, json.Unmarshal(logger.Report(), &report))
+
+	require.Len(t, report["kv"].TopRequests, 1)
+	item := report["kv"].TopRequests[0]
+	assert.Equal(t, "Set", item.OperationName)
+	assert.Equal(t, srv.Addr(), item.LastDispatchedTo)
+	assert.NotEmpty(t, item.LastLocalSocket)
+	assert.NotEmpty(t, item.LastRemoteSocket)
+	assert.NotEmpty(t, item.LastOperationID)
+}
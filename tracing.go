@@ -0,0 +1,88 @@
+package gocbcorex
+
+import "context"
+
+// RequestSpan represents a single traced unit of work.
+type RequestSpan interface {
+	// SetAttribute attaches a key/value pair to the span.
+	SetAttribute(key string, value interface{})
+
+	// End marks the span as complete.
+	End()
+
+	// TraceParent returns the W3C traceparent value identifying this span.
+	// It is propagated to the server so that server-side work is linked to
+	// the client trace.  An empty string disables propagation.
+	TraceParent() string
+}
+
+// RequestTracer creates spans for the requests performed by the SDK.  It maps
+// directly onto an OpenTelemetry Tracer: any parent span is taken from ctx and
+// the returned context must carry the new span.
+type RequestTracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, RequestSpan)
+}
+
+const (
+	spanAttribDbSystem      = "db.system"
+	spanAttribDbOperation   = "db.operation"
+	spanAttribNetPeerName   = "net.peer.name"
+	spanAttribNetHostName   = "net.host.name"
+	spanAttribRetryAttempts = "db.couchbase.retries"
+
+	spanNameDispatchToServer = "dispatch_to_server"
+)
+
+type noopRequestSpan struct{}
+
+func (s noopRequestSpan) SetAttribute(key string, value interface{}) {}
+func (s noopRequestSpan) End()                                       {}
+func (s noopRequestSpan) TraceParent() string                        { return "" }
+
+type requestSpanCtxKey struct{}
+
+type requestSpanCtxValue struct {
+	tracer RequestTracer
+	span   RequestSpan
+}
+
+// startRequestSpan starts a new span for an operation.  If tracer is nil, the
+// tracer of any span already in ctx is used instead, and if there is none the
+// returned span does nothing.
+func startRequestSpan(ctx context.Context, tracer RequestTracer, name string) (context.Context, RequestSpan) {
+	if tracer == nil {
+		parent, _ := ctx.Value(requestSpanCtxKey{}).(*requestSpanCtxValue)
+		if parent == nil {
+			return ctx, noopRequestSpan{}
+		}
+
+		tracer = parent.tracer
+	}
+
+	ctx, span := tracer.StartSpan(ctx, name)
+	span.SetAttribute(spanAttribDbSystem, "couchbase")
+
+	ctx = context.WithValue(ctx, requestSpanCtxKey{}, &requestSpanCtxValue{
+		tracer: tracer,
+		span:   span,
+	})
+	return ctx, span
+}
+
+// startOpRequestSpan starts the top-level span for an SDK operation.
+func startOpRequestSpan(ctx context.Context, tracer RequestTracer, opName string) (context.Context, RequestSpan) {
+	ctx, span := startRequestSpan(ctx, tracer, opName)
+	span.SetAttribute(spanAttribDbOperation, opName)
+	return ctx, span
+}
+
+// requestSpanFromContext returns the innermost span in ctx, or a span which
+// does nothing if ctx is not being traced.
+func requestSpanFromContext(ctx context.Context) RequestSpan {
+	parent, _ := ctx.Value(requestSpanCtxKey{}).(*requestSpanCtxValue)
+	if parent == nil {
+		return noopRequestSpan{}
+	}
+
+	return parent.span
+}
@@ -0,0 +1,233 @@
+package gocbcorex
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/couchbase/gocbcorex/cbmgmtx"
+	"github.com/couchbase/gocbcorex/memdx"
+)
+
+type testRequestSpan struct {
+	tracer     *testRequestTracer
+	id         int
+	name       string
+	parent     *testRequestSpan
+	attributes map[string]interface{}
+	ended      bool
+}
+
+func (s *testRequestSpan) SetAttribute(key string, value interface{}) {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	s.attributes[key] = value
+}
+
+func (s *testRequestSpan) End() {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	s.ended = true
+}
+
+func (s *testRequestSpan) TraceParent() string {
+	return fmt.Sprintf("00-0af7651916cd43dd8448eb211c80319c-%016x-01", s.id)
+}
+
+type testRequestSpanCtxKey struct{}
+
+type testRequestTracer struct {
+	lock  sync.Mutex
+	spans []*testRequestSpan
+}
+
+func (t *testRequestTracer) StartSpan(ctx context.Context, name string) (context.Context, RequestSpan) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	parent, _ := ctx.Value(testRequestSpanCtxKey{}).(*testRequestSpan)
+	span := &testRequestSpan{
+		tracer:     t,
+		id:         len(t.spans) + 1,
+		name:       name,
+		parent:     parent,
+		attributes: make(map[string]interface{}),
+	}
+	t.spans = append(t.spans, span)
+
+	return context.WithValue(ctx, testRequestSpanCtxKey{}, span), span
+}
+
+func TestRequestSpanNesting(t *testing.T) {
+	tracer := &testRequestTracer{}
+
+	ctx, span := startRequestSpan(context.Background(), nil, "untraced")
+	assert.Equal(t, noopRequestSpan{}, span)
+	assert.Equal(t, "", span.TraceParent())
+
+	ctx, opSpan := startOpRequestSpan(ctx, tracer, "get")
+	assert.Equal(t, opSpan, requestSpanFromContext(ctx))
+
+	// child spans pick up the tracer from the context
+	childCtx, childSpan := startRequestSpan(ctx, nil, spanNameDispatchToServer)
+	assert.Equal(t, childSpan, requestSpanFromContext(childCtx))
+	childSpan.End()
+	opSpan.End()
+
+	require.Len(t, tracer.spans, 2)
+	assert.Equal(t, "get", tracer.spans[0].name)
+	assert.Equal(t, "get", tracer.spans[0].attributes[spanAttribDbOperation])
+	assert.Equal(t, "couchbase", tracer.spans[0].attributes[spanAttribDbSystem])
+	assert.Equal(t, tracer.spans[0], tracer.spans[1].parent)
+	assert.True(t, tracer.spans[0].ended)
+	assert.True(t, tracer.spans[1].ended)
+}
+
+func TestCrudRequestSpans(t *testing.T) {
+	tracer := &testRequestTracer{}
+
+	var dispatchCtx context.Context
+	client := &KvClientMock{
+		TouchFunc: func(ctx context.Context, req *memdx.TouchRequest) (*memdx.TouchResponse, error) {
+			dispatchCtx = ctx
+			return &memdx.TouchResponse{}, nil
+		},
+	}
+
+	cc := &CrudComponent{
+		logger:  zap.NewNop(),
+		retries: NewRetryManagerFastFail(),
+		tracer:  tracer,
+		collections: &CollectionResolverMock{
+			ResolveCollectionIDFunc: func(ctx context.Context, scopeName string, collectionName string) (uint32, uint64, error) {
+				return 0, 0, nil
+			},
+		},
+		vbs: &VbucketRouterMock{
+			DispatchByKeyFunc: func(key []byte, replicaIdx uint32) (string, uint16, error) {
+				return "endpoint1", 1, nil
+			},
+		},
+		connManager: &KvClientManagerMock{
+			GetClientFunc: func(ctx context.Context, endpoint string) (KvClient, error) {
+				return client, nil
+			},
+		},
+	}
+
+	_, err := cc.Touch(context.Background(), &TouchOptions{
+		Key: []byte("key"),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, tracer.spans, 1)
+	assert.Equal(t, "touch", tracer.spans[0].name)
+	assert.True(t, tracer.spans[0].ended)
+	assert.Equal(t, RequestSpan(tracer.spans[0]), requestSpanFromContext(dispatchCtx))
+}
+
+func TestMgmtTraceParentHeader(t *testing.T) {
+	tracer := &testRequestTracer{}
+	meter := NewAggregatingMeter(nil)
+
+	var traceParent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("traceparent")
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	mgmt := NewMgmtComponent(NewRetryManagerFastFail(), &MgmtComponentConfig{
+		HttpRoundTripper: http.DefaultTransport,
+		Endpoints:        []string{srv.URL},
+		Authenticator: &PasswordAuthenticator{
+			Username: "user",
+			Password: "pass",
+		},
+	}, &MgmtComponentOptions{
+		Logger: zap.NewNop(),
+		Tracer: tracer,
+		Meter:  meter,
+	})
+
+	_, err := mgmt.GetAllBuckets(context.Background(), &cbmgmtx.GetAllBucketsOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, tracer.spans, 1)
+	assert.Equal(t, tracer.spans[0].TraceParent(), traceParent)
+
+	// each management operation is reported under its own name
+	assert.Equal(t, "manager_get_all_buckets", tracer.spans[0].name)
+
+	var buf bytes.Buffer
+	require.NoError(t, meter.WritePrometheus(&buf))
+	assert.Contains(t, buf.String(),
+		`db_couchbase_operation_duration_count{db_couchbase_service="management",db_operation="manager_get_all_buckets",outcome="success"} 1`)
+}
+
+func TestQueryRequestSpans(t *testing.T) {
+	tracer := &testRequestTracer{}
+
+	var traceParent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("traceparent")
+		_, _ = w.Write([]byte(`{"requestID":"1","results":[{"a":1},{"a":2}],"status":"success",` +
+			`"metrics":{"elapsedTime":"1ms","executionTime":"1ms","resultCount":2,"resultSize":14}}`))
+	}))
+	defer srv.Close()
+
+	query := NewQueryComponent(NewRetryManagerFastFail(), &QueryComponentConfig{
+		HttpRoundTripper: http.DefaultTransport,
+		Endpoints:        []string{srv.URL},
+		Authenticator: &PasswordAuthenticator{
+			Username: "user",
+			Password: "pass",
+		},
+	}, &QueryComponentOptions{
+		Logger: zap.NewNop(),
+		Tracer: tracer,
+	})
+
+	rows, err := query.Query(context.Background(), &QueryOptions{
+		Statement: "SELECT 1",
+	})
+	require.NoError(t, err)
+
+	// the request is dispatched under its own span, but the query itself is
+	// only complete once its rows have been read.
+	require.Len(t, tracer.spans, 2)
+	assert.Equal(t, "query", tracer.spans[0].name)
+	assert.False(t, tracer.spans[0].ended)
+	assert.Equal(t, spanNameDispatchToServer, tracer.spans[1].name)
+	assert.Equal(t, tracer.spans[0], tracer.spans[1].parent)
+	assert.Equal(t, srv.URL, tracer.spans[1].attributes[spanAttribNetPeerName])
+	assert.True(t, tracer.spans[1].ended)
+	assert.Equal(t, tracer.spans[1].TraceParent(), traceParent)
+
+	_, err = rows.ReadRow()
+	require.NoError(t, err)
+	assert.False(t, tracer.spans[0].ended)
+
+	_, err = rows.ReadRow()
+	require.NoError(t, err)
+	assert.True(t, tracer.spans[0].ended)
+
+	// a query whose rows are not all read ends once it is closed
+	rows, err = query.Query(context.Background(), &QueryOptions{
+		Statement: "SELECT 1",
+	})
+	require.NoError(t, err)
+
+	require.Len(t, tracer.spans, 4)
+	assert.False(t, tracer.spans[2].ended)
+	require.NoError(t, rows.Close())
+	assert.True(t, tracer.spans[2].ended)
+}
@@ -87,6 +87,13 @@ func (vbMap VbucketMap) VbucketsByServer(replicaID int) ([][]uint16, error) {
 		}
 
 		serverID := entry[replicaID]
+		if serverID < 0 {
+			// listing the vbucket against no server would leave the listing
+			// silently incomplete, such as during a failover.
+			return nil, noServerAssignedError{
+				RequestedVbId: uint16(vbID),
+			}
+		}
 
 		for len(vbList) <= serverID {
 			vbList = append(vbList, nil)
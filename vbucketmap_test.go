@@ -37,3 +37,14 @@ func TestVbucketMapWith13Vbs(t *testing.T) {
 	require.Equal(t, uint16(0x0008), vbMap.VbucketByKey([]byte("hello")))
 	require.Equal(t, uint16(0x0003), vbMap.VbucketByKey([]byte("hello world, I am a super long key lets see if it works")))
 }
+
+func TestVbucketMapVbucketsByServerUnassigned(t *testing.T) {
+	vbMap := NewVbucketMap([][]int{{0, 1}, {1, -1}}, 1)
+
+	vbList, err := vbMap.VbucketsByServer(0)
+	require.NoError(t, err)
+	require.Equal(t, [][]uint16{{0}, {1}}, vbList)
+
+	_, err = vbMap.VbucketsByServer(1)
+	require.ErrorIs(t, err, ErrNoServerAssigned)
+}
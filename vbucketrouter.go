@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net"
+	"strings"
 
 	"github.com/couchbase/gocbcorex/contrib/cbconfig"
 	"github.com/couchbase/gocbcorex/memdx"
@@ -19,6 +21,7 @@ type VbucketRouter interface {
 	UpdateRoutingInfo(*VbucketRoutingInfo)
 	DispatchByKey(key []byte, replicaID uint32) (string, uint16, error)
 	DispatchToVbucket(vbID uint16) (string, error)
+	VbucketsByServer(replicaIdx uint32) (map[string][]uint16, error)
 }
 
 type VbucketRoutingInfo struct {
@@ -103,10 +106,107 @@ func (vbd *vbucketRouter) DispatchToVbucket(vbID uint16) (string, error) {
 	return info.ServerList[idx], nil
 }
 
+// VbucketsByServer returns the list of vbuckets which each server is
+// responsible for at a particular replica index.  If any vbucket has no server
+// assigned at that index, such as during a failover, a noServerAssignedError
+// is returned rather than a list which silently omits it.
+func (vbd *vbucketRouter) VbucketsByServer(replicaIdx uint32) (map[string][]uint16, error) {
+	info, err := vbd.getRoutingInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	vbList, err := info.VbMap.VbucketsByServer(int(replicaIdx))
+	if err != nil {
+		return nil, err
+	}
+
+	vbsByServer := make(map[string][]uint16)
+	for serverIdx, vbIDs := range vbList {
+		if len(vbIDs) == 0 {
+			continue
+		}
+
+		if serverIdx >= len(info.ServerList) {
+			return nil, noServerAssignedError{
+				RequestedVbId: vbIDs[0],
+			}
+		}
+
+		vbsByServer[info.ServerList[serverIdx]] = vbIDs
+	}
+
+	return vbsByServer, nil
+}
+
 type NotMyVbucketConfigHandler interface {
 	HandleNotMyVbucketConfig(config *cbconfig.TerseConfigJson, sourceHostname string)
 }
 
+// applyNotMyVbucketConfig attempts to apply the configuration attached to a
+// not-my-vbucket error, returning whether a new configuration was applied.
+func applyNotMyVbucketConfig(ch NotMyVbucketConfigHandler, endpoint string, err error) bool {
+	if ch == nil {
+		// if we have no config handler, no point in trying to parse the config
+		return false
+	}
+
+	var nmvErr memdx.ServerErrorWithConfig
+	if !errors.As(err, &nmvErr) {
+		// if there is no new config available, we cant make any assumptions
+		// about the meaning of this error and propagate it upwards.
+		// log.Printf("received a not-my-vbucket without config information")
+		return false
+	}
+
+	// the config refers to the node which sent it as $HOST, which we replace
+	// with the address the request was dispatched to where we know it.
+	sourceHostname := endpoint
+	var serverErr memdx.ServerError
+	if errors.As(err, &serverErr) {
+		if hostname := hostnameFromAddress(serverErr.DispatchedTo); hostname != "" {
+			sourceHostname = hostname
+		}
+	}
+
+	configJson, parseErr := parseTerseConfigFromHost(nmvErr.ConfigJson, sourceHostname)
+	if parseErr != nil {
+		return false
+	}
+
+	ch.HandleNotMyVbucketConfig(configJson, sourceHostname)
+	return true
+}
+
+// hostnameFromAddress returns the host of a host:port address, keeping the
+// brackets around IPv6 addresses so that a port can be appended to it again.
+// An empty string is returned if the address cannot be parsed.
+func hostnameFromAddress(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return ""
+	}
+
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// parseTerseConfigFromHost parses a config sent by a node, which refers to
+// itself as $HOST, as it does not know the address it was reached through.
+func parseTerseConfigFromHost(configJson []byte, sourceHostname string) (*cbconfig.TerseConfigJson, error) {
+	configJsonBytes := bytes.ReplaceAll(configJson, []byte("$HOST"), []byte(sourceHostname))
+
+	var config *cbconfig.TerseConfigJson
+	err := json.Unmarshal(configJsonBytes, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
 func OrchestrateMemdRouting[RespT any](ctx context.Context, vb VbucketRouter, ch NotMyVbucketConfigHandler, key []byte, replicaIdx uint32,
 	fn func(endpoint string, vbID uint16) (RespT, error)) (RespT, error) {
 	endpoint, vbID, err := vb.DispatchByKey(key, replicaIdx)
@@ -120,58 +220,76 @@ func OrchestrateMemdRouting[RespT any](ctx context.Context, vb VbucketRouter, ch
 		res, err := fn(endpoint, vbID)
 		if err != nil {
 			if errors.Is(err, memdx.ErrNotMyVbucket) {
-				if ch == nil {
-					// if we have no config handler, no point in trying to parse the config
+				if !applyNotMyVbucketConfig(ch, endpoint, err) {
 					return res, &VbucketMapOutdatedError{
 						Cause: err,
 					}
 				}
 
-				var nmvErr memdx.ServerErrorWithConfig
-				if !errors.As(err, &nmvErr) {
-					// if there is no new config available, we cant make any assumptions
-					// about the meaning of this error and propagate it upwards.
-					// log.Printf("received a not-my-vbucket without config information")
+				newEndpoint, newVbID, err := vb.DispatchByKey(key, replicaIdx)
+				if err != nil {
+					var emptyResp RespT
+					return emptyResp, &VbucketMapOutdatedError{
+						Cause: err,
+					}
+				}
+
+				if newEndpoint == endpoint && newVbID == vbID {
+					// if after the update we are going to be sending the request back
+					// to the place that rejected it, we consider this non-deterministic
+					// and fall back to the application to deal with (or retries).
 					return res, &VbucketMapOutdatedError{
 						Cause: err,
 					}
 				}
 
-				// configs can contain $HOST, which needs to be replaced with the querying endpoint...
-				configJsonBytes := bytes.ReplaceAll(
-					nmvErr.ConfigJson,
-					[]byte("$HOST"),
-					[]byte(endpoint))
+				endpoint = newEndpoint
+				vbID = newVbID
+				continue
+			}
+
+			return res, err
+		}
 
-				var configJson *cbconfig.TerseConfigJson
-				unmarshalErr := json.Unmarshal(configJsonBytes, &configJson)
-				if unmarshalErr != nil {
+		return res, nil
+	}
+}
+
+func OrchestrateMemdVbucketRouting[RespT any](ctx context.Context, vb VbucketRouter, ch NotMyVbucketConfigHandler, vbID uint16,
+	fn func(endpoint string) (RespT, error)) (RespT, error) {
+	endpoint, err := vb.DispatchToVbucket(vbID)
+	if err != nil {
+		var emptyResp RespT
+		return emptyResp, err
+	}
+
+	for {
+		res, err := fn(endpoint)
+		if err != nil {
+			if errors.Is(err, memdx.ErrNotMyVbucket) {
+				if !applyNotMyVbucketConfig(ch, endpoint, err) {
 					return res, &VbucketMapOutdatedError{
 						Cause: err,
 					}
 				}
 
-				ch.HandleNotMyVbucketConfig(configJson, endpoint)
-
-				newEndpoint, newVbID, err := vb.DispatchByKey(key, replicaIdx)
-				if err != nil {
+				newEndpoint, dispatchErr := vb.DispatchToVbucket(vbID)
+				if dispatchErr != nil {
 					var emptyResp RespT
 					return emptyResp, &VbucketMapOutdatedError{
-						Cause: err,
+						Cause: dispatchErr,
 					}
 				}
 
-				if newEndpoint == endpoint && newVbID == vbID {
-					// if after the update we are going to be sending the request back
-					// to the place that rejected it, we consider this non-deterministic
-					// and fall back to the application to deal with (or retries).
+				if newEndpoint == endpoint {
+					// the updated configuration still points at the node which
+					// rejected the request, so we cannot make further progress.
 					return res, &VbucketMapOutdatedError{
 						Cause: err,
 					}
 				}
 
 				endpoint = newEndpoint
-				vbID = newVbID
 				continue
 			}
 
@@ -1,10 +1,14 @@
 package gocbcorex
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocbcorex/contrib/cbconfig"
+	"github.com/couchbase/gocbcorex/memdx"
 )
 
 func TestVbucketRouterDispatchToKey(t *testing.T) {
@@ -53,3 +57,113 @@ func TestVbucketRouterDispatchToKey(t *testing.T) {
 	assert.Equal(t, "endpoint2", endpoint)
 	assert.Equal(t, uint16(3), vbID)
 }
+
+func TestVbucketRouterVbucketsByServer(t *testing.T) {
+	dispatcher := NewVbucketRouter(nil)
+	dispatcher.UpdateRoutingInfo(&VbucketRoutingInfo{
+		VbMap: &VbucketMap{
+			entries: [][]int{
+				{0, 1},
+				{1, 0},
+				{1, 0},
+				{0, 1},
+			},
+			numReplicas: 1,
+		},
+		ServerList: []string{"endpoint1", "endpoint2"},
+	})
+
+	vbsByServer, err := dispatcher.VbucketsByServer(0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]uint16{
+		"endpoint1": {0, 3},
+		"endpoint2": {1, 2},
+	}, vbsByServer)
+
+	vbsByServer, err = dispatcher.VbucketsByServer(1)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]uint16{
+		"endpoint1": {1, 2},
+		"endpoint2": {0, 3},
+	}, vbsByServer)
+}
+
+func TestVbucketRouterVbucketsByServerUnassigned(t *testing.T) {
+	dispatcher := NewVbucketRouter(nil)
+	dispatcher.UpdateRoutingInfo(&VbucketRoutingInfo{
+		VbMap: &VbucketMap{
+			entries: [][]int{
+				{0, 1},
+				{-1, 0},
+				{0, -1},
+			},
+			numReplicas: 1,
+		},
+		ServerList: []string{"endpoint1", "endpoint2"},
+	})
+
+	_, err := dispatcher.VbucketsByServer(0)
+	assert.ErrorIs(t, err, ErrNoServerAssigned)
+	assert.Equal(t, noServerAssignedError{RequestedVbId: 1}, err)
+
+	_, err = dispatcher.VbucketsByServer(1)
+	assert.Equal(t, noServerAssignedError{RequestedVbId: 2}, err)
+}
+
+type testNotMyVbucketConfigHandler struct {
+	config         *cbconfig.TerseConfigJson
+	sourceHostname string
+}
+
+func (h *testNotMyVbucketConfigHandler) HandleNotMyVbucketConfig(config *cbconfig.TerseConfigJson, sourceHostname string) {
+	h.config = config
+	h.sourceHostname = sourceHostname
+}
+
+func TestApplyNotMyVbucketConfigHostname(t *testing.T) {
+	handler := &testNotMyVbucketConfigHandler{}
+
+	applied := applyNotMyVbucketConfig(handler, "endpoint1", memdx.ServerErrorWithConfig{
+		Cause: memdx.ServerError{
+			Cause:        memdx.ErrNotMyVbucket,
+			Status:       memdx.StatusNotMyVBucket,
+			DispatchedTo: "[fd00::1]:11210",
+		},
+		ConfigJson: []byte(`{"rev":7,"nodesExt":[{"hostname":"$HOST"}]}`),
+	})
+	require.True(t, applied)
+
+	// the brackets of an IPv6 host are kept, so that a port can be appended
+	require.Len(t, handler.config.NodesExt, 1)
+	assert.Equal(t, "[fd00::1]", handler.config.NodesExt[0].Hostname)
+	assert.Equal(t, "[fd00::1]", handler.sourceHostname)
+	assert.Equal(t, "[fd00::1]", parseConfigHostname(handler.config.NodesExt[0].Hostname, handler.sourceHostname))
+}
+
+func TestOrchestrateMemdVbucketRoutingKeepsNotMyVbucketCause(t *testing.T) {
+	handler := &testNotMyVbucketConfigHandler{}
+	vb := &VbucketRouterMock{
+		DispatchToVbucketFunc: func(vbID uint16) (string, error) {
+			return "endpoint1", nil
+		},
+	}
+
+	nmvErr := memdx.ServerErrorWithConfig{
+		Cause: memdx.ServerError{
+			Cause:        memdx.ErrNotMyVbucket,
+			Status:       memdx.StatusNotMyVBucket,
+			DispatchedTo: "10.0.0.1:11210",
+		},
+		ConfigJson: []byte(`{"rev":7,"nodesExt":[{"hostname":"$HOST"}]}`),
+	}
+
+	_, err := OrchestrateMemdVbucketRouting(context.Background(), vb, handler, 12,
+		func(endpoint string) (int, error) {
+			return 0, nmvErr
+		})
+	require.NotNil(t, handler.config)
+
+	var outdatedErr *VbucketMapOutdatedError
+	require.ErrorAs(t, err, &outdatedErr)
+	assert.ErrorIs(t, outdatedErr.Cause, memdx.ErrNotMyVbucket)
+}